@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -8,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
@@ -63,8 +66,57 @@ func main() {
 	log.Printf("  ClientSecret: %s", secretPreview)
 
 	authService := services.NewAuthService(db, cfg)
+	if gitlabService != nil {
+		// 仓库浏览和编辑使用用户登录时保存的GitLab令牌
+		gitlabService.SetUserClientProvider(authService.GitLabClientForUser)
+	}
 	projectService := services.NewProjectService(db, permissionService, gitlabService)
-	assignmentService := services.NewAssignmentService(db, permissionService, gitlabService, projectService)
+	notificationService := services.NewNotificationService(db, permissionService, gitlabService)
+	assignmentService := services.NewAssignmentService(db, permissionService, gitlabService, projectService, notificationService)
+	analyticsService := services.NewAnalyticsService(db)
+	achievementService := services.NewAchievementService(db, permissionService, gitlabService, notificationService)
+	learningProgressService := services.NewLearningProgressService(db, permissionService, gitlabService, achievementService)
+	gradebookService := services.NewGradebookService(db, permissionService)
+	educationReportService := services.NewEducationReportService(db, permissionService, cfg.Report.ExportDir, cfg.Report.ExportTTL)
+	autogradeService := services.NewAutogradeService(db, permissionService, gitlabService, cfg.Autograde)
+	emailService := services.NewEmailService(db, cfg.SMTP, cfg.Frontend.URL)
+	discussionService := services.NewDiscussionService(db, permissionService, gitlabService)
+	webhookService := services.NewWebhookService(db, permissionService)
+	oauthService := services.NewOAuthService(db)
+
+	// 成就服务依赖通知服务，需在创建后注入到产生成就事件的服务中
+	notificationService.SetAchievementService(achievementService)
+	assignmentService.SetAchievementService(achievementService)
+	notificationService.SetAssignmentService(assignmentService)
+	notificationService.SetProjectService(projectService)
+	notificationService.SetEmailService(emailService)
+	discussionService.SetAchievementService(achievementService)
+
+	// 向第三方订阅推送作业、提交、评审、课题成员和话题事件
+	assignmentService.SetWebhookService(webhookService)
+	projectService.SetWebhookService(webhookService)
+	discussionService.SetWebhookService(webhookService)
+
+	// 通知实时推送，多个实例通过Redis发布订阅转发事件
+	redisClient := initRedis(cfg)
+	authService.SetRedisClient(redisClient)
+	notificationHub := services.NewNotificationHub(redisClient)
+	notificationHub.Start()
+	notificationService.SetNotificationHub(notificationHub)
+	if err := achievementService.SeedDefaultAchievements(); err != nil {
+		log.Printf("Warning: Failed to seed default achievements: %v", err)
+	}
+
+	// 提交作业后自动运行测试
+	projectService.SetAutogradeService(autogradeService)
+	assignmentService.SetAutogradeService(autogradeService)
+	autogradeService.Start()
+
+	// 定时任务，多个实例中只有持有数据库锁的实例按计划运行
+	assignmentService.SetPipelineLookupTimeout(cfg.Pipeline.LookupTimeout)
+	schedulerService := services.NewSchedulerService(db, permissionService, cfg.Scheduler.PollInterval)
+	registerScheduledJobs(schedulerService, cfg, userService, projectService, assignmentService, notificationService, emailService, webhookService, oauthService, authService, educationReportService)
+	schedulerService.Start()
 
 	log.Printf("GitLab Service Status:")
 	if gitlabService != nil {
@@ -77,23 +129,37 @@ func main() {
 	userHandler := handlers.NewUserHandler(userService)
 	projectHandler := handlers.NewProjectHandler(projectService, permissionService)
 	assignmentHandler := handlers.NewAssignmentHandler(assignmentService, userService)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, userService)
+	learningProgressHandler := handlers.NewLearningProgressHandler(learningProgressService)
+	achievementHandler := handlers.NewAchievementHandler(achievementService)
+	educationReportHandler := handlers.NewEducationReportHandler(educationReportService)
+	gradebookHandler := handlers.NewGradebookHandler(gradebookService)
+	autogradeHandler := handlers.NewAutogradeHandler(autogradeService)
+	webhookHandler := handlers.NewWebhookHandler(notificationService, cfg.GitLab.WebhookSecret)
+	schedulerHandler := handlers.NewSchedulerHandler(schedulerService)
+	notificationPreferenceHandler := handlers.NewNotificationPreferenceHandler(notificationService)
+	notificationStreamHandler := handlers.NewNotificationStreamHandler(notificationService, notificationHub, authService)
+	discussionHandler := handlers.NewDiscussionHandler(discussionService, userService)
 
 	// 初始化OAuth中间件
-	oauthMiddleware := middleware.NewOAuthMiddleware(cfg, db, userService)
+	apiKeyService := services.NewAPIKeyService(db)
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit, redisClient)
+	oauthMiddleware := middleware.NewOAuthMiddleware(cfg, db, authService, userService, apiKeyService, oauthService, rateLimiter)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, permissionService, cfg.Frontend.URL)
 
-	// 创建一个简化的notification handler（临时方案）
-	notificationHandler := &handlers.NotificationHandler{}
+	notificationHandler := handlers.NewNotificationHandler(notificationService, userService)
 
 	// 初始化重构后的第三方API Handler
 	thirdPartyHandler := handlers.NewThirdPartyAPIHandler(
 		userHandler, projectHandler, assignmentHandler, notificationHandler,
-		oauthMiddleware, gitlabService)
+		oauthMiddleware, gitlabService, webhookService, apiKeyService,
+		permissionService, projectService, userService, analyticsService)
 
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
 	// 初始化路由 - 简化版本
-	router := setupSimpleRoutes(authService, permissionService, userHandler, projectHandler, assignmentHandler, thirdPartyHandler)
+	router := setupSimpleRoutes(authService, permissionService, userHandler, projectHandler, assignmentHandler, analyticsHandler, learningProgressHandler, achievementHandler, educationReportHandler, gradebookHandler, autogradeHandler, webhookHandler, schedulerHandler, notificationPreferenceHandler, notificationStreamHandler, discussionHandler, thirdPartyHandler, oauthHandler, rateLimiter)
 
 	// 启动服务器
 	addr := cfg.GetServerAddr()
@@ -140,11 +206,69 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// initRedis 连接Redis，连接失败时返回nil，通知推送和限流只在本实例内进行
+func initRedis(cfg *config.Config) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Printf("Warning: Failed to connect to Redis at %s, notification push and rate limits are limited to this instance: %v", cfg.GetRedisAddr(), err)
+		client.Close()
+		return nil
+	}
+
+	log.Printf("Redis connected: %s", cfg.GetRedisAddr())
+	return client
+}
+
+// registerScheduledJobs 注册定时任务及默认执行计划，执行计划可由管理员修改
+func registerScheduledJobs(scheduler *services.SchedulerService, cfg *config.Config, userService *services.UserService,
+	projectService *services.ProjectService, assignmentService *services.AssignmentService, notificationService *services.NotificationService,
+	emailService *services.EmailService, webhookService *services.WebhookService, oauthService *services.OAuthService,
+	authService *services.AuthService, educationReportService *services.EducationReportService) {
+	scheduler.RegisterJob("assignment_due_reminders", "按提醒时间点提醒未提交作业的学生，并向老师汇总", "*/15 * * * *",
+		notificationService.ScheduleAssignmentDueNotifications)
+	scheduler.RegisterJob("notification_cleanup", "清理超过保留天数的通知", "30 3 * * *", func() error {
+		return notificationService.CleanupOldNotifications(cfg.Scheduler.NotificationRetentionDays)
+	})
+	scheduler.RegisterJob("gitlab_sync", "从GitLab同步用户信息和课题成员", "0 2 * * *", func() error {
+		userErr := userService.SyncUsersFromGitLab()
+		memberErr := projectService.SyncProjectMembersFromGitLab()
+		if userErr != nil {
+			return userErr
+		}
+		return memberErr
+	})
+	scheduler.RegisterJob("stats_refresh", "重新统计课题作业数和成员提交", "@hourly", func() error {
+		commitErr := projectService.ReconcileCommitStats()
+		return errors.Join(projectService.RefreshProjectStats(), commitErr)
+	})
+	scheduler.RegisterJob("notification_digests", "为到达发送时间的用户生成通知摘要", "0 * * * *",
+		notificationService.SendNotificationDigests)
+	scheduler.RegisterJob("email_delivery", "发送邮件队列中的通知邮件，失败时重试", "* * * * *", emailService.ProcessOutbox)
+	scheduler.RegisterJob("webhook_delivery", "重试投递失败的第三方Webhook", "* * * * *", webhookService.ProcessDeliveries)
+	scheduler.RegisterJob("session_cleanup", "清理过期或已撤销的登录会话", "45 4 * * *", authService.CleanupSessions)
+	scheduler.RegisterJob("report_exports", "重新执行遗留的报表导出任务，标记中断的任务失败，清理过期文件", "*/5 * * * *",
+		educationReportService.ProcessStaleExports)
+	scheduler.RegisterJob("oauth_cleanup", "清理过期或已撤销的OAuth2授权码和令牌", "15 4 * * *", oauthService.CleanupExpired)
+	scheduler.RegisterJob("pipeline_tracking", "同步未结束的CI流水线，补充流水线Webhook", "* * * * *", func() error {
+		assignmentService.SyncPendingPipelines()
+		return nil
+	})
+}
+
 // autoMigrate 自动迁移数据库表
 func autoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		// 用户管理相关
 		&models.User{},
+		&models.UserSession{},
+		&models.GitLabCredential{},
 
 		// 课题管理相关
 		&models.Project{},
@@ -153,10 +277,45 @@ func autoMigrate(db *gorm.DB) error {
 		// 作业管理相关
 		&models.Assignment{},
 		&models.AssignmentSubmission{},
+		&models.AssignmentExtension{},
 		&models.Review{},
+		&models.Rubric{},
+		&models.RubricCriterion{},
+		&models.ReviewCriterionScore{},
+
+		// 成就系统相关
+		&models.Achievement{},
+		&models.UserAchievement{},
 
 		// 通知系统相关
 		&models.Notification{},
+		&models.AssignmentReminder{},
+		&models.NotificationPreference{},
+		&models.EmailOutbox{},
+		&models.NotificationDigestSetting{},
+		&models.NotificationDigest{},
+
+		// 报表导出相关
+		&models.ReportExport{},
+
+		// 自动评测相关
+		&models.AutogradeSuite{},
+		&models.AutogradeRun{},
+
+		// 定时任务相关
+		&models.ScheduledJob{},
+		&models.JobRun{},
+
+		// 第三方Webhook相关
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.APIKey{},
+
+		// OAuth2授权相关
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthToken{},
+		&models.OAuthConsent{},
 
 		// 文档管理相关
 		&models.Document{},
@@ -296,7 +455,13 @@ func setupRoutes(authService *services.AuthService, permissionService *services.
 // setupSimpleRoutes 简化路由设置
 func setupSimpleRoutes(authService *services.AuthService, permissionService *services.PermissionService,
 	userHandler *handlers.UserHandler, projectHandler *handlers.ProjectHandler,
-	assignmentHandler *handlers.AssignmentHandler, thirdPartyHandler *handlers.ThirdPartyAPIHandler) *gin.Engine {
+	assignmentHandler *handlers.AssignmentHandler, analyticsHandler *handlers.AnalyticsHandler,
+	learningProgressHandler *handlers.LearningProgressHandler, achievementHandler *handlers.AchievementHandler,
+	educationReportHandler *handlers.EducationReportHandler, gradebookHandler *handlers.GradebookHandler,
+	autogradeHandler *handlers.AutogradeHandler, webhookHandler *handlers.WebhookHandler,
+	schedulerHandler *handlers.SchedulerHandler, notificationPreferenceHandler *handlers.NotificationPreferenceHandler,
+	notificationStreamHandler *handlers.NotificationStreamHandler, discussionHandler *handlers.DiscussionHandler,
+	thirdPartyHandler *handlers.ThirdPartyAPIHandler, oauthHandler *handlers.OAuthHandler, rateLimiter *middleware.RateLimiter) *gin.Engine {
 	router := gin.New()
 
 	// 中间件
@@ -331,8 +496,8 @@ func setupSimpleRoutes(authService *services.AuthService, permissionService *ser
 			})
 		})
 
-		// 认证相关路由
-		auth := api.Group("/auth")
+		// 认证相关路由，公开接口按IP限流
+		auth := api.Group("/auth", rateLimiter.LimitIP(""))
 		{
 			auth.GET("/gitlab", func(c *gin.Context) {
 				// 生成随机state以防止CSRF攻击
@@ -346,9 +511,22 @@ func setupSimpleRoutes(authService *services.AuthService, permissionService *ser
 			})
 			auth.GET("/gitlab/callback", authService.HandleGitLabCallback)
 			auth.POST("/gitlab/callback", authService.HandleGitLabCallback)
+			auth.POST("/login/exchange", authService.HandleLoginExchange) // 使用登录回调中的一次性登录码换取令牌
+			auth.POST("/refresh", authService.HandleRefresh)              // 使用刷新令牌换取新的访问令牌
 			auth.POST("/logout", authService.Logout)
+
+			// 登录会话管理
+			sessions := auth.Group("/sessions", authService.AuthMiddleware())
+			{
+				sessions.GET("", authService.HandleListSessions)         // 我的登录会话
+				sessions.DELETE("/:id", authService.HandleRevokeSession) // 撤销一个会话
+				sessions.DELETE("", authService.HandleRevokeAllSessions) // 撤销其他所有会话
+			}
 		}
 
+		// GitLab Webhook（密钥认证）
+		webhookHandler.RegisterRoutes(api)
+
 		// 用户管理路由
 		users := api.Group("/users")
 		users.Use(authService.AuthMiddleware())    // JWT认证中间件
@@ -367,9 +545,63 @@ func setupSimpleRoutes(authService *services.AuthService, permissionService *ser
 		projectsAuth.Use(authService.AuthMiddleware())
 		projectsAuth.Use(permissionService.RequireAuth())
 		projectHandler.RegisterRoutes(projectsAuth, permissionService)
+		gradebookHandler.RegisterRoutes(projectsAuth)
 
-		// 作业管理路由
-		assignmentHandler.RegisterRoutes(api)
+		// 分析统计路由（需要认证）
+		analytics := api.Group("/analytics")
+		analytics.Use(authService.AuthMiddleware())
+		analytics.Use(permissionService.RequireAuth())
+		{
+			analytics.GET("/overview", analyticsHandler.GetAnalyticsOverview)
+			analytics.GET("/project-stats", analyticsHandler.GetProjectStats)
+			analytics.GET("/student-stats", analyticsHandler.GetStudentStats)
+			analytics.GET("/assignment-stats", analyticsHandler.GetAssignmentStats)
+			analytics.GET("/submission-trend", analyticsHandler.GetSubmissionTrend)
+			analytics.GET("/project-distribution", analyticsHandler.GetProjectDistribution)
+			analytics.GET("/grade-distribution", analyticsHandler.GetGradeDistribution)
+			analytics.GET("/activity-stats", analyticsHandler.GetActivityStats)
+			analytics.GET("/dashboard-stats", analyticsHandler.GetDashboardStats)
+			analytics.GET("/recent-activities", analyticsHandler.GetRecentActivities)
+		}
+
+		// 作业管理路由（需要认证）
+		assignmentsAuth := api.Group("")
+		assignmentsAuth.Use(authService.AuthMiddleware())
+		assignmentsAuth.Use(permissionService.RequireAuth())
+		assignmentHandler.RegisterRoutes(assignmentsAuth)
+		autogradeHandler.RegisterRoutes(assignmentsAuth)
+
+		// 学习进度路由（需要认证）
+		learningProgressAuth := api.Group("")
+		learningProgressAuth.Use(authService.AuthMiddleware())
+		learningProgressAuth.Use(permissionService.RequireAuth())
+		learningProgressHandler.RegisterRoutes(learningProgressAuth)
+		achievementHandler.RegisterRoutes(learningProgressAuth)
+		educationReportHandler.RegisterRoutes(learningProgressAuth)
+
+		// 定时任务管理路由（需要认证，仅管理员）
+		schedulerAuth := api.Group("")
+		schedulerAuth.Use(authService.AuthMiddleware())
+		schedulerAuth.Use(permissionService.RequireAuth())
+		schedulerHandler.RegisterRoutes(schedulerAuth)
+
+		// 通知偏好路由（需要认证）
+		notificationPreferenceAuth := api.Group("")
+		notificationPreferenceAuth.Use(authService.AuthMiddleware())
+		notificationPreferenceAuth.Use(permissionService.RequireAuth())
+		notificationPreferenceHandler.RegisterRoutes(notificationPreferenceAuth)
+
+		// 话题讨论路由（需要认证）
+		discussionsAuth := api.Group("")
+		discussionsAuth.Use(authService.AuthMiddleware())
+		discussionsAuth.Use(permissionService.RequireAuth())
+		discussionHandler.RegisterRoutes(discussionsAuth)
+
+		// 通知实时推送（EventSource 使用一次性连接票据认证）
+		notificationStreamHandler.RegisterRoutes(api, permissionService)
+
+		// OAuth2授权服务，第三方应用代表用户调用第三方API
+		oauthHandler.RegisterRoutes(api, authService, rateLimiter)
 
 		// 第三方API路由
 		thirdPartyHandler.RegisterRoutes(api)
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +18,12 @@ type Config struct {
 	GitLab     GitLabConfig
 	JWT        JWTConfig
 	Frontend   FrontendConfig
+	Report     ReportConfig
+	Autograde  AutogradeConfig
+	Pipeline   PipelineConfig
+	Scheduler  SchedulerConfig
+	SMTP       SMTPConfig
+	RateLimit  RateLimitConfig
 }
 
 // ServerConfig 服务器配置
@@ -51,6 +59,11 @@ type GitLabConfig struct {
 	RedirectURI  string
 	Token        string
 	Scopes       string // OAuth权限范围
+
+	WebhookURL    string // GitLab回调本系统的Webhook地址，创建仓库时自动注册
+	WebhookSecret string // Webhook密钥，GitLab通过 X-Gitlab-Token 请求头发送
+
+	TokenEncryptionKey string // 加密保存用户GitLab令牌的密钥，为空时由JWT密钥派生
 }
 
 // OnlyOfficeConfig OnlyOffice配置
@@ -62,7 +75,9 @@ type OnlyOfficeConfig struct {
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret string
+	Secret          string
+	AccessTokenTTL  time.Duration // 访问令牌有效期，过期后使用刷新令牌换取
+	RefreshTokenTTL time.Duration // 刷新令牌（登录会话）有效期，每次刷新后重新计算
 }
 
 // FrontendConfig 前端配置
@@ -70,6 +85,60 @@ type FrontendConfig struct {
 	URL string // 前端应用URL
 }
 
+// ReportConfig 报表导出配置
+type ReportConfig struct {
+	ExportDir string        // 导出文件存储目录
+	ExportTTL time.Duration // 导出文件保留时长，过期后不可下载
+}
+
+// AutogradeConfig 自动评测配置
+type AutogradeConfig struct {
+	WorkDir          string        // 检出学生代码的工作目录
+	Workers          int           // 同时运行的评测数
+	DockerBinary     string        // docker 命令路径
+	AllowLocalRunner bool          // 是否允许在本机进程中运行测试（仅用于受信任的环境）
+	MaxTimeout       time.Duration // 单个测试允许配置的最长超时时间
+	OutputLimit      int           // 每个测试保留的标准输出/错误输出字节数
+	MaxSourceSize    int64         // 检出代码（归档和解压后文件）允许的最大字节数
+}
+
+// PipelineConfig CI流水线跟踪配置
+type PipelineConfig struct {
+	LookupTimeout time.Duration // 提交后等待GitLab创建流水线的最长时间
+}
+
+// SchedulerConfig 定时任务调度配置
+type SchedulerConfig struct {
+	PollInterval              time.Duration // 检查到期任务的间隔
+	NotificationRetentionDays int           // 通知保留天数，由清理任务删除更早的通知
+}
+
+// SMTPConfig 邮件发送配置，Host为空时不发送邮件
+type SMTPConfig struct {
+	Host        string
+	Port        int
+	Username    string // 为空时不进行SMTP认证（如本地MailHog）
+	Password    string
+	From        string
+	ImplicitTLS bool // 使用SMTPS（通常为465端口），否则在服务器支持时使用STARTTLS
+	MaxAttempts int  // 发送失败后的最大尝试次数
+}
+
+// RateLimitConfig 第三方API和公开接口的限流配置，每个路由组使用独立的令牌桶
+type RateLimitConfig struct {
+	Enabled bool
+	Backend string        // memory 为本实例内存，redis 为多实例共享（使用Redis配置）
+	APIKey  RateLimitRule // 每个API Key
+	User    RateLimitRule // 每个JWT用户
+	IP      RateLimitRule // 每个IP，用于公开接口和认证失败的请求
+}
+
+// RateLimitRule 令牌桶限流规则，PerMinute 不大于0时不限流
+type RateLimitRule struct {
+	PerMinute int // 每分钟补充的令牌数
+	Burst     int // 令牌桶容量，即允许的突发请求数，不大于0时等于 PerMinute
+}
+
 func LoadConfig() (*Config, error) {
 	// 1. 加载应用基础配置
 	configPaths := []string{
@@ -123,7 +192,7 @@ func LoadConfig() (*Config, error) {
 			Host:     getEnv("REDIS_HOST", "localhost"),
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", "password123"),
-			DB:       0,
+			DB:       getEnvInt("REDIS_DB", 0),
 		},
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8080"),
@@ -142,13 +211,65 @@ func LoadConfig() (*Config, error) {
 			RedirectURI:  getEnv("GITLAB_REDIRECT_URI", "http://localhost:8080/api/auth/gitlab/callback"),
 			Token:        getEnv("GITLAB_TOKEN", ""),
 			Scopes:       getEnv("GITLAB_SCOPES", "api read_user email"),
+
+			WebhookURL:    getEnv("GITLAB_WEBHOOK_URL", "http://localhost:8080/api/webhooks/gitlab"),
+			WebhookSecret: getEnv("GITLAB_WEBHOOK_SECRET", ""),
+
+			TokenEncryptionKey: getEnv("GITLAB_TOKEN_ENCRYPTION_KEY", ""),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-jwt-secret-key"),
+			Secret:          getEnv("JWT_SECRET", "your-jwt-secret-key"),
+			AccessTokenTTL:  getEnvDuration("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getEnvDuration("JWT_REFRESH_TOKEN_TTL", 30*24*time.Hour),
 		},
 		Frontend: FrontendConfig{
 			URL: getEnv("FRONTEND_URL", "http://localhost:3000"),
 		},
+		Report: ReportConfig{
+			ExportDir: getEnv("REPORT_EXPORT_DIR", "uploads/exports"),
+			ExportTTL: getEnvDuration("REPORT_EXPORT_TTL", 24*time.Hour),
+		},
+		Autograde: AutogradeConfig{
+			WorkDir:          getEnv("AUTOGRADE_WORK_DIR", "uploads/autograde"),
+			Workers:          getEnvInt("AUTOGRADE_WORKERS", 2),
+			DockerBinary:     getEnv("AUTOGRADE_DOCKER_BINARY", "docker"),
+			AllowLocalRunner: getEnv("AUTOGRADE_ALLOW_LOCAL_RUNNER", "false") == "true",
+			MaxTimeout:       getEnvDuration("AUTOGRADE_MAX_TIMEOUT", 10*time.Minute),
+			OutputLimit:      getEnvInt("AUTOGRADE_OUTPUT_LIMIT", 65536),
+			MaxSourceSize:    int64(getEnvInt("AUTOGRADE_MAX_SOURCE_SIZE", 200*1024*1024)),
+		},
+		Pipeline: PipelineConfig{
+			LookupTimeout: getEnvDuration("PIPELINE_LOOKUP_TIMEOUT", 30*time.Minute),
+		},
+		Scheduler: SchedulerConfig{
+			PollInterval:              getEnvDuration("SCHEDULER_POLL_INTERVAL", 30*time.Second),
+			NotificationRetentionDays: getEnvInt("NOTIFICATION_RETENTION_DAYS", 90),
+		},
+		SMTP: SMTPConfig{
+			Host:        getEnv("SMTP_HOST", ""),
+			Port:        getEnvInt("SMTP_PORT", 587),
+			Username:    getEnv("SMTP_USER", ""),
+			Password:    getEnv("SMTP_PASSWORD", ""),
+			From:        getEnv("SMTP_FROM", "noreply@gitlabex.com"),
+			ImplicitTLS: getEnv("SMTP_IMPLICIT_TLS", "false") == "true",
+			MaxAttempts: getEnvInt("SMTP_MAX_ATTEMPTS", 6),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: getEnv("RATE_LIMIT_ENABLED", "true") == "true",
+			Backend: getEnv("RATE_LIMIT_BACKEND", "memory"),
+			APIKey: RateLimitRule{
+				PerMinute: getEnvInt("RATE_LIMIT_API_KEY_PER_MINUTE", 600),
+				Burst:     getEnvInt("RATE_LIMIT_API_KEY_BURST", 100),
+			},
+			User: RateLimitRule{
+				PerMinute: getEnvInt("RATE_LIMIT_USER_PER_MINUTE", 300),
+				Burst:     getEnvInt("RATE_LIMIT_USER_BURST", 60),
+			},
+			IP: RateLimitRule{
+				PerMinute: getEnvInt("RATE_LIMIT_IP_PER_MINUTE", 60),
+				Burst:     getEnvInt("RATE_LIMIT_IP_BURST", 20),
+			},
+		},
 	}
 
 	// 4. 验证必要的配置
@@ -166,6 +287,26 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Warning: Invalid duration for %s: %s, using default %s\n", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+		fmt.Printf("Warning: Invalid integer for %s: %s, using default %d\n", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 func (c *Config) GetDatabaseDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=Asia/Shanghai",
 		c.Database.Host,
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AchievementHandler 成就徽章处理器
+type AchievementHandler struct {
+	achievementService *services.AchievementService
+}
+
+// NewAchievementHandler 创建成就徽章处理器
+func NewAchievementHandler(achievementService *services.AchievementService) *AchievementHandler {
+	return &AchievementHandler{
+		achievementService: achievementService,
+	}
+}
+
+// RegisterRoutes 注册成就路由
+func (h *AchievementHandler) RegisterRoutes(router *gin.RouterGroup) {
+	achievements := router.Group("/achievements")
+	{
+		achievements.GET("", h.ListAchievements)        // 获取成就定义列表
+		achievements.GET("/my", h.GetMyAchievements)    // 获取我的成就
+		achievements.POST("", h.CreateAchievement)      // 创建成就（管理员）
+		achievements.PUT("/:id", h.UpdateAchievement)   // 更新成就（管理员）
+		achievements.POST("/my/evaluate", h.EvaluateMy) // 重新评估我的成就
+	}
+}
+
+// ListAchievements 获取成就定义列表
+func (h *AchievementHandler) ListAchievements(c *gin.Context) {
+	achievements, err := h.achievementService.ListAchievements()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取成就列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": achievements,
+	})
+}
+
+// GetMyAchievements 获取当前用户的成就获得情况
+func (h *AchievementHandler) GetMyAchievements(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	achievements, err := h.achievementService.GetUserAchievements(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取成就失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": achievements,
+	})
+}
+
+// EvaluateMy 重新评估当前用户的所有成就（用于补发历史数据对应的成就）
+func (h *AchievementHandler) EvaluateMy(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	awarded, err := h.achievementService.Evaluate(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "评估成就失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "成就评估完成",
+		"data":    awarded,
+	})
+}
+
+// CreateAchievement 创建成就定义
+func (h *AchievementHandler) CreateAchievement(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	var req services.AchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	achievement, err := h.achievementService.CreateAchievement(currentUser.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建成就失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "成就创建成功",
+		"data":    achievement,
+	})
+}
+
+// UpdateAchievement 更新成就定义
+func (h *AchievementHandler) UpdateAchievement(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	achievementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的成就ID",
+		})
+		return
+	}
+
+	var req services.AchievementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	achievement, err := h.achievementService.UpdateAchievement(currentUser.ID, uint(achievementID), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新成就失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "成就更新成功",
+		"data":    achievement,
+	})
+}
@@ -23,7 +23,13 @@ type ThirdPartyAPIHandler struct {
 	oauthMiddleware *middleware.OAuthMiddleware
 
 	// 服务
-	gitlabService *services.GitLabService
+	gitlabService     *services.GitLabService
+	webhookService    *services.WebhookService
+	apiKeyService     *services.APIKeyService
+	permissionService *services.PermissionService
+	projectService    *services.ProjectService
+	userService       *services.UserService
+	analyticsService  *services.AnalyticsService
 }
 
 // NewThirdPartyAPIHandler 创建第三方API处理器
@@ -34,6 +40,12 @@ func NewThirdPartyAPIHandler(
 	notificationHandler *NotificationHandler,
 	oauthMiddleware *middleware.OAuthMiddleware,
 	gitlabService *services.GitLabService,
+	webhookService *services.WebhookService,
+	apiKeyService *services.APIKeyService,
+	permissionService *services.PermissionService,
+	projectService *services.ProjectService,
+	userService *services.UserService,
+	analyticsService *services.AnalyticsService,
 ) *ThirdPartyAPIHandler {
 	return &ThirdPartyAPIHandler{
 		userHandler:         userHandler,
@@ -42,6 +54,12 @@ func NewThirdPartyAPIHandler(
 		notificationHandler: notificationHandler,
 		oauthMiddleware:     oauthMiddleware,
 		gitlabService:       gitlabService,
+		webhookService:      webhookService,
+		apiKeyService:       apiKeyService,
+		permissionService:   permissionService,
+		projectService:      projectService,
+		userService:         userService,
+		analyticsService:    analyticsService,
 	}
 }
 
@@ -56,66 +74,74 @@ func (h *ThirdPartyAPIHandler) RegisterRoutes(router *gin.RouterGroup) {
 		api.Use(h.oauthMiddleware.CORS())
 		api.Use(h.oauthMiddleware.RateLimit())
 
-		// API Key管理
+		// API Key管理，使用API Key调用时需要相应的权限范围（JWT Token不受限制）
 		auth := api.Group("/auth")
 		{
-			auth.POST("/api-key", h.GenerateAPIKey) // 生成API Key
-			auth.DELETE("/api-key", h.RevokeAPIKey) // 撤销API Key
-			auth.GET("/validate", h.ValidateToken)  // 验证Token
+			auth.GET("/validate", h.ValidateToken)         // 验证Token
+			auth.DELETE("/api-key", h.RevokeCurrentAPIKey) // 撤销当前使用的API Key
+			h.registerAPIKeyRoutes(auth)
 		}
 
+		requireScope := h.oauthMiddleware.RequireScope
+
 		// Git仓库管理API - 基于现有项目API + GitLab扩展
-		repos := api.Group("/repos")
+		repos := api.Group("/repos", requireScope(models.APIScopeReposRead))
 		{
-			repos.POST("", h.CreateRepository)           // 创建Git仓库
-			repos.GET("", h.proxyToProjectList)          // 代理到项目列表
-			repos.GET("/:id", h.proxyToProjectDetail)    // 代理到项目详情
-			repos.PUT("/:id", h.proxyToProjectUpdate)    // 代理到项目更新
-			repos.DELETE("/:id", h.proxyToProjectDelete) // 代理到项目删除
+			reposWrite := requireScope(models.APIScopeReposWrite)
+			repos.POST("", reposWrite, h.CreateRepository)           // 创建Git仓库
+			repos.GET("", h.proxyToProjectList)                      // 代理到项目列表
+			repos.GET("/:id", h.proxyToProjectDetail)                // 代理到项目详情
+			repos.PUT("/:id", reposWrite, h.proxyToProjectUpdate)    // 代理到项目更新
+			repos.DELETE("/:id", reposWrite, h.proxyToProjectDelete) // 代理到项目删除
 
 			// GitLab特有功能
-			repos.POST("/:id/clone", h.GetCloneInfo)               // 获取克隆信息
-			repos.GET("/:id/commits", h.GetRepositoryCommits)      // 获取提交记录
-			repos.GET("/:id/branches", h.GetRepositoryBranches)    // 获取分支列表
-			repos.POST("/:id/branches", h.CreateBranch)            // 创建分支
-			repos.GET("/:id/files", h.GetRepositoryFiles)          // 获取文件列表
-			repos.GET("/:id/files/*filepath", h.GetFileContent)    // 获取文件内容
-			repos.PUT("/:id/files/*filepath", h.UpdateFileContent) // 更新文件内容
+			repos.POST("/:id/clone", h.GetCloneInfo)                           // 获取克隆信息
+			repos.GET("/:id/commits", h.GetRepositoryCommits)                  // 获取提交记录
+			repos.GET("/:id/branches", h.GetRepositoryBranches)                // 获取分支列表
+			repos.POST("/:id/branches", reposWrite, h.CreateBranch)            // 创建分支
+			repos.GET("/:id/files", h.GetRepositoryFiles)                      // 获取文件列表
+			repos.GET("/:id/files/*filepath", h.GetFileContent)                // 获取文件内容
+			repos.PUT("/:id/files/*filepath", reposWrite, h.UpdateFileContent) // 更新文件内容
 		}
 
 		// 用户管理API - 代理到现有用户API + 扩展
-		users := api.Group("/users")
+		users := api.Group("/users", requireScope(models.APIScopeUsersRead))
 		{
-			users.GET("", h.proxyToUserList)           // 代理到用户列表
-			users.GET("/:id", h.proxyToUserDetail)     // 代理到用户详情
-			users.PUT("/:id", h.proxyToUserUpdate)     // 代理到用户更新
-			users.POST("/:id/sync", h.proxyToUserSync) // 代理到用户同步
+			usersWrite := requireScope(models.APIScopeUsersWrite)
+			users.GET("", h.proxyToUserList)                       // 代理到用户列表
+			users.GET("/:id", h.proxyToUserDetail)                 // 代理到用户详情
+			users.PUT("/:id", usersWrite, h.proxyToUserUpdate)     // 代理到用户更新
+			users.POST("/:id/sync", usersWrite, h.proxyToUserSync) // 代理到用户同步
 
 			// 第三方特有功能
-			users.POST("", h.CreateUserForThirdParty)           // 第三方创建用户
-			users.PUT("/:id/role", h.UpdateUserRole)            // 更新用户角色
-			users.GET("/:id/permissions", h.GetUserPermissions) // 获取用户权限
+			users.POST("", usersWrite, h.CreateUserForThirdParty) // 第三方创建用户
+			users.PUT("/:id/role", usersWrite, h.UpdateUserRole)  // 更新用户角色
+			users.GET("/:id/permissions", h.GetUserPermissions)   // 获取用户权限
 		}
 
 		// 权限管理API
-		permissions := api.Group("/permissions")
+		permissions := api.Group("/permissions", requireScope(models.APIScopePermissionsRead))
 		{
 			permissions.GET("/roles", h.GetAllRoles)      // 获取所有角色
 			permissions.POST("/check", h.CheckPermission) // 检查权限
 		}
 
 		// 作业管理API - 代理到现有作业API
-		assignments := api.Group("/assignments")
+		assignments := api.Group("/assignments", requireScope(models.APIScopeAssignmentsRead))
 		{
-			assignments.POST("", h.proxyToAssignmentCreate)       // 代理到作业创建
-			assignments.GET("", h.proxyToAssignmentList)          // 代理到作业列表
-			assignments.GET("/:id", h.proxyToAssignmentDetail)    // 代理到作业详情
-			assignments.PUT("/:id", h.proxyToAssignmentUpdate)    // 代理到作业更新
-			assignments.DELETE("/:id", h.proxyToAssignmentDelete) // 代理到作业删除
+			assignmentsWrite := requireScope(models.APIScopeAssignmentsWrite)
+			assignments.POST("", assignmentsWrite, h.proxyToAssignmentCreate)       // 代理到作业创建
+			assignments.GET("", h.proxyToAssignmentList)                            // 代理到作业列表
+			assignments.GET("/:id", h.proxyToAssignmentDetail)                      // 代理到作业详情
+			assignments.PUT("/:id", assignmentsWrite, h.proxyToAssignmentUpdate)    // 代理到作业更新
+			assignments.DELETE("/:id", assignmentsWrite, h.proxyToAssignmentDelete) // 代理到作业删除
 		}
 
+		// Webhook订阅
+		h.registerWebhookRoutes(api)
+
 		// 系统状态API
-		status := api.Group("/status")
+		status := api.Group("/status", requireScope(models.APIScopeStatusRead))
 		{
 			status.GET("/health", h.GetSystemStatus) // 系统健康状态
 			status.GET("/stats", h.GetSystemStats)   // 系统统计信息
@@ -125,30 +151,6 @@ func (h *ThirdPartyAPIHandler) RegisterRoutes(router *gin.RouterGroup) {
 
 // ===== 认证管理 =====
 
-// GenerateAPIKey 生成API Key
-func (h *ThirdPartyAPIHandler) GenerateAPIKey(c *gin.Context) {
-	user, exists := c.Get("current_user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Authentication required",
-		})
-		return
-	}
-
-	currentUser := user.(*models.User)
-	apiKey := h.oauthMiddleware.GenerateAPIKey(currentUser.ID)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "API Key generated successfully",
-		"data": gin.H{
-			"api_key":    apiKey,
-			"user_id":    currentUser.ID,
-			"expires_in": "7 days",
-			"scopes":     []string{"read", "write", "manage"},
-		},
-	})
-}
-
 // ValidateToken 验证Token
 func (h *ThirdPartyAPIHandler) ValidateToken(c *gin.Context) {
 	user, exists := c.Get("current_user")
@@ -162,14 +164,28 @@ func (h *ThirdPartyAPIHandler) ValidateToken(c *gin.Context) {
 	currentUser := user.(*models.User)
 	authType, _ := c.Get("auth_type")
 
+	data := gin.H{
+		"user_id":   currentUser.ID,
+		"username":  currentUser.Username,
+		"role":      currentUser.Role,
+		"auth_type": authType,
+	}
+	if key := currentAPIKey(c); key != nil {
+		data["api_key_id"] = key.ID
+		data["scopes"] = key.Scopes
+		data["expires_at"] = key.ExpiresAt
+	}
+	if value, exists := c.Get("oauth_token"); exists {
+		if token, ok := value.(*models.OAuthToken); ok {
+			data["oauth_client_id"] = token.ClientID
+			data["scopes"] = token.Scopes
+			data["expires_at"] = token.AccessExpiresAt
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"valid": true,
-		"data": gin.H{
-			"user_id":   currentUser.ID,
-			"username":  currentUser.Username,
-			"role":      currentUser.Role,
-			"auth_type": authType,
-		},
+		"data":  data,
 	})
 }
 
@@ -380,6 +396,153 @@ func (h *ThirdPartyAPIHandler) CheckPermission(c *gin.Context) {
 	})
 }
 
+// UpdateUserRole 更新用户的系统角色，只有管理员可以操作
+func (h *ThirdPartyAPIHandler) UpdateUserRole(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	if !h.permissionService.IsAdmin(currentUser.ID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Admin permission required",
+		})
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+
+	var req struct {
+		Role int `json:"role" binding:"required,min=1,max=4"` // 1:管理员, 2:老师, 3:学生, 4:访客
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if uint(userID) == currentUser.ID && req.Role != currentUser.Role {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot change your own role",
+		})
+		return
+	}
+
+	if _, err := h.userService.GetUserByID(uint(userID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "User not found",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := h.userService.UpdateUserRole(uint(userID), req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update user role",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userService.GetUserByID(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get user",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User role updated successfully",
+		"data":    user,
+	})
+}
+
+// GetUserPermissions 获取用户的系统角色以及在所参与课题中的权限，只能查询自己，管理员可以查询所有用户
+func (h *ThirdPartyAPIHandler) GetUserPermissions(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid user ID",
+		})
+		return
+	}
+	if uint(userID) != currentUser.ID && !h.permissionService.IsAdmin(currentUser.ID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Permission denied",
+		})
+		return
+	}
+
+	user, err := h.userService.GetUserByID(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "User not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	teaching, err := h.projectService.GetProjectsByTeacher(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get user projects",
+			"details": err.Error(),
+		})
+		return
+	}
+	joined, err := h.projectService.GetProjectsByStudent(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get user projects",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	seen := make(map[uint]bool)
+	projects := make([]gin.H, 0, len(teaching)+len(joined))
+	for _, project := range append(teaching, joined...) {
+		if seen[project.ID] {
+			continue
+		}
+		seen[project.ID] = true
+
+		role, _ := h.permissionService.GetUserRole(user.ID, "project", project.ID)
+		projects = append(projects, gin.H{
+			"project_id":   project.ID,
+			"project_name": project.Name,
+			"role":         role.String(),
+			"permissions": gin.H{
+				"read":   h.permissionService.CanAccessProject(user.ID, project.ID, "read"),
+				"write":  h.permissionService.CanAccessProject(user.ID, project.ID, "write"),
+				"manage": h.permissionService.CanAccessProject(user.ID, project.ID, "manage"),
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"user_id":    user.ID,
+			"role":       user.Role,
+			"is_admin":   h.permissionService.IsAdmin(user.ID),
+			"is_teacher": h.permissionService.IsTeacher(user.ID),
+			"projects":   projects,
+		},
+	})
+}
+
 // GetSystemStatus 获取系统状态
 func (h *ThirdPartyAPIHandler) GetSystemStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -398,44 +561,56 @@ func (h *ThirdPartyAPIHandler) GetSystemStatus(c *gin.Context) {
 	})
 }
 
-// ===== 未实现的GitLab扩展功能（占位符） =====
-
-func (h *ThirdPartyAPIHandler) RevokeAPIKey(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "API Key revocation not implemented"})
-}
-
-func (h *ThirdPartyAPIHandler) GetRepositoryCommits(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Repository commits feature not implemented"})
-}
-
-func (h *ThirdPartyAPIHandler) GetRepositoryBranches(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Repository branches feature not implemented"})
-}
-
-func (h *ThirdPartyAPIHandler) CreateBranch(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Create branch feature not implemented"})
-}
-
-func (h *ThirdPartyAPIHandler) GetRepositoryFiles(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Repository files feature not implemented"})
-}
-
-func (h *ThirdPartyAPIHandler) GetFileContent(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "File content feature not implemented"})
-}
-
-func (h *ThirdPartyAPIHandler) UpdateFileContent(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Update file content feature not implemented"})
-}
+// GetSystemStats 获取系统统计信息，只有管理员可以查询
+func (h *ThirdPartyAPIHandler) GetSystemStats(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	if !h.permissionService.IsAdmin(currentUser.ID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Admin permission required",
+		})
+		return
+	}
 
-func (h *ThirdPartyAPIHandler) UpdateUserRole(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Update user role feature not implemented"})
-}
+	users, err := h.userService.GetUserStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get user stats",
+			"details": err.Error(),
+		})
+		return
+	}
+	overview, err := h.analyticsService.GetAdminOverview(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get system overview",
+			"details": err.Error(),
+		})
+		return
+	}
 
-func (h *ThirdPartyAPIHandler) GetUserPermissions(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Get user permissions feature not implemented"})
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"users":            users,
+			"projects":         overview.TotalProjects,
+			"assignments":      overview.TotalAssignments,
+			"students":         overview.TotalStudents,
+			"completion_rate":  overview.CompletionRate,
+			"gitlab_connected": h.gitlabService != nil,
+		},
+	})
 }
 
-func (h *ThirdPartyAPIHandler) GetSystemStats(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "System stats feature not implemented"})
+// thirdPartyUser 获取当前用户，未认证时返回401
+func (h *ThirdPartyAPIHandler) thirdPartyUser(c *gin.Context) (*models.User, bool) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return nil, false
+	}
+	return user.(*models.User), true
 }
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== API Key管理 =====
+
+// registerAPIKeyRoutes 注册API Key管理路由
+func (h *ThirdPartyAPIHandler) registerAPIKeyRoutes(auth *gin.RouterGroup) {
+	requireAPIKeys := h.oauthMiddleware.RequireScope(models.APIScopeAPIKeys)
+
+	// 兼容旧接口：生成API Key
+	auth.POST("/api-key", requireAPIKeys, h.CreateAPIKey)
+
+	keys := auth.Group("/api-keys", requireAPIKeys)
+	{
+		keys.GET("/scopes", h.GetAPIKeyScopes) // 可授予的权限范围
+		keys.GET("", h.ListAPIKeys)            // 我的API Key
+		keys.POST("", h.CreateAPIKey)          // 创建API Key
+		keys.GET("/:id", h.GetAPIKey)          // API Key详情
+		keys.PUT("/:id", h.UpdateAPIKey)       // 修改名称和权限范围
+		keys.DELETE("/:id", h.RevokeAPIKey)    // 撤销API Key
+
+		keys.PUT("/:id/rate-limit", h.SetAPIKeyRateLimit) // 设置单个API Key的限额（管理员）
+	}
+}
+
+// GetAPIKeyScopes 获取可授予的权限范围
+func (h *ThirdPartyAPIHandler) GetAPIKeyScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": services.APIScopes,
+	})
+}
+
+// ListAPIKeys 获取当前用户的API Key，include_revoked=true 时包含已撤销的密钥
+func (h *ThirdPartyAPIHandler) ListAPIKeys(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(currentUser.ID, c.Query("include_revoked") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": keys,
+	})
+}
+
+// CreateAPIKey 创建API Key，响应中的明文密钥只返回这一次
+func (h *ThirdPartyAPIHandler) CreateAPIKey(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	key, rawKey, err := h.apiKeyService.CreateAPIKey(currentUser.ID, &req, currentAPIKey(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created successfully",
+		"data": gin.H{
+			"api_key": rawKey,
+			"key":     key,
+		},
+	})
+}
+
+// GetAPIKey 获取API Key详情
+func (h *ThirdPartyAPIHandler) GetAPIKey(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	keyID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeyService.GetAPIKey(currentUser.ID, keyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "API key not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": key,
+	})
+}
+
+// UpdateAPIKey 修改API Key的名称和权限范围
+func (h *ThirdPartyAPIHandler) UpdateAPIKey(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	keyID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.UpdateAPIKey(currentUser.ID, keyID, &req, currentAPIKey(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to update API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key updated successfully",
+		"data":    key,
+	})
+}
+
+// SetAPIKeyRateLimit 设置API Key的每分钟请求数，可设置任意用户的密钥，只有管理员可以操作
+func (h *ThirdPartyAPIHandler) SetAPIKeyRateLimit(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	if !h.permissionService.IsAdmin(currentUser.ID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Admin permission required",
+		})
+		return
+	}
+	keyID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		RateLimitPerMinute *int `json:"rate_limit_per_minute" binding:"required"` // 为0时使用默认限额
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.SetAPIKeyRateLimit(keyID, *req.RateLimitPerMinute)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to update API key rate limit",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key rate limit updated successfully",
+		"data":    key,
+	})
+}
+
+// RevokeAPIKey 撤销API Key，撤销后立即失效
+func (h *ThirdPartyAPIHandler) RevokeAPIKey(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	keyID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeyService.RevokeAPIKey(currentUser.ID, keyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Failed to revoke API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+		"data":    key,
+	})
+}
+
+// RevokeCurrentAPIKey 撤销当前请求使用的API Key
+func (h *ThirdPartyAPIHandler) RevokeCurrentAPIKey(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	current := currentAPIKey(c)
+	if current == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Request is not authenticated with an API key",
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.RevokeAPIKey(currentUser.ID, current.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+		"data":    key,
+	})
+}
+
+// currentAPIKey 当前请求使用的API Key，使用JWT Token认证时返回nil
+func currentAPIKey(c *gin.Context) *models.APIKey {
+	if authType, _ := c.Get("auth_type"); authType != "api_key" {
+		return nil
+	}
+	if key, exists := c.Get("api_key"); exists {
+		return key.(*models.APIKey)
+	}
+	return nil
+}
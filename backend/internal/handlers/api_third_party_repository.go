@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== Git仓库内容 =====
+
+// GetRepositoryCommits 分页获取提交记录，支持 ref、path、since、until 过滤
+func (h *ThirdPartyAPIHandler) GetRepositoryCommits(c *gin.Context) {
+	project, currentUser, ok := h.repositoryProject(c, "read")
+	if !ok {
+		return
+	}
+	page, pageSize := repositoryPagination(c)
+
+	req := &services.ListCommitsRequest{
+		Ref:      c.DefaultQuery("ref", project.DefaultBranch),
+		Path:     c.Query("path"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if req.Since, ok = repositoryTimeQuery(c, "since"); !ok {
+		return
+	}
+	if req.Until, ok = repositoryTimeQuery(c, "until"); !ok {
+		return
+	}
+
+	commits, pagination, err := h.gitlabService.ForUser(currentUser.ID).ListCommits(project.GitLabProjectID, req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to get commits",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	repositoryListResponse(c, commits, pagination)
+}
+
+// GetRepositoryBranches 分页获取分支，search 按名称过滤
+func (h *ThirdPartyAPIHandler) GetRepositoryBranches(c *gin.Context) {
+	project, currentUser, ok := h.repositoryProject(c, "read")
+	if !ok {
+		return
+	}
+	page, pageSize := repositoryPagination(c)
+
+	branches, pagination, err := h.gitlabService.ForUser(currentUser.ID).ListBranches(project.GitLabProjectID, c.Query("search"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to get branches",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	repositoryListResponse(c, branches, pagination)
+}
+
+// CreateBranch 创建分支，from 为空时从默认分支创建
+func (h *ThirdPartyAPIHandler) CreateBranch(c *gin.Context) {
+	project, currentUser, ok := h.repositoryProject(c, "write")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required"`
+		From string `json:"from"` // 分支、标签或提交
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+	if req.From == "" {
+		req.From = project.DefaultBranch
+	}
+
+	branch, err := h.gitlabService.ForUser(currentUser.ID).CreateBranch(project.GitLabProjectID, req.Name, req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to create branch",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Branch created successfully",
+		"data":    branch,
+	})
+}
+
+// GetRepositoryFiles 分页获取目录下的文件和子目录，支持 ref、path、recursive
+func (h *ThirdPartyAPIHandler) GetRepositoryFiles(c *gin.Context) {
+	project, currentUser, ok := h.repositoryProject(c, "read")
+	if !ok {
+		return
+	}
+	page, pageSize := repositoryPagination(c)
+
+	nodes, pagination, err := h.gitlabService.ForUser(currentUser.ID).ListTree(project.GitLabProjectID,
+		c.DefaultQuery("ref", project.DefaultBranch), strings.Trim(c.Query("path"), "/"),
+		c.Query("recursive") == "true", page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to get repository files",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	repositoryListResponse(c, nodes, pagination)
+}
+
+// GetFileContent 获取文件内容，format=raw 时直接返回文件原始内容，否则返回base64编码的内容及元数据
+func (h *ThirdPartyAPIHandler) GetFileContent(c *gin.Context) {
+	project, currentUser, ok := h.repositoryProject(c, "read")
+	if !ok {
+		return
+	}
+	filePath, ok := repositoryFilePath(c)
+	if !ok {
+		return
+	}
+	ref := c.DefaultQuery("ref", project.DefaultBranch)
+
+	switch c.DefaultQuery("format", "base64") {
+	case "raw":
+		content, err := h.gitlabService.ForUser(currentUser.ID).GetRawFile(project.GitLabProjectID, filePath, ref)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "File not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		// 仓库内容由学生提交，按附件下载且禁止浏览器嗅探类型，避免HTML等内容在本站域名下被渲染执行
+		c.Header("X-Content-Type-Options", "nosniff")
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(filePath)})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+		c.Header("Content-Disposition", disposition)
+		c.Data(http.StatusOK, "application/octet-stream", content)
+	case "base64":
+		file, err := h.gitlabService.ForUser(currentUser.ID).GetFile(project.GitLabProjectID, filePath, ref)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "File not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"data": file,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid format, must be raw or base64",
+		})
+	}
+}
+
+// UpdateFileContent 在分支上创建或更新文件，与在线编辑相同，受保护的默认分支只有课题老师和管理员可以修改
+func (h *ThirdPartyAPIHandler) UpdateFileContent(c *gin.Context) {
+	project, currentUser, ok := h.repositoryProject(c, "write")
+	if !ok {
+		return
+	}
+	filePath, ok := repositoryFilePath(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Branch        string `json:"branch" binding:"required"`
+		Content       string `json:"content"`
+		Encoding      string `json:"encoding"` // text（默认）或 base64
+		CommitMessage string `json:"commit_message"`
+		LastCommitID  string `json:"last_commit_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	size := len(req.Content)
+	switch req.Encoding {
+	case "", "text":
+		req.Encoding = "text"
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(req.Content)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid base64 content",
+				"details": err.Error(),
+			})
+			return
+		}
+		size = len(decoded)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid encoding, must be text or base64",
+		})
+		return
+	}
+
+	if project.MainBranchProtected && req.Branch == project.DefaultBranch &&
+		!h.permissionService.CanAccessProject(currentUser.ID, project.ID, "manage") {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Main branch is protected",
+		})
+		return
+	}
+	if project.MaxFileSize > 0 && int64(size) > project.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "File size exceeds limit",
+		})
+		return
+	}
+	if req.CommitMessage == "" {
+		req.CommitMessage = "Update " + filePath
+	}
+
+	info, created, err := h.gitlabService.ForUser(currentUser.ID).SaveFile(project.GitLabProjectID, filePath, &services.SaveFileRequest{
+		Branch:        req.Branch,
+		Content:       req.Content,
+		Encoding:      req.Encoding,
+		CommitMessage: req.CommitMessage,
+		LastCommitID:  req.LastCommitID,
+		AuthorName:    currentUser.Name,
+		AuthorEmail:   currentUser.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to save file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	status, message := http.StatusOK, "File updated successfully"
+	if created {
+		status, message = http.StatusCreated, "File created successfully"
+	}
+	c.JSON(status, gin.H{
+		"message": message,
+		"data":    info,
+	})
+}
+
+// repositoryProject 获取路径中的课题并检查课题权限，课题需要已关联GitLab仓库
+func (h *ThirdPartyAPIHandler) repositoryProject(c *gin.Context, permission string) (*models.Project, *models.User, bool) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid repository ID",
+		})
+		return nil, nil, false
+	}
+
+	if !h.permissionService.CanAccessProject(currentUser.ID, uint(projectID), permission) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Permission denied",
+		})
+		return nil, nil, false
+	}
+
+	project, err := h.projectService.GetLinkedProject(uint(projectID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+	if h.gitlabService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "GitLab is not connected",
+		})
+		return nil, nil, false
+	}
+
+	return project, currentUser, true
+}
+
+// repositoryFilePath 路径中的文件路径，不能为空或包含 ..
+func repositoryFilePath(c *gin.Context) (string, bool) {
+	filePath := strings.Trim(c.Param("filepath"), "/")
+	if filePath == "" || strings.Contains("/"+filePath+"/", "/../") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file path",
+		})
+		return "", false
+	}
+	return filePath, true
+}
+
+// repositoryTimeQuery 解析RFC3339格式的时间参数，未提供时返回nil
+func repositoryTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid " + name,
+			"details": "time must be in RFC3339 format",
+		})
+		return nil, false
+	}
+	return &t, true
+}
+
+// repositoryPagination 分页参数，page_size 最大100
+func repositoryPagination(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// repositoryListResponse 列表响应，分页信息来自GitLab
+func repositoryListResponse(c *gin.Context, data interface{}, pagination *services.RepositoryPage) {
+	c.JSON(http.StatusOK, gin.H{
+		"data":        data,
+		"total":       pagination.Total,
+		"page":        pagination.Page,
+		"page_size":   pagination.PageSize,
+		"total_pages": pagination.TotalPages,
+		"next_page":   pagination.NextPage,
+	})
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ===== Webhook订阅 =====
+
+// registerWebhookRoutes 注册Webhook订阅路由
+func (h *ThirdPartyAPIHandler) registerWebhookRoutes(api *gin.RouterGroup) {
+	webhooks := api.Group("/webhooks", h.oauthMiddleware.RequireScope(models.APIScopeWebhooks))
+	{
+		webhooks.GET("/events", h.GetWebhookEvents)                                         // 可订阅的事件
+		webhooks.GET("", h.ListWebhooks)                                                    // 我的订阅
+		webhooks.POST("", h.CreateWebhook)                                                  // 创建订阅
+		webhooks.GET("/:id", h.GetWebhook)                                                  // 订阅详情
+		webhooks.PUT("/:id", h.UpdateWebhook)                                               // 修改订阅
+		webhooks.DELETE("/:id", h.DeleteWebhook)                                            // 删除订阅
+		webhooks.POST("/:id/secret", h.RotateWebhookSecret)                                 // 重新生成签名密钥
+		webhooks.POST("/:id/ping", h.PingWebhook)                                           // 发送测试事件
+		webhooks.GET("/:id/deliveries", h.ListWebhookDeliveries)                            // 投递记录
+		webhooks.GET("/:id/deliveries/:delivery_id", h.GetWebhookDelivery)                  // 投递详情
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", h.RedeliverWebhookDelivery) // 重新投递
+	}
+}
+
+// GetWebhookEvents 获取可订阅的事件
+func (h *ThirdPartyAPIHandler) GetWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": services.WebhookEvents,
+	})
+}
+
+// ListWebhooks 获取当前用户的Webhook订阅
+func (h *ThirdPartyAPIHandler) ListWebhooks(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.webhookService.ListSubscriptions(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get webhooks",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": subscriptions,
+	})
+}
+
+// CreateWebhook 创建Webhook订阅，响应中的签名密钥只返回这一次
+func (h *ThirdPartyAPIHandler) CreateWebhook(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	subscription, secret, err := h.webhookService.CreateSubscription(currentUser.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to create webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully",
+		"data": gin.H{
+			"webhook": subscription,
+			"secret":  secret,
+		},
+	})
+}
+
+// GetWebhook 获取Webhook订阅详情
+func (h *ThirdPartyAPIHandler) GetWebhook(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	subscription, err := h.webhookService.GetSubscription(currentUser.ID, webhookID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Webhook not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": subscription,
+	})
+}
+
+// UpdateWebhook 修改Webhook订阅
+func (h *ThirdPartyAPIHandler) UpdateWebhook(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req services.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	subscription, err := h.webhookService.UpdateSubscription(currentUser.ID, webhookID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to update webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook updated successfully",
+		"data":    subscription,
+	})
+}
+
+// DeleteWebhook 删除Webhook订阅
+func (h *ThirdPartyAPIHandler) DeleteWebhook(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(currentUser.ID, webhookID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to delete webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// RotateWebhookSecret 重新生成签名密钥，旧密钥立即失效
+func (h *ThirdPartyAPIHandler) RotateWebhookSecret(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	subscription, secret, err := h.webhookService.RotateSecret(currentUser.ID, webhookID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to rotate webhook secret",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook secret rotated successfully",
+		"data": gin.H{
+			"webhook": subscription,
+			"secret":  secret,
+		},
+	})
+}
+
+// PingWebhook 发送 ping 事件并返回投递结果
+func (h *ThirdPartyAPIHandler) PingWebhook(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Ping(currentUser.ID, webhookID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to ping webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": delivery,
+	})
+}
+
+// ListWebhookDeliveries 分页获取投递记录
+func (h *ThirdPartyAPIHandler) ListWebhookDeliveries(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	deliveries, total, err := h.webhookService.GetDeliveries(currentUser.ID, webhookID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Failed to get webhook deliveries",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deliveries": deliveries,
+			"total":      total,
+			"page":       page,
+			"page_size":  pageSize,
+		},
+	})
+}
+
+// GetWebhookDelivery 获取投递详情（含推送正文，响应正文仅管理员可见）
+func (h *ThirdPartyAPIHandler) GetWebhookDelivery(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseWebhookParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.GetDelivery(currentUser.ID, webhookID, deliveryID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Webhook delivery not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": delivery,
+	})
+}
+
+// RedeliverWebhookDelivery 重新投递并返回新的投递记录
+func (h *ThirdPartyAPIHandler) RedeliverWebhookDelivery(c *gin.Context) {
+	currentUser, ok := h.thirdPartyUser(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookParam(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseWebhookParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(currentUser.ID, webhookID, deliveryID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to redeliver webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook redelivered",
+		"data":    delivery,
+	})
+}
+
+// parseWebhookParam 解析路径中的ID参数
+func parseWebhookParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid " + name,
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
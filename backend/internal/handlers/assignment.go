@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"gitlabex/internal/models"
 	"gitlabex/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -39,6 +41,30 @@ func (h *AssignmentHandler) RegisterRoutes(router *gin.RouterGroup) {
 		assignments.GET("/:id/submissions", h.GetSubmissions)                 // 获取作业提交列表
 		assignments.GET("/submissions/:submission_id", h.GetSubmissionDetail) // 获取提交详情
 
+		// 多次提交
+		assignments.GET("/:id/attempts", h.GetSubmissionAttempts)                         // 获取学生的全部提交
+		assignments.GET("/:id/attempts/compare", h.CompareSubmissionAttempts)             // 比较两次提交的代码差异
+		assignments.POST("/submissions/:submission_id/select", h.SelectSubmissionAttempt) // 选择用于评分的提交（老师）
+
+		// CI流水线
+		assignments.POST("/submissions/:submission_id/pipeline", h.SyncSubmissionPipeline) // 立即同步提交的流水线结果
+
+		// 评分标准
+		assignments.GET("/:id/rubric", h.GetRubric)       // 获取评分标准
+		assignments.PUT("/:id/rubric", h.SaveRubric)      // 创建或替换评分标准（老师）
+		assignments.DELETE("/:id/rubric", h.DeleteRubric) // 删除评分标准（老师）
+
+		// 学生延期
+		assignments.GET("/:id/extensions", h.GetExtensions)                  // 获取延期列表（老师）
+		assignments.POST("/:id/extensions", h.GrantExtension)                // 批准或更新学生延期（老师）
+		assignments.DELETE("/:id/extensions/:student_id", h.RevokeExtension) // 撤销学生延期（老师）
+
+		// 评审流程
+		assignments.POST("/submissions/:submission_id/reviews", h.CreateReview)                       // 创建评审（老师）
+		assignments.GET("/submissions/:submission_id/reviews", h.GetReviews)                          // 获取评审列表
+		assignments.PUT("/submissions/:submission_id/reviews/:review_id", h.UpdateReview)             // 更新评审（老师）
+		assignments.POST("/submissions/:submission_id/reviews/:review_id/finalize", h.FinalizeReview) // 完成评审（老师）
+
 		// 统计和分析
 		assignments.GET("/:id/stats", h.GetAssignmentStats)    // 获取作业统计
 		assignments.GET("/my-submissions", h.GetMySubmissions) // 获取我的提交记录
@@ -47,8 +73,14 @@ func (h *AssignmentHandler) RegisterRoutes(router *gin.RouterGroup) {
 
 // CreateAssignment 创建作业
 func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
-	// TODO: 从JWT获取用户ID，这里暂时使用测试用户
-	teacherID := uint(1)
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	teacherID := user.(*models.User).ID
 
 	var req services.CreateAssignmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -76,9 +108,15 @@ func (h *AssignmentHandler) CreateAssignment(c *gin.Context) {
 
 // ListAssignments 获取作业列表
 func (h *AssignmentHandler) ListAssignments(c *gin.Context) {
-	// TODO: 从JWT获取用户信息
-	userID := uint(1)
-	userRole := 2 // 假设是老师角色
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	userID := user.(*models.User).ID
+	userRole := user.(*models.User).Role
 
 	// 检查是否按课题筛选
 	projectIDStr := c.Query("project_id")
@@ -241,8 +279,14 @@ func (h *AssignmentHandler) DeleteAssignment(c *gin.Context) {
 
 // SubmitAssignment 提交作业
 func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
-	// TODO: 从JWT获取学生ID
-	studentID := uint(2)
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	studentID := user.(*models.User).ID
 
 	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -263,6 +307,15 @@ func (h *AssignmentHandler) SubmitAssignment(c *gin.Context) {
 
 	submission, err := h.assignmentService.SubmitAssignment(studentID, uint(assignmentID), &req)
 	if err != nil {
+		var checkErr *services.SubmissionCheckError
+		if errors.As(err, &checkErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "作业文件检查未通过",
+				"details": err.Error(),
+				"data":    checkErr.Report,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "提交作业失败",
 			"details": err.Error(),
@@ -301,8 +354,16 @@ func (h *AssignmentHandler) GetSubmissions(c *gin.Context) {
 	})
 }
 
-// GetSubmissionDetail 获取提交详情
+// GetSubmissionDetail 获取提交详情，只有提交的学生和有评审权限的用户可以查看
 func (h *AssignmentHandler) GetSubmissionDetail(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+
 	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -311,7 +372,8 @@ func (h *AssignmentHandler) GetSubmissionDetail(c *gin.Context) {
 		return
 	}
 
-	submission, err := h.assignmentService.GetSubmissionByID(uint(submissionID))
+	// 无权查看时同样返回不存在，避免泄露提交ID
+	submission, err := h.assignmentService.GetSubmissionByID(user.(*models.User).ID, uint(submissionID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "提交记录不存在",
@@ -351,8 +413,14 @@ func (h *AssignmentHandler) GetAssignmentStats(c *gin.Context) {
 
 // GetMySubmissions 获取我的提交记录
 func (h *AssignmentHandler) GetMySubmissions(c *gin.Context) {
-	// TODO: 从JWT获取学生ID
-	studentID := uint(2)
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	studentID := user.(*models.User).ID
 
 	submissions, err := h.assignmentService.GetSubmissionsByStudent(studentID)
 	if err != nil {
@@ -368,3 +436,550 @@ func (h *AssignmentHandler) GetMySubmissions(c *gin.Context) {
 		"total": len(submissions),
 	})
 }
+
+// CreateReview 创建评审
+func (h *AssignmentHandler) CreateReview(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的提交ID",
+		})
+		return
+	}
+
+	var req services.CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	review, err := h.assignmentService.CreateReview(currentUser.ID, uint(submissionID), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建评审失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "评审创建成功",
+		"data":    review,
+	})
+}
+
+// GetReviews 获取评审列表
+func (h *AssignmentHandler) GetReviews(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的提交ID",
+		})
+		return
+	}
+
+	reviews, err := h.assignmentService.GetReviewsBySubmission(currentUser.ID, uint(submissionID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取评审列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  reviews,
+		"total": len(reviews),
+	})
+}
+
+// UpdateReview 更新评审
+func (h *AssignmentHandler) UpdateReview(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	submissionID, reviewID, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	review, err := h.assignmentService.UpdateReview(currentUser.ID, submissionID, reviewID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新评审失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评审更新成功",
+		"data":    review,
+	})
+}
+
+// FinalizeReview 完成评审
+func (h *AssignmentHandler) FinalizeReview(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	submissionID, reviewID, ok := parseReviewParams(c)
+	if !ok {
+		return
+	}
+
+	var req services.FinalizeReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	review, err := h.assignmentService.FinalizeReview(currentUser.ID, submissionID, reviewID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "完成评审失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评审已完成",
+		"data":    review,
+	})
+}
+
+// GetRubric 获取评分标准
+func (h *AssignmentHandler) GetRubric(c *gin.Context) {
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	rubric, err := h.assignmentService.GetRubric(uint(assignmentID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "评分标准不存在",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": rubric,
+	})
+}
+
+// SaveRubric 创建或替换评分标准
+func (h *AssignmentHandler) SaveRubric(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	var req services.RubricRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rubric, err := h.assignmentService.SaveRubric(currentUser.ID, uint(assignmentID), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "保存评分标准失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评分标准保存成功",
+		"data":    rubric,
+	})
+}
+
+// DeleteRubric 删除评分标准
+func (h *AssignmentHandler) DeleteRubric(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	if err := h.assignmentService.DeleteRubric(currentUser.ID, uint(assignmentID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除评分标准失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评分标准删除成功",
+	})
+}
+
+// GetSubmissionAttempts 获取学生在作业上的全部提交，未指定学生时返回自己的提交
+func (h *AssignmentHandler) GetSubmissionAttempts(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	studentID := currentUser.ID
+	if value := c.Query("student_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "无效的学生ID",
+			})
+			return
+		}
+		studentID = uint(id)
+	}
+
+	attempts, err := h.assignmentService.GetSubmissionAttempts(currentUser.ID, uint(assignmentID), studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取提交记录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": attempts,
+	})
+}
+
+// CompareSubmissionAttempts 比较同一学生两次提交的代码差异
+func (h *AssignmentHandler) CompareSubmissionAttempts(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	fromID, err := strconv.ParseUint(c.Query("from"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的起始提交ID",
+		})
+		return
+	}
+
+	toID, err := strconv.ParseUint(c.Query("to"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的目标提交ID",
+		})
+		return
+	}
+
+	comparison, err := h.assignmentService.CompareSubmissionAttempts(currentUser.ID, uint(fromID), uint(toID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "比较提交失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": comparison,
+	})
+}
+
+// SelectSubmissionAttempt 选择用于评分的提交
+func (h *AssignmentHandler) SelectSubmissionAttempt(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的提交ID",
+		})
+		return
+	}
+
+	submission, err := h.assignmentService.SelectSubmissionAttempt(currentUser.ID, uint(submissionID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "选择评分提交失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已选择评分提交",
+		"data":    submission,
+	})
+}
+
+// SyncSubmissionPipeline 立即同步提交的CI流水线结果
+func (h *AssignmentHandler) SyncSubmissionPipeline(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的提交ID",
+		})
+		return
+	}
+
+	pipeline, err := h.assignmentService.SyncSubmissionPipeline(currentUser.ID, uint(submissionID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "同步流水线失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "流水线同步成功",
+		"data":    pipeline,
+	})
+}
+
+// GetExtensions 获取作业的学生延期列表
+func (h *AssignmentHandler) GetExtensions(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	extensions, err := h.assignmentService.GetExtensions(currentUser.ID, uint(assignmentID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取延期列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": extensions,
+	})
+}
+
+// GrantExtension 批准或更新学生延期
+func (h *AssignmentHandler) GrantExtension(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	var req services.GrantExtensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	extension, err := h.assignmentService.GrantExtension(currentUser.ID, uint(assignmentID), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "批准延期失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "延期批准成功",
+		"data":    extension,
+	})
+}
+
+// RevokeExtension 撤销学生延期
+func (h *AssignmentHandler) RevokeExtension(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	assignmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的作业ID",
+		})
+		return
+	}
+
+	studentID, err := strconv.ParseUint(c.Param("student_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的学生ID",
+		})
+		return
+	}
+
+	if err := h.assignmentService.RevokeExtension(currentUser.ID, uint(assignmentID), uint(studentID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "撤销延期失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "延期撤销成功",
+	})
+}
+
+// parseReviewParams 解析评审路由中的提交ID和评审ID
+func parseReviewParams(c *gin.Context) (uint, uint, bool) {
+	submissionID, err := strconv.ParseUint(c.Param("submission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的提交ID",
+		})
+		return 0, 0, false
+	}
+
+	reviewID, err := strconv.ParseUint(c.Param("review_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的评审ID",
+		})
+		return 0, 0, false
+	}
+
+	return uint(submissionID), uint(reviewID), true
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AutogradeHandler 自动评测处理器
+type AutogradeHandler struct {
+	autogradeService *services.AutogradeService
+}
+
+// NewAutogradeHandler 创建自动评测处理器
+func NewAutogradeHandler(autogradeService *services.AutogradeService) *AutogradeHandler {
+	return &AutogradeHandler{
+		autogradeService: autogradeService,
+	}
+}
+
+// RegisterRoutes 注册自动评测路由
+func (h *AutogradeHandler) RegisterRoutes(router *gin.RouterGroup) {
+	assignments := router.Group("/assignments")
+	{
+		assignments.GET("/:id/autograde", h.GetSuite)       // 获取自动评测配置（老师）
+		assignments.PUT("/:id/autograde", h.SaveSuite)      // 创建或替换自动评测配置（老师）
+		assignments.DELETE("/:id/autograde", h.DeleteSuite) // 删除自动评测配置（老师）
+
+		assignments.GET("/submissions/:submission_id/autograde", h.GetRuns)     // 获取提交的评测记录
+		assignments.POST("/submissions/:submission_id/autograde", h.RerunTests) // 重新评测（老师）
+	}
+}
+
+// GetSuite 获取作业的自动评测配置
+func (h *AutogradeHandler) GetSuite(c *gin.Context) {
+	currentUser, assignmentID, ok := parseAutogradeParam(c, "id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	suite, err := h.autogradeService.GetSuite(currentUser.ID, assignmentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "获取自动评测配置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": suite,
+	})
+}
+
+// SaveSuite 创建或替换作业的自动评测配置
+func (h *AutogradeHandler) SaveSuite(c *gin.Context) {
+	currentUser, assignmentID, ok := parseAutogradeParam(c, "id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	var req services.AutogradeSuiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	suite, err := h.autogradeService.SaveSuite(currentUser.ID, assignmentID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "保存自动评测配置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "自动评测配置保存成功",
+		"data":    suite,
+	})
+}
+
+// DeleteSuite 删除作业的自动评测配置
+func (h *AutogradeHandler) DeleteSuite(c *gin.Context) {
+	currentUser, assignmentID, ok := parseAutogradeParam(c, "id", "无效的作业ID")
+	if !ok {
+		return
+	}
+
+	if err := h.autogradeService.DeleteSuite(currentUser.ID, assignmentID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除自动评测配置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "自动评测配置删除成功",
+	})
+}
+
+// GetRuns 获取提交的评测记录
+func (h *AutogradeHandler) GetRuns(c *gin.Context) {
+	currentUser, submissionID, ok := parseAutogradeParam(c, "submission_id", "无效的提交ID")
+	if !ok {
+		return
+	}
+
+	runs, err := h.autogradeService.GetRuns(currentUser.ID, submissionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取评测记录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": runs,
+	})
+}
+
+// RerunTests 重新评测提交
+func (h *AutogradeHandler) RerunTests(c *gin.Context) {
+	currentUser, submissionID, ok := parseAutogradeParam(c, "submission_id", "无效的提交ID")
+	if !ok {
+		return
+	}
+
+	run, err := h.autogradeService.RerunSubmission(currentUser.ID, submissionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建评测任务失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "评测任务已创建",
+		"data":    run,
+	})
+}
+
+// parseAutogradeParam 解析当前用户和路由中的ID参数
+func parseAutogradeParam(c *gin.Context, param, invalidMessage string) (*models.User, uint, bool) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return nil, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": invalidMessage,
+		})
+		return nil, 0, false
+	}
+
+	return user.(*models.User), uint(id), true
+}
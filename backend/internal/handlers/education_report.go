@@ -1,189 +1,173 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"gitlabex/internal/models"
 	"gitlabex/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type EducationReportHandler struct {
-	gitlabService *services.GitLabService
+	educationReportService *services.EducationReportService
 }
 
-func NewEducationReportHandler(gitlabService *services.GitLabService) *EducationReportHandler {
+func NewEducationReportHandler(educationReportService *services.EducationReportService) *EducationReportHandler {
 	return &EducationReportHandler{
-		gitlabService: gitlabService,
+		educationReportService: educationReportService,
 	}
 }
 
-type ReportData struct {
-	Overview struct {
-		TotalStudents     int     `json:"total_students"`
-		TotalAssignments  int     `json:"total_assignments"`
-		TotalProjects     int     `json:"total_projects"`
-		AverageCompletion float64 `json:"average_completion"`
-	} `json:"overview"`
-	ClassActivity []struct {
-		ClassName      string  `json:"class_name"`
-		ActiveStudents int     `json:"active_students"`
-		TotalStudents  int     `json:"total_students"`
-		CompletionRate float64 `json:"completion_rate"`
-	} `json:"class_activity"`
-	AssignmentStats []struct {
-		Name      string `json:"name"`
-		Submitted int    `json:"submitted"`
-		Total     int    `json:"total"`
-		OnTime    int    `json:"on_time"`
-		Late      int    `json:"late"`
-	} `json:"assignment_stats"`
-	ProgressTrends []struct {
-		Date          string `json:"date"`
-		Completion    int    `json:"completion"`
-		Participation int    `json:"participation"`
-	} `json:"progress_trends"`
-	TopPerformers []struct {
-		ID             int     `json:"id"`
-		Name           string  `json:"name"`
-		CompletionRate float64 `json:"completion_rate"`
-		Points         int     `json:"points"`
-	} `json:"top_performers"`
-}
-
 // GetEducationReports 获取教育报表数据
 func (h *EducationReportHandler) GetEducationReports(c *gin.Context) {
-	// 获取查询参数
-	timeRange := c.Query("time_range")
-	className := c.Query("class")
-
-	// 这里应该根据参数从GitLab API获取真实数据
-	// 目前返回模拟数据
-	reportData := ReportData{
-		Overview: struct {
-			TotalStudents     int     `json:"total_students"`
-			TotalAssignments  int     `json:"total_assignments"`
-			TotalProjects     int     `json:"total_projects"`
-			AverageCompletion float64 `json:"average_completion"`
-		}{
-			TotalStudents:     125,
-			TotalAssignments:  45,
-			TotalProjects:     8,
-			AverageCompletion: 87.5,
-		},
-		ClassActivity: []struct {
-			ClassName      string  `json:"class_name"`
-			ActiveStudents int     `json:"active_students"`
-			TotalStudents  int     `json:"total_students"`
-			CompletionRate float64 `json:"completion_rate"`
-		}{
-			{ClassName: "计算机科学1班", ActiveStudents: 28, TotalStudents: 30, CompletionRate: 93.3},
-			{ClassName: "计算机科学2班", ActiveStudents: 25, TotalStudents: 32, CompletionRate: 78.1},
-			{ClassName: "软件工程1班", ActiveStudents: 31, TotalStudents: 33, CompletionRate: 93.9},
-			{ClassName: "软件工程2班", ActiveStudents: 27, TotalStudents: 30, CompletionRate: 90.0},
-		},
-		AssignmentStats: []struct {
-			Name      string `json:"name"`
-			Submitted int    `json:"submitted"`
-			Total     int    `json:"total"`
-			OnTime    int    `json:"on_time"`
-			Late      int    `json:"late"`
-		}{
-			{Name: "数据结构实验", Submitted: 28, Total: 30, OnTime: 25, Late: 3},
-			{Name: "算法分析作业", Submitted: 32, Total: 33, OnTime: 30, Late: 2},
-			{Name: "Web开发项目", Submitted: 25, Total: 30, OnTime: 20, Late: 5},
-			{Name: "数据库设计", Submitted: 29, Total: 32, OnTime: 26, Late: 3},
-		},
-		ProgressTrends: []struct {
-			Date          string `json:"date"`
-			Completion    int    `json:"completion"`
-			Participation int    `json:"participation"`
-		}{
-			{Date: "2024-03-01", Completion: 75, Participation: 82},
-			{Date: "2024-03-08", Completion: 82, Participation: 88},
-			{Date: "2024-03-15", Completion: 87, Participation: 91},
-			{Date: "2024-03-22", Completion: 89, Participation: 93},
-		},
-		TopPerformers: []struct {
-			ID             int     `json:"id"`
-			Name           string  `json:"name"`
-			CompletionRate float64 `json:"completion_rate"`
-			Points         int     `json:"points"`
-		}{
-			{ID: 1, Name: "张三", CompletionRate: 98.5, Points: 245},
-			{ID: 2, Name: "李四", CompletionRate: 95.2, Points: 238},
-			{ID: 3, Name: "王五", CompletionRate: 92.8, Points: 232},
-			{ID: 4, Name: "赵六", CompletionRate: 90.5, Points: 226},
-			{ID: 5, Name: "钱七", CompletionRate: 88.9, Points: 222},
-		},
-	}
-
-	// 根据参数调整数据（模拟筛选逻辑）
-	if timeRange == "week" {
-		// 调整为周数据
-		reportData.Overview.TotalStudents = 30
-		reportData.Overview.TotalAssignments = 8
-	} else if timeRange == "month" {
-		// 调整为月数据
-		reportData.Overview.TotalStudents = 125
-		reportData.Overview.TotalAssignments = 45
-	}
-
-	if className != "" && className != "all" {
-		// 根据班级筛选数据
-		var filteredClassActivity []struct {
-			ClassName      string  `json:"class_name"`
-			ActiveStudents int     `json:"active_students"`
-			TotalStudents  int     `json:"total_students"`
-			CompletionRate float64 `json:"completion_rate"`
-		}
-		for _, class := range reportData.ClassActivity {
-			if class.ClassName == className {
-				filteredClassActivity = append(filteredClassActivity, class)
-			}
-		}
-		reportData.ClassActivity = filteredClassActivity
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	filter, ok := parseReportFilter(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.educationReportService.GetReport(currentUser.ID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取报表数据失败",
+			"details": err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
-		"data":   reportData,
+		"data":   report,
 	})
 }
 
-// ExportReport 导出报表
+// ExportReport 创建报表导出任务
 func (h *EducationReportHandler) ExportReport(c *gin.Context) {
-	// 获取查询参数
-	format := c.Query("format")
-	timeRange := c.Query("time_range")
-	className := c.Query("class")
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	currentUser := user.(*models.User)
 
-	// 这里应该实现真实的导出逻辑
-	// 目前返回模拟响应
-	response := gin.H{
+	filter, ok := parseReportFilter(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", services.ReportFormatXLSX)
+	if _, err := services.NormalizeReportFormat(format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "不支持的导出格式",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	export, err := h.educationReportService.CreateExport(currentUser.ID, format, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建导出任务失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
 		"status":  "success",
-		"message": "报表导出成功",
-		"data": gin.H{
-			"format":       format,
-			"time_range":   timeRange,
-			"class":        className,
-			"download_url": "/api/reports/download/" + format,
-		},
+		"message": "报表导出任务已创建",
+		"data":    exportResponse(export),
+	})
+}
+
+// GetExport 获取报表导出任务状态
+func (h *EducationReportHandler) GetExport(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	exportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的导出任务ID"})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	export, err := h.educationReportService.GetExport(currentUser.ID, uint(exportID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "导出任务不存在",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   exportResponse(export),
+	})
+}
+
+// DownloadExport 下载导出的报表文件
+func (h *EducationReportHandler) DownloadExport(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	exportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的导出任务ID"})
+		return
+	}
+
+	export, err := h.educationReportService.GetExportFile(currentUser.ID, uint(exportID))
+	if err != nil {
+		status := http.StatusConflict
+		if current, getErr := h.educationReportService.GetExport(currentUser.ID, uint(exportID)); getErr != nil {
+			status = http.StatusNotFound
+		} else if current.Status == models.ReportExportStatusExpired {
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{
+			"error":   "报表文件不可下载",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.FileAttachment(export.FilePath, export.FileName)
 }
 
 // GetClassList 获取班级列表
 func (h *EducationReportHandler) GetClassList(c *gin.Context) {
-	// 这里应该从GitLab Groups API获取真实数据
-	// 目前返回模拟数据
-	classes := []gin.H{
-		{"value": "all", "label": "所有班级"},
-		{"value": "计算机科学1班", "label": "计算机科学1班"},
-		{"value": "计算机科学2班", "label": "计算机科学2班"},
-		{"value": "软件工程1班", "label": "软件工程1班"},
-		{"value": "软件工程2班", "label": "软件工程2班"},
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	classes, err := h.educationReportService.GetClassList(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取班级列表失败",
+			"details": err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -199,5 +183,34 @@ func (h *EducationReportHandler) RegisterRoutes(rg *gin.RouterGroup) {
 		reports.GET("", h.GetEducationReports)
 		reports.GET("/classes", h.GetClassList)
 		reports.POST("/export", h.ExportReport)
+		reports.GET("/exports/:id", h.GetExport)
+		reports.GET("/exports/:id/download", h.DownloadExport)
+	}
+}
+
+// parseReportFilter 解析报表筛选参数，class 为 all 或班级（课题）ID
+func parseReportFilter(c *gin.Context) (services.ReportFilter, bool) {
+	filter := services.ReportFilter{TimeRange: c.Query("time_range")}
+
+	if class := c.Query("class"); class != "" && class != "all" {
+		classID, err := strconv.ParseUint(class, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的班级ID"})
+			return filter, false
+		}
+		filter.ClassID = uint(classID)
+	}
+
+	return filter, true
+}
+
+// exportResponse 导出任务响应，完成后附带下载地址
+func exportResponse(export *models.ReportExport) gin.H {
+	response := gin.H{
+		"export": export,
+	}
+	if export.Status == models.ReportExportStatusCompleted {
+		response["download_url"] = fmt.Sprintf("/api/education-reports/exports/%d/download", export.ID)
 	}
+	return response
 }
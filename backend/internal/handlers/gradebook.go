@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GradebookHandler 成绩册处理器
+type GradebookHandler struct {
+	gradebookService *services.GradebookService
+}
+
+// NewGradebookHandler 创建成绩册处理器
+func NewGradebookHandler(gradebookService *services.GradebookService) *GradebookHandler {
+	return &GradebookHandler{
+		gradebookService: gradebookService,
+	}
+}
+
+// RegisterRoutes 注册成绩册路由
+func (h *GradebookHandler) RegisterRoutes(router *gin.RouterGroup) {
+	gradebook := router.Group("/projects/:project_id/gradebook")
+	{
+		gradebook.GET("", h.GetGradebook)            // 获取成绩册（老师）
+		gradebook.GET("/export", h.ExportGradebook)  // 导出成绩册CSV（老师）
+		gradebook.POST("/import", h.ImportGradebook) // 导入成绩CSV（老师）
+	}
+}
+
+// GetGradebook 获取课题成绩册
+func (h *GradebookHandler) GetGradebook(c *gin.Context) {
+	currentUser, projectID, ok := parseGradebookParams(c)
+	if !ok {
+		return
+	}
+
+	gradebook, err := h.gradebookService.GetGradebook(currentUser.ID, projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取成绩册失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "success",
+		"data":    gradebook,
+	})
+}
+
+// ExportGradebook 导出成绩册CSV
+func (h *GradebookHandler) ExportGradebook(c *gin.Context) {
+	currentUser, projectID, ok := parseGradebookParams(c)
+	if !ok {
+		return
+	}
+
+	identifier := c.DefaultQuery("identifier", services.GradebookIdentifierUsername)
+	includeFeedback := c.Query("include_feedback") == "true"
+
+	content, fileName, err := h.gradebookService.ExportGradebookCSV(currentUser.ID, projectID, identifier, includeFeedback)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "导出成绩册失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", content)
+}
+
+// ImportGradebook 导入成绩CSV
+// 支持 multipart 上传（字段名 file）或直接以 text/csv 作为请求体；默认仅预览差异，dry_run=false 时写入
+func (h *GradebookHandler) ImportGradebook(c *gin.Context) {
+	currentUser, projectID, ok := parseGradebookParams(c)
+	if !ok {
+		return
+	}
+
+	identifier := c.DefaultQuery("identifier", services.GradebookIdentifierUsername)
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+
+	var reader io.Reader = c.Request.Body
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "无法读取上传文件",
+				"details": err.Error(),
+			})
+			return
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	result, err := h.gradebookService.ImportGradebookCSV(currentUser.ID, projectID, identifier, reader, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "导入成绩失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusOK
+	message := "成绩导入预览"
+	if !dryRun {
+		message = "成绩导入成功"
+		if !result.Applied && len(result.Errors) > 0 {
+			status = http.StatusUnprocessableEntity
+			message = "成绩导入存在错误，未写入任何数据"
+		}
+	}
+
+	c.JSON(status, gin.H{
+		"message": message,
+		"data":    result,
+	})
+}
+
+// parseGradebookParams 解析当前用户和课题ID
+func parseGradebookParams(c *gin.Context) (*models.User, uint, bool) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return nil, 0, false
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("project_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的课题ID",
+		})
+		return nil, 0, false
+	}
+
+	return user.(*models.User), uint(projectID), true
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationPreferenceHandler 通知设置处理器（投递渠道和摘要）
+type NotificationPreferenceHandler struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationPreferenceHandler 创建通知设置处理器
+func NewNotificationPreferenceHandler(notificationService *services.NotificationService) *NotificationPreferenceHandler {
+	return &NotificationPreferenceHandler{
+		notificationService: notificationService,
+	}
+}
+
+// UpdateNotificationPreferencesRequest 更新通知设置请求
+type UpdateNotificationPreferencesRequest struct {
+	Preferences map[string]string `json:"preferences" binding:"required"` // 通知类型 -> in_app, email, digest, off, default
+}
+
+// RegisterRoutes 注册通知设置路由
+func (h *NotificationPreferenceHandler) RegisterRoutes(router *gin.RouterGroup) {
+	notifications := router.Group("/notifications")
+	{
+		notifications.GET("/preferences", h.GetPreferences)          // 获取我的通知设置
+		notifications.PUT("/preferences", h.UpdatePreferences)       // 修改我的通知设置
+		notifications.GET("/digest-settings", h.GetDigestSetting)    // 获取我的摘要设置
+		notifications.PUT("/digest-settings", h.UpdateDigestSetting) // 修改我的摘要设置
+		notifications.GET("/digests/:id", h.GetDigest)               // 获取摘要汇总的通知
+	}
+}
+
+// GetPreferences 获取当前用户各类通知的投递渠道
+func (h *NotificationPreferenceHandler) GetPreferences(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	preferences, err := h.notificationService.GetNotificationPreferences(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取通知设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": preferences,
+	})
+}
+
+// UpdatePreferences 修改当前用户的通知投递渠道
+func (h *NotificationPreferenceHandler) UpdatePreferences(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	var req UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	preferences, err := h.notificationService.UpdateNotificationPreferences(currentUser.ID, req.Preferences)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "修改通知设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "通知设置修改成功",
+		"data":    preferences,
+	})
+}
+
+// GetDigestSetting 获取当前用户的摘要频率和发送时间
+func (h *NotificationPreferenceHandler) GetDigestSetting(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	setting, err := h.notificationService.GetDigestSetting(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取摘要设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": setting,
+	})
+}
+
+// UpdateDigestSetting 修改当前用户的摘要频率和发送时间
+func (h *NotificationPreferenceHandler) UpdateDigestSetting(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	var req services.UpdateDigestSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	setting, err := h.notificationService.UpdateDigestSetting(currentUser.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "修改摘要设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "摘要设置修改成功",
+		"data":    setting,
+	})
+}
+
+// GetDigest 获取摘要及其按课题和作业分组的通知
+func (h *NotificationPreferenceHandler) GetDigest(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+
+	digestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的摘要ID",
+		})
+		return
+	}
+
+	digest, err := h.notificationService.GetDigest(currentUser.ID, uint(digestID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "获取摘要失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": digest,
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 心跳间隔，避免代理因连接空闲而断开
+const notificationStreamHeartbeat = 25 * time.Second
+
+// NotificationStreamHandler 通知实时推送处理器（Server-Sent Events）
+type NotificationStreamHandler struct {
+	notificationService *services.NotificationService
+	notificationHub     *services.NotificationHub
+	authService         *services.AuthService
+}
+
+// NewNotificationStreamHandler 创建通知实时推送处理器
+func NewNotificationStreamHandler(notificationService *services.NotificationService, notificationHub *services.NotificationHub, authService *services.AuthService) *NotificationStreamHandler {
+	return &NotificationStreamHandler{
+		notificationService: notificationService,
+		notificationHub:     notificationHub,
+		authService:         authService,
+	}
+}
+
+// RegisterRoutes 注册通知推送路由
+func (h *NotificationStreamHandler) RegisterRoutes(router *gin.RouterGroup, permissionService *services.PermissionService) {
+	router.POST("/notifications/stream/ticket",
+		h.authService.AuthMiddleware(), permissionService.RequireAuth(),
+		h.CreateTicket) // 获取一次性连接票据
+	router.GET("/notifications/stream",
+		h.ticketAuth(), permissionService.RequireAuth(),
+		h.Stream) // 订阅我的通知变化
+}
+
+// ticketAuth 浏览器的 EventSource 无法设置请求头，使用 ticket 查询参数中的一次性票据认证，避免访问令牌出现在URL和访问日志中
+// 未带票据时按 Authorization 请求头认证
+func (h *NotificationStreamHandler) ticketAuth() gin.HandlerFunc {
+	headerAuth := h.authService.AuthMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			headerAuth(c)
+			return
+		}
+
+		user, sessionID, err := h.authService.RedeemStreamTicket(ticket)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "无效的连接票据",
+				"details": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		c.Set("current_user", user)
+		c.Set("user_id", user.ID)
+		c.Set("session_id", sessionID)
+		c.Next()
+	}
+}
+
+// CreateTicket 为当前会话签发一次性连接票据，有效期30秒
+func (h *NotificationStreamHandler) CreateTicket(c *gin.Context) {
+	ticket, err := h.authService.IssueStreamTicket(c.GetUint("user_id"), c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取连接票据失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"data": ticket,
+	})
+}
+
+// Stream 以 text/event-stream 推送当前用户的新通知、已读和删除事件，每个事件都带有最新的未读数量
+// 每次心跳时检查登录会话，会话撤销（登出）后结束推送
+func (h *NotificationStreamHandler) Stream(c *gin.Context) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return
+	}
+	currentUser := user.(*models.User)
+	sessionID := c.GetString("session_id")
+
+	count, err := h.notificationService.GetUnreadCount(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取未读数量失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// 长连接不受服务器写超时限制
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		fmt.Printf("Warning: Failed to clear write deadline for notification stream: %v\n", err)
+	}
+
+	events := h.notificationHub.Subscribe(currentUser.ID)
+	defer h.notificationHub.Unsubscribe(currentUser.ID, events)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭Nginx缓冲
+	c.SSEvent(services.NotificationEventUnreadCount, services.NotificationEvent{
+		Type:        services.NotificationEventUnreadCount,
+		UserID:      currentUser.ID,
+		UnreadCount: count,
+	})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(notificationStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			if h.authService.IsSessionRevoked(sessionID) {
+				c.SSEvent("session_revoked", gin.H{"type": "session_revoked"})
+				c.Writer.Flush()
+				return
+			}
+			c.Writer.WriteString(": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
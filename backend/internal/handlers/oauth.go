@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gitlabex/internal/middleware"
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler OAuth2授权服务处理器，第三方应用通过授权码流程（PKCE）获得代表用户调用第三方API的令牌
+type OAuthHandler struct {
+	oauthService      *services.OAuthService
+	permissionService *services.PermissionService
+	frontendURL       string
+}
+
+// NewOAuthHandler 创建OAuth2授权服务处理器，授权确认页面位于前端 /oauth/authorize
+func NewOAuthHandler(oauthService *services.OAuthService, permissionService *services.PermissionService, frontendURL string) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService:      oauthService,
+		permissionService: permissionService,
+		frontendURL:       strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// RegisterRoutes 注册OAuth2路由，授权、令牌、内省和撤销端点不使用JWT认证，按IP限流
+func (h *OAuthHandler) RegisterRoutes(router *gin.RouterGroup, authService *services.AuthService, rateLimiter *middleware.RateLimiter) {
+	public := router.Group("/oauth", rateLimiter.LimitIP(""))
+	{
+		public.GET("/scopes", h.GetScopes)            // 可申请的权限范围
+		public.GET("/authorize", h.Authorize)         // 授权端点，重定向到前端授权确认页面
+		public.POST("/token", h.Token)                // 令牌端点
+		public.POST("/introspect", h.IntrospectToken) // 令牌内省（RFC 7662）
+		public.POST("/revoke", h.RevokeToken)         // 令牌撤销（RFC 7009）
+	}
+
+	oauth := router.Group("/oauth", authService.AuthMiddleware(), h.permissionService.RequireAuth())
+	{
+		oauth.GET("/consent", h.GetConsent) // 授权确认页面信息
+		oauth.POST("/consent", h.Consent)   // 同意或拒绝授权
+
+		oauth.GET("/authorizations", h.ListAuthorizations)                // 我授权的应用
+		oauth.DELETE("/authorizations/:client_id", h.RevokeAuthorization) // 取消授权
+
+		clients := oauth.Group("/clients", h.permissionService.RequireTeacher())
+		{
+			clients.GET("", h.ListClients)              // 我注册的应用，管理员可查看全部
+			clients.POST("", h.CreateClient)            // 注册应用
+			clients.GET("/:id", h.GetClient)            // 应用详情
+			clients.PUT("/:id", h.UpdateClient)         // 修改应用
+			clients.DELETE("/:id", h.DeleteClient)      // 删除应用
+			clients.POST("/:id/secret", h.RotateSecret) // 重新生成密钥
+		}
+	}
+}
+
+// GetScopes 获取可申请的权限范围
+func (h *OAuthHandler) GetScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": services.OAuthScopes,
+	})
+}
+
+// Authorize 授权端点，参数原样转发到前端授权确认页面，由前端完成GitLab登录和授权确认
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	c.Redirect(http.StatusFound, h.frontendURL+"/oauth/authorize?"+c.Request.URL.RawQuery)
+}
+
+// GetConsent 校验授权请求并返回应用和权限范围信息，请求可以重定向回应用时返回 redirect_to
+func (h *OAuthHandler) GetConsent(c *gin.Context) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的授权请求",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	info, redirectURI, err := h.oauthService.GetConsentInfo(currentUser.ID, &req)
+	if err != nil {
+		h.consentError(c, &req, redirectURI, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": info,
+	})
+}
+
+// Consent 用户同意或拒绝授权，返回重定向回应用的地址
+func (h *OAuthHandler) Consent(c *gin.Context) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		services.AuthorizeRequest
+		Approve bool `json:"approve"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	redirectTo, err := h.oauthService.Authorize(currentUser.ID, &req.AuthorizeRequest, req.Approve)
+	if err != nil {
+		h.consentError(c, &req.AuthorizeRequest, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"redirect_to": redirectTo,
+		},
+	})
+}
+
+// Token 令牌端点，支持 authorization_code 和 refresh_token，客户端通过HTTP Basic或表单参数认证
+func (h *OAuthHandler) Token(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	var req services.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthErrorResponse(c, &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	var response *services.TokenResponse
+	var err error
+	switch req.GrantType {
+	case "authorization_code":
+		response, err = h.oauthService.ExchangeCode(client, &req)
+	case "refresh_token":
+		response, err = h.oauthService.Refresh(client, &req)
+	default:
+		err = &services.OAuthError{Code: services.OAuthErrUnsupportedGrantType, Description: "grant_type must be authorization_code or refresh_token"}
+	}
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, response)
+}
+
+// IntrospectToken 令牌内省，只能查询签发给当前客户端的令牌
+func (h *OAuthHandler) IntrospectToken(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthErrorResponse(c, &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: "token is required"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, h.oauthService.Introspect(client, token))
+}
+
+// RevokeToken 撤销令牌，令牌无效或已撤销时同样返回200
+func (h *OAuthHandler) RevokeToken(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthErrorResponse(c, &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: "token is required"})
+		return
+	}
+	if err := h.oauthService.Revoke(client, token); err != nil {
+		oauthErrorResponse(c, &services.OAuthError{Code: services.OAuthErrServerError, Description: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListAuthorizations 获取我授权的应用
+func (h *OAuthHandler) ListAuthorizations(c *gin.Context) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	consents, err := h.oauthService.ListAuthorizations(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取已授权应用失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": consents,
+	})
+}
+
+// RevokeAuthorization 取消对应用的授权，应用的令牌立即失效
+func (h *OAuthHandler) RevokeAuthorization(c *gin.Context) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.RevokeAuthorization(currentUser.ID, c.Param("client_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "取消授权失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已取消授权",
+	})
+}
+
+// ListClients 获取我注册的应用，管理员返回全部应用
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	clients, err := h.oauthService.ListClients(currentUser.ID, h.permissionService.IsAdmin(currentUser.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取应用列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": clients,
+	})
+}
+
+// CreateClient 注册应用，机密客户端的密钥只在响应中返回一次
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	client, secret, err := h.oauthService.CreateClient(currentUser.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "注册应用失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "应用注册成功，请妥善保存密钥，密钥不会再次显示",
+		"data":    oauthClientWithSecret(client, secret),
+	})
+}
+
+// GetClient 获取应用详情
+func (h *OAuthHandler) GetClient(c *gin.Context) {
+	currentUser, id, ok := h.clientParam(c)
+	if !ok {
+		return
+	}
+
+	client, err := h.oauthService.GetClient(currentUser.ID, id, h.permissionService.IsAdmin(currentUser.ID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "应用不存在",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": client,
+	})
+}
+
+// UpdateClient 修改应用，停用后应用的令牌立即失效
+func (h *OAuthHandler) UpdateClient(c *gin.Context) {
+	currentUser, id, ok := h.clientParam(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	client, err := h.oauthService.UpdateClient(currentUser.ID, id, h.permissionService.IsAdmin(currentUser.ID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "修改应用失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "应用修改成功",
+		"data":    client,
+	})
+}
+
+// DeleteClient 删除应用及其所有令牌和授权记录
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	currentUser, id, ok := h.clientParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(currentUser.ID, id, h.permissionService.IsAdmin(currentUser.ID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "删除应用失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "应用已删除",
+	})
+}
+
+// RotateSecret 重新生成机密客户端的密钥，旧密钥立即失效
+func (h *OAuthHandler) RotateSecret(c *gin.Context) {
+	currentUser, id, ok := h.clientParam(c)
+	if !ok {
+		return
+	}
+
+	client, secret, err := h.oauthService.RotateClientSecret(currentUser.ID, id, h.permissionService.IsAdmin(currentUser.ID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "重新生成密钥失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥已重新生成，请妥善保存，密钥不会再次显示",
+		"data":    oauthClientWithSecret(client, secret),
+	})
+}
+
+// authenticateClient 令牌端点的客户端认证，优先使用HTTP Basic
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, clientSecret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 2.3.1：Basic认证中的客户端ID和密钥先经过表单编码
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := h.oauthService.AuthenticateClient(clientID, clientSecret)
+	if err != nil {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthErrorResponse(c, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// consentError 授权请求错误，可以重定向回应用时同时返回带错误信息的重定向地址
+func (h *OAuthHandler) consentError(c *gin.Context, req *services.AuthorizeRequest, redirectURI string, err error) {
+	response := gin.H{
+		"error":   "无效的授权请求",
+		"details": err.Error(),
+	}
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response["error_code"] = oauthErr.Code
+	if redirectURI != "" {
+		response["redirect_to"] = services.OAuthErrorRedirect(redirectURI, req.State, oauthErr)
+	}
+	c.JSON(http.StatusBadRequest, response)
+}
+
+// clientParam 当前用户和路径中的应用ID
+func (h *OAuthHandler) clientParam(c *gin.Context) (*models.User, uint, bool) {
+	currentUser, ok := oauthCurrentUser(c)
+	if !ok {
+		return nil, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的应用ID",
+		})
+		return nil, 0, false
+	}
+	return currentUser, uint(id), true
+}
+
+// oauthErrorResponse RFC 6749 格式的错误响应
+func oauthErrorResponse(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = &services.OAuthError{Code: services.OAuthErrServerError, Description: err.Error()}
+	}
+
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case services.OAuthErrInvalidClient:
+		status = http.StatusUnauthorized
+	case services.OAuthErrServerError:
+		status = http.StatusInternalServerError
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
+
+// oauthClientWithSecret 应用信息，密钥不为空时附带明文密钥
+func oauthClientWithSecret(client *models.OAuthClient, secret string) gin.H {
+	data := gin.H{"client": client}
+	if secret != "" {
+		data["client_secret"] = secret
+	}
+	return data
+}
+
+func oauthCurrentUser(c *gin.Context) (*models.User, bool) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return nil, false
+	}
+	return user.(*models.User), true
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -437,6 +438,25 @@ func (h *ProjectHandler) GetProjectGitLabInfo(c *gin.Context) {
 	})
 }
 
+// SyncCommitStats 从GitLab重新统计课题成员的提交
+func (h *ProjectHandler) SyncCommitStats(c *gin.Context) {
+	projectID := c.GetUint("project_id")
+
+	stats, err := h.projectService.SyncProjectCommitStats(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to sync commit stats",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Commit stats synced successfully",
+		"data":    stats,
+	})
+}
+
 // SubmitAssignmentToGitLab 提交作业到GitLab
 func (h *ProjectHandler) SubmitAssignmentToGitLab(c *gin.Context) {
 	user, exists := c.Get("current_user")
@@ -465,6 +485,15 @@ func (h *ProjectHandler) SubmitAssignmentToGitLab(c *gin.Context) {
 
 	submission, err := h.projectService.SubmitAssignmentToGitLab(projectID, currentUser.ID, req.AssignmentID, req.Files)
 	if err != nil {
+		var checkErr *services.SubmissionCheckError
+		if errors.As(err, &checkErr) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Submission failed file checks",
+				"details": err.Error(),
+				"data":    checkErr.Report,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to submit assignment",
 			"details": err.Error(),
@@ -524,6 +553,10 @@ func (h *ProjectHandler) RegisterRoutes(router *gin.RouterGroup, permissionServi
 				gitlab.POST("/submit",
 					permissionService.RequireRole(models.EducationRole(config.RoleStudent)),
 					h.SubmitAssignmentToGitLab)
+
+				gitlab.POST("/sync-commits",
+					permissionService.RequireProjectAccess(config.PermissionManage), // 需要管理权限
+					h.SyncCommitStats)
 			}
 		}
 	}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerHandler 定时任务管理处理器
+type SchedulerHandler struct {
+	schedulerService *services.SchedulerService
+}
+
+// NewSchedulerHandler 创建定时任务管理处理器
+func NewSchedulerHandler(schedulerService *services.SchedulerService) *SchedulerHandler {
+	return &SchedulerHandler{
+		schedulerService: schedulerService,
+	}
+}
+
+// RegisterRoutes 注册定时任务管理路由（管理员）
+func (h *SchedulerHandler) RegisterRoutes(router *gin.RouterGroup) {
+	jobs := router.Group("/admin/jobs")
+	{
+		jobs.GET("", h.ListJobs)              // 获取定时任务列表
+		jobs.PUT("/:name", h.UpdateJob)       // 修改执行计划或启用状态
+		jobs.POST("/:name/run", h.TriggerJob) // 立即运行
+		jobs.GET("/:name/runs", h.GetJobRuns) // 获取运行记录
+	}
+}
+
+// ListJobs 获取定时任务列表
+func (h *SchedulerHandler) ListJobs(c *gin.Context) {
+	currentUser, ok := schedulerCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.schedulerService.ListJobs(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "获取定时任务失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": jobs,
+	})
+}
+
+// UpdateJob 修改定时任务
+func (h *SchedulerHandler) UpdateJob(c *gin.Context) {
+	currentUser, ok := schedulerCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateScheduledJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	job, err := h.schedulerService.UpdateJob(currentUser.ID, c.Param("name"), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "修改定时任务失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时任务修改成功",
+		"data":    job,
+	})
+}
+
+// TriggerJob 立即运行定时任务
+func (h *SchedulerHandler) TriggerJob(c *gin.Context) {
+	currentUser, ok := schedulerCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.schedulerService.TriggerJob(currentUser.ID, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "运行定时任务失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "定时任务已开始运行",
+		"data":    run,
+	})
+}
+
+// GetJobRuns 获取定时任务的运行记录
+func (h *SchedulerHandler) GetJobRuns(c *gin.Context) {
+	currentUser, ok := schedulerCurrentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	runs, err := h.schedulerService.GetJobRuns(currentUser.ID, c.Param("name"), limit)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "获取运行记录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": runs,
+	})
+}
+
+// schedulerCurrentUser 获取当前用户
+func schedulerCurrentUser(c *gin.Context) (*models.User, bool) {
+	user, exists := c.Get("current_user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "未授权访问",
+		})
+		return nil, false
+	}
+	return user.(*models.User), true
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+
+	"gitlabex/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xanzy/go-gitlab"
+)
+
+// maxWebhookPayloadSize Webhook请求体大小上限
+const maxWebhookPayloadSize = 5 << 20
+
+// WebhookHandler GitLab Webhook处理器
+type WebhookHandler struct {
+	notificationService *services.NotificationService
+	secret              string
+}
+
+// NewWebhookHandler 创建GitLab Webhook处理器
+func NewWebhookHandler(notificationService *services.NotificationService, secret string) *WebhookHandler {
+	return &WebhookHandler{
+		notificationService: notificationService,
+		secret:              secret,
+	}
+}
+
+// RegisterRoutes 注册Webhook路由（由GitLab调用，使用密钥认证而非用户登录）
+func (h *WebhookHandler) RegisterRoutes(router *gin.RouterGroup) {
+	webhooks := router.Group("/webhooks")
+	{
+		webhooks.POST("/gitlab", h.HandleGitLabWebhook) // 接收GitLab项目事件
+	}
+}
+
+// HandleGitLabWebhook 校验 X-Gitlab-Token 后解析事件，异步交给通知服务处理
+func (h *WebhookHandler) HandleGitLabWebhook(c *gin.Context) {
+	if h.secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Webhook未配置",
+		})
+		return
+	}
+
+	token := c.GetHeader("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "无效的Webhook密钥",
+		})
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookPayloadSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "读取请求数据失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	eventType := gitlab.HookEventType(c.Request)
+	switch eventType {
+	case gitlab.EventTypePush, gitlab.EventTypeMergeRequest, gitlab.EventTypeIssue,
+		gitlab.EventTypeWikiPage, gitlab.EventTypePipeline:
+	default:
+		// 不处理的事件类型直接忽略，避免GitLab因失败次数过多禁用Webhook
+		c.JSON(http.StatusOK, gin.H{
+			"message": "事件已忽略",
+		})
+		return
+	}
+
+	event, err := gitlab.ParseWebhook(eventType, payload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的事件数据",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// GitLab要求Webhook尽快响应，事件在后台处理；后台处理不在gin.Recovery保护范围内，需自行恢复panic
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("Warning: Panic while processing GitLab webhook %s: %v\n%s", eventType, r, debug.Stack())
+			}
+		}()
+		if err := h.notificationService.ProcessGitLabWebhook(event); err != nil {
+			fmt.Printf("Warning: Failed to process GitLab webhook %s: %v\n", eventType, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "事件已接收",
+	})
+}
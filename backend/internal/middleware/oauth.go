@@ -1,37 +1,39 @@
 package middleware
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
 	"gitlabex/internal/config"
 	"gitlabex/internal/models"
 	"gitlabex/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
 // OAuthMiddleware OAuth认证中间件
 type OAuthMiddleware struct {
-	config      *config.Config
-	db          *gorm.DB
-	userService *services.UserService
+	config        *config.Config
+	db            *gorm.DB
+	authService   *services.AuthService
+	userService   *services.UserService
+	apiKeyService *services.APIKeyService
+	oauthService  *services.OAuthService
+	rateLimiter   *RateLimiter
 }
 
 // NewOAuthMiddleware 创建OAuth认证中间件
-func NewOAuthMiddleware(config *config.Config, db *gorm.DB, userService *services.UserService) *OAuthMiddleware {
+func NewOAuthMiddleware(config *config.Config, db *gorm.DB, authService *services.AuthService, userService *services.UserService, apiKeyService *services.APIKeyService, oauthService *services.OAuthService, rateLimiter *RateLimiter) *OAuthMiddleware {
 	return &OAuthMiddleware{
-		config:      config,
-		db:          db,
-		userService: userService,
+		config:        config,
+		db:            db,
+		authService:   authService,
+		userService:   userService,
+		apiKeyService: apiKeyService,
+		oauthService:  oauthService,
+		rateLimiter:   rateLimiter,
 	}
 }
 
@@ -48,6 +50,18 @@ func (m *OAuthMiddleware) ThirdPartyAuth() gin.HandlerFunc {
 		// 优先尝试JWT Token认证
 		if authHeader != "" {
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+			// 第三方应用通过OAuth2授权获得的访问令牌
+			if services.IsOAuthAccessToken(tokenString) {
+				if token, tokenUser, err := m.oauthService.AuthenticateAccessToken(tokenString); err == nil {
+					c.Set("current_user", tokenUser)
+					c.Set("auth_type", "oauth")
+					c.Set("oauth_token", token)
+					c.Next()
+					return
+				}
+			}
+
 			user, err = m.validateJWTToken(tokenString)
 			if err == nil {
 				c.Set("current_user", user)
@@ -55,23 +69,30 @@ func (m *OAuthMiddleware) ThirdPartyAuth() gin.HandlerFunc {
 				c.Next()
 				return
 			}
+			// 也可以通过 Authorization: Bearer 传递API Key
+			if apiKey == "" && strings.HasPrefix(tokenString, "glx_") {
+				apiKey = tokenString
+			}
 		}
 
 		// 尝试API Key认证
 		if apiKey != "" {
-			user, err = m.validateAPIKey(apiKey)
-			if err == nil {
-				c.Set("current_user", user)
+			if key, keyUser, err := m.apiKeyService.Authenticate(apiKey, c.ClientIP()); err == nil {
+				c.Set("current_user", keyUser)
 				c.Set("auth_type", "api_key")
+				c.Set("api_key", key)
 				c.Next()
 				return
 			}
 		}
 
-		// 认证失败
+		// 认证失败的请求按IP限流，防止猜测API Key
+		if !m.rateLimiter.AllowIP(c, "third-party/unauthenticated") {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "Authentication required",
-			"message": "Please provide valid JWT token or API key",
+			"message": "Please provide valid JWT token, OAuth access token or API key",
 		})
 		c.Abort()
 	}
@@ -114,95 +135,40 @@ func (m *OAuthMiddleware) RequireRole(roles ...int) gin.HandlerFunc {
 	}
 }
 
-// RequireScope 要求特定OAuth范围
+// RequireScope 要求API Key具有权限范围，OAuth2令牌需要具有对应的OAuth2权限范围，JWT Token不受限制
 func (m *OAuthMiddleware) RequireScope(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authType, exists := c.Get("auth_type")
-		if !exists || authType != "api_key" {
-			// JWT Token默认有所有权限，API Key需要检查scope
-			c.Next()
+		authType, _ := c.Get("auth_type")
+
+		allowed := true
+		switch authType {
+		case "api_key":
+			value, _ := c.Get("api_key")
+			key, ok := value.(*models.APIKey)
+			allowed = ok && key.HasScope(scope)
+		case "oauth":
+			value, _ := c.Get("oauth_token")
+			token, ok := value.(*models.OAuthToken)
+			allowed = ok && services.OAuthAllowsAPIScope(token.Scopes, scope)
+		}
+
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "Insufficient scope",
+				"required_scope": scope,
+			})
+			c.Abort()
 			return
 		}
 
-		// 这里可以扩展API Key的scope检查逻辑
-		// 暂时允许所有API Key访问所有scope
 		c.Next()
 	}
 }
 
-// validateJWTToken 验证JWT Token
+// validateJWTToken 验证JWT Token，登录会话已撤销的令牌无效
 func (m *OAuthMiddleware) validateJWTToken(tokenString string) (*models.User, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(m.config.JWT.Secret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := uint(claims["user_id"].(float64))
-		return m.userService.GetUserByID(userID)
-	}
-
-	return nil, jwt.ErrInvalidKey
-}
-
-// validateAPIKey 验证API Key
-func (m *OAuthMiddleware) validateAPIKey(apiKey string) (*models.User, error) {
-	// API Key格式验证
-	if len(apiKey) < 32 {
-		return nil, jwt.ErrInvalidKey
-	}
-
-	// 从API Key中提取用户信息（简化实现）
-	// 实际生产环境中应该有专门的API Key管理表
-
-	// 解析API Key：user_id.timestamp.signature
-	parts := strings.Split(apiKey, ".")
-	if len(parts) != 3 {
-		return nil, jwt.ErrInvalidKey
-	}
-
-	userID, err := strconv.ParseUint(parts[0], 10, 32)
-	if err != nil {
-		return nil, err
-	}
-
-	timestamp, err := strconv.ParseInt(parts[1], 10, 64)
-	if err != nil {
-		return nil, err
-	}
-
-	// 检查时间戳（API Key 有效期7天）
-	if time.Now().Unix()-timestamp > 7*24*3600 {
-		return nil, jwt.ErrTokenExpired
-	}
-
-	// 验证签名
-	expectedSig := m.generateAPIKeySignature(uint(userID), timestamp)
-	if !hmac.Equal([]byte(parts[2]), []byte(expectedSig)) {
-		return nil, jwt.ErrSignatureInvalid
-	}
-
-	return m.userService.GetUserByID(uint(userID))
-}
-
-// GenerateAPIKey 生成API Key
-func (m *OAuthMiddleware) GenerateAPIKey(userID uint) string {
-	timestamp := time.Now().Unix()
-	signature := m.generateAPIKeySignature(userID, timestamp)
-	return strconv.FormatUint(uint64(userID), 10) + "." + strconv.FormatInt(timestamp, 10) + "." + signature
-}
-
-// generateAPIKeySignature 生成API Key签名
-func (m *OAuthMiddleware) generateAPIKeySignature(userID uint, timestamp int64) string {
-	h := hmac.New(sha256.New, []byte(m.config.JWT.Secret))
-	h.Write([]byte(strconv.FormatUint(uint64(userID), 10) + "." + strconv.FormatInt(timestamp, 10)))
-	return hex.EncodeToString(h.Sum(nil))
+	_, user, err := m.authService.ValidateAccessToken(tokenString)
+	return user, err
 }
 
 // LogAPIAccess API访问日志中间件
@@ -252,7 +218,7 @@ func (m *OAuthMiddleware) CORS() gin.HandlerFunc {
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Requested-With, X-API-Key")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -264,24 +230,9 @@ func (m *OAuthMiddleware) CORS() gin.HandlerFunc {
 	}
 }
 
-// RateLimit 限流中间件（简化实现）
+// RateLimit 限流中间件，按API Key或用户分别计数，每个路由组使用独立的令牌桶
 func (m *OAuthMiddleware) RateLimit() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 简化的限流实现
-		// 生产环境中应该使用Redis等缓存系统
-
-		// 获取客户端IP或用户ID
-		clientID := c.ClientIP()
-		if user, exists := c.Get("current_user"); exists {
-			currentUser := user.(*models.User)
-			clientID = "user_" + strconv.FormatUint(uint64(currentUser.ID), 10)
-		}
-
-		// 这里应该实现真正的限流逻辑
-		// 暂时允许所有请求通过
-		c.Set("client_id", clientID)
-		c.Next()
-	}
+	return m.rateLimiter.LimitClient("")
 }
 
 // isOAuthBypassEnabled 检查是否启用OAuth绕过（临时测试功能）
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlabex/internal/config"
+	"gitlabex/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	rateLimitKeyPrefix     = "gitlabex:ratelimit:"
+	rateLimitSweepInterval = time.Minute           // 清理内存中已补满的令牌桶的间隔
+	rateLimitRedisTimeout  = 50 * time.Millisecond // Redis超时后退回本实例内存限流
+)
+
+// rateLimitStore 令牌桶存储，take 取出一个令牌并返回取出后剩余的令牌数
+type rateLimitStore interface {
+	take(key string, rule config.RateLimitRule, now time.Time) (allowed bool, tokens float64, err error)
+}
+
+// RateLimiter 令牌桶限流器，按API Key、用户或IP以及路由组分别计数
+type RateLimiter struct {
+	config config.RateLimitConfig
+	store  rateLimitStore
+}
+
+// NewRateLimiter 创建限流器，Backend 为 redis 但Redis不可用时使用本实例内存
+func NewRateLimiter(cfg config.RateLimitConfig, redisClient *redis.Client) *RateLimiter {
+	memory := newMemoryRateLimitStore()
+	limiter := &RateLimiter{config: cfg, store: memory}
+
+	switch cfg.Backend {
+	case "redis":
+		if redisClient == nil {
+			fmt.Printf("Warning: Redis is unavailable, rate limits are counted per instance\n")
+		} else {
+			limiter.store = &redisRateLimitStore{client: redisClient, fallback: memory}
+		}
+	case "memory", "":
+	default:
+		fmt.Printf("Warning: Unknown rate limit backend %q, using memory\n", cfg.Backend)
+	}
+	return limiter
+}
+
+// LimitIP 按客户端IP限流，group 为空时按路由路径的前两级分组
+func (l *RateLimiter) LimitIP(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.AllowIP(c, group) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitClient 按API Key、OAuth2应用或用户限流，未认证的请求按IP限流，需要在认证中间件之后使用
+func (l *RateLimiter) LimitClient(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, rule := l.clientIdentity(c)
+		c.Set("client_id", identity)
+		if !l.allow(c, group, identity, rule) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// AllowIP 按客户端IP消耗一个令牌，超出限额时返回429并中止请求
+func (l *RateLimiter) AllowIP(c *gin.Context, group string) bool {
+	return l.allow(c, group, "ip:"+c.ClientIP(), l.config.IP)
+}
+
+// clientIdentity 当前请求的限流对象及规则
+func (l *RateLimiter) clientIdentity(c *gin.Context) (string, config.RateLimitRule) {
+	if value, exists := c.Get("api_key"); exists {
+		if key, ok := value.(*models.APIKey); ok && key != nil {
+			return "key:" + strconv.FormatUint(uint64(key.ID), 10), l.apiKeyRule(key)
+		}
+	}
+	// OAuth2令牌按应用和用户计数，与API Key使用相同的规则
+	if value, exists := c.Get("oauth_token"); exists {
+		if token, ok := value.(*models.OAuthToken); ok && token != nil {
+			return fmt.Sprintf("oauth:%d:%d", token.ClientID, token.UserID), l.config.APIKey
+		}
+	}
+	if value, exists := c.Get("current_user"); exists {
+		if user, ok := value.(*models.User); ok && user != nil {
+			return "user:" + strconv.FormatUint(uint64(user.ID), 10), l.config.User
+		}
+	}
+	return "ip:" + c.ClientIP(), l.config.IP
+}
+
+// apiKeyRule API Key的限流规则，密钥设置了每分钟请求数时覆盖默认值，突发数按默认规则的比例缩放
+func (l *RateLimiter) apiKeyRule(key *models.APIKey) config.RateLimitRule {
+	if key.RateLimitPerMinute <= 0 {
+		return l.config.APIKey
+	}
+
+	rule := config.RateLimitRule{PerMinute: key.RateLimitPerMinute}
+	if base := normalizeRateLimitRule(l.config.APIKey); base.PerMinute > 0 {
+		rule.Burst = int(math.Max(1, math.Round(float64(rule.PerMinute)*float64(base.Burst)/float64(base.PerMinute))))
+	}
+	return rule
+}
+
+// allow 消耗一个令牌并设置 RateLimit-* 响应头，超出限额时返回429
+func (l *RateLimiter) allow(c *gin.Context, group, identity string, rule config.RateLimitRule) bool {
+	if !l.config.Enabled || rule.PerMinute <= 0 {
+		return true
+	}
+	rule = normalizeRateLimitRule(rule)
+	if group == "" {
+		group = rateLimitGroup(c.FullPath())
+	}
+
+	allowed, tokens, err := l.store.take(rateLimitKeyPrefix+group+":"+identity, rule, time.Now())
+	if err != nil {
+		fmt.Printf("Warning: Rate limit check failed for %s: %v\n", identity, err)
+		return true
+	}
+
+	rate := float64(rule.PerMinute) / 60
+	reset := math.Ceil((float64(rule.Burst) - tokens) / rate)
+	c.Header("RateLimit-Limit", strconv.Itoa(rule.Burst))
+	c.Header("RateLimit-Remaining", strconv.Itoa(int(math.Floor(tokens))))
+	c.Header("RateLimit-Reset", strconv.Itoa(int(reset)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=60;burst=%d", rule.PerMinute, rule.Burst))
+
+	if allowed {
+		return true
+	}
+
+	retryAfter := int(math.Max(1, math.Ceil((1-tokens)/rate)))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Rate limit exceeded",
+		"message":     fmt.Sprintf("Too many requests, retry after %d seconds", retryAfter),
+		"retry_after": retryAfter,
+	})
+	c.Abort()
+	return false
+}
+
+// normalizeRateLimitRule 未配置突发数时等于每分钟请求数
+func normalizeRateLimitRule(rule config.RateLimitRule) config.RateLimitRule {
+	if rule.Burst <= 0 {
+		rule.Burst = rule.PerMinute
+	}
+	return rule
+}
+
+// rateLimitGroup 路由组名称，如 /api/third-party/repos/:id 为 third-party/repos
+func rateLimitGroup(fullPath string) string {
+	parts := strings.Split(strings.TrimPrefix(fullPath, "/api/"), "/")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	group := strings.Trim(strings.Join(parts, "/"), "/")
+	if group == "" {
+		return "default"
+	}
+	return group
+}
+
+// ===== 内存存储 =====
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// memoryRateLimitStore 本实例内存中的令牌桶
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	fullAt    map[string]time.Time // 令牌桶补满的时间，之后可以删除
+	lastSweep time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		fullAt:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memoryRateLimitStore) take(key string, rule config.RateLimitRule, now time.Time) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		for k, full := range s.fullAt {
+			if now.After(full) {
+				delete(s.buckets, k)
+				delete(s.fullAt, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	rate := float64(rule.PerMinute) / 60
+	burst := float64(rule.Burst)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		s.buckets[key] = bucket
+	}
+	if elapsed := now.Sub(bucket.updated).Seconds(); elapsed > 0 {
+		bucket.tokens = math.Min(burst, bucket.tokens+elapsed*rate)
+		bucket.updated = now
+	}
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	s.fullAt[key] = now.Add(time.Duration((burst - bucket.tokens) / rate * float64(time.Second)))
+	return allowed, bucket.tokens, nil
+}
+
+// ===== Redis存储 =====
+
+// rateLimitScript 在Redis中原子地补充并取出令牌，时间单位为毫秒
+var rateLimitScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate)
+  ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// redisRateLimitStore 多实例共享的令牌桶，Redis出错时退回本实例内存
+type redisRateLimitStore struct {
+	client   *redis.Client
+	fallback *memoryRateLimitStore
+
+	mu         sync.Mutex
+	lastWarnAt time.Time
+}
+
+func (s *redisRateLimitStore) take(key string, rule config.RateLimitRule, now time.Time) (bool, float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rateLimitRedisTimeout)
+	defer cancel()
+
+	ratePerMs := float64(rule.PerMinute) / 60000
+	result, err := rateLimitScript.Run(ctx, s.client, []string{key},
+		strconv.FormatFloat(ratePerMs, 'f', -1, 64), rule.Burst, now.UnixMilli()).Slice()
+	if err == nil {
+		if len(result) == 2 {
+			allowed, _ := result[0].(int64)
+			tokensStr, _ := result[1].(string)
+			if tokens, parseErr := strconv.ParseFloat(tokensStr, 64); parseErr == nil {
+				return allowed == 1, tokens, nil
+			}
+		}
+		err = fmt.Errorf("unexpected rate limit script result: %v", result)
+	}
+
+	s.warn(err)
+	return s.fallback.take(key, rule, now)
+}
+
+// warn 每分钟最多输出一次Redis错误
+func (s *redisRateLimitStore) warn(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.lastWarnAt) < time.Minute {
+		return
+	}
+	s.lastWarnAt = time.Now()
+	fmt.Printf("Warning: Redis rate limit failed, counting per instance: %v\n", err)
+}
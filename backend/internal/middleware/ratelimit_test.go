@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlabex/internal/config"
+	"gitlabex/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitRouter 使用内存令牌桶按IP限流的路由
+func newRateLimitRouter(rule config.RateLimitRule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	limiter := NewRateLimiter(config.RateLimitConfig{
+		Enabled: true,
+		Backend: "memory",
+		IP:      rule,
+	}, nil)
+
+	router := gin.New()
+	api := router.Group("/api", limiter.LimitIP(""))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/third-party/repos", ok)
+	api.GET("/third-party/repos/:id", ok)
+	api.GET("/third-party/users", ok)
+	return router
+}
+
+func doRateLimitRequest(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiterDrainsBucket(t *testing.T) {
+	router := newRateLimitRouter(config.RateLimitRule{PerMinute: 60, Burst: 3})
+
+	// 突发容量内的请求都通过，剩余数逐个减少
+	for i, wantRemaining := range []string{"2", "1", "0"} {
+		w := doRateLimitRequest(router, "/api/third-party/repos")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, w.Code)
+		}
+		headers := map[string]string{
+			"RateLimit-Limit":     "3",
+			"RateLimit-Remaining": wantRemaining,
+			"RateLimit-Policy":    "60;w=60;burst=3",
+		}
+		for name, want := range headers {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("request %d %s = %q, want %q", i+1, name, got, want)
+			}
+		}
+		if got := w.Header().Get("Retry-After"); got != "" {
+			t.Errorf("request %d Retry-After = %q, want empty", i+1, got)
+		}
+	}
+
+	w := doRateLimitRequest(router, "/api/third-party/repos")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	// 每秒补充一个令牌
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "3" {
+		t.Errorf("RateLimit-Reset = %q, want 3", got)
+	}
+
+	var body struct {
+		Error      string `json:"error"`
+		Message    string `json:"message"`
+		RetryAfter int    `json:"retry_after"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body %q: %v", w.Body.String(), err)
+	}
+	if body.Error != "Rate limit exceeded" || body.RetryAfter != 1 ||
+		body.Message != "Too many requests, retry after 1 seconds" {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestRateLimiterSeparateGroups(t *testing.T) {
+	router := newRateLimitRouter(config.RateLimitRule{PerMinute: 60, Burst: 1})
+
+	if w := doRateLimitRequest(router, "/api/third-party/repos"); w.Code != http.StatusOK {
+		t.Fatalf("first repos request status = %d, want 200", w.Code)
+	}
+	// 同一路由组下的不同路径共用令牌桶
+	if w := doRateLimitRequest(router, "/api/third-party/repos/42"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("repos/:id request status = %d, want 429", w.Code)
+	}
+	// 其他路由组单独计数
+	if w := doRateLimitRequest(router, "/api/third-party/users"); w.Code != http.StatusOK {
+		t.Errorf("users request status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimiterAPIKeyOverride(t *testing.T) {
+	tests := []struct {
+		name       string
+		perMinute  int // 密钥的每分钟请求数
+		wantLimit  string
+		wantPolicy string
+	}{
+		{name: "default rule", perMinute: 0, wantLimit: "10", wantPolicy: "60;w=60;burst=10"},
+		{name: "raised limit", perMinute: 600, wantLimit: "100", wantPolicy: "600;w=60;burst=100"},
+		{name: "lowered limit keeps at least one burst", perMinute: 1, wantLimit: "1", wantPolicy: "1;w=60;burst=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			limiter := NewRateLimiter(config.RateLimitConfig{
+				Enabled: true,
+				Backend: "memory",
+				APIKey:  config.RateLimitRule{PerMinute: 60, Burst: 10},
+			}, nil)
+
+			router := gin.New()
+			key := &models.APIKey{ID: 7, RateLimitPerMinute: tt.perMinute}
+			router.GET("/api/third-party/repos", func(c *gin.Context) {
+				c.Set("api_key", key)
+			}, limiter.LimitClient(""), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := doRateLimitRequest(router, "/api/third-party/repos")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("RateLimit-Limit"); got != tt.wantLimit {
+				t.Errorf("RateLimit-Limit = %q, want %q", got, tt.wantLimit)
+			}
+			if got := w.Header().Get("RateLimit-Policy"); got != tt.wantPolicy {
+				t.Errorf("RateLimit-Policy = %q, want %q", got, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.RateLimitConfig
+	}{
+		{name: "disabled", cfg: config.RateLimitConfig{Enabled: false, IP: config.RateLimitRule{PerMinute: 1, Burst: 1}}},
+		{name: "zero per minute", cfg: config.RateLimitConfig{Enabled: true, IP: config.RateLimitRule{PerMinute: 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/api/auth/gitlab", NewRateLimiter(tt.cfg, nil).LimitIP(""), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			for i := 0; i < 5; i++ {
+				w := doRateLimitRequest(router, "/api/auth/gitlab")
+				if w.Code != http.StatusOK {
+					t.Fatalf("request %d status = %d, want 200", i+1, w.Code)
+				}
+				if got := w.Header().Get("RateLimit-Limit"); got != "" {
+					t.Errorf("RateLimit-Limit = %q, want empty", got)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreRefill(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	rule := config.RateLimitRule{PerMinute: 60, Burst: 2}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := store.take("k", rule, now); !allowed {
+			t.Fatalf("take %d denied, want allowed", i+1)
+		}
+	}
+	if allowed, _, _ := store.take("k", rule, now); allowed {
+		t.Fatalf("take on empty bucket allowed, want denied")
+	}
+
+	// 一秒后补充一个令牌
+	if allowed, tokens, _ := store.take("k", rule, now.Add(time.Second)); !allowed || tokens != 0 {
+		t.Errorf("take after 1s = %v, %v, want true, 0", allowed, tokens)
+	}
+	// 令牌数不超过突发容量
+	if _, tokens, _ := store.take("k", rule, now.Add(time.Hour)); tokens != 1 {
+		t.Errorf("tokens after 1h = %v, want 1", tokens)
+	}
+}
+
+func TestRateLimitGroup(t *testing.T) {
+	tests := map[string]string{
+		"/api/third-party/repos/:id/files/*filepath": "third-party/repos",
+		"/api/third-party/users":                     "third-party/users",
+		"/api/auth/gitlab":                           "auth/gitlab",
+		"/api/health":                                "health",
+		"":                                           "default",
+	}
+	for path, want := range tests {
+		if got := rateLimitGroup(path); got != want {
+			t.Errorf("rateLimitGroup(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
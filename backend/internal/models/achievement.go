@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// Achievement 成就徽章定义
+type Achievement struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Code        string    `gorm:"not null;uniqueIndex" json:"code"` // 唯一标识，如 first_merge_request
+	Title       string    `gorm:"not null" json:"title"`
+	Description string    `json:"description"`
+	Icon        string    `json:"icon"`
+	RuleType    string    `gorm:"not null;index" json:"rule_type"`     // 规则类型
+	Threshold   int       `gorm:"not null;default:1" json:"threshold"` // 达成阈值
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	SortOrder   int       `gorm:"default:0" json:"sort_order"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Achievement) TableName() string {
+	return "achievements"
+}
+
+// UserAchievement 用户获得的成就
+type UserAchievement struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_user_achievement" json:"user_id"`
+	AchievementID uint      `gorm:"not null;uniqueIndex:idx_user_achievement" json:"achievement_id"`
+	Progress      int       `json:"progress"` // 获得时的规则统计值
+	AwardedAt     time.Time `json:"awarded_at"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// 关联关系
+	Achievement Achievement `gorm:"foreignKey:AchievementID" json:"achievement,omitempty"`
+}
+
+// TableName 指定表名
+func (UserAchievement) TableName() string {
+	return "user_achievements"
+}
+
+// 成就规则类型
+const (
+	AchievementRuleMergedMergeRequests = "merged_merge_requests" // 合并请求被合并次数
+	AchievementRuleOnTimeStreak        = "on_time_streak"        // 连续按时提交作业次数
+	AchievementRuleDiscussionAnswers   = "discussion_answers"    // 回答他人提问的话题数
+	AchievementRuleCommits             = "commits"               // 代码提交次数
+	AchievementRulePerfectScores       = "perfect_scores"        // 作业满分次数
+)
+
+// AchievementRuleTypes 所有支持的成就规则类型
+var AchievementRuleTypes = []string{
+	AchievementRuleMergedMergeRequests,
+	AchievementRuleOnTimeStreak,
+	AchievementRuleDiscussionAnswers,
+	AchievementRuleCommits,
+	AchievementRulePerfectScores,
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 第三方API的权限范围，write 范围包含同一资源的 read 范围
+const (
+	APIScopeReposRead        = "repos:read"
+	APIScopeReposWrite       = "repos:write"
+	APIScopeUsersRead        = "users:read"
+	APIScopeUsersWrite       = "users:write"
+	APIScopeAssignmentsRead  = "assignments:read"
+	APIScopeAssignmentsWrite = "assignments:write"
+	APIScopePermissionsRead  = "permissions:read"
+	APIScopeStatusRead       = "status:read"
+	APIScopeWebhooks         = "webhooks"
+	APIScopeAPIKeys          = "api_keys"
+)
+
+// APIKey 第三方API密钥，只保存密钥的SHA-256哈希
+type APIKey struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	UserID             uint       `gorm:"not null;index" json:"user_id"`
+	Name               string     `gorm:"not null" json:"name"`
+	Prefix             string     `gorm:"not null" json:"prefix"`                          // 密钥开头的几位，用于辨认密钥
+	KeyHash            string     `gorm:"not null;uniqueIndex" json:"-"`                   // 密钥的SHA-256哈希
+	Scopes             []string   `gorm:"serializer:json;type:text" json:"scopes"`         // 权限范围
+	ExpiresAt          *time.Time `json:"expires_at"`                                      // 为空时不过期
+	RateLimitPerMinute int        `gorm:"not null;default:0" json:"rate_limit_per_minute"` // 每分钟请求数，为0时使用默认限额
+	LastUsedAt         *time.Time `json:"last_used_at"`
+	LastUsedIP         string     `json:"last_used_ip"`
+	RevokedAt          *time.Time `gorm:"index" json:"revoked_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsActive 密钥未撤销且未过期
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// HasScope 密钥是否具有权限范围，write 范围包含同一资源的 read 范围
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+		if resource, ok := strings.CutSuffix(scope, ":read"); ok && s == resource+":write" {
+			return true
+		}
+	}
+	return false
+}
@@ -27,10 +27,24 @@ type Assignment struct {
 	MaxFileSize       int64    `gorm:"default:10485760" json:"max_file_size"`     // 最大文件大小（字节）
 	AllowedFileTypes  []string `gorm:"serializer:json" json:"allowed_file_types"` // 允许的文件类型
 
+	// 提交策略
+	MaxAttempts     int  `gorm:"default:0" json:"max_attempts"`          // 最大提交次数，0表示不限
+	StrictFileCheck bool `gorm:"default:false" json:"strict_file_check"` // 文件检查不通过时拒绝提交，否则仅标记
+
+	// 迟交策略
+	GracePeriodMinutes  int        `gorm:"default:0" json:"grace_period_minutes"`      // 宽限期（分钟），宽限期内提交不算迟交
+	LateUntil           *time.Time `json:"late_until"`                                 // 最晚可提交时间，为空表示不接受迟交
+	LatePenaltyPercent  float64    `gorm:"default:0" json:"late_penalty_percent"`      // 每个扣分周期扣除的得分百分比
+	LatePenaltyInterval string     `gorm:"default:'day'" json:"late_penalty_interval"` // 扣分周期：hour, day
+
+	// 截止提醒，截止前多少小时提醒未提交的学生，为空时使用默认值
+	ReminderOffsets []int `gorm:"serializer:json" json:"reminder_offsets"`
+
 	// 关联关系
 	Project     Project                `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 	Teacher     User                   `gorm:"foreignKey:TeacherID" json:"teacher,omitempty"`
 	Submissions []AssignmentSubmission `gorm:"foreignKey:AssignmentID" json:"submissions,omitempty"`
+	Rubric      *Rubric                `gorm:"foreignKey:AssignmentID" json:"rubric,omitempty"`
 }
 
 // TableName 指定表名
@@ -66,6 +80,22 @@ type AssignmentSubmission struct {
 	CodeReviewStatus string   `gorm:"default:'pending'" json:"code_review_status"` // pending, approved, rejected
 	AutoCheckPassed  bool     `gorm:"default:false" json:"auto_check_passed"`      // 自动检查是否通过
 	AutoCheckResults string   `json:"auto_check_results"`                          // 自动检查结果详情
+	AutogradeScore   *float64 `json:"autograde_score"`                             // 自动评测建议得分
+
+	// CI流水线
+	PipelineID     int    `gorm:"default:0" json:"pipeline_id"` // 提交版本对应的GitLab流水线ID
+	PipelineStatus string `gorm:"index" json:"pipeline_status"` // waiting, none 或GitLab流水线状态
+	PipelineURL    string `json:"pipeline_url"`                 // 流水线URL
+
+	// 多次提交
+	AttemptNumber int  `gorm:"default:1" json:"attempt_number"` // 第几次提交
+	IsSelected    bool `gorm:"default:true" json:"is_selected"` // 是否为用于评分的提交
+
+	// 迟交信息
+	IsLate             bool    `gorm:"default:false" json:"is_late"`          // 是否迟交
+	LateMinutes        int     `gorm:"default:0" json:"late_minutes"`         // 超过截止时间的分钟数
+	LatePenaltyPercent float64 `gorm:"default:0" json:"late_penalty_percent"` // 评分时扣除的得分百分比
+	RawScore           float64 `json:"raw_score"`                             // 扣除迟交惩罚前的得分
 
 	// 关联关系
 	Assignment Assignment `gorm:"foreignKey:AssignmentID" json:"assignment,omitempty"`
@@ -73,11 +103,37 @@ type AssignmentSubmission struct {
 	Reviews    []Review   `gorm:"foreignKey:SubmissionID" json:"reviews,omitempty"`
 }
 
+// 提交的CI流水线状态，除以下两个状态外与GitLab流水线状态一致
+const (
+	PipelineStatusWaiting = "waiting" // 等待GitLab创建流水线
+	PipelineStatusNone    = "none"    // 提交版本没有流水线
+)
+
 // TableName 指定表名
 func (AssignmentSubmission) TableName() string {
 	return "assignment_submissions"
 }
 
+// AssignmentExtension 学生作业延期
+type AssignmentExtension struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	AssignmentID uint      `gorm:"not null;uniqueIndex:idx_assignment_extension" json:"assignment_id"`
+	StudentID    uint      `gorm:"not null;uniqueIndex:idx_assignment_extension" json:"student_id"`
+	DueDate      time.Time `gorm:"not null" json:"due_date"` // 延期后的截止时间
+	Reason       string    `json:"reason"`
+	GrantedBy    uint      `gorm:"not null" json:"granted_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// 关联关系
+	Student User `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+}
+
+// TableName 指定表名
+func (AssignmentExtension) TableName() string {
+	return "assignment_extensions"
+}
+
 // Review 评审记录
 type Review struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
@@ -110,8 +166,9 @@ type Review struct {
 	ApprovalsReceived int    `json:"approvals_received"` // 收到的批准数量
 
 	// 关联关系
-	Submission AssignmentSubmission `gorm:"foreignKey:SubmissionID" json:"submission,omitempty"`
-	Reviewer   User                 `gorm:"foreignKey:ReviewerID" json:"reviewer,omitempty"`
+	Submission      AssignmentSubmission   `gorm:"foreignKey:SubmissionID" json:"submission,omitempty"`
+	Reviewer        User                   `gorm:"foreignKey:ReviewerID" json:"reviewer,omitempty"`
+	CriterionScores []ReviewCriterionScore `gorm:"foreignKey:ReviewID" json:"criterion_scores,omitempty"`
 }
 
 // TableName 指定表名
@@ -125,4 +182,7 @@ type AssignmentStats struct {
 	ReviewedSubmissions int     `json:"reviewed_submissions"`
 	PendingSubmissions  int     `json:"pending_submissions"`
 	AverageScore        float64 `json:"average_score"`
+
+	// 评分标准各维度的平均得分（仅在作业配置了评分标准时返回）
+	CriterionAverages []CriterionAverage `json:"criterion_averages,omitempty"`
 }
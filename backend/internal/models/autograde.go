@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// 自动评测运行方式
+const (
+	AutogradeRunnerDocker = "docker" // 在容器中运行
+	AutogradeRunnerLocal  = "local"  // 在受限的本机进程中运行
+)
+
+// 自动评测状态
+const (
+	AutogradeStatusPending   = "pending"
+	AutogradeStatusRunning   = "running"
+	AutogradeStatusCompleted = "completed"
+	AutogradeStatusFailed    = "failed" // 评测无法执行，如检出失败、环境错误
+)
+
+// AutogradeTestCase 评测用例
+type AutogradeTestCase struct {
+	Name    string  `json:"name"`
+	Command string  `json:"command"` // 在代码根目录执行，退出码为0视为通过
+	Points  float64 `json:"points"`
+}
+
+// AutogradeSuite 作业的自动评测配置（老师提供的测试包）
+type AutogradeSuite struct {
+	ID             uint                `gorm:"primaryKey" json:"id"`
+	AssignmentID   uint                `gorm:"not null;uniqueIndex" json:"assignment_id"`
+	Runner         string              `gorm:"not null;default:'docker'" json:"runner"` // docker, local
+	Image          string              `json:"image"`                                   // 容器镜像，docker 方式必填
+	SetupCommand   string              `json:"setup_command"`                           // 运行测试前执行的准备命令
+	Tests          []AutogradeTestCase `gorm:"serializer:json" json:"tests"`
+	Files          map[string]string   `gorm:"serializer:json" json:"files"`      // 测试文件，检出后写入代码目录
+	TimeoutSeconds int                 `gorm:"default:60" json:"timeout_seconds"` // 每个命令的超时时间
+	MemoryMB       int                 `gorm:"default:512" json:"memory_mb"`      // 内存限制
+	CPUs           float64             `gorm:"default:1" json:"cpus"`             // CPU限制
+	IsActive       bool                `gorm:"default:true" json:"is_active"`     // 是否在提交时自动评测
+	CreatedBy      uint                `gorm:"not null" json:"created_by"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AutogradeSuite) TableName() string {
+	return "autograde_suites"
+}
+
+// AutogradeTestResult 单个用例的评测结果
+type AutogradeTestResult struct {
+	Name         string  `json:"name"`
+	Passed       bool    `json:"passed"`
+	Points       float64 `json:"points"`
+	EarnedPoints float64 `json:"earned_points"`
+	ExitCode     int     `json:"exit_code"`
+	TimedOut     bool    `json:"timed_out"`
+	Stdout       string  `json:"stdout"`
+	Stderr       string  `json:"stderr"`
+	DurationMs   int64   `json:"duration_ms"`
+}
+
+// AutogradeRun 一次自动评测
+type AutogradeRun struct {
+	ID             uint                  `gorm:"primaryKey" json:"id"`
+	SubmissionID   uint                  `gorm:"not null;index" json:"submission_id"`
+	SuiteID        uint                  `gorm:"not null" json:"suite_id"`
+	Status         string                `gorm:"not null;default:'pending'" json:"status"`
+	CommitHash     string                `json:"commit_hash"`
+	Score          float64               `json:"score"`           // 获得的测试分
+	MaxScore       float64               `json:"max_score"`       // 测试总分
+	SuggestedScore float64               `json:"suggested_score"` // 按作业满分换算的建议得分
+	Results        []AutogradeTestResult `gorm:"serializer:json" json:"results"`
+	SetupOutput    string                `json:"setup_output"`
+	Error          string                `json:"error"`
+	TriggeredBy    uint                  `json:"triggered_by"` // 手动重新评测的用户，提交时自动评测为0
+	StartedAt      *time.Time            `json:"started_at"`
+	FinishedAt     *time.Time            `json:"finished_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AutogradeRun) TableName() string {
+	return "autograde_runs"
+}
@@ -15,6 +15,11 @@ type Notification struct {
 	TargetID   uint       `json:"target_id"`                 // 目标ID
 	Read       bool       `gorm:"default:false" json:"read"` // 是否已读
 	ReadAt     *time.Time `json:"read_at"`                   // 读取时间
+
+	// 摘要模式：Digest 为true的通知不在通知列表中单独显示，汇总到摘要后记录所属摘要
+	Digest     bool       `gorm:"default:false;index" json:"digest"`
+	DigestID   *uint      `gorm:"index" json:"digest_id"`
+	DigestedAt *time.Time `json:"digested_at"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
 
@@ -27,6 +32,28 @@ func (Notification) TableName() string {
 	return "notifications"
 }
 
+// 作业提醒的接收人
+const (
+	ReminderRecipientStudent = "student" // 提醒未提交的学生
+	ReminderRecipientTeacher = "teacher" // 向老师汇总未提交的学生
+)
+
+// AssignmentReminder 已发送的作业截止提醒，每个作业、用户和提醒时间点只发送一次
+type AssignmentReminder struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	AssignmentID uint      `gorm:"not null;uniqueIndex:idx_assignment_reminder" json:"assignment_id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_assignment_reminder" json:"user_id"`
+	OffsetHours  int       `gorm:"not null;uniqueIndex:idx_assignment_reminder" json:"offset_hours"` // 截止前多少小时的提醒
+	Recipient    string    `gorm:"not null;uniqueIndex:idx_assignment_reminder" json:"recipient"`    // student, teacher
+	DueDate      time.Time `json:"due_date"`                                                         // 发送时该用户的截止时间
+	SentAt       time.Time `json:"sent_at"`
+}
+
+// TableName 指定表名
+func (AssignmentReminder) TableName() string {
+	return "assignment_reminders"
+}
+
 // NotificationTypes 通知类型常量
 const (
 	NotificationTypeAssignmentSubmitted = "assignment_submitted"
@@ -35,15 +62,18 @@ const (
 	NotificationTypeClassJoined         = "class_joined"
 	NotificationTypeAssignmentCreated   = "assignment_created"
 	NotificationTypeProjectCreated      = "project_created"
+	NotificationTypeAchievementEarned   = "achievement_earned"
 
 	// GitLab 集成相关通知类型
-	NotificationTypeGitLabCommit   = "gitlab_commit"
-	NotificationTypeMergeRequest   = "merge_request"
-	NotificationTypeIssueCreated   = "issue_created"
-	NotificationTypeWikiCreated    = "wiki_created"
-	NotificationTypeAssignmentDue  = "assignment_due"
-	NotificationTypeCodeReview     = "code_review"
-	NotificationTypeGitLabActivity = "gitlab_activity"
+	NotificationTypeGitLabCommit          = "gitlab_commit"
+	NotificationTypeMergeRequest          = "merge_request"
+	NotificationTypeIssueCreated          = "issue_created"
+	NotificationTypeWikiCreated           = "wiki_created"
+	NotificationTypeAssignmentDue         = "assignment_due"
+	NotificationTypeAssignmentUnsubmitted = "assignment_unsubmitted"
+	NotificationTypeDigest                = "notification_digest"
+	NotificationTypeCodeReview            = "code_review"
+	NotificationTypeGitLabActivity        = "gitlab_activity"
 )
 
 // MarkAsRead 标记通知为已读
@@ -52,3 +82,96 @@ func (n *Notification) MarkAsRead() {
 	now := time.Now()
 	n.ReadAt = &now
 }
+
+// 通知投递渠道
+const (
+	NotificationChannelInApp  = "in_app" // 仅站内通知
+	NotificationChannelEmail  = "email"  // 站内通知并立即发送邮件
+	NotificationChannelDigest = "digest" // 站内通知并汇总到摘要邮件
+	NotificationChannelOff    = "off"    // 不接收
+)
+
+// NotificationPreference 用户对某类通知的投递渠道设置，未设置的类型使用默认渠道
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_notification_preference" json:"user_id"`
+	Type      string    `gorm:"not null;uniqueIndex:idx_notification_preference" json:"type"`
+	Channel   string    `gorm:"not null" json:"channel"` // in_app, email, digest, off
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// 邮件发送状态
+const (
+	EmailStatusPending = "pending" // 等待发送或重试
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed" // 超过最大尝试次数
+)
+
+// EmailOutbox 待发送的邮件，发送失败时按退避时间重试
+type EmailOutbox struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	NotificationID uint       `gorm:"index" json:"notification_id"`
+	UserID         uint       `gorm:"index" json:"user_id"`
+	To             string     `gorm:"not null" json:"to"`
+	Subject        string     `gorm:"not null" json:"subject"`
+	TextBody       string     `gorm:"type:text" json:"text_body"`
+	HTMLBody       string     `gorm:"type:text" json:"html_body"`
+	Status         string     `gorm:"not null;index;default:'pending'" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"` // 下次可发送时间，发送中时为租约到期时间
+	LastError      string     `json:"last_error"`
+	SentAt         *time.Time `json:"sent_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (EmailOutbox) TableName() string {
+	return "email_outbox"
+}
+
+// 摘要频率
+const (
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+)
+
+// NotificationDigestSetting 用户的通知摘要设置，未设置时每天8点发送
+type NotificationDigestSetting struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	Frequency    string     `gorm:"not null;default:'daily'" json:"frequency"` // daily, weekly
+	Hour         int        `json:"hour"`                                      // 发送时间（0-23时）
+	Weekday      int        `json:"weekday"`                                   // 每周发送时的星期（0为周日）
+	LastDigestAt *time.Time `json:"last_digest_at"`                            // 最近一次发送摘要的时间
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (NotificationDigestSetting) TableName() string {
+	return "notification_digest_settings"
+}
+
+// NotificationDigest 通知摘要，汇总一段时间内摘要模式的通知
+type NotificationDigest struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"not null;index" json:"user_id"`
+	Frequency      string    `json:"frequency"`
+	PeriodStart    time.Time `json:"period_start"` // 汇总的第一条通知的时间
+	PeriodEnd      time.Time `json:"period_end"`   // 生成摘要的时间
+	ItemCount      int       `json:"item_count"`
+	NotificationID uint      `json:"notification_id"` // 摘要本身的站内通知
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (NotificationDigest) TableName() string {
+	return "notification_digests"
+}
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// OAuthClient 注册的OAuth2客户端（第三方应用）
+type OAuthClient struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ClientID     string    `gorm:"not null;uniqueIndex" json:"client_id"`
+	SecretHash   string    `json:"-"` // 机密客户端的密钥哈希，公开客户端为空
+	Name         string    `gorm:"not null" json:"name"`
+	Description  string    `json:"description"`
+	HomepageURL  string    `json:"homepage_url"`
+	RedirectURIs []string  `gorm:"serializer:json;type:text" json:"redirect_uris"` // 回调地址需要完全匹配
+	Scopes       []string  `gorm:"serializer:json;type:text" json:"scopes"`        // 允许申请的权限范围
+	Confidential bool      `gorm:"not null" json:"confidential"`                   // 机密客户端（有服务端，可保存密钥）
+	OwnerID      uint      `gorm:"not null;index" json:"owner_id"`
+	Active       bool      `gorm:"not null" json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// OAuthAuthorizationCode OAuth2授权码，只能使用一次
+type OAuthAuthorizationCode struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	CodeHash            string     `gorm:"not null;uniqueIndex" json:"-"`
+	ClientID            uint       `gorm:"not null;index" json:"client_id"`
+	UserID              uint       `gorm:"not null;index" json:"user_id"`
+	RedirectURI         string     `gorm:"not null" json:"redirect_uri"` // 授权请求中提供的回调地址，未提供时为空
+	Scopes              []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	CodeChallenge       string     `gorm:"not null" json:"-"` // PKCE
+	CodeChallengeMethod string     `gorm:"not null" json:"-"`
+	ExpiresAt           time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt              *time.Time `json:"used_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthToken OAuth2访问令牌及其刷新令牌，只保存令牌的哈希
+type OAuthToken struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ClientID         uint       `gorm:"not null;index" json:"client_id"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	AuthorizationID  *uint      `gorm:"index" json:"-"` // 换取令牌的授权码，授权码被重复使用时撤销其令牌
+	AccessTokenHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	RefreshTokenHash string     `gorm:"not null;index" json:"-"`
+	Scopes           []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	AccessExpiresAt  time.Time  `json:"access_expires_at"`
+	RefreshExpiresAt time.Time  `gorm:"index" json:"refresh_expires_at"`
+	RefreshedAt      *time.Time `json:"refreshed_at"` // 刷新令牌已被使用（轮换），再次使用视为泄露
+	RevokedAt        *time.Time `gorm:"index" json:"revoked_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
+
+// IsAccessActive 访问令牌未撤销且未过期
+func (t *OAuthToken) IsAccessActive(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.AccessExpiresAt)
+}
+
+// OAuthConsent 用户对客户端的授权记录，已授权的权限范围再次申请时无需确认
+type OAuthConsent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"user_id"`
+	ClientID  uint      `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"client_id"`
+	Scopes    []string  `gorm:"serializer:json;type:text" json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Client OAuthClient `gorm:"foreignKey:ClientID" json:"client,omitempty"`
+}
+
+// TableName 指定表名
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
@@ -69,12 +69,13 @@ type ProjectMember struct {
 	IsActive  bool      `gorm:"default:true" json:"is_active"`
 
 	// GitLab 相关字段
-	GitLabAccessLevel int        `json:"gitlab_access_level"` // GitLab访问级别
-	PersonalBranch    string     `json:"personal_branch"`     // 个人分支名
-	PersonalBranchURL string     `json:"personal_branch_url"` // 个人分支URL
-	LastCommitHash    string     `json:"last_commit_hash"`    // 最后提交的哈希
-	LastCommitMessage string     `json:"last_commit_message"` // 最后提交的消息
-	LastCommitTime    *time.Time `json:"last_commit_time"`    // 最后提交时间
+	GitLabAccessLevel int        `json:"gitlab_access_level"`           // GitLab访问级别
+	PersonalBranch    string     `json:"personal_branch"`               // 个人分支名
+	PersonalBranchURL string     `json:"personal_branch_url"`           // 个人分支URL
+	LastCommitHash    string     `json:"last_commit_hash"`              // 最后提交的哈希
+	LastCommitMessage string     `json:"last_commit_message"`           // 最后提交的消息
+	LastCommitTime    *time.Time `json:"last_commit_time"`              // 最后提交时间
+	CommitCount       int        `gorm:"default:0" json:"commit_count"` // 提交次数
 
 	// 互动开发相关字段
 	BranchCreatedAt   *time.Time `json:"branch_created_at"`                       // 分支创建时间
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// ReportExport 报表导出任务
+type ReportExport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`   // 发起导出的用户
+	Format      string     `gorm:"not null" json:"format"`          // csv, xlsx, pdf
+	TimeRange   string     `json:"time_range"`                      // week, month, quarter, year, all
+	ClassID     uint       `json:"class_id"`                        // 班级（课题）ID，0表示全部
+	Status      string     `gorm:"default:'pending'" json:"status"` // pending, processing, completed, failed, expired
+	FileName    string     `json:"file_name"`                       // 下载文件名
+	FilePath    string     `json:"-"`                               // 服务器存储路径
+	FileSize    int64      `json:"file_size"`                       // 文件大小（字节）
+	Error       string     `json:"error,omitempty"`                 // 失败原因
+	CompletedAt *time.Time `json:"completed_at"`                    // 生成完成时间
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`         // 下载过期时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ReportExport) TableName() string {
+	return "report_exports"
+}
+
+// 报表导出任务状态
+const (
+	ReportExportStatusPending    = "pending"
+	ReportExportStatusProcessing = "processing"
+	ReportExportStatusCompleted  = "completed"
+	ReportExportStatusFailed     = "failed"
+	ReportExportStatusExpired    = "expired"
+)
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+)
+
+// Rubric 作业评分标准
+type Rubric struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	AssignmentID uint      `gorm:"not null;uniqueIndex" json:"assignment_id"`
+	Name         string    `gorm:"not null" json:"name"`
+	Description  string    `json:"description"`
+	CreatedBy    uint      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// 关联关系
+	Criteria []RubricCriterion `gorm:"foreignKey:RubricID" json:"criteria,omitempty"`
+}
+
+// TableName 指定表名
+func (Rubric) TableName() string {
+	return "rubrics"
+}
+
+// RubricCriterion 评分维度
+type RubricCriterion struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	RubricID    uint          `gorm:"not null;index" json:"rubric_id"`
+	Name        string        `gorm:"not null" json:"name"`
+	Description string        `json:"description"`
+	Weight      float64       `gorm:"default:1" json:"weight"`       // 权重
+	MaxPoints   float64       `gorm:"not null" json:"max_points"`    // 满分
+	Levels      []RubricLevel `gorm:"serializer:json" json:"levels"` // 等级描述
+	SortOrder   int           `gorm:"default:0" json:"sort_order"`   // 排序
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (RubricCriterion) TableName() string {
+	return "rubric_criteria"
+}
+
+// RubricLevel 评分等级描述
+type RubricLevel struct {
+	Name        string  `json:"name"`        // 等级名称，如"优秀"
+	Points      float64 `json:"points"`      // 对应得分
+	Description string  `json:"description"` // 等级说明
+}
+
+// ReviewCriterionScore 评审中各评分维度的得分
+type ReviewCriterionScore struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ReviewID    uint      `gorm:"not null;uniqueIndex:idx_review_criterion" json:"review_id"`
+	CriterionID uint      `gorm:"not null;uniqueIndex:idx_review_criterion" json:"criterion_id"`
+	Points      float64   `json:"points"`
+	Comment     string    `json:"comment"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 关联关系
+	Criterion RubricCriterion `gorm:"foreignKey:CriterionID" json:"criterion,omitempty"`
+}
+
+// TableName 指定表名
+func (ReviewCriterionScore) TableName() string {
+	return "review_criterion_scores"
+}
+
+// CriterionAverage 评分维度平均得分
+type CriterionAverage struct {
+	CriterionID    uint    `json:"criterion_id"`
+	Name           string  `json:"name"`
+	MaxPoints      float64 `json:"max_points"`
+	AveragePoints  float64 `json:"average_points"`
+	AveragePercent float64 `json:"average_percent"` // 平均得分率（0-100）
+	ScoredCount    int     `json:"scored_count"`
+}
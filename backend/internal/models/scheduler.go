@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// 定时任务运行状态
+const (
+	JobRunStatusRunning   = "running"
+	JobRunStatusSucceeded = "succeeded"
+	JobRunStatusFailed    = "failed"
+)
+
+// ScheduledJob 定时任务定义，任务逻辑由代码注册，执行计划和启用状态保存在数据库中
+type ScheduledJob struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"not null;uniqueIndex" json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `gorm:"not null" json:"schedule"`    // cron表达式
+	Enabled     bool       `gorm:"default:true" json:"enabled"` // 是否按计划运行
+	NextRunAt   *time.Time `json:"next_run_at"`                 // 下次计划运行时间
+	LastRunAt   *time.Time `json:"last_run_at"`                 // 最近一次开始运行的时间
+	LastStatus  string     `json:"last_status"`                 // 最近一次运行结果
+	LastError   string     `json:"last_error"`                  // 最近一次失败的原因
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ScheduledJob) TableName() string {
+	return "scheduled_jobs"
+}
+
+// JobRun 定时任务运行记录
+type JobRun struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	JobName     string     `gorm:"not null;index" json:"job_name"`
+	Status      string     `gorm:"not null;default:'running'" json:"status"`
+	TriggeredBy uint       `json:"triggered_by"` // 手动运行的用户，按计划运行为0
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	DurationMs  int64      `json:"duration_ms"`
+	Error       string     `json:"error"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (JobRun) TableName() string {
+	return "job_runs"
+}
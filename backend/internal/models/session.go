@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// UserSession 登录会话，访问令牌携带会话ID，撤销会话后其访问令牌和刷新令牌立即失效
+type UserSession struct {
+	ID                       uint       `gorm:"primaryKey" json:"id"`
+	SessionID                string     `gorm:"not null;uniqueIndex" json:"-"` // 访问令牌中的 sid
+	UserID                   uint       `gorm:"not null;index" json:"user_id"`
+	RefreshTokenHash         string     `gorm:"not null;uniqueIndex" json:"-"`
+	PreviousRefreshTokenHash string     `gorm:"index" json:"-"` // 上一个刷新令牌，再次使用视为泄露并撤销会话
+	Device                   string     `json:"device"`         // 由User-Agent识别的浏览器和系统
+	UserAgent                string     `json:"user_agent"`
+	IP                       string     `json:"ip"`
+	LastUsedAt               time.Time  `json:"last_used_at"` // 最近一次刷新令牌的时间
+	ExpiresAt                time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt                *time.Time `gorm:"index" json:"revoked_at"`
+	CreatedAt                time.Time  `json:"created_at"`
+	UpdatedAt                time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+// IsActive 会话未撤销且未过期
+func (s *UserSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// GitLabCredential 用户登录时获得的GitLab OAuth令牌，加密保存，用于以用户身份调用GitLab API
+type GitLabCredential struct {
+	ID                    uint       `gorm:"primaryKey" json:"id"`
+	UserID                uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	AccessTokenEncrypted  string     `gorm:"type:text;not null" json:"-"`
+	RefreshTokenEncrypted string     `gorm:"type:text" json:"-"`
+	Scope                 string     `json:"scope"`
+	ExpiresAt             *time.Time `json:"expires_at"` // 为空时不过期
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (GitLabCredential) TableName() string {
+	return "gitlab_credentials"
+}
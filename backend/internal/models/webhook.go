@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// 第三方Webhook事件
+const (
+	WebhookEventAssignmentCreated  = "assignment.created"
+	WebhookEventSubmissionCreated  = "submission.created"
+	WebhookEventReviewCompleted    = "review.completed"
+	WebhookEventProjectMemberAdded = "project.member_added"
+	WebhookEventDiscussionCreated  = "discussion.created"
+	WebhookEventPing               = "ping"
+	WebhookEventAll                = "*"
+)
+
+// Webhook投递状态
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription 第三方系统订阅的Webhook，事件发生时向URL推送签名的JSON
+type WebhookSubscription struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"` // 创建者，只推送创建者有权查看的事件
+	URL         string     `gorm:"not null" json:"url"`
+	Secret      string     `gorm:"not null" json:"-"`                       // HMAC签名密钥
+	Events      []string   `gorm:"serializer:json;type:text" json:"events"` // 订阅的事件，* 为全部
+	ProjectID   *uint      `gorm:"index" json:"project_id"`                 // 只推送该课题的事件，为空时不限
+	Description string     `json:"description"`
+	Active      bool       `gorm:"not null" json:"active"`
+	LastSentAt  *time.Time `json:"last_sent_at"` // 最近一次投递成功的时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookDelivery Webhook投递记录，失败时按退避时间重试
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        string     `gorm:"not null;index" json:"event_id"` // 同一事件推送给多个订阅时相同，重新投递时不变
+	Event          string     `gorm:"not null" json:"event"`
+	Payload        string     `gorm:"type:text" json:"payload"`
+	Status         string     `gorm:"not null;index;default:'pending'" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"` // 下次可投递时间，投递中时为租约到期时间
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"` // 截断保存
+	DurationMs     int64      `json:"duration_ms"`
+	LastError      string     `json:"last_error"`
+	RedeliveryOf   *uint      `json:"redelivery_of"` // 重新投递时为原投递记录
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
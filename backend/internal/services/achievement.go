@@ -0,0 +1,422 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AchievementService 成就徽章服务
+type AchievementService struct {
+	db                  *gorm.DB
+	permissionService   *PermissionService
+	gitlabService       *GitLabService
+	notificationService *NotificationService
+}
+
+// NewAchievementService 创建成就徽章服务
+func NewAchievementService(db *gorm.DB, permissionService *PermissionService, gitlabService *GitLabService, notificationService *NotificationService) *AchievementService {
+	return &AchievementService{
+		db:                  db,
+		permissionService:   permissionService,
+		gitlabService:       gitlabService,
+		notificationService: notificationService,
+	}
+}
+
+// defaultAchievements 系统内置成就
+var defaultAchievements = []models.Achievement{
+	{Code: "first_merge_request", Title: "初次合并", Description: "第一个合并请求被合并", Icon: "🔀", RuleType: models.AchievementRuleMergedMergeRequests, Threshold: 1, SortOrder: 1},
+	{Code: "on_time_streak_10", Title: "守时达人", Description: "连续10次按时提交作业", Icon: "⏰", RuleType: models.AchievementRuleOnTimeStreak, Threshold: 10, SortOrder: 2},
+	{Code: "discussion_answers_5", Title: "乐于助人", Description: "回答了5个讨论区提问", Icon: "💬", RuleType: models.AchievementRuleDiscussionAnswers, Threshold: 5, SortOrder: 3},
+	{Code: "commits_100", Title: "代码达人", Description: "累计提交100次代码", Icon: "💻", RuleType: models.AchievementRuleCommits, Threshold: 100, SortOrder: 4},
+	{Code: "first_perfect_score", Title: "满分作业", Description: "第一次获得作业满分", Icon: "🏆", RuleType: models.AchievementRulePerfectScores, Threshold: 1, SortOrder: 5},
+}
+
+// AchievementRequest 创建/更新成就请求
+type AchievementRequest struct {
+	Code        string `json:"code" binding:"required"`
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	RuleType    string `json:"rule_type" binding:"required"`
+	Threshold   int    `json:"threshold" binding:"required,min=1"`
+	IsActive    *bool  `json:"is_active"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// AchievementStatus 成就获得情况
+type AchievementStatus struct {
+	ID          uint       `json:"id"`
+	Code        string     `json:"code"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Icon        string     `json:"icon"`
+	Earned      bool       `json:"earned"`
+	EarnedAt    *time.Time `json:"earned_at,omitempty"`
+}
+
+// SeedDefaultAchievements 初始化内置成就（已存在的不会被覆盖）
+func (s *AchievementService) SeedDefaultAchievements() error {
+	for _, achievement := range defaultAchievements {
+		achievement := achievement
+		achievement.IsActive = true
+		if err := s.db.Where("code = ?", achievement.Code).FirstOrCreate(&achievement).Error; err != nil {
+			return fmt.Errorf("failed to seed achievement %s: %w", achievement.Code, err)
+		}
+	}
+	return nil
+}
+
+// ListAchievements 获取所有成就定义
+func (s *AchievementService) ListAchievements() ([]models.Achievement, error) {
+	var achievements []models.Achievement
+	if err := s.db.Order("sort_order, id").Find(&achievements).Error; err != nil {
+		return nil, fmt.Errorf("failed to get achievements: %w", err)
+	}
+	return achievements, nil
+}
+
+// CreateAchievement 创建成就定义（仅管理员）
+func (s *AchievementService) CreateAchievement(userID uint, req *AchievementRequest) (*models.Achievement, error) {
+	if !s.permissionService.IsAdmin(userID) {
+		return nil, fmt.Errorf("permission denied to manage achievements")
+	}
+	if !isValidAchievementRule(req.RuleType) {
+		return nil, fmt.Errorf("unsupported rule type: %s", req.RuleType)
+	}
+
+	achievement := &models.Achievement{IsActive: true}
+	applyAchievementRequest(achievement, req)
+
+	if err := s.db.Create(achievement).Error; err != nil {
+		return nil, fmt.Errorf("failed to create achievement: %w", err)
+	}
+	// is_active 有默认值，创建时为false会被忽略，需要单独更新
+	if !achievement.IsActive {
+		if err := s.db.Model(achievement).Update("is_active", false).Error; err != nil {
+			return nil, fmt.Errorf("failed to update achievement: %w", err)
+		}
+	}
+
+	return achievement, nil
+}
+
+// UpdateAchievement 更新成就定义（仅管理员），已颁发的成就不会被收回
+func (s *AchievementService) UpdateAchievement(userID, achievementID uint, req *AchievementRequest) (*models.Achievement, error) {
+	if !s.permissionService.IsAdmin(userID) {
+		return nil, fmt.Errorf("permission denied to manage achievements")
+	}
+	if !isValidAchievementRule(req.RuleType) {
+		return nil, fmt.Errorf("unsupported rule type: %s", req.RuleType)
+	}
+
+	var achievement models.Achievement
+	if err := s.db.First(&achievement, achievementID).Error; err != nil {
+		return nil, fmt.Errorf("achievement not found: %w", err)
+	}
+
+	applyAchievementRequest(&achievement, req)
+
+	if err := s.db.Save(&achievement).Error; err != nil {
+		return nil, fmt.Errorf("failed to update achievement: %w", err)
+	}
+
+	return &achievement, nil
+}
+
+// GetUserAchievements 获取用户的成就获得情况（包含未获得的成就）
+func (s *AchievementService) GetUserAchievements(userID uint) ([]AchievementStatus, error) {
+	var achievements []models.Achievement
+	if err := s.db.Where("is_active = ?", true).Order("sort_order, id").Find(&achievements).Error; err != nil {
+		return nil, fmt.Errorf("failed to get achievements: %w", err)
+	}
+
+	var awards []models.UserAchievement
+	if err := s.db.Where("user_id = ?", userID).Find(&awards).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user achievements: %w", err)
+	}
+
+	awardedAt := make(map[uint]time.Time, len(awards))
+	for _, award := range awards {
+		awardedAt[award.AchievementID] = award.AwardedAt
+	}
+
+	statuses := make([]AchievementStatus, 0, len(achievements))
+	for _, achievement := range achievements {
+		status := AchievementStatus{
+			ID:          achievement.ID,
+			Code:        achievement.Code,
+			Title:       achievement.Title,
+			Description: achievement.Description,
+			Icon:        achievement.Icon,
+		}
+		if at, ok := awardedAt[achievement.ID]; ok {
+			status.Earned = true
+			status.EarnedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Evaluate 按规则类型增量评估用户成就，返回本次新获得的成就
+// 只会计算尚有未获得成就的规则，已获得的成就不会重复颁发
+func (s *AchievementService) Evaluate(userID uint, ruleTypes ...string) ([]models.UserAchievement, error) {
+	var pending []models.Achievement
+	query := s.db.Where("is_active = ?", true).
+		Where("id NOT IN (?)", s.db.Model(&models.UserAchievement{}).Select("achievement_id").Where("user_id = ?", userID))
+	if len(ruleTypes) > 0 {
+		query = query.Where("rule_type IN ?", ruleTypes)
+	}
+	if err := query.Order("sort_order, id").Find(&pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pending achievements: %w", err)
+	}
+
+	var awarded []models.UserAchievement
+	metrics := make(map[string]int)
+	for _, achievement := range pending {
+		value, ok := metrics[achievement.RuleType]
+		if !ok {
+			var err error
+			value, err = s.ruleMetric(userID, achievement.RuleType)
+			if err != nil {
+				// 单个规则统计失败不影响其他规则
+				fmt.Printf("Warning: Failed to evaluate achievement rule %s for user %d: %v\n", achievement.RuleType, userID, err)
+				continue
+			}
+			metrics[achievement.RuleType] = value
+		}
+
+		if value < achievement.Threshold {
+			continue
+		}
+
+		award := models.UserAchievement{
+			UserID:        userID,
+			AchievementID: achievement.ID,
+			Progress:      value,
+			AwardedAt:     time.Now(),
+		}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&award)
+		if result.Error != nil {
+			return awarded, fmt.Errorf("failed to award achievement: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// 并发事件已颁发
+			continue
+		}
+
+		award.Achievement = achievement
+		awarded = append(awarded, award)
+
+		if s.notificationService != nil {
+			if err := s.notificationService.NotifyAchievementEarned(userID, &achievement); err != nil {
+				fmt.Printf("Warning: Failed to notify achievement earned: %v\n", err)
+			}
+		}
+	}
+
+	return awarded, nil
+}
+
+// EvaluateQuietly 评估用户成就，失败时只记录日志，供业务流程中的事件钩子使用
+func (s *AchievementService) EvaluateQuietly(userID uint, ruleTypes ...string) {
+	if _, err := s.Evaluate(userID, ruleTypes...); err != nil {
+		fmt.Printf("Warning: Failed to evaluate achievements for user %d: %v\n", userID, err)
+	}
+}
+
+// ruleMetric 计算用户在某个规则上的当前统计值
+func (s *AchievementService) ruleMetric(userID uint, ruleType string) (int, error) {
+	switch ruleType {
+	case models.AchievementRuleMergedMergeRequests:
+		return s.countMergedMergeRequests(userID)
+	case models.AchievementRuleOnTimeStreak:
+		return s.longestOnTimeStreak(userID)
+	case models.AchievementRuleDiscussionAnswers:
+		return s.countDiscussionAnswers(userID)
+	case models.AchievementRuleCommits:
+		return s.countCommits(userID)
+	case models.AchievementRulePerfectScores:
+		return s.countPerfectScores(userID)
+	default:
+		return 0, fmt.Errorf("unsupported rule type: %s", ruleType)
+	}
+}
+
+// countMergedMergeRequests 统计用户在所参与课题中被合并的合并请求数
+func (s *AchievementService) countMergedMergeRequests(userID uint) (int, error) {
+	if s.gitlabService == nil {
+		return 0, fmt.Errorf("gitlab service not available")
+	}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return 0, fmt.Errorf("user not found: %w", err)
+	}
+	if user.GitLabID == 0 {
+		return 0, nil
+	}
+
+	var gitlabProjectIDs []int
+	if err := s.db.Model(&models.Project{}).
+		Joins("JOIN project_members ON project_members.project_id = projects.id").
+		Where("project_members.user_id = ? AND projects.gitlab_project_id > 0", userID).
+		Distinct().Pluck("projects.gitlab_project_id", &gitlabProjectIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to get projects: %w", err)
+	}
+
+	merged := 0
+	for _, projectID := range gitlabProjectIDs {
+		mrs, err := s.gitlabService.ListUserMergeRequests(projectID, user.GitLabID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get merge requests: %w", err)
+		}
+		for _, mr := range mrs {
+			if mr.State == "merged" {
+				merged++
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// longestOnTimeStreak 统计用户最长的连续按时提交次数（每个作业只计首次提交）
+// 按学生所在课题的作业截止时间排序，已过截止时间仍未提交或迟交都会中断连续记录
+func (s *AchievementService) longestOnTimeStreak(userID uint) (int, error) {
+	var rows []struct {
+		DueDate            time.Time
+		ExtendedDueDate    *time.Time
+		GracePeriodMinutes int
+		JoinedAt           time.Time
+		SubmittedAt        *time.Time
+		IsLate             *bool
+	}
+	err := s.db.Table("assignments").
+		Select(`assignments.due_date, ext.due_date AS extended_due_date, assignments.grace_period_minutes,
+			pm.joined_at, sub.submitted_at, sub.is_late`).
+		Joins("JOIN project_members pm ON pm.project_id = assignments.project_id AND pm.user_id = ? AND pm.role = 'student' AND pm.is_active = true", userID).
+		Joins("LEFT JOIN assignment_extensions ext ON ext.assignment_id = assignments.id AND ext.student_id = ?", userID).
+		Joins(`LEFT JOIN (
+			SELECT assignment_id, MIN(submitted_at) AS submitted_at, BOOL_AND(is_late) AS is_late
+			FROM assignment_submissions WHERE student_id = ? GROUP BY assignment_id
+		) sub ON sub.assignment_id = assignments.id`, userID).
+		Scan(&rows).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get assignments: %w", err)
+	}
+
+	type streakEntry struct {
+		deadline time.Time
+		onTime   bool
+	}
+	now := time.Now()
+	entries := make([]streakEntry, 0, len(rows))
+	for _, row := range rows {
+		var extension *models.AssignmentExtension
+		if row.ExtendedDueDate != nil {
+			extension = &models.AssignmentExtension{DueDate: *row.ExtendedDueDate}
+		}
+		deadline := buildSubmissionDeadline(&models.Assignment{
+			DueDate:            row.DueDate,
+			GracePeriodMinutes: row.GracePeriodMinutes,
+		}, extension)
+
+		switch {
+		case row.SubmittedAt != nil:
+			entries = append(entries, streakEntry{deadline: deadline.DueDate, onTime: row.IsLate == nil || !*row.IsLate})
+		case deadline.GraceUntil.Before(row.JoinedAt), now.Before(deadline.GraceUntil):
+			// 加入课题前已截止或尚未截止的未提交作业不影响连续记录
+		default:
+			entries = append(entries, streakEntry{deadline: deadline.DueDate, onTime: false})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].deadline.Before(entries[j].deadline)
+	})
+
+	longest, current := 0, 0
+	for _, entry := range entries {
+		if !entry.onTime {
+			current = 0
+			continue
+		}
+		current++
+		if current > longest {
+			longest = current
+		}
+	}
+
+	return longest, nil
+}
+
+// countDiscussionAnswers 统计用户回答过的他人提问话题数
+func (s *AchievementService) countDiscussionAnswers(userID uint) (int, error) {
+	var count int64
+	err := s.db.Model(&models.DiscussionReply{}).
+		Joins("JOIN discussions ON discussions.id = discussion_replies.discussion_id").
+		Where("discussion_replies.author_id = ? AND discussions.category = 'question' AND discussions.author_id <> ?", userID, userID).
+		Distinct("discussion_replies.discussion_id").
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count discussion answers: %w", err)
+	}
+	return int(count), nil
+}
+
+// countCommits 统计用户在所有课题中的代码提交次数
+func (s *AchievementService) countCommits(userID uint) (int, error) {
+	var total int64
+	err := s.db.Model(&models.ProjectMember{}).
+		Select("COALESCE(SUM(commit_count), 0)").
+		Where("user_id = ?", userID).
+		Scan(&total).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count commits: %w", err)
+	}
+	return int(total), nil
+}
+
+// countPerfectScores 统计用户获得满分的作业数
+func (s *AchievementService) countPerfectScores(userID uint) (int, error) {
+	var count int64
+	err := s.db.Model(&models.AssignmentSubmission{}).
+		Where("student_id = ? AND status = 'graded' AND is_selected = true AND max_score > 0 AND score >= max_score", userID).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count perfect scores: %w", err)
+	}
+	return int(count), nil
+}
+
+// isValidAchievementRule 检查规则类型是否受支持
+func isValidAchievementRule(ruleType string) bool {
+	for _, t := range models.AchievementRuleTypes {
+		if t == ruleType {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAchievementRequest 将请求内容写入成就定义
+func applyAchievementRequest(achievement *models.Achievement, req *AchievementRequest) {
+	achievement.Code = req.Code
+	achievement.Title = req.Title
+	achievement.Description = req.Description
+	achievement.Icon = req.Icon
+	achievement.RuleType = req.RuleType
+	achievement.Threshold = req.Threshold
+	achievement.SortOrder = req.SortOrder
+	if req.IsActive != nil {
+		achievement.IsActive = *req.IsActive
+	}
+}
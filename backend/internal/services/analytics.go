@@ -2,6 +2,8 @@ package services
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"time"
 
 	"gitlabex/internal/models"
@@ -53,6 +55,7 @@ type StudentOverview struct {
 
 // ProjectStat 课题统计数据
 type ProjectStat struct {
+	ID              uint      `json:"id"`
 	Name            string    `json:"name"`
 	StudentCount    int       `json:"student_count"`
 	AssignmentCount int       `json:"assignment_count"`
@@ -63,6 +66,7 @@ type ProjectStat struct {
 
 // StudentStat 学生统计数据
 type StudentStat struct {
+	ID              uint    `json:"id"`
 	Name            string  `json:"name"`
 	ClassName       string  `json:"class_name"`
 	ProjectCount    int     `json:"project_count"`
@@ -74,6 +78,8 @@ type StudentStat struct {
 
 // AssignmentStat 作业统计数据
 type AssignmentStat struct {
+	ID              uint      `json:"id"`
+	ProjectID       uint      `json:"project_id"`
 	Title           string    `json:"title"`
 	ProjectName     string    `json:"project_name"`
 	DueDate         time.Time `json:"due_date"`
@@ -85,18 +91,23 @@ type AssignmentStat struct {
 
 // ChartData 图表数据结构
 type ChartData struct {
-	Labels   []string `json:"labels"`
-	Datasets []struct {
-		Label           string    `json:"label"`
-		Data            []float64 `json:"data"`
-		BackgroundColor string    `json:"backgroundColor,omitempty"`
-		BorderColor     string    `json:"borderColor,omitempty"`
-		Tension         float64   `json:"tension,omitempty"`
-	} `json:"datasets"`
+	Labels   []string       `json:"labels"`
+	Datasets []ChartDataset `json:"datasets"`
+}
+
+// ChartDataset 图表数据集
+type ChartDataset struct {
+	Label           string    `json:"label"`
+	Data            []float64 `json:"data"`
+	BackgroundColor string    `json:"backgroundColor,omitempty"`
+	BorderColor     string    `json:"borderColor,omitempty"`
+	Tension         float64   `json:"tension,omitempty"`
 }
 
 // Activity 活动数据
 type Activity struct {
+	Type        string    `json:"type"`      // project_created, assignment_created, submission, review
+	TargetID    uint      `json:"target_id"` // 活动对象ID
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	Timestamp   time.Time `json:"timestamp"`
@@ -110,27 +121,56 @@ type DashboardStats struct {
 	DocumentsCount          int `json:"documentsCount"`
 }
 
+// 系统角色（与User.Role存储值一致）
+const (
+	analyticsRoleAdmin   = 1
+	analyticsRoleTeacher = 2
+	analyticsRoleStudent = 3
+)
+
+// activeStudentJoin 关联作业所属课题中的在读学生
+const activeStudentJoin = "JOIN project_members pm ON pm.project_id = assignments.project_id AND pm.role = 'student' AND pm.is_active = true"
+
+// completionCount 作业完成情况统计（应提交人次/已提交人次）
+type completionCount struct {
+	GroupID   uint
+	Expected  int64
+	Completed int64
+}
+
+// Rate 完成率（百分比）
+func (c completionCount) Rate() float64 {
+	if c.Expected == 0 {
+		return 0
+	}
+	return math.Round(float64(c.Completed)/float64(c.Expected)*1000) / 10
+}
+
 // GetAdminOverview 获取管理员概览数据
 func (s *AnalyticsService) GetAdminOverview(userID uint) (*AdminOverview, error) {
 	overview := &AdminOverview{}
 
 	// 获取总课题数
-	if err := s.db.Model(&models.Project{}).Count(&overview.TotalProjects); err != nil {
+	if err := s.db.Model(&models.Project{}).Count(&overview.TotalProjects).Error; err != nil {
 		return nil, fmt.Errorf("failed to count projects: %w", err)
 	}
 
 	// 获取总作业数
-	if err := s.db.Model(&models.Assignment{}).Count(&overview.TotalAssignments); err != nil {
+	if err := s.db.Model(&models.Assignment{}).Count(&overview.TotalAssignments).Error; err != nil {
 		return nil, fmt.Errorf("failed to count assignments: %w", err)
 	}
 
 	// 获取总学生数
-	if err := s.db.Model(&models.User{}).Where("role = ?", 3).Count(&overview.TotalStudents); err != nil {
+	if err := s.db.Model(&models.User{}).Where("role = ?", analyticsRoleStudent).Count(&overview.TotalStudents).Error; err != nil {
 		return nil, fmt.Errorf("failed to count students: %w", err)
 	}
 
 	// 计算完成率
-	overview.CompletionRate = s.calculateOverallCompletionRate()
+	completion, err := s.completionBy("", nil)
+	if err != nil {
+		return nil, err
+	}
+	overview.CompletionRate = int(math.Round(completion[0].Rate()))
 
 	return overview, nil
 }
@@ -138,73 +178,65 @@ func (s *AnalyticsService) GetAdminOverview(userID uint) (*AdminOverview, error)
 // GetTeacherOverview 获取教师概览数据
 func (s *AnalyticsService) GetTeacherOverview(userID uint) (*TeacherOverview, error) {
 	overview := &TeacherOverview{}
+	scope := s.scopeFilter("assignments.project_id", userID, analyticsRoleTeacher)
 
 	// 课题统计
 	var totalProjects int64
 	var activeProjects int64
-	s.db.Model(&models.Project{}).Where("teacher_id = ?", userID).Count(&totalProjects)
-	s.db.Model(&models.Project{}).Where("teacher_id = ? AND status = 'active'", userID).Count(&activeProjects)
+	if err := s.db.Model(&models.Project{}).Where("teacher_id = ?", userID).Count(&totalProjects).Error; err != nil {
+		return nil, fmt.Errorf("failed to count projects: %w", err)
+	}
+	if err := s.db.Model(&models.Project{}).Where("teacher_id = ? AND status = 'active'", userID).Count(&activeProjects).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active projects: %w", err)
+	}
 
 	// 作业统计
 	var totalAssignments int64
-	s.db.Model(&models.Assignment{}).
-		Joins("JOIN projects ON assignments.project_id = projects.id").
-		Where("projects.teacher_id = ?", userID).
-		Count(&totalAssignments)
+	if err := scope(s.db.Model(&models.Assignment{})).Count(&totalAssignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to count assignments: %w", err)
+	}
 
 	// 提交统计
-	var totalSubmissions int64
-	var pendingReviews int64
-	s.db.Model(&models.AssignmentSubmission{}).
-		Joins("JOIN assignments ON assignment_submissions.assignment_id = assignments.id").
-		Joins("JOIN projects ON assignments.project_id = projects.id").
-		Where("projects.teacher_id = ?", userID).
-		Count(&totalSubmissions)
-
-	s.db.Model(&models.AssignmentSubmission{}).
-		Joins("JOIN assignments ON assignment_submissions.assignment_id = assignments.id").
-		Joins("JOIN projects ON assignments.project_id = projects.id").
-		Where("projects.teacher_id = ? AND assignment_submissions.status = 'submitted'", userID).
-		Count(&pendingReviews)
+	var submissionStats struct {
+		Total   int64
+		Pending int64
+	}
+	if err := scope(s.db.Table("assignment_submissions").
+		Select("COUNT(*) AS total, SUM(CASE WHEN assignment_submissions.status = 'submitted' THEN 1 ELSE 0 END) AS pending").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignment_submissions.is_selected = true")).
+		Scan(&submissionStats).Error; err != nil {
+		return nil, fmt.Errorf("failed to count submissions: %w", err)
+	}
 
 	// 学生统计
 	var totalStudents int64
-	s.db.Model(&models.ProjectMember{}).
+	if err := s.db.Model(&models.ProjectMember{}).
 		Joins("JOIN projects ON project_members.project_id = projects.id").
-		Where("projects.teacher_id = ? AND project_members.is_active = true", userID).
-		Count(&totalStudents)
+		Where("projects.teacher_id = ? AND project_members.role = 'student' AND project_members.is_active = true", userID).
+		Distinct("project_members.user_id").
+		Count(&totalStudents).Error; err != nil {
+		return nil, fmt.Errorf("failed to count students: %w", err)
+	}
 
 	// 平均分和完成率
-	var avgScore struct {
-		AvgScore float64
-		Count    int64
+	averages, err := s.averageScoreBy("", scope)
+	if err != nil {
+		return nil, err
 	}
-	s.db.Model(&models.AssignmentSubmission{}).
-		Select("AVG(score) as avg_score, COUNT(*) as count").
-		Joins("JOIN assignments ON assignment_submissions.assignment_id = assignments.id").
-		Joins("JOIN projects ON assignments.project_id = projects.id").
-		Where("projects.teacher_id = ? AND assignment_submissions.status = 'graded'", userID).
-		Scan(&avgScore)
-
-	completionRate := float64(0)
-	if totalAssignments > 0 {
-		var completedSubmissions int64
-		s.db.Model(&models.AssignmentSubmission{}).
-			Joins("JOIN assignments ON assignment_submissions.assignment_id = assignments.id").
-			Joins("JOIN projects ON assignments.project_id = projects.id").
-			Where("projects.teacher_id = ? AND assignment_submissions.status IN ('submitted', 'graded')", userID).
-			Count(&completedSubmissions)
-		completionRate = float64(completedSubmissions) / float64(totalAssignments) * 100
+	completion, err := s.completionBy("", scope)
+	if err != nil {
+		return nil, err
 	}
 
 	overview.TotalProjects = int(totalProjects)
 	overview.ActiveProjects = int(activeProjects)
 	overview.TotalAssignments = int(totalAssignments)
-	overview.TotalSubmissions = int(totalSubmissions)
-	overview.PendingReviews = int(pendingReviews)
+	overview.TotalSubmissions = int(submissionStats.Total)
+	overview.PendingReviews = int(submissionStats.Pending)
 	overview.TotalStudents = int(totalStudents)
-	overview.AverageScore = avgScore.AvgScore
-	overview.CompletionRate = completionRate
+	overview.AverageScore = averages[0]
+	overview.CompletionRate = completion[0].Rate()
 
 	return overview, nil
 }
@@ -215,95 +247,105 @@ func (s *AnalyticsService) GetStudentOverview(userID uint) (*StudentOverview, er
 
 	// 参与的课题数量
 	var joinedProjects int64
-	s.db.Model(&models.ProjectMember{}).
+	if err := s.db.Model(&models.ProjectMember{}).
 		Where("user_id = ? AND is_active = true", userID).
-		Count(&joinedProjects)
-
-	// 作业统计
-	var activeAssignments int64
-	var completedAssignments int64
-	var totalSubmissions int64
+		Count(&joinedProjects).Error; err != nil {
+		return nil, fmt.Errorf("failed to count joined projects: %w", err)
+	}
 
 	// 活跃作业（未提交的）
-	s.db.Model(&models.Assignment{}).
+	var activeAssignments int64
+	if err := s.db.Model(&models.Assignment{}).
 		Joins("JOIN project_members ON assignments.project_id = project_members.project_id").
-		Joins("LEFT JOIN assignment_submissions ON assignments.id = assignment_submissions.assignment_id AND assignment_submissions.student_id = ?", userID).
-		Where("project_members.user_id = ? AND project_members.is_active = true AND assignments.status = 'active' AND assignment_submissions.id IS NULL", userID).
-		Count(&activeAssignments)
-
-	// 已完成作业（已提交的）
-	s.db.Model(&models.AssignmentSubmission{}).
-		Where("student_id = ?", userID).
-		Count(&totalSubmissions)
-
-	s.db.Model(&models.AssignmentSubmission{}).
-		Where("student_id = ? AND status IN ('submitted', 'graded')", userID).
-		Count(&completedAssignments)
-
-	pendingAssignments := int(activeAssignments)
+		Where("project_members.user_id = ? AND project_members.is_active = true AND assignments.status = 'active'", userID).
+		Where("NOT EXISTS (SELECT 1 FROM assignment_submissions WHERE assignment_submissions.assignment_id = assignments.id AND assignment_submissions.student_id = ?)", userID).
+		Count(&activeAssignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active assignments: %w", err)
+	}
 
-	// 分数统计
-	var scoreStats struct {
-		AvgScore float64
-		MaxScore float64
-		MinScore float64
-		Count    int64
+	// 提交和分数统计
+	var submissionStats struct {
+		Total     int64
+		Completed int64
+		AvgScore  float64
+		MaxScore  float64
+		MinScore  float64
+	}
+	if err := s.db.Model(&models.AssignmentSubmission{}).
+		Select(`COUNT(*) AS total,
+			COUNT(DISTINCT CASE WHEN status IN ('submitted', 'graded') THEN assignment_id END) AS completed,
+			COALESCE(AVG(CASE WHEN status = 'graded' THEN score END), 0) AS avg_score,
+			COALESCE(MAX(CASE WHEN status = 'graded' THEN score END), 0) AS max_score,
+			COALESCE(MIN(CASE WHEN status = 'graded' THEN score END), 0) AS min_score`).
+		Where("student_id = ? AND is_selected = true", userID).
+		Scan(&submissionStats).Error; err != nil {
+		return nil, fmt.Errorf("failed to get submission stats: %w", err)
 	}
-	s.db.Model(&models.AssignmentSubmission{}).
-		Select("AVG(score) as avg_score, MAX(score) as max_score, MIN(score) as min_score, COUNT(*) as count").
-		Where("student_id = ? AND status = 'graded'", userID).
-		Scan(&scoreStats)
 
 	overview.JoinedProjects = int(joinedProjects)
 	overview.ActiveAssignments = int(activeAssignments)
-	overview.CompletedAssignments = int(completedAssignments)
-	overview.PendingAssignments = pendingAssignments
-	overview.TotalSubmissions = int(totalSubmissions)
-	overview.AverageScore = scoreStats.AvgScore
-	overview.HighestScore = scoreStats.MaxScore
-	overview.LowestScore = scoreStats.MinScore
+	overview.CompletedAssignments = int(submissionStats.Completed)
+	overview.PendingAssignments = int(activeAssignments)
+	overview.TotalSubmissions = int(submissionStats.Total)
+	overview.AverageScore = submissionStats.AvgScore
+	overview.HighestScore = submissionStats.MaxScore
+	overview.LowestScore = submissionStats.MinScore
 
 	return overview, nil
 }
 
 // GetProjectStats 获取课题统计数据
 func (s *AnalyticsService) GetProjectStats(userID uint, userRole int) ([]ProjectStat, error) {
-	var stats []ProjectStat
-
-	query := s.db.Model(&models.Project{}).
-		Select("projects.name, COUNT(DISTINCT pm.user_id) as student_count, COUNT(DISTINCT a.id) as assignment_count").
-		Joins("LEFT JOIN project_members pm ON pm.project_id = projects.id AND pm.role = 'student'").
-		Joins("LEFT JOIN assignments a ON a.project_id = projects.id").
-		Group("projects.id, projects.name")
-
-	// 根据角色过滤
-	if userRole == 2 { // 教师
-		query = query.Where("projects.teacher_id = ?", userID)
-	} else if userRole == 3 { // 学生
-		query = query.Joins("JOIN project_members pm2 ON pm2.project_id = projects.id").
-			Where("pm2.user_id = ? AND pm2.role = 'student'", userID)
-	}
-
 	var results []struct {
+		ID              uint
 		Name            string
+		UpdatedAt       time.Time
 		StudentCount    int
 		AssignmentCount int
 	}
 
-	if err := query.Find(&results).Error; err != nil {
+	query := s.db.Model(&models.Project{}).
+		Select("projects.id, projects.name, projects.updated_at, COUNT(DISTINCT pm.user_id) AS student_count, COUNT(DISTINCT a.id) AS assignment_count").
+		Joins("LEFT JOIN project_members pm ON pm.project_id = projects.id AND pm.role = 'student' AND pm.is_active = true").
+		Joins("LEFT JOIN assignments a ON a.project_id = projects.id").
+		Group("projects.id, projects.name, projects.updated_at").
+		Order("projects.id")
+	query = s.scopeFilter("projects.id", userID, userRole)(query)
+
+	if err := query.Scan(&results).Error; err != nil {
 		return nil, fmt.Errorf("failed to get project stats: %w", err)
 	}
 
+	scope := s.scopeFilter("assignments.project_id", userID, userRole)
+	completion, err := s.completionBy("assignments.project_id", scope)
+	if err != nil {
+		return nil, err
+	}
+	averages, err := s.averageScoreBy("assignments.project_id", scope)
+	if err != nil {
+		return nil, err
+	}
+	lastSubmissions, err := s.lastSubmissionBy("assignments.project_id", scope)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]ProjectStat, 0, len(results))
 	for _, result := range results {
-		stat := ProjectStat{
+		lastActivity := result.UpdatedAt
+		if last, ok := lastSubmissions[result.ID]; ok && last.After(lastActivity) {
+			lastActivity = last
+		}
+
+		stats = append(stats, ProjectStat{
+			ID:              result.ID,
 			Name:            result.Name,
 			StudentCount:    result.StudentCount,
 			AssignmentCount: result.AssignmentCount,
-			CompletionRate:  s.calculateProjectCompletionRate(result.Name),
-			AverageScore:    s.calculateProjectAverageScore(result.Name),
-			LastActivity:    time.Now(), // 简化实现
-		}
-		stats = append(stats, stat)
+			CompletionRate:  int(math.Round(completion[result.ID].Rate())),
+			AverageScore:    averages[result.ID],
+			LastActivity:    lastActivity,
+		})
 	}
 
 	return stats, nil
@@ -311,42 +353,48 @@ func (s *AnalyticsService) GetProjectStats(userID uint, userRole int) ([]Project
 
 // GetStudentStats 获取学生统计数据
 func (s *AnalyticsService) GetStudentStats(userID uint, userRole int) ([]StudentStat, error) {
-	var stats []StudentStat
-
-	query := s.db.Model(&models.User{}).
-		Select("users.name, '' as class_name, COUNT(DISTINCT pm.project_id) as project_count").
-		Joins("LEFT JOIN project_members pm ON pm.user_id = users.id AND pm.role = 'student'").
-		Where("users.role = ?", 3).
-		Group("users.id, users.name")
-
-	// 根据角色过滤
-	if userRole == 2 { // 教师只能看到自己班级的学生
-		query = query.Joins("JOIN project_members pm2 ON pm2.user_id = users.id").
-			Joins("JOIN projects p ON p.id = pm2.project_id").
-			Where("p.teacher_id = ?", userID)
-	}
-
 	var results []struct {
+		ID           uint
 		Name         string
 		ClassName    string
 		ProjectCount int
 	}
 
-	if err := query.Find(&results).Error; err != nil {
+	query := s.db.Table("users").
+		Select("users.id, users.name, STRING_AGG(DISTINCT p.name, ', ') AS class_name, COUNT(DISTINCT pm.project_id) AS project_count").
+		Joins("JOIN project_members pm ON pm.user_id = users.id AND pm.role = 'student' AND pm.is_active = true").
+		Joins("JOIN projects p ON p.id = pm.project_id").
+		Group("users.id, users.name").
+		Order("users.id")
+	query = s.scopeFilter("pm.project_id", userID, userRole)(query)
+
+	if err := query.Scan(&results).Error; err != nil {
 		return nil, fmt.Errorf("failed to get student stats: %w", err)
 	}
 
+	scope := s.scopeFilter("assignments.project_id", userID, userRole)
+	completion, err := s.completionBy("pm.user_id", scope)
+	if err != nil {
+		return nil, err
+	}
+	averages, err := s.averageScoreBy("assignment_submissions.student_id", scope)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]StudentStat, 0, len(results))
 	for _, result := range results {
-		stat := StudentStat{
+		count := completion[result.ID]
+		stats = append(stats, StudentStat{
+			ID:              result.ID,
 			Name:            result.Name,
 			ClassName:       result.ClassName,
 			ProjectCount:    result.ProjectCount,
-			AssignmentCount: s.calculateStudentAssignmentCount(result.Name),
-			CompletedCount:  s.calculateStudentCompletedCount(result.Name),
-			CompletionRate:  s.calculateStudentCompletionRate(0), // 简化实现
-			AverageScore:    s.calculateStudentAverageScore(result.Name),
-		}
-		stats = append(stats, stat)
+			AssignmentCount: int(count.Expected),
+			CompletedCount:  int(count.Completed),
+			CompletionRate:  int(math.Round(count.Rate())),
+			AverageScore:    averages[result.ID],
+		})
 	}
 
 	return stats, nil
@@ -354,105 +402,168 @@ func (s *AnalyticsService) GetStudentStats(userID uint, userRole int) ([]Student
 
 // GetAssignmentStats 获取作业统计数据
 func (s *AnalyticsService) GetAssignmentStats(userID uint, userRole int) ([]AssignmentStat, error) {
-	var stats []AssignmentStat
-
-	query := s.db.Model(&models.Assignment{}).
-		Select("assignments.title, projects.name as project_name, assignments.due_date").
-		Joins("JOIN projects ON assignments.project_id = projects.id")
-
-	// 根据角色过滤
-	if userRole == 2 { // 教师
-		query = query.Where("projects.teacher_id = ?", userID)
-	} else if userRole == 3 { // 学生
-		query = query.Joins("JOIN project_members pm ON pm.project_id = projects.id").
-			Where("pm.user_id = ? AND pm.role = 'student'", userID)
-	}
-
 	var results []struct {
+		ID          uint
+		ProjectID   uint
 		Title       string
 		ProjectName string
 		DueDate     time.Time
 	}
 
-	if err := query.Find(&results).Error; err != nil {
+	scope := s.scopeFilter("assignments.project_id", userID, userRole)
+	query := scope(s.db.Model(&models.Assignment{}).
+		Select("assignments.id, assignments.project_id, assignments.title, projects.name AS project_name, assignments.due_date").
+		Joins("JOIN projects ON assignments.project_id = projects.id").
+		Order("assignments.due_date DESC"))
+
+	if err := query.Scan(&results).Error; err != nil {
 		return nil, fmt.Errorf("failed to get assignment stats: %w", err)
 	}
 
+	completion, err := s.completionBy("assignments.id", scope)
+	if err != nil {
+		return nil, err
+	}
+	averages, err := s.averageScoreBy("assignments.id", scope)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]AssignmentStat, 0, len(results))
 	for _, result := range results {
-		stat := AssignmentStat{
+		count := completion[result.ID]
+		stats = append(stats, AssignmentStat{
+			ID:              result.ID,
+			ProjectID:       result.ProjectID,
 			Title:           result.Title,
 			ProjectName:     result.ProjectName,
 			DueDate:         result.DueDate,
-			SubmissionCount: s.calculateAssignmentSubmissionCount(result.Title),
-			TotalStudents:   s.calculateAssignmentTotalStudents(result.Title),
-			CompletionRate:  s.calculateAssignmentCompletionRate(result.Title),
-			AverageScore:    s.calculateAssignmentAverageScore(result.Title),
-		}
-		stats = append(stats, stat)
+			SubmissionCount: int(count.Completed),
+			TotalStudents:   int(count.Expected),
+			CompletionRate:  int(math.Round(count.Rate())),
+			AverageScore:    averages[result.ID],
+		})
 	}
 
 	return stats, nil
 }
 
-// GetSubmissionTrend 获取提交趋势数据
+// GetSubmissionTrend 获取提交趋势数据（按天统计）
 func (s *AnalyticsService) GetSubmissionTrend(userID uint, userRole int, startDate, endDate time.Time) (*ChartData, error) {
-	// 简化实现，返回模拟数据
-	data := &ChartData{
-		Labels: []string{"周一", "周二", "周三", "周四", "周五", "周六", "周日"},
-		Datasets: []struct {
-			Label           string    `json:"label"`
-			Data            []float64 `json:"data"`
-			BackgroundColor string    `json:"backgroundColor,omitempty"`
-			BorderColor     string    `json:"borderColor,omitempty"`
-			Tension         float64   `json:"tension,omitempty"`
-		}{
-			{
-				Label:       "作业提交数",
-				Data:        []float64{5, 8, 12, 15, 10, 6, 3},
-				BorderColor: "#409EFF",
-				Tension:     0.4,
-			},
-		},
+	start := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+	end := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, endDate.Location()).AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return nil, fmt.Errorf("start date must be before end date")
+	}
+
+	var results []struct {
+		Day   time.Time
+		Count int
+	}
+
+	query := s.submissionScope(userID, userRole, s.db.Table("assignment_submissions").
+		Select("DATE(assignment_submissions.submitted_at) AS day, COUNT(*) AS count").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignment_submissions.submitted_at >= ? AND assignment_submissions.submitted_at < ?", start, end).
+		Group("DATE(assignment_submissions.submitted_at)"))
+
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get submission trend: %w", err)
+	}
+
+	counts := make(map[string]float64, len(results))
+	for _, result := range results {
+		counts[result.Day.Format("2006-01-02")] = float64(result.Count)
 	}
+
+	dataset := ChartDataset{
+		Label:       "作业提交数",
+		BorderColor: "#409EFF",
+		Tension:     0.4,
+	}
+	data := &ChartData{}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		label := day.Format("2006-01-02")
+		data.Labels = append(data.Labels, label)
+		dataset.Data = append(dataset.Data, counts[label])
+	}
+	data.Datasets = []ChartDataset{dataset}
+
 	return data, nil
 }
 
-// GetProjectDistribution 获取课题分布数据
+// GetProjectDistribution 获取课题分布数据（按课题类型统计）
 func (s *AnalyticsService) GetProjectDistribution(userID uint, userRole int) (*ChartData, error) {
-	// 简化实现，返回模拟数据
-	data := &ChartData{
-		Labels: []string{"Web开发", "数据库", "算法", "移动开发", "AI"},
-		Datasets: []struct {
-			Label           string    `json:"label"`
-			Data            []float64 `json:"data"`
-			BackgroundColor string    `json:"backgroundColor,omitempty"`
-			BorderColor     string    `json:"borderColor,omitempty"`
-			Tension         float64   `json:"tension,omitempty"`
-		}{
-			{
-				Data:            []float64{30, 25, 20, 15, 10},
-				BackgroundColor: "#409EFF",
-			},
-		},
+	var results []struct {
+		Type  string
+		Count int
+	}
+
+	query := s.scopeFilter("projects.id", userID, userRole)(s.db.Model(&models.Project{}).
+		Select("projects.type, COUNT(*) AS count").
+		Group("projects.type").
+		Order("count DESC"))
+
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to get project distribution: %w", err)
+	}
+
+	typeNames := map[string]string{
+		"graduation":  "毕业设计",
+		"research":    "科研项目",
+		"competition": "竞赛项目",
+		"practice":    "实践项目",
 	}
+
+	dataset := ChartDataset{
+		Label:           "课题数",
+		BackgroundColor: "#409EFF",
+	}
+	data := &ChartData{}
+	for _, result := range results {
+		label := typeNames[result.Type]
+		if label == "" {
+			label = result.Type
+		}
+		data.Labels = append(data.Labels, label)
+		dataset.Data = append(dataset.Data, float64(result.Count))
+	}
+	data.Datasets = []ChartDataset{dataset}
+
 	return data, nil
 }
 
-// GetGradeDistribution 获取成绩分布数据
+// GetGradeDistribution 获取成绩分布数据（按得分率分段统计已评分提交）
 func (s *AnalyticsService) GetGradeDistribution(userID uint, userRole int) (*ChartData, error) {
-	// 简化实现，返回模拟数据
+	graded := s.submissionScope(userID, userRole, s.db.Table("assignment_submissions").
+		Select("assignment_submissions.score * 100 / NULLIF(assignment_submissions.max_score, 0) AS percent").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignment_submissions.status = 'graded' AND assignment_submissions.is_selected = true"))
+
+	var result struct {
+		Excellent int
+		Good      int
+		Medium    int
+		Pass      int
+		Fail      int
+	}
+	err := s.db.Table("(?) AS graded", graded).
+		Select(`SUM(CASE WHEN percent >= 90 THEN 1 ELSE 0 END) AS excellent,
+			SUM(CASE WHEN percent >= 80 AND percent < 90 THEN 1 ELSE 0 END) AS good,
+			SUM(CASE WHEN percent >= 70 AND percent < 80 THEN 1 ELSE 0 END) AS medium,
+			SUM(CASE WHEN percent >= 60 AND percent < 70 THEN 1 ELSE 0 END) AS pass,
+			SUM(CASE WHEN percent < 60 THEN 1 ELSE 0 END) AS fail`).
+		Scan(&result).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grade distribution: %w", err)
+	}
+
 	data := &ChartData{
 		Labels: []string{"90-100", "80-89", "70-79", "60-69", "60以下"},
-		Datasets: []struct {
-			Label           string    `json:"label"`
-			Data            []float64 `json:"data"`
-			BackgroundColor string    `json:"backgroundColor,omitempty"`
-			BorderColor     string    `json:"borderColor,omitempty"`
-			Tension         float64   `json:"tension,omitempty"`
-		}{
+		Datasets: []ChartDataset{
 			{
-				Label:           "学生数",
-				Data:            []float64{15, 25, 30, 20, 10},
+				Label:           "提交数",
+				Data:            []float64{float64(result.Excellent), float64(result.Good), float64(result.Medium), float64(result.Pass), float64(result.Fail)},
 				BackgroundColor: "#409EFF",
 			},
 		},
@@ -460,21 +571,66 @@ func (s *AnalyticsService) GetGradeDistribution(userID uint, userRole int) (*Cha
 	return data, nil
 }
 
-// GetActivityStats 获取活跃度统计数据
+// GetActivityStats 获取活跃度统计数据（近30天）
 func (s *AnalyticsService) GetActivityStats(userID uint, userRole int) (*ChartData, error) {
-	// 简化实现，返回模拟数据
+	since := time.Now().AddDate(0, 0, -30)
+	isStudent := userRole == analyticsRoleStudent
+
+	var submissions, documentEdits, discussions, replies, commits, activeMembers int64
+
+	if err := s.submissionScope(userID, userRole, s.db.Model(&models.AssignmentSubmission{}).
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignment_submissions.submitted_at >= ?", since)).
+		Count(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to count submissions: %w", err)
+	}
+
+	documentQuery := s.scopeFilter("documents.project_id", userID, userRole)(s.db.Model(&models.DocumentHistory{}).
+		Joins("JOIN documents ON documents.id = document_histories.document_id").
+		Where("document_histories.created_at >= ?", since))
+	if isStudent {
+		documentQuery = documentQuery.Where("document_histories.author_id = ?", userID)
+	}
+	if err := documentQuery.Count(&documentEdits).Error; err != nil {
+		return nil, fmt.Errorf("failed to count document edits: %w", err)
+	}
+
+	discussionQuery := s.scopeFilter("discussions.project_id", userID, userRole)(s.db.Model(&models.Discussion{}).
+		Where("discussions.created_at >= ?", since))
+	replyQuery := s.scopeFilter("discussions.project_id", userID, userRole)(s.db.Model(&models.DiscussionReply{}).
+		Joins("JOIN discussions ON discussions.id = discussion_replies.discussion_id").
+		Where("discussion_replies.created_at >= ?", since))
+	if isStudent {
+		discussionQuery = discussionQuery.Where("discussions.author_id = ?", userID)
+		replyQuery = replyQuery.Where("discussion_replies.author_id = ?", userID)
+	}
+	if err := discussionQuery.Count(&discussions).Error; err != nil {
+		return nil, fmt.Errorf("failed to count discussions: %w", err)
+	}
+	if err := replyQuery.Count(&replies).Error; err != nil {
+		return nil, fmt.Errorf("failed to count discussion replies: %w", err)
+	}
+
+	memberQuery := func() *gorm.DB {
+		query := s.scopeFilter("project_members.project_id", userID, userRole)(s.db.Model(&models.ProjectMember{}))
+		if isStudent {
+			query = query.Where("project_members.user_id = ?", userID)
+		}
+		return query
+	}
+	if err := memberQuery().Where("project_members.last_commit_time >= ?", since).Count(&commits).Error; err != nil {
+		return nil, fmt.Errorf("failed to count committing members: %w", err)
+	}
+	if err := memberQuery().Where("project_members.last_active_time >= ?", since).Count(&activeMembers).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active members: %w", err)
+	}
+
 	data := &ChartData{
 		Labels: []string{"作业提交", "文档编辑", "讨论参与", "代码提交", "项目活跃"},
-		Datasets: []struct {
-			Label           string    `json:"label"`
-			Data            []float64 `json:"data"`
-			BackgroundColor string    `json:"backgroundColor,omitempty"`
-			BorderColor     string    `json:"borderColor,omitempty"`
-			Tension         float64   `json:"tension,omitempty"`
-		}{
+		Datasets: []ChartDataset{
 			{
-				Label:           "活跃度",
-				Data:            []float64{80, 65, 70, 85, 75},
+				Label:           "近30天活跃度",
+				Data:            []float64{float64(submissions), float64(documentEdits), float64(discussions + replies), float64(commits), float64(activeMembers)},
 				BackgroundColor: "#409EFF20",
 				BorderColor:     "#409EFF",
 			},
@@ -486,126 +642,269 @@ func (s *AnalyticsService) GetActivityStats(userID uint, userRole int) (*ChartDa
 // GetDashboardStats 获取仪表盘统计数据
 func (s *AnalyticsService) GetDashboardStats(userID uint, userRole int) (*DashboardStats, error) {
 	var stats DashboardStats
+	if userRole != analyticsRoleAdmin && userRole != analyticsRoleTeacher && userRole != analyticsRoleStudent {
+		return &stats, nil
+	}
 
-	// 根据角色返回不同的统计数据
-	switch userRole {
-	case 1: // 管理员
-		stats = DashboardStats{
-			ClassesCount:            5,
-			ActiveProjectsCount:     15,
-			PendingAssignmentsCount: 8,
-			DocumentsCount:          25,
-		}
-	case 2: // 教师
-		stats = DashboardStats{
-			ClassesCount:            3,
-			ActiveProjectsCount:     8,
-			PendingAssignmentsCount: 12,
-			DocumentsCount:          15,
-		}
-	case 3: // 学生
-		stats = DashboardStats{
-			ClassesCount:            1,
-			ActiveProjectsCount:     4,
-			PendingAssignmentsCount: 2,
-			DocumentsCount:          15,
+	projectScope := s.scopeFilter("projects.id", userID, userRole)
+
+	// 课题即教学班级
+	var projects, activeProjects, pending, documents int64
+	if err := projectScope(s.db.Model(&models.Project{})).Count(&projects).Error; err != nil {
+		return nil, fmt.Errorf("failed to count projects: %w", err)
+	}
+	if err := projectScope(s.db.Model(&models.Project{})).Where("projects.status = 'active'").Count(&activeProjects).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active projects: %w", err)
+	}
+
+	if userRole == analyticsRoleStudent {
+		// 学生：尚未提交的进行中作业
+		if err := s.scopeFilter("assignments.project_id", userID, userRole)(s.db.Model(&models.Assignment{})).
+			Where("assignments.status = 'active'").
+			Where("NOT EXISTS (SELECT 1 FROM assignment_submissions WHERE assignment_submissions.assignment_id = assignments.id AND assignment_submissions.student_id = ?)", userID).
+			Count(&pending).Error; err != nil {
+			return nil, fmt.Errorf("failed to count pending assignments: %w", err)
 		}
-	default:
-		stats = DashboardStats{
-			ClassesCount:            0,
-			ActiveProjectsCount:     0,
-			PendingAssignmentsCount: 0,
-			DocumentsCount:          0,
+	} else {
+		// 教师/管理员：待评审的提交
+		if err := s.scopeFilter("assignments.project_id", userID, userRole)(s.db.Model(&models.AssignmentSubmission{}).
+			Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id")).
+			Where("assignment_submissions.status = 'submitted' AND assignment_submissions.is_selected = true").
+			Count(&pending).Error; err != nil {
+			return nil, fmt.Errorf("failed to count pending reviews: %w", err)
 		}
 	}
 
+	if err := s.scopeFilter("documents.project_id", userID, userRole)(s.db.Model(&models.Document{})).
+		Where("documents.status = 'active'").
+		Count(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	stats.ClassesCount = int(projects)
+	stats.ActiveProjectsCount = int(activeProjects)
+	stats.PendingAssignmentsCount = int(pending)
+	stats.DocumentsCount = int(documents)
+
 	return &stats, nil
 }
 
 // GetRecentActivities 获取最近活动数据
 func (s *AnalyticsService) GetRecentActivities(userID uint, userRole int, limit int) ([]Activity, error) {
-	activities := []Activity{
-		{
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var activities []Activity
+
+	// 课题创建
+	var projects []struct {
+		ID        uint
+		Name      string
+		CreatedAt time.Time
+	}
+	if err := s.scopeFilter("projects.id", userID, userRole)(s.db.Model(&models.Project{})).
+		Select("projects.id, projects.name, projects.created_at").
+		Order("projects.created_at DESC").
+		Limit(limit).
+		Scan(&projects).Error; err != nil {
+		return nil, fmt.Errorf("failed to get recent projects: %w", err)
+	}
+	for _, project := range projects {
+		activities = append(activities, Activity{
+			Type:        "project_created",
+			TargetID:    project.ID,
 			Title:       "新课题创建",
-			Description: "创建了新的课题：Web开发实战",
-			Timestamp:   time.Now().Add(-time.Hour * 2),
-		},
-		{
+			Description: fmt.Sprintf("创建了新的课题：%s", project.Name),
+			Timestamp:   project.CreatedAt,
+		})
+	}
+
+	// 作业发布
+	var assignments []struct {
+		ID        uint
+		Title     string
+		CreatedAt time.Time
+	}
+	if err := s.scopeFilter("assignments.project_id", userID, userRole)(s.db.Model(&models.Assignment{})).
+		Select("assignments.id, assignments.title, assignments.created_at").
+		Order("assignments.created_at DESC").
+		Limit(limit).
+		Scan(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get recent assignments: %w", err)
+	}
+	for _, assignment := range assignments {
+		activities = append(activities, Activity{
+			Type:        "assignment_created",
+			TargetID:    assignment.ID,
+			Title:       "新作业发布",
+			Description: fmt.Sprintf("发布了作业：%s", assignment.Title),
+			Timestamp:   assignment.CreatedAt,
+		})
+	}
+
+	// 作业提交与评审
+	var submissions []struct {
+		ID              uint
+		StudentName     string
+		AssignmentTitle string
+		SubmittedAt     time.Time
+		GradedAt        *time.Time
+	}
+	if err := s.submissionScope(userID, userRole, s.db.Table("assignment_submissions").
+		Select("assignment_submissions.id, users.name AS student_name, assignments.title AS assignment_title, assignment_submissions.submitted_at, assignment_submissions.graded_at").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Joins("JOIN users ON users.id = assignment_submissions.student_id").
+		Order("GREATEST(assignment_submissions.submitted_at, COALESCE(assignment_submissions.graded_at, assignment_submissions.submitted_at)) DESC").
+		Limit(limit)).
+		Scan(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get recent submissions: %w", err)
+	}
+	for _, submission := range submissions {
+		activities = append(activities, Activity{
+			Type:        "submission",
+			TargetID:    submission.ID,
 			Title:       "作业提交",
-			Description: "学生张三提交了作业：数据库设计",
-			Timestamp:   time.Now().Add(-time.Hour * 4),
-		},
-		{
-			Title:       "文档更新",
-			Description: "更新了项目文档：API设计规范",
-			Timestamp:   time.Now().Add(-time.Hour * 6),
-		},
+			Description: fmt.Sprintf("学生%s提交了作业：%s", submission.StudentName, submission.AssignmentTitle),
+			Timestamp:   submission.SubmittedAt,
+		})
+		if submission.GradedAt != nil {
+			activities = append(activities, Activity{
+				Type:        "review",
+				TargetID:    submission.ID,
+				Title:       "作业评审",
+				Description: fmt.Sprintf("学生%s的作业「%s」已完成评审", submission.StudentName, submission.AssignmentTitle),
+				Timestamp:   *submission.GradedAt,
+			})
+		}
 	}
 
+	sort.Slice(activities, func(i, j int) bool {
+		return activities[i].Timestamp.After(activities[j].Timestamp)
+	})
+
 	// 根据limit限制返回的活动数量
-	if limit > 0 && limit < len(activities) {
+	if limit < len(activities) {
 		activities = activities[:limit]
 	}
 
 	return activities, nil
 }
 
-// 辅助方法 - 计算整体完成率
-func (s *AnalyticsService) calculateOverallCompletionRate() int {
-	return 75 // 简化实现
+// scopeFilter 按角色限制可见课题范围：管理员不限制，教师为自己创建的课题，学生为已加入的课题
+func (s *AnalyticsService) scopeFilter(projectColumn string, userID uint, userRole int) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		switch userRole {
+		case analyticsRoleAdmin:
+			return query
+		case analyticsRoleTeacher:
+			return query.Where(projectColumn+" IN (?)", s.db.Model(&models.Project{}).Select("id").Where("teacher_id = ?", userID))
+		case analyticsRoleStudent:
+			return query.Where(projectColumn+" IN (?)", s.db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ? AND is_active = true", userID))
+		default:
+			return query.Where("1 = 0")
+		}
+	}
 }
 
-// 辅助方法 - 计算教师完成率
-func (s *AnalyticsService) calculateTeacherCompletionRate(userID uint) int {
-	return 80 // 简化实现
+// submissionScope 限制提交查询范围，学生只统计自己的提交（查询需已关联assignments表）
+func (s *AnalyticsService) submissionScope(userID uint, userRole int, query *gorm.DB) *gorm.DB {
+	query = s.scopeFilter("assignments.project_id", userID, userRole)(query)
+	if userRole == analyticsRoleStudent {
+		query = query.Where("assignment_submissions.student_id = ?", userID)
+	}
+	return query
 }
 
-// 辅助方法 - 计算学生完成率
-func (s *AnalyticsService) calculateStudentCompletionRate(userID uint) int {
-	return 70 // 简化实现
-}
+// completionBy 按分组统计作业完成情况，groupColumn为空时统计总数（键为0）
+// 应提交人次为作业所属课题中的在读学生数，已提交人次为其中已提交作业的学生数
+func (s *AnalyticsService) completionBy(groupColumn string, filter func(*gorm.DB) *gorm.DB) (map[uint]completionCount, error) {
+	groupExpr := "0"
+	if groupColumn != "" {
+		groupExpr = groupColumn
+	}
 
-// 辅助方法 - 计算课题完成率
-func (s *AnalyticsService) calculateProjectCompletionRate(projectName string) int {
-	return 75 // 简化实现
-}
+	query := s.db.Table("assignments").
+		Select(groupExpr + ` AS group_id,
+			COUNT(*) AS expected,
+			SUM(CASE WHEN EXISTS (
+				SELECT 1 FROM assignment_submissions sub
+				WHERE sub.assignment_id = assignments.id AND sub.student_id = pm.user_id
+			) THEN 1 ELSE 0 END) AS completed`).
+		Joins(activeStudentJoin)
+	if groupColumn != "" {
+		query = query.Group(groupColumn)
+	}
+	if filter != nil {
+		query = filter(query)
+	}
 
-// 辅助方法 - 计算课题平均分
-func (s *AnalyticsService) calculateProjectAverageScore(projectName string) float64 {
-	return 82.5 // 简化实现
-}
+	var rows []completionCount
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to calculate completion: %w", err)
+	}
 
-// 辅助方法 - 计算学生作业数
-func (s *AnalyticsService) calculateStudentAssignmentCount(studentName string) int {
-	return 10 // 简化实现
+	result := make(map[uint]completionCount, len(rows))
+	for _, row := range rows {
+		result[row.GroupID] = row
+	}
+	return result, nil
 }
 
-// 辅助方法 - 计算学生完成数
-func (s *AnalyticsService) calculateStudentCompletedCount(studentName string) int {
-	return 8 // 简化实现
-}
+// averageScoreBy 按分组统计已评分提交的平均分，groupColumn为空时统计总体（键为0）
+func (s *AnalyticsService) averageScoreBy(groupColumn string, filter func(*gorm.DB) *gorm.DB) (map[uint]float64, error) {
+	groupExpr := "0"
+	if groupColumn != "" {
+		groupExpr = groupColumn
+	}
 
-// 辅助方法 - 计算学生平均分
-func (s *AnalyticsService) calculateStudentAverageScore(studentName string) float64 {
-	return 85.0 // 简化实现
-}
+	query := s.db.Table("assignment_submissions").
+		Select(groupExpr + " AS group_id, COALESCE(AVG(assignment_submissions.score), 0) AS average").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignment_submissions.status = 'graded' AND assignment_submissions.is_selected = true")
+	if groupColumn != "" {
+		query = query.Group(groupColumn)
+	}
+	if filter != nil {
+		query = filter(query)
+	}
 
-// 辅助方法 - 计算作业提交数
-func (s *AnalyticsService) calculateAssignmentSubmissionCount(assignmentTitle string) int {
-	return 20 // 简化实现
-}
+	var rows []struct {
+		GroupID uint
+		Average float64
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to calculate average score: %w", err)
+	}
 
-// 辅助方法 - 计算作业学生总数
-func (s *AnalyticsService) calculateAssignmentTotalStudents(assignmentTitle string) int {
-	return 25 // 简化实现
+	result := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		result[row.GroupID] = math.Round(row.Average*10) / 10
+	}
+	return result, nil
 }
 
-// 辅助方法 - 计算作业完成率
-func (s *AnalyticsService) calculateAssignmentCompletionRate(assignmentTitle string) int {
-	return 80 // 简化实现
-}
+// lastSubmissionBy 按分组获取最近一次提交时间
+func (s *AnalyticsService) lastSubmissionBy(groupColumn string, filter func(*gorm.DB) *gorm.DB) (map[uint]time.Time, error) {
+	query := s.db.Table("assignment_submissions").
+		Select(groupColumn + " AS group_id, MAX(assignment_submissions.submitted_at) AS last_at").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Group(groupColumn)
+	if filter != nil {
+		query = filter(query)
+	}
 
-// 辅助方法 - 计算作业平均分
-func (s *AnalyticsService) calculateAssignmentAverageScore(assignmentTitle string) float64 {
-	return 78.5 // 简化实现
+	var rows []struct {
+		GroupID uint
+		LastAt  time.Time
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get last submission time: %w", err)
+	}
+
+	result := make(map[uint]time.Time, len(rows))
+	for _, row := range rows {
+		result[row.GroupID] = row.LastAt
+	}
+	return result, nil
 }
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 连接测试数据库（TEST_DATABASE_DSN），未设置时跳过测试
+// 每个测试在事务中执行并回滚，不影响数据库中已有的数据
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect test database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Project{},
+		&models.ProjectMember{},
+		&models.Assignment{},
+		&models.AssignmentSubmission{},
+		&models.OAuthClient{},
+		&models.OAuthConsent{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthToken{},
+	); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("failed to begin transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+	})
+
+	// 管理员范围的统计不按用户隔离，清空相关表后再写入测试数据（随事务回滚）
+	if err := tx.Exec("TRUNCATE assignment_submissions, assignments, project_members, projects CASCADE").Error; err != nil {
+		t.Fatalf("failed to clear tables: %v", err)
+	}
+	return tx
+}
+
+// analyticsFixture 分析统计测试数据
+//
+//	课题P1（教师T1）：在读学生S1、S2，已退出学生S3；作业A1、A2
+//	课题P2（教师T2）：在读学生S3；作业A3
+//	A1：S1 第1次提交40分（未选用）、第2次提交90分；S2 70分
+//	A2：S1 已提交未评分
+//	A3：S3 50分
+type analyticsFixture struct {
+	teacher1, teacher2           models.User
+	student1, student2, student3 models.User
+	project1, project2           models.Project
+	assignment1, assignment2     models.Assignment
+	assignment3                  models.Assignment
+}
+
+func seedAnalyticsFixture(t *testing.T, db *gorm.DB) *analyticsFixture {
+	t.Helper()
+
+	suffix := time.Now().UnixNano()
+	userCount := 0
+	newUser := func(name string, role int) models.User {
+		userCount++
+		user := models.User{
+			GitLabID: -int(suffix%1000000)*10 - userCount, // 负数避免与真实GitLab用户冲突
+			Username: fmt.Sprintf("%s_%d", name, suffix),
+			Email:    fmt.Sprintf("%s_%d@example.com", name, suffix),
+			Name:     name,
+			Role:     role,
+		}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("failed to create user %s: %v", name, err)
+		}
+		return user
+	}
+	newProject := func(name string, teacher models.User) models.Project {
+		project := models.Project{
+			Name:      name,
+			Code:      fmt.Sprintf("%s_%d", name, suffix),
+			TeacherID: teacher.ID,
+		}
+		if err := db.Create(&project).Error; err != nil {
+			t.Fatalf("failed to create project %s: %v", name, err)
+		}
+		return project
+	}
+	addStudent := func(project models.Project, student models.User, active bool) {
+		member := models.ProjectMember{ProjectID: project.ID, UserID: student.ID, Role: "student"}
+		if err := db.Create(&member).Error; err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+		// is_active 默认为true，零值不会写入
+		if !active {
+			if err := db.Model(&member).Update("is_active", false).Error; err != nil {
+				t.Fatalf("failed to deactivate member: %v", err)
+			}
+		}
+	}
+	newAssignment := func(title string, project models.Project) models.Assignment {
+		assignment := models.Assignment{
+			Title:     title,
+			ProjectID: project.ID,
+			TeacherID: project.TeacherID,
+			DueDate:   time.Now().Add(-24 * time.Hour),
+		}
+		if err := db.Create(&assignment).Error; err != nil {
+			t.Fatalf("failed to create assignment %s: %v", title, err)
+		}
+		return assignment
+	}
+	submit := func(assignment models.Assignment, student models.User, status string, score float64, selected bool) {
+		submission := models.AssignmentSubmission{
+			AssignmentID: assignment.ID,
+			StudentID:    student.ID,
+			Status:       status,
+			Score:        score,
+			MaxScore:     100,
+		}
+		if err := db.Create(&submission).Error; err != nil {
+			t.Fatalf("failed to create submission: %v", err)
+		}
+		if !selected {
+			if err := db.Model(&submission).Update("is_selected", false).Error; err != nil {
+				t.Fatalf("failed to unselect submission: %v", err)
+			}
+		}
+	}
+
+	f := &analyticsFixture{}
+	f.teacher1 = newUser("teacher1", analyticsRoleTeacher)
+	f.teacher2 = newUser("teacher2", analyticsRoleTeacher)
+	f.student1 = newUser("student1", analyticsRoleStudent)
+	f.student2 = newUser("student2", analyticsRoleStudent)
+	f.student3 = newUser("student3", analyticsRoleStudent)
+
+	f.project1 = newProject("project1", f.teacher1)
+	f.project2 = newProject("project2", f.teacher2)
+	addStudent(f.project1, f.student1, true)
+	addStudent(f.project1, f.student2, true)
+	addStudent(f.project1, f.student3, false)
+	addStudent(f.project2, f.student3, true)
+
+	f.assignment1 = newAssignment("assignment1", f.project1)
+	f.assignment2 = newAssignment("assignment2", f.project1)
+	f.assignment3 = newAssignment("assignment3", f.project2)
+
+	submit(f.assignment1, f.student1, "graded", 40, false)
+	submit(f.assignment1, f.student1, "graded", 90, true)
+	submit(f.assignment1, f.student2, "graded", 70, true)
+	submit(f.assignment2, f.student1, "submitted", 0, true)
+	submit(f.assignment3, f.student3, "graded", 50, true)
+
+	return f
+}
+
+func TestAnalyticsCompletionAndAverageByRole(t *testing.T) {
+	db := openTestDB(t)
+	f := seedAnalyticsFixture(t, db)
+	s := NewAnalyticsService(db)
+
+	tests := []struct {
+		name          string
+		userID        uint
+		userRole      int
+		wantExpected  int64
+		wantCompleted int64
+		wantRate      float64
+		wantAverage   float64
+	}{
+		{name: "admin sees all projects", userID: f.teacher1.ID, userRole: analyticsRoleAdmin, wantExpected: 5, wantCompleted: 4, wantRate: 80, wantAverage: 70},
+		{name: "teacher sees own projects", userID: f.teacher1.ID, userRole: analyticsRoleTeacher, wantExpected: 4, wantCompleted: 3, wantRate: 75, wantAverage: 80},
+		{name: "other teacher", userID: f.teacher2.ID, userRole: analyticsRoleTeacher, wantExpected: 1, wantCompleted: 1, wantRate: 100, wantAverage: 50},
+		{name: "student sees joined projects", userID: f.student3.ID, userRole: analyticsRoleStudent, wantExpected: 1, wantCompleted: 1, wantRate: 100, wantAverage: 50},
+		{name: "unknown role sees nothing", userID: f.teacher1.ID, userRole: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := s.scopeFilter("assignments.project_id", tt.userID, tt.userRole)
+
+			completion, err := s.completionBy("", scope)
+			if err != nil {
+				t.Fatalf("completionBy() error = %v", err)
+			}
+			got := completion[0]
+			if got.Expected != tt.wantExpected || got.Completed != tt.wantCompleted {
+				t.Errorf("completionBy() = %d/%d, want %d/%d", got.Completed, got.Expected, tt.wantCompleted, tt.wantExpected)
+			}
+			if got.Rate() != tt.wantRate {
+				t.Errorf("Rate() = %v, want %v", got.Rate(), tt.wantRate)
+			}
+
+			averages, err := s.averageScoreBy("", scope)
+			if err != nil {
+				t.Fatalf("averageScoreBy() error = %v", err)
+			}
+			if averages[0] != tt.wantAverage {
+				t.Errorf("averageScoreBy() = %v, want %v", averages[0], tt.wantAverage)
+			}
+		})
+	}
+}
+
+func TestAnalyticsGroupedStats(t *testing.T) {
+	db := openTestDB(t)
+	f := seedAnalyticsFixture(t, db)
+	s := NewAnalyticsService(db)
+	scope := s.scopeFilter("assignments.project_id", f.teacher1.ID, analyticsRoleTeacher)
+
+	t.Run("completion by student", func(t *testing.T) {
+		completion, err := s.completionBy("pm.user_id", scope)
+		if err != nil {
+			t.Fatalf("completionBy() error = %v", err)
+		}
+		tests := []struct {
+			studentID     uint
+			wantCompleted int64
+			wantExpected  int64
+		}{
+			{f.student1.ID, 2, 2},
+			{f.student2.ID, 1, 2},
+			{f.student3.ID, 0, 0}, // 已退出课题的学生不计入
+		}
+		for _, tt := range tests {
+			got := completion[tt.studentID]
+			if got.Completed != tt.wantCompleted || got.Expected != tt.wantExpected {
+				t.Errorf("student %d completion = %d/%d, want %d/%d", tt.studentID, got.Completed, got.Expected, tt.wantCompleted, tt.wantExpected)
+			}
+		}
+	})
+
+	t.Run("average by assignment uses selected submissions", func(t *testing.T) {
+		averages, err := s.averageScoreBy("assignments.id", scope)
+		if err != nil {
+			t.Fatalf("averageScoreBy() error = %v", err)
+		}
+		tests := []struct {
+			assignmentID uint
+			want         float64
+		}{
+			{f.assignment1.ID, 80},
+			{f.assignment2.ID, 0}, // 没有已评分提交
+			{f.assignment3.ID, 0}, // 不在教师范围内
+		}
+		for _, tt := range tests {
+			if got := averages[tt.assignmentID]; got != tt.want {
+				t.Errorf("assignment %d average = %v, want %v", tt.assignmentID, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("last submission by project", func(t *testing.T) {
+		lastSubmissions, err := s.lastSubmissionBy("assignments.project_id", scope)
+		if err != nil {
+			t.Fatalf("lastSubmissionBy() error = %v", err)
+		}
+		if _, ok := lastSubmissions[f.project1.ID]; !ok {
+			t.Errorf("lastSubmissionBy() missing project %d", f.project1.ID)
+		}
+		if _, ok := lastSubmissions[f.project2.ID]; ok {
+			t.Errorf("lastSubmissionBy() includes project %d outside teacher scope", f.project2.ID)
+		}
+	})
+
+	t.Run("student stats", func(t *testing.T) {
+		stats, err := s.GetStudentStats(f.teacher1.ID, analyticsRoleTeacher)
+		if err != nil {
+			t.Fatalf("GetStudentStats() error = %v", err)
+		}
+		want := map[uint]StudentStat{
+			f.student1.ID: {AssignmentCount: 2, CompletedCount: 2, CompletionRate: 100, AverageScore: 90},
+			f.student2.ID: {AssignmentCount: 2, CompletedCount: 1, CompletionRate: 50, AverageScore: 70},
+		}
+		if len(stats) != len(want) {
+			t.Fatalf("GetStudentStats() returned %d students, want %d", len(stats), len(want))
+		}
+		for _, got := range stats {
+			w, ok := want[got.ID]
+			if !ok {
+				t.Errorf("unexpected student %d", got.ID)
+				continue
+			}
+			if got.AssignmentCount != w.AssignmentCount || got.CompletedCount != w.CompletedCount ||
+				got.CompletionRate != w.CompletionRate || got.AverageScore != w.AverageScore {
+				t.Errorf("student %d = %+v, want %+v", got.ID, got, w)
+			}
+		}
+	})
+}
+
+func TestAnalyticsGradeDistribution(t *testing.T) {
+	db := openTestDB(t)
+	f := seedAnalyticsFixture(t, db)
+	s := NewAnalyticsService(db)
+
+	tests := []struct {
+		name     string
+		userID   uint
+		userRole int
+		want     []float64 // 90-100, 80-89, 70-79, 60-69, 60以下
+	}{
+		{name: "admin", userID: f.teacher1.ID, userRole: analyticsRoleAdmin, want: []float64{1, 0, 1, 0, 1}},
+		{name: "teacher", userID: f.teacher1.ID, userRole: analyticsRoleTeacher, want: []float64{1, 0, 1, 0, 0}},
+		{name: "student only own submissions", userID: f.student2.ID, userRole: analyticsRoleStudent, want: []float64{0, 0, 1, 0, 0}},
+		{name: "student with unselected attempt", userID: f.student1.ID, userRole: analyticsRoleStudent, want: []float64{1, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := s.GetGradeDistribution(tt.userID, tt.userRole)
+			if err != nil {
+				t.Fatalf("GetGradeDistribution() error = %v", err)
+			}
+			got := data.Datasets[0].Data
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetGradeDistribution() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("GetGradeDistribution() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
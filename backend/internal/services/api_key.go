@@ -0,0 +1,271 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// API密钥参数
+const (
+	apiKeyPrefix             = "glx_"
+	apiKeyDisplayPrefixLen   = len(apiKeyPrefix) + 8 // 保存并显示的密钥开头长度
+	apiKeyDefaultExpiryDays  = 90
+	apiKeyMaxExpiryDays      = 365
+	apiKeyUsageWriteInterval = time.Minute // 最近使用时间的更新间隔，避免每个请求都写数据库
+)
+
+// APIScopes 可授予API密钥的权限范围
+var APIScopes = []string{
+	models.APIScopeReposRead,
+	models.APIScopeReposWrite,
+	models.APIScopeUsersRead,
+	models.APIScopeUsersWrite,
+	models.APIScopeAssignmentsRead,
+	models.APIScopeAssignmentsWrite,
+	models.APIScopePermissionsRead,
+	models.APIScopeStatusRead,
+	models.APIScopeWebhooks,
+	models.APIScopeAPIKeys,
+}
+
+// APIKeyService 第三方API密钥管理服务
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyService 创建API密钥管理服务
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// CreateAPIKeyRequest 创建API密钥请求
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays *int     `json:"expires_in_days"` // 有效天数，默认90天，0为不过期
+}
+
+// UpdateAPIKeyRequest 更新API密钥请求，未提供的字段保持不变
+type UpdateAPIKeyRequest struct {
+	Name   *string   `json:"name"`
+	Scopes *[]string `json:"scopes"`
+}
+
+// CreateAPIKey 创建API密钥，返回的明文密钥只在创建时返回一次
+// grantable 不为nil时新密钥的权限范围不能超出它（使用API密钥创建密钥时为当前密钥的范围）
+func (s *APIKeyService) CreateAPIKey(userID uint, req *CreateAPIKeyRequest, grantable *models.APIKey) (*models.APIKey, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("api key name is required")
+	}
+	scopes, err := normalizeAPIScopes(req.Scopes, grantable)
+	if err != nil {
+		return nil, "", err
+	}
+
+	days := apiKeyDefaultExpiryDays
+	if req.ExpiresInDays != nil {
+		days = *req.ExpiresInDays
+	}
+	if days < 0 || days > apiKeyMaxExpiryDays {
+		return nil, "", fmt.Errorf("expires_in_days must be between 0 and %d", apiKeyMaxExpiryDays)
+	}
+
+	rawKey, err := generateSecretToken(apiKeyPrefix)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := &models.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  rawKey[:apiKeyDisplayPrefixLen],
+		KeyHash: hashSecretToken(rawKey),
+		Scopes:  scopes,
+	}
+	if days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, days)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	return key, rawKey, nil
+}
+
+// ListAPIKeys 获取用户的API密钥，includeRevoked 为false时不含已撤销的密钥
+func (s *APIKeyService) ListAPIKeys(userID uint, includeRevoked bool) ([]models.APIKey, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if !includeRevoked {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKey 获取用户的API密钥
+func (s *APIKeyService) GetAPIKey(userID, keyID uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("id = ? AND user_id = ?", keyID, userID).First(&key).Error; err != nil {
+		return nil, fmt.Errorf("api key not found: %w", err)
+	}
+	return &key, nil
+}
+
+// UpdateAPIKey 修改API密钥的名称和权限范围
+func (s *APIKeyService) UpdateAPIKey(userID, keyID uint, req *UpdateAPIKeyRequest, grantable *models.APIKey) (*models.APIKey, error) {
+	key, err := s.GetAPIKey(userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("api key has been revoked")
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("api key name is required")
+		}
+		key.Name = name
+	}
+	if req.Scopes != nil {
+		scopes, err := normalizeAPIScopes(*req.Scopes, grantable)
+		if err != nil {
+			return nil, err
+		}
+		key.Scopes = scopes
+	}
+
+	if err := s.db.Save(key).Error; err != nil {
+		return nil, fmt.Errorf("failed to update api key: %w", err)
+	}
+	return key, nil
+}
+
+// SetAPIKeyRateLimit 设置API密钥的每分钟请求数，不限密钥所有者，由管理员调用；为0时恢复默认限额
+func (s *APIKeyService) SetAPIKeyRateLimit(keyID uint, perMinute int) (*models.APIKey, error) {
+	if perMinute < 0 {
+		return nil, fmt.Errorf("rate_limit_per_minute must not be negative")
+	}
+
+	var key models.APIKey
+	if err := s.db.First(&key, keyID).Error; err != nil {
+		return nil, fmt.Errorf("api key not found: %w", err)
+	}
+	if err := s.db.Model(&key).Update("rate_limit_per_minute", perMinute).Error; err != nil {
+		return nil, fmt.Errorf("failed to update api key rate limit: %w", err)
+	}
+	key.RateLimitPerMinute = perMinute
+	return &key, nil
+}
+
+// RevokeAPIKey 撤销API密钥，撤销后立即失效
+func (s *APIKeyService) RevokeAPIKey(userID, keyID uint) (*models.APIKey, error) {
+	key, err := s.GetAPIKey(userID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	now := time.Now()
+	if err := s.db.Model(key).Update("revoked_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	key.RevokedAt = &now
+	return key, nil
+}
+
+// Authenticate 校验明文密钥，返回密钥及其所有者，并记录最近使用时间和IP
+func (s *APIKeyService) Authenticate(rawKey, clientIP string) (*models.APIKey, *models.User, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, nil, fmt.Errorf("invalid api key")
+	}
+
+	var key models.APIKey
+	if err := s.db.Where("key_hash = ?", hashSecretToken(rawKey)).First(&key).Error; err != nil {
+		return nil, nil, fmt.Errorf("invalid api key")
+	}
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, nil, fmt.Errorf("api key has been revoked or expired")
+	}
+
+	var user models.User
+	if err := s.db.First(&user, key.UserID).Error; err != nil {
+		return nil, nil, fmt.Errorf("api key owner not found: %w", err)
+	}
+	if !user.Active {
+		return nil, nil, fmt.Errorf("api key owner is inactive")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyUsageWriteInterval || key.LastUsedIP != clientIP {
+		if err := s.db.Model(&key).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": clientIP,
+		}).Error; err != nil {
+			fmt.Printf("Warning: Failed to record api key %d usage: %v\n", key.ID, err)
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = clientIP
+	}
+
+	return &key, &user, nil
+}
+
+// normalizeAPIScopes 检查权限范围并去重，grantable 不为nil时不能超出它的范围
+func normalizeAPIScopes(scopes []string, grantable *models.APIKey) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	known := make(map[string]bool, len(APIScopes))
+	for _, scope := range APIScopes {
+		known[scope] = true
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !known[scope] {
+			return nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+		if grantable != nil && !grantable.HasScope(scope) {
+			return nil, fmt.Errorf("cannot grant scope %s not held by the current api key", scope)
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+// generateSecretToken 生成带前缀的随机令牌（API密钥、OAuth令牌等）
+func generateSecretToken(prefix string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// hashSecretToken 令牌的SHA-256哈希，令牌为随机生成的高熵字符串，无需加盐
+func hashSecretToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"gitlabex/internal/models"
@@ -11,22 +12,44 @@ import (
 
 // AssignmentService 作业管理服务
 type AssignmentService struct {
-	db                *gorm.DB
-	permissionService *PermissionService
-	gitlabService     *GitLabService
-	projectService    *ProjectService
+	db                  *gorm.DB
+	permissionService   *PermissionService
+	gitlabService       *GitLabService
+	projectService      *ProjectService
+	notificationService *NotificationService
+	achievementService  *AchievementService
+	autogradeService    *AutogradeService
+	webhookService      *WebhookService
+
+	pipelineLookupTimeout time.Duration // 提交后等待GitLab创建流水线的最长时间
 }
 
 // NewAssignmentService 创建作业管理服务
-func NewAssignmentService(db *gorm.DB, permissionService *PermissionService, gitlabService *GitLabService, projectService *ProjectService) *AssignmentService {
+func NewAssignmentService(db *gorm.DB, permissionService *PermissionService, gitlabService *GitLabService, projectService *ProjectService, notificationService *NotificationService) *AssignmentService {
 	return &AssignmentService{
-		db:                db,
-		permissionService: permissionService,
-		gitlabService:     gitlabService,
-		projectService:    projectService,
+		db:                  db,
+		permissionService:   permissionService,
+		gitlabService:       gitlabService,
+		projectService:      projectService,
+		notificationService: notificationService,
 	}
 }
 
+// SetAutogradeService 设置自动评测服务，用于在作业提交后运行测试
+func (s *AssignmentService) SetAutogradeService(autogradeService *AutogradeService) {
+	s.autogradeService = autogradeService
+}
+
+// SetAchievementService 设置成就服务，用于在作业提交和评审完成时评估成就
+func (s *AssignmentService) SetAchievementService(achievementService *AchievementService) {
+	s.achievementService = achievementService
+}
+
+// SetWebhookService 设置Webhook服务，用于向第三方推送作业、提交和评审事件
+func (s *AssignmentService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
 // CreateAssignmentRequest 创建作业请求
 type CreateAssignmentRequest struct {
 	Title       string    `json:"title" binding:"required"`
@@ -42,6 +65,20 @@ type CreateAssignmentRequest struct {
 	RequireCodeReview bool     `json:"require_code_review"` // 是否需要代码审查
 	MaxFileSize       int64    `json:"max_file_size"`       // 最大文件大小
 	AllowedFileTypes  []string `json:"allowed_file_types"`  // 允许的文件类型
+
+	// 提交策略
+	MaxAttempts     int  `json:"max_attempts" binding:"min=0"` // 最大提交次数，0表示不限
+	StrictFileCheck bool `json:"strict_file_check"`            // 文件检查不通过时拒绝提交
+
+	// 迟交策略
+	GracePeriodMinutes  int        `json:"grace_period_minutes" binding:"min=0"` // 宽限期（分钟）
+	LateUntil           *time.Time `json:"late_until"`                           // 最晚可提交时间，为空表示不接受迟交
+	LatePenaltyPercent  float64    `json:"late_penalty_percent"`                 // 每个扣分周期扣除的得分百分比
+	LatePenaltyInterval string     `json:"late_penalty_interval"`                // 扣分周期：hour, day
+
+	ReminderOffsets []int `json:"reminder_offsets"` // 截止前多少小时提醒未提交的学生，不传使用默认值，空数组表示不提醒
+
+	Rubric *RubricRequest `json:"rubric"` // 评分标准（可选）
 }
 
 // UpdateAssignmentRequest 更新作业请求
@@ -50,6 +87,19 @@ type UpdateAssignmentRequest struct {
 	Description string     `json:"description"`
 	DueDate     *time.Time `json:"due_date"`
 	Status      string     `json:"status"`
+
+	MaxAttempts     *int  `json:"max_attempts"`      // 最大提交次数，0表示不限
+	StrictFileCheck *bool `json:"strict_file_check"` // 文件检查不通过时拒绝提交
+
+	// 迟交策略，未传的字段保持不变
+	GracePeriodMinutes  *int       `json:"grace_period_minutes"`
+	LateUntil           *time.Time `json:"late_until"`
+	ClearLateUntil      bool       `json:"clear_late_until"` // 为true时取消迟交提交
+	LatePenaltyPercent  *float64   `json:"late_penalty_percent"`
+	LatePenaltyInterval string     `json:"late_penalty_interval"`
+
+	ReminderOffsets      *[]int `json:"reminder_offsets"`       // 截止提醒时间点，空数组表示不提醒
+	ResetReminderOffsets bool   `json:"reset_reminder_offsets"` // 为true时恢复默认提醒时间点
 }
 
 // SubmitAssignmentRequest 提交作业请求
@@ -78,6 +128,16 @@ func (s *AssignmentService) CreateAssignment(teacherID uint, req *CreateAssignme
 	if req.SubmissionBranch == "" {
 		req.SubmissionBranch = "assignment"
 	}
+	if req.LatePenaltyInterval == "" {
+		req.LatePenaltyInterval = LatePenaltyIntervalDay
+	}
+	if err := validateLatePolicy(req.DueDate, req.LateUntil, req.LatePenaltyPercent, req.LatePenaltyInterval); err != nil {
+		return nil, err
+	}
+	reminderOffsets, err := normalizeReminderOffsets(req.ReminderOffsets)
+	if err != nil {
+		return nil, err
+	}
 
 	assignment := &models.Assignment{
 		Title:       req.Title,
@@ -96,19 +156,44 @@ func (s *AssignmentService) CreateAssignment(teacherID uint, req *CreateAssignme
 		AllowedFileTypes:  req.AllowedFileTypes,
 		MRTitle:           fmt.Sprintf("Assignment: %s", req.Title),
 		MRDescription:     fmt.Sprintf("Assignment submission for: %s\n\n%s", req.Title, req.Description),
+		MaxAttempts:       req.MaxAttempts,
+		StrictFileCheck:   req.StrictFileCheck,
+		// 迟交策略
+		GracePeriodMinutes:  req.GracePeriodMinutes,
+		LateUntil:           req.LateUntil,
+		LatePenaltyPercent:  req.LatePenaltyPercent,
+		LatePenaltyInterval: req.LatePenaltyInterval,
+		ReminderOffsets:     reminderOffsets,
 	}
 
-	if err := s.db.Create(assignment).Error; err != nil {
-		return nil, fmt.Errorf("failed to create assignment: %w", err)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(assignment).Error; err != nil {
+			return fmt.Errorf("failed to create assignment: %w", err)
+		}
+
+		if req.Rubric != nil {
+			if _, err := s.createRubric(tx, teacherID, assignment.ID, req.Rubric); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 预加载关联数据
-	if err := s.db.Preload("Teacher").Preload("Project").First(assignment, assignment.ID).Error; err != nil {
+	if err := s.db.Preload("Teacher").Preload("Project").Preload("Rubric.Criteria").First(assignment, assignment.ID).Error; err != nil {
 		return nil, fmt.Errorf("failed to load assignment: %w", err)
 	}
 
 	// TODO: 发送通知给课题成员（待通知服务实现）
 
+	if s.webhookService != nil {
+		s.webhookService.EmitAssignmentCreated(assignment)
+	}
+
 	return assignment, nil
 }
 
@@ -118,6 +203,7 @@ func (s *AssignmentService) GetAssignmentByID(assignmentID uint) (*models.Assign
 	err := s.db.Preload("Teacher").
 		Preload("Project").
 		Preload("Submissions").
+		Preload("Rubric.Criteria").
 		First(&assignment, assignmentID).Error
 
 	if err != nil {
@@ -221,6 +307,44 @@ func (s *AssignmentService) UpdateAssignment(assignmentID uint, req *UpdateAssig
 	if req.Status != "" {
 		assignment.Status = req.Status
 	}
+	if req.MaxAttempts != nil {
+		if *req.MaxAttempts < 0 {
+			return nil, fmt.Errorf("max attempts must not be negative")
+		}
+		assignment.MaxAttempts = *req.MaxAttempts
+	}
+	if req.StrictFileCheck != nil {
+		assignment.StrictFileCheck = *req.StrictFileCheck
+	}
+	if req.GracePeriodMinutes != nil {
+		if *req.GracePeriodMinutes < 0 {
+			return nil, fmt.Errorf("grace period must not be negative")
+		}
+		assignment.GracePeriodMinutes = *req.GracePeriodMinutes
+	}
+	if req.ClearLateUntil {
+		assignment.LateUntil = nil
+	} else if req.LateUntil != nil {
+		assignment.LateUntil = req.LateUntil
+	}
+	if req.LatePenaltyPercent != nil {
+		assignment.LatePenaltyPercent = *req.LatePenaltyPercent
+	}
+	if req.LatePenaltyInterval != "" {
+		assignment.LatePenaltyInterval = req.LatePenaltyInterval
+	}
+	if err := validateLatePolicy(assignment.DueDate, assignment.LateUntil, assignment.LatePenaltyPercent, assignment.LatePenaltyInterval); err != nil {
+		return nil, err
+	}
+	if req.ResetReminderOffsets {
+		assignment.ReminderOffsets = nil
+	} else if req.ReminderOffsets != nil {
+		offsets, err := normalizeReminderOffsets(*req.ReminderOffsets)
+		if err != nil {
+			return nil, err
+		}
+		assignment.ReminderOffsets = offsets
+	}
 
 	if err := s.db.Save(&assignment).Error; err != nil {
 		return nil, fmt.Errorf("failed to update assignment: %w", err)
@@ -237,16 +361,42 @@ func (s *AssignmentService) UpdateAssignment(assignmentID uint, req *UpdateAssig
 // DeleteAssignment 删除作业
 func (s *AssignmentService) DeleteAssignment(assignmentID uint) error {
 	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 删除评审维度得分
+		if err := tx.Where("review_id IN (SELECT reviews.id FROM reviews JOIN assignment_submissions ON assignment_submissions.id = reviews.submission_id WHERE assignment_submissions.assignment_id = ?)", assignmentID).Delete(&models.ReviewCriterionScore{}).Error; err != nil {
+			return fmt.Errorf("failed to delete criterion scores: %w", err)
+		}
+
 		// 删除评审
 		if err := tx.Where("submission_id IN (SELECT id FROM assignment_submissions WHERE assignment_id = ?)", assignmentID).Delete(&models.Review{}).Error; err != nil {
 			return fmt.Errorf("failed to delete reviews: %w", err)
 		}
 
+		// 删除自动评测记录和配置
+		if err := tx.Where("submission_id IN (SELECT id FROM assignment_submissions WHERE assignment_id = ?)", assignmentID).Delete(&models.AutogradeRun{}).Error; err != nil {
+			return fmt.Errorf("failed to delete autograde runs: %w", err)
+		}
+		if err := tx.Where("assignment_id = ?", assignmentID).Delete(&models.AutogradeSuite{}).Error; err != nil {
+			return fmt.Errorf("failed to delete autograde suite: %w", err)
+		}
+
 		// 删除作业提交
 		if err := tx.Where("assignment_id = ?", assignmentID).Delete(&models.AssignmentSubmission{}).Error; err != nil {
 			return fmt.Errorf("failed to delete submissions: %w", err)
 		}
 
+		// 删除学生延期
+		if err := tx.Where("assignment_id = ?", assignmentID).Delete(&models.AssignmentExtension{}).Error; err != nil {
+			return fmt.Errorf("failed to delete extensions: %w", err)
+		}
+
+		// 删除评分标准
+		if err := tx.Where("rubric_id IN (SELECT id FROM rubrics WHERE assignment_id = ?)", assignmentID).Delete(&models.RubricCriterion{}).Error; err != nil {
+			return fmt.Errorf("failed to delete rubric criteria: %w", err)
+		}
+		if err := tx.Where("assignment_id = ?", assignmentID).Delete(&models.Rubric{}).Error; err != nil {
+			return fmt.Errorf("failed to delete rubric: %w", err)
+		}
+
 		// 删除作业
 		if err := tx.Delete(&models.Assignment{}, assignmentID).Error; err != nil {
 			return fmt.Errorf("failed to delete assignment: %w", err)
@@ -266,20 +416,26 @@ func (s *AssignmentService) SubmitAssignment(studentID uint, assignmentID uint,
 
 	// 验证学生是否在课题中
 	var member models.ProjectMember
-	if err := s.db.Where("project_id = ? AND student_id = ? AND status = 'active'", assignment.ProjectID, studentID).First(&member).Error; err != nil {
+	if err := s.db.Preload("User").Where("project_id = ? AND user_id = ? AND is_active = true", assignment.ProjectID, studentID).First(&member).Error; err != nil {
 		return nil, fmt.Errorf("student not in project or inactive: %w", err)
 	}
 
-	// 检查是否已经提交过
-	var existingSubmission models.AssignmentSubmission
-	if err := s.db.Where("assignment_id = ? AND student_id = ?", assignmentID, studentID).First(&existingSubmission).Error; err == nil {
-		return nil, fmt.Errorf("assignment already submitted")
+	// 检查提交次数
+	attemptNumber, selectAttempt, err := nextSubmissionAttempt(s.db, &assignment, studentID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 检查截止日期
-	if time.Now().After(assignment.DueDate) {
+	// 检查截止日期（含宽限期、迟交期限和学生延期）
+	deadline, err := s.GetSubmissionDeadline(&assignment, studentID)
+	if err != nil {
+		return nil, err
+	}
+	submittedAt := time.Now()
+	if submittedAt.After(deadline.Cutoff) {
 		return nil, fmt.Errorf("assignment deadline has passed")
 	}
+	isLate, lateMinutes := deadline.lateness(submittedAt)
 
 	// 如果有文件需要提交到GitLab
 	if len(req.Files) > 0 && assignment.Project.GitLabProjectID > 0 {
@@ -289,6 +445,18 @@ func (s *AssignmentService) SubmitAssignment(studentID uint, assignmentID uint,
 			return nil, fmt.Errorf("failed to submit to GitLab: %w", err)
 		}
 
+		// 记录迟交信息
+		if isLate {
+			submission.IsLate = true
+			submission.LateMinutes = lateMinutes
+			if err := s.db.Model(submission).Updates(map[string]interface{}{
+				"is_late":      true,
+				"late_minutes": lateMinutes,
+			}).Error; err != nil {
+				return nil, fmt.Errorf("failed to mark late submission: %w", err)
+			}
+		}
+
 		// 如果需要自动创建MR
 		if req.AutoCreateMR || assignment.AutoCreateMR {
 			mrTitle := fmt.Sprintf("Assignment: %s - %s", assignment.Title, member.User.Username)
@@ -312,19 +480,51 @@ func (s *AssignmentService) SubmitAssignment(studentID uint, assignmentID uint,
 			}
 		}
 
+		// GitLab提交会增加代码提交次数
+		s.evaluateAchievements(studentID, models.AchievementRuleOnTimeStreak, models.AchievementRuleCommits)
+
+		if s.webhookService != nil {
+			s.webhookService.EmitSubmissionCreated(submission, &assignment)
+		}
+
 		return submission, nil
 	} else {
-		// 传统方式提交（不使用GitLab）
+		// 传统方式提交（不使用GitLab），课题关联GitLab时检查学生分支的文件
+		report, err := checkSubmission(s.gitlabService, &assignment, assignment.Project.GitLabProjectID, member.PersonalBranch, nil)
+		if err != nil {
+			return nil, err
+		}
+
 		submission := &models.AssignmentSubmission{
-			AssignmentID: assignmentID,
-			StudentID:    studentID,
-			Content:      req.Content,
-			Status:       "submitted",
-			SubmittedAt:  time.Now(),
+			AssignmentID:  assignmentID,
+			StudentID:     studentID,
+			Content:       req.Content,
+			Status:        "submitted",
+			SubmittedAt:   submittedAt,
+			IsLate:        isLate,
+			LateMinutes:   lateMinutes,
+			AttemptNumber: attemptNumber,
+		}
+		if report.FileCheck != nil && report.FileCheck.Commit != "" {
+			submission.CommitHash = report.FileCheck.Commit
+			submission.BranchName = member.PersonalBranch
+			submission.BranchURL = member.PersonalBranchURL
+			submission.PipelineStatus = models.PipelineStatusWaiting
+		}
+		applySubmissionCheck(submission, report)
+
+		if err := createSubmissionAttempt(s.db, submission, selectAttempt); err != nil {
+			return nil, err
+		}
+
+		if s.autogradeService != nil && submission.CommitHash != "" {
+			s.autogradeService.EnqueueSubmissionQuietly(submission.ID)
 		}
 
-		if err := s.db.Create(submission).Error; err != nil {
-			return nil, fmt.Errorf("failed to create submission: %w", err)
+		s.evaluateAchievements(studentID, models.AchievementRuleOnTimeStreak)
+
+		if s.webhookService != nil {
+			s.webhookService.EmitSubmissionCreated(submission, &assignment)
 		}
 
 		return submission, nil
@@ -335,7 +535,7 @@ func (s *AssignmentService) SubmitAssignment(studentID uint, assignmentID uint,
 func (s *AssignmentService) GetSubmissionsByAssignment(assignmentID uint) ([]models.AssignmentSubmission, error) {
 	var submissions []models.AssignmentSubmission
 	err := s.db.Preload("Student").
-		Preload("Review").
+		Preload("Reviews").
 		Where("assignment_id = ?", assignmentID).
 		Order("submitted_at DESC").
 		Find(&submissions).Error
@@ -347,11 +547,11 @@ func (s *AssignmentService) GetSubmissionsByAssignment(assignmentID uint) ([]mod
 	return submissions, nil
 }
 
-// GetSubmissionsByStudent 获取学生的所有提交
+// GetSubmissionsByStudent 获取学生的所有提交，只包含已完成的评审
 func (s *AssignmentService) GetSubmissionsByStudent(studentID uint) ([]models.AssignmentSubmission, error) {
 	var submissions []models.AssignmentSubmission
 	err := s.db.Preload("Assignment").
-		Preload("Review").
+		Preload("Reviews", "status = ?", "completed").
 		Where("student_id = ?", studentID).
 		Order("submitted_at DESC").
 		Find(&submissions).Error
@@ -363,15 +563,26 @@ func (s *AssignmentService) GetSubmissionsByStudent(studentID uint) ([]models.As
 	return submissions, nil
 }
 
-// GetSubmissionByID 根据ID获取作业提交
-func (s *AssignmentService) GetSubmissionByID(submissionID uint) (*models.AssignmentSubmission, error) {
+// GetSubmissionByID 根据ID获取作业提交，只有提交的学生和有评审权限的用户可以查看
+// 学生只能看到已完成的评审，评审中的草稿只对评审者可见
+func (s *AssignmentService) GetSubmissionByID(userID, submissionID uint) (*models.AssignmentSubmission, error) {
 	var submission models.AssignmentSubmission
-	err := s.db.Preload("Assignment").
-		Preload("Student").
-		Preload("Review").
-		First(&submission, submissionID).Error
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
 
-	if err != nil {
+	canReview := s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "review")
+	if !canReview && !(submission.StudentID == userID && s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "read")) {
+		return nil, fmt.Errorf("permission denied to view this submission")
+	}
+
+	query := s.db.Preload("Assignment").Preload("Student")
+	if canReview {
+		query = query.Preload("Reviews")
+	} else {
+		query = query.Preload("Reviews", "status = ?", "completed")
+	}
+	if err := query.First(&submission, submissionID).Error; err != nil {
 		return nil, fmt.Errorf("submission not found: %w", err)
 	}
 
@@ -416,10 +627,10 @@ func (s *AssignmentService) GetSubmissionWithGitLabInfo(submissionID uint) (*mod
 func (s *AssignmentService) GetAssignmentStats(assignmentID uint) (*models.AssignmentStats, error) {
 	stats := &models.AssignmentStats{}
 
-	// 总提交数
+	// 总提交数（每个学生只统计用于评分的提交）
 	var totalSubmissions int64
 	if err := s.db.Model(&models.AssignmentSubmission{}).
-		Where("assignment_id = ?", assignmentID).
+		Where("assignment_id = ? AND is_selected = true", assignmentID).
 		Count(&totalSubmissions).Error; err != nil {
 		return nil, fmt.Errorf("failed to count total submissions: %w", err)
 	}
@@ -428,7 +639,7 @@ func (s *AssignmentService) GetAssignmentStats(assignmentID uint) (*models.Assig
 	// 已评审提交数
 	var reviewedSubmissions int64
 	if err := s.db.Model(&models.AssignmentSubmission{}).
-		Where("assignment_id = ? AND status = 'reviewed'", assignmentID).
+		Where("assignment_id = ? AND status = 'graded' AND is_selected = true", assignmentID).
 		Count(&reviewedSubmissions).Error; err != nil {
 		return nil, fmt.Errorf("failed to count reviewed submissions: %w", err)
 	}
@@ -441,7 +652,7 @@ func (s *AssignmentService) GetAssignmentStats(assignmentID uint) (*models.Assig
 	if stats.ReviewedSubmissions > 0 {
 		var totalScore float64
 		err := s.db.Model(&models.AssignmentSubmission{}).
-			Where("assignment_id = ? AND status = 'reviewed'", assignmentID).
+			Where("assignment_id = ? AND status = 'graded' AND is_selected = true", assignmentID).
 			Select("AVG(score)").
 			Scan(&totalScore).Error
 		if err != nil {
@@ -450,6 +661,13 @@ func (s *AssignmentService) GetAssignmentStats(assignmentID uint) (*models.Assig
 		stats.AverageScore = totalScore
 	}
 
+	// 评分标准各维度平均得分
+	criterionAverages, err := s.getCriterionAverages(assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	stats.CriterionAverages = criterionAverages
+
 	return stats, nil
 }
 
@@ -530,3 +748,602 @@ type GitLabSubmissionStats struct {
 	MRStatus     string     `json:"mr_status"`
 	MRUrl        string     `json:"mr_url"`
 }
+
+// ===== 作业评审 =====
+
+// 评审维度权重（各维度评分均为0-100）
+const (
+	reviewWeightCodeQuality   = 0.25
+	reviewWeightFunctionality = 0.30
+	reviewWeightDocumentation = 0.15
+	reviewWeightCodeStyle     = 0.10
+	reviewWeightTestCoverage  = 0.10
+	reviewWeightCreativity    = 0.10
+)
+
+// CreateReviewRequest 创建评审请求
+type CreateReviewRequest struct {
+	CodeQuality    float64 `json:"code_quality" binding:"min=0,max=100"`
+	Functionality  float64 `json:"functionality" binding:"min=0,max=100"`
+	Documentation  float64 `json:"documentation" binding:"min=0,max=100"`
+	CodeStyle      float64 `json:"code_style" binding:"min=0,max=100"`
+	TestCoverage   float64 `json:"test_coverage" binding:"min=0,max=100"`
+	Creativity     float64 `json:"creativity" binding:"min=0,max=100"`
+	Feedback       string  `json:"feedback"`
+	Suggestions    string  `json:"suggestions"`
+	Strengths      string  `json:"strengths"`
+	Weaknesses     string  `json:"weaknesses"`
+	OverallComment string  `json:"overall_comment"`
+
+	// 作业配置了评分标准时按维度打分
+	CriterionScores []CriterionScoreRequest `json:"criterion_scores" binding:"dive"`
+}
+
+// CriterionScoreRequest 评分维度得分请求
+type CriterionScoreRequest struct {
+	CriterionID uint    `json:"criterion_id" binding:"required"`
+	Points      float64 `json:"points" binding:"min=0"`
+	Comment     string  `json:"comment"`
+}
+
+// UpdateReviewRequest 更新评审请求
+type UpdateReviewRequest struct {
+	CodeQuality    *float64 `json:"code_quality" binding:"omitempty,min=0,max=100"`
+	Functionality  *float64 `json:"functionality" binding:"omitempty,min=0,max=100"`
+	Documentation  *float64 `json:"documentation" binding:"omitempty,min=0,max=100"`
+	CodeStyle      *float64 `json:"code_style" binding:"omitempty,min=0,max=100"`
+	TestCoverage   *float64 `json:"test_coverage" binding:"omitempty,min=0,max=100"`
+	Creativity     *float64 `json:"creativity" binding:"omitempty,min=0,max=100"`
+	Feedback       *string  `json:"feedback"`
+	Suggestions    *string  `json:"suggestions"`
+	Strengths      *string  `json:"strengths"`
+	Weaknesses     *string  `json:"weaknesses"`
+	OverallComment *string  `json:"overall_comment"`
+
+	CriterionScores []CriterionScoreRequest `json:"criterion_scores" binding:"dive"`
+}
+
+// FinalizeReviewRequest 完成评审请求
+type FinalizeReviewRequest struct {
+	Status string `json:"status" binding:"required,oneof=graded returned"` // graded: 评分完成, returned: 退回修改
+}
+
+// CreateReview 创建评审（老师/助教权限）
+func (s *AssignmentService) CreateReview(reviewerID, submissionID uint, req *CreateReviewRequest) (*models.Review, error) {
+	var submission models.AssignmentSubmission
+	if err := s.db.Preload("Assignment").First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	if !s.permissionService.CanAccessAssignment(reviewerID, submission.AssignmentID, "review") {
+		return nil, fmt.Errorf("permission denied to review this submission")
+	}
+
+	if submission.Status != "submitted" {
+		return nil, fmt.Errorf("submission has already been %s", submission.Status)
+	}
+
+	// 多次提交时只评审选中的提交
+	if !submission.IsSelected {
+		return nil, fmt.Errorf("only the selected attempt can be reviewed")
+	}
+
+	rubric, err := s.getRubricByAssignment(submission.AssignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	criterionScores, err := buildCriterionScores(rubric, nil, req.CriterionScores)
+	if err != nil {
+		return nil, err
+	}
+
+	review := &models.Review{
+		SubmissionID:   submissionID,
+		ReviewerID:     reviewerID,
+		Status:         "pending",
+		CodeQuality:    req.CodeQuality,
+		Functionality:  req.Functionality,
+		Documentation:  req.Documentation,
+		CodeStyle:      req.CodeStyle,
+		TestCoverage:   req.TestCoverage,
+		Creativity:     req.Creativity,
+		Feedback:       req.Feedback,
+		Suggestions:    req.Suggestions,
+		Strengths:      req.Strengths,
+		Weaknesses:     req.Weaknesses,
+		OverallComment: req.OverallComment,
+
+		CriterionScores: criterionScores,
+	}
+	review.Score = calculateReviewScore(review, rubric, submission.MaxScore)
+
+	if err := s.db.Create(review).Error; err != nil {
+		return nil, fmt.Errorf("failed to create review: %w", err)
+	}
+
+	if err := s.preloadReview(s.db).First(review, review.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load review: %w", err)
+	}
+
+	return review, nil
+}
+
+// GetReviewsBySubmission 获取作业提交的评审列表
+func (s *AssignmentService) GetReviewsBySubmission(userID, submissionID uint) ([]models.Review, error) {
+	var submission models.AssignmentSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	// 学生只能查看自己提交的评审
+	if submission.StudentID != userID && !s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "review") {
+		return nil, fmt.Errorf("permission denied to view reviews of this submission")
+	}
+
+	query := s.preloadReview(s.db).Where("submission_id = ?", submissionID)
+	if submission.StudentID == userID {
+		// 未完成的评审对学生不可见
+		query = query.Where("status = 'completed'")
+	}
+
+	var reviews []models.Review
+	if err := query.Order("created_at DESC").Find(&reviews).Error; err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+
+	return reviews, nil
+}
+
+// UpdateReview 更新评审（仅评审人可修改未完成的评审）
+func (s *AssignmentService) UpdateReview(reviewerID, submissionID, reviewID uint, req *UpdateReviewRequest) (*models.Review, error) {
+	review, submission, err := s.getPendingReview(reviewerID, submissionID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CodeQuality != nil {
+		review.CodeQuality = *req.CodeQuality
+	}
+	if req.Functionality != nil {
+		review.Functionality = *req.Functionality
+	}
+	if req.Documentation != nil {
+		review.Documentation = *req.Documentation
+	}
+	if req.CodeStyle != nil {
+		review.CodeStyle = *req.CodeStyle
+	}
+	if req.TestCoverage != nil {
+		review.TestCoverage = *req.TestCoverage
+	}
+	if req.Creativity != nil {
+		review.Creativity = *req.Creativity
+	}
+	if req.Feedback != nil {
+		review.Feedback = *req.Feedback
+	}
+	if req.Suggestions != nil {
+		review.Suggestions = *req.Suggestions
+	}
+	if req.Strengths != nil {
+		review.Strengths = *req.Strengths
+	}
+	if req.Weaknesses != nil {
+		review.Weaknesses = *req.Weaknesses
+	}
+	if req.OverallComment != nil {
+		review.OverallComment = *req.OverallComment
+	}
+
+	rubric, err := s.getRubricByAssignment(submission.AssignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	review.CriterionScores, err = buildCriterionScores(rubric, review.CriterionScores, req.CriterionScores)
+	if err != nil {
+		return nil, err
+	}
+	review.Score = calculateReviewScore(review, rubric, submission.MaxScore)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("CriterionScores").Save(review).Error; err != nil {
+			return fmt.Errorf("failed to update review: %w", err)
+		}
+		for i := range review.CriterionScores {
+			review.CriterionScores[i].ReviewID = review.ID
+			if err := tx.Omit("Criterion").Save(&review.CriterionScores[i]).Error; err != nil {
+				return fmt.Errorf("failed to save criterion score: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.preloadReview(s.db).First(review, review.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload review: %w", err)
+	}
+
+	return review, nil
+}
+
+// FinalizeReview 完成评审：计算加权得分并更新作业提交状态
+func (s *AssignmentService) FinalizeReview(reviewerID, submissionID, reviewID uint, req *FinalizeReviewRequest) (*models.Review, error) {
+	review, submission, err := s.getPendingReview(reviewerID, submissionID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if submission.Status != "submitted" {
+		return nil, fmt.Errorf("submission has already been %s", submission.Status)
+	}
+
+	rubric, err := s.getRubricByAssignment(submission.AssignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 配置了评分标准时，所有维度都必须打分
+	if rubric != nil && len(review.CriterionScores) < len(rubric.Criteria) {
+		return nil, fmt.Errorf("all rubric criteria must be scored before finalizing")
+	}
+
+	var assignment models.Assignment
+	if err := s.db.First(&assignment, submission.AssignmentID).Error; err != nil {
+		return nil, fmt.Errorf("assignment not found: %w", err)
+	}
+
+	now := time.Now()
+	review.Score = calculateReviewScore(review, rubric, submission.MaxScore)
+	review.Status = "completed"
+
+	// 迟交提交自动扣分，评审记录保留原始得分
+	penalty := 0.0
+	if submission.IsLate {
+		penalty = calculateLatePenalty(&assignment, submission.LateMinutes)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("CriterionScores").Save(review).Error; err != nil {
+			return fmt.Errorf("failed to complete review: %w", err)
+		}
+
+		feedback := review.OverallComment
+		if feedback == "" {
+			feedback = review.Feedback
+		}
+
+		updates := map[string]interface{}{
+			"feedback": feedback,
+			"status":   req.Status,
+		}
+		// 退回的提交只记录反馈，不产生最终成绩
+		if req.Status == "graded" {
+			updates["score"] = applyLatePenalty(review.Score, penalty)
+			updates["raw_score"] = review.Score
+			updates["late_penalty_percent"] = penalty
+			updates["graded_at"] = now
+		}
+		if err := tx.Model(submission).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update submission: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.notificationService != nil {
+		if err := s.notificationService.NotifyAssignmentReviewed(review); err != nil {
+			// 通知失败不影响评审结果
+			fmt.Printf("Warning: Failed to notify assignment reviewed: %v\n", err)
+		}
+	}
+	if s.webhookService != nil {
+		s.webhookService.EmitReviewCompleted(review, submission)
+	}
+
+	if req.Status == "graded" {
+		s.evaluateAchievements(submission.StudentID, models.AchievementRulePerfectScores)
+	}
+
+	if err := s.preloadReview(s.db).First(review, review.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload review: %w", err)
+	}
+
+	return review, nil
+}
+
+// preloadReview 预加载评审的评审人和维度得分
+func (s *AssignmentService) preloadReview(db *gorm.DB) *gorm.DB {
+	return db.Preload("Reviewer").Preload("CriterionScores.Criterion")
+}
+
+// getPendingReview 获取评审人可操作的未完成评审
+func (s *AssignmentService) getPendingReview(reviewerID, submissionID, reviewID uint) (*models.Review, *models.AssignmentSubmission, error) {
+	var review models.Review
+	if err := s.db.Preload("CriterionScores").Where("id = ? AND submission_id = ?", reviewID, submissionID).First(&review).Error; err != nil {
+		return nil, nil, fmt.Errorf("review not found: %w", err)
+	}
+
+	var submission models.AssignmentSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return nil, nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	if review.ReviewerID != reviewerID || !s.permissionService.CanAccessAssignment(reviewerID, submission.AssignmentID, "review") {
+		return nil, nil, fmt.Errorf("permission denied to modify this review")
+	}
+
+	if review.Status == "completed" {
+		return nil, nil, fmt.Errorf("review has already been finalized")
+	}
+
+	return &review, &submission, nil
+}
+
+// calculateReviewScore 计算评审得分并换算到提交满分
+// 作业配置了评分标准时按维度权重计算，否则使用默认评审维度权重
+func calculateReviewScore(review *models.Review, rubric *models.Rubric, maxScore float64) float64 {
+	if maxScore <= 0 {
+		maxScore = 100
+	}
+
+	var percent float64
+	if rubric != nil {
+		points := make(map[uint]float64, len(review.CriterionScores))
+		for _, score := range review.CriterionScores {
+			points[score.CriterionID] = score.Points
+		}
+
+		var totalWeight, weighted float64
+		for _, criterion := range rubric.Criteria {
+			totalWeight += criterion.Weight
+			weighted += criterion.Weight * points[criterion.ID] / criterion.MaxPoints
+		}
+		if totalWeight > 0 {
+			percent = weighted / totalWeight * 100
+		}
+	} else {
+		percent = review.CodeQuality*reviewWeightCodeQuality +
+			review.Functionality*reviewWeightFunctionality +
+			review.Documentation*reviewWeightDocumentation +
+			review.CodeStyle*reviewWeightCodeStyle +
+			review.TestCoverage*reviewWeightTestCoverage +
+			review.Creativity*reviewWeightCreativity
+	}
+
+	return math.Round(percent*maxScore) / 100
+}
+
+// ===== 评分标准 =====
+
+// RubricRequest 评分标准请求
+type RubricRequest struct {
+	Name        string                   `json:"name" binding:"required"`
+	Description string                   `json:"description"`
+	Criteria    []RubricCriterionRequest `json:"criteria" binding:"required,min=1,dive"`
+}
+
+// RubricCriterionRequest 评分维度请求
+type RubricCriterionRequest struct {
+	Name        string               `json:"name" binding:"required"`
+	Description string               `json:"description"`
+	Weight      float64              `json:"weight" binding:"min=0"` // 为0时默认为1
+	MaxPoints   float64              `json:"max_points" binding:"required,gt=0"`
+	Levels      []models.RubricLevel `json:"levels"`
+}
+
+// GetRubric 获取作业的评分标准
+func (s *AssignmentService) GetRubric(assignmentID uint) (*models.Rubric, error) {
+	rubric, err := s.getRubricByAssignment(assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if rubric == nil {
+		return nil, fmt.Errorf("rubric not found")
+	}
+	return rubric, nil
+}
+
+// SaveRubric 创建或替换作业的评分标准（老师权限）
+func (s *AssignmentService) SaveRubric(userID, assignmentID uint, req *RubricRequest) (*models.Rubric, error) {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return nil, fmt.Errorf("permission denied to manage rubric of this assignment")
+	}
+
+	var rubric *models.Rubric
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.deleteRubric(tx, assignmentID); err != nil {
+			return err
+		}
+
+		var err error
+		rubric, err = s.createRubric(tx, userID, assignmentID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRubric(rubric.AssignmentID)
+}
+
+// DeleteRubric 删除作业的评分标准（老师权限）
+func (s *AssignmentService) DeleteRubric(userID, assignmentID uint) error {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return fmt.Errorf("permission denied to manage rubric of this assignment")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.deleteRubric(tx, assignmentID)
+	})
+}
+
+// createRubric 创建评分标准及其维度
+func (s *AssignmentService) createRubric(tx *gorm.DB, userID, assignmentID uint, req *RubricRequest) (*models.Rubric, error) {
+	rubric := &models.Rubric{
+		AssignmentID: assignmentID,
+		Name:         req.Name,
+		Description:  req.Description,
+		CreatedBy:    userID,
+	}
+
+	for i, c := range req.Criteria {
+		weight := c.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		for _, level := range c.Levels {
+			if level.Points < 0 || level.Points > c.MaxPoints {
+				return nil, fmt.Errorf("level %q of criterion %q is out of range 0-%.1f", level.Name, c.Name, c.MaxPoints)
+			}
+		}
+
+		rubric.Criteria = append(rubric.Criteria, models.RubricCriterion{
+			Name:        c.Name,
+			Description: c.Description,
+			Weight:      weight,
+			MaxPoints:   c.MaxPoints,
+			Levels:      c.Levels,
+			SortOrder:   i,
+		})
+	}
+
+	if err := tx.Create(rubric).Error; err != nil {
+		return nil, fmt.Errorf("failed to create rubric: %w", err)
+	}
+
+	return rubric, nil
+}
+
+// deleteRubric 删除评分标准，已被评审使用的评分标准不能删除
+func (s *AssignmentService) deleteRubric(tx *gorm.DB, assignmentID uint) error {
+	var rubric models.Rubric
+	if err := tx.Where("assignment_id = ?", assignmentID).First(&rubric).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to get rubric: %w", err)
+	}
+
+	var scored int64
+	if err := tx.Model(&models.ReviewCriterionScore{}).
+		Where("criterion_id IN (SELECT id FROM rubric_criteria WHERE rubric_id = ?)", rubric.ID).
+		Count(&scored).Error; err != nil {
+		return fmt.Errorf("failed to check rubric usage: %w", err)
+	}
+	if scored > 0 {
+		return fmt.Errorf("rubric is already used by reviews and cannot be changed")
+	}
+
+	if err := tx.Where("rubric_id = ?", rubric.ID).Delete(&models.RubricCriterion{}).Error; err != nil {
+		return fmt.Errorf("failed to delete rubric criteria: %w", err)
+	}
+	if err := tx.Delete(&rubric).Error; err != nil {
+		return fmt.Errorf("failed to delete rubric: %w", err)
+	}
+
+	return nil
+}
+
+// getRubricByAssignment 获取作业的评分标准，未配置时返回nil
+func (s *AssignmentService) getRubricByAssignment(assignmentID uint) (*models.Rubric, error) {
+	var rubric models.Rubric
+	err := s.db.Preload("Criteria", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC, id ASC")
+	}).Where("assignment_id = ?", assignmentID).First(&rubric).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rubric: %w", err)
+	}
+
+	return &rubric, nil
+}
+
+// getCriterionAverages 统计作业已完成评审中各评分维度的平均得分
+func (s *AssignmentService) getCriterionAverages(assignmentID uint) ([]models.CriterionAverage, error) {
+	var averages []models.CriterionAverage
+	err := s.db.Table("rubric_criteria").
+		Select(`rubric_criteria.id AS criterion_id,
+			rubric_criteria.name,
+			rubric_criteria.max_points,
+			COALESCE(AVG(review_criterion_scores.points), 0) AS average_points,
+			COALESCE(AVG(review_criterion_scores.points / rubric_criteria.max_points) * 100, 0) AS average_percent,
+			COUNT(review_criterion_scores.id) AS scored_count`).
+		Joins("JOIN rubrics ON rubrics.id = rubric_criteria.rubric_id").
+		Joins(`LEFT JOIN review_criterion_scores ON review_criterion_scores.criterion_id = rubric_criteria.id
+			AND review_criterion_scores.review_id IN (
+				SELECT reviews.id FROM reviews
+				JOIN assignment_submissions ON assignment_submissions.id = reviews.submission_id
+				WHERE assignment_submissions.assignment_id = ? AND reviews.status = 'completed'
+			)`, assignmentID).
+		Where("rubrics.assignment_id = ?", assignmentID).
+		Group("rubric_criteria.id, rubric_criteria.name, rubric_criteria.max_points, rubric_criteria.sort_order").
+		Order("rubric_criteria.sort_order ASC, rubric_criteria.id ASC").
+		Scan(&averages).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate criterion averages: %w", err)
+	}
+
+	return averages, nil
+}
+
+// buildCriterionScores 校验并合并评审的维度得分
+func buildCriterionScores(rubric *models.Rubric, existing []models.ReviewCriterionScore, inputs []CriterionScoreRequest) ([]models.ReviewCriterionScore, error) {
+	if rubric == nil {
+		if len(inputs) > 0 {
+			return nil, fmt.Errorf("assignment has no rubric configured")
+		}
+		return existing, nil
+	}
+
+	criteria := make(map[uint]models.RubricCriterion, len(rubric.Criteria))
+	for _, criterion := range rubric.Criteria {
+		criteria[criterion.ID] = criterion
+	}
+
+	scores := existing
+	for _, input := range inputs {
+		criterion, ok := criteria[input.CriterionID]
+		if !ok {
+			return nil, fmt.Errorf("criterion %d does not belong to the assignment rubric", input.CriterionID)
+		}
+		if input.Points > criterion.MaxPoints {
+			return nil, fmt.Errorf("points for criterion %q exceed max points %.1f", criterion.Name, criterion.MaxPoints)
+		}
+
+		updated := false
+		for i := range scores {
+			if scores[i].CriterionID == input.CriterionID {
+				scores[i].Points = input.Points
+				scores[i].Comment = input.Comment
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			scores = append(scores, models.ReviewCriterionScore{
+				CriterionID: input.CriterionID,
+				Points:      input.Points,
+				Comment:     input.Comment,
+			})
+		}
+	}
+
+	return scores, nil
+}
+
+// evaluateAchievements 评估学生成就，失败不影响作业流程
+func (s *AssignmentService) evaluateAchievements(studentID uint, ruleTypes ...string) {
+	if s.achievementService == nil {
+		return
+	}
+	s.achievementService.EvaluateQuietly(studentID, ruleTypes...)
+}
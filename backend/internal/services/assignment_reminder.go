@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// DefaultReminderOffsets 作业未配置提醒时间点时使用的默认值（截止前的小时数）
+var DefaultReminderOffsets = []int{72, 24, 2}
+
+// maxReminderOffsetHours 提醒时间点最早为截止前30天
+const maxReminderOffsetHours = 30 * 24
+
+// normalizeReminderOffsets 校验提醒时间点，去重后按从早到晚（小时数从大到小）排序
+// 传入空数组表示不提醒，结果为非nil的空切片，与未配置（nil）区分
+func normalizeReminderOffsets(offsets []int) ([]int, error) {
+	if offsets == nil {
+		return nil, nil
+	}
+
+	seen := make(map[int]bool, len(offsets))
+	result := make([]int, 0, len(offsets))
+	for _, offset := range offsets {
+		if offset <= 0 || offset > maxReminderOffsetHours {
+			return nil, fmt.Errorf("reminder offset must be between 1 and %d hours", maxReminderOffsetHours)
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			result = append(result, offset)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(result)))
+	return result, nil
+}
+
+// assignmentReminderOffsets 作业实际使用的提醒时间点（从早到晚）
+func assignmentReminderOffsets(assignment *models.Assignment) []int {
+	if assignment.ReminderOffsets == nil {
+		return DefaultReminderOffsets
+	}
+	offsets, err := normalizeReminderOffsets(assignment.ReminderOffsets)
+	if err != nil {
+		return DefaultReminderOffsets
+	}
+	return offsets
+}
+
+// currentReminderOffset 返回当前已到达的最晚的提醒时间点
+// 调度间隔较长时错过的较早时间点不再补发，截止后或尚未到达任何时间点时返回false
+func currentReminderOffset(offsets []int, dueDate, now time.Time) (int, bool) {
+	if !now.Before(dueDate) {
+		return 0, false
+	}
+	for i := len(offsets) - 1; i >= 0; i-- {
+		if !now.Before(dueDate.Add(-time.Duration(offsets[i]) * time.Hour)) {
+			return offsets[i], true
+		}
+	}
+	return 0, false
+}
+
+// ScheduleAssignmentDueNotifications 发送作业截止提醒，由定时任务周期调用
+// 每个作业、学生和提醒时间点只提醒一次，已提交的学生不再提醒；到达最后一个时间点时向老师汇总未提交的学生
+func (s *NotificationService) ScheduleAssignmentDueNotifications() error {
+	now := time.Now()
+	extended := s.db.Model(&models.AssignmentExtension{}).Select("assignment_id").Where("due_date > ?", now)
+
+	var assignments []models.Assignment
+	if err := s.db.Where("status = ? AND (due_date > ? OR id IN (?))", "active", now, extended).
+		Find(&assignments).Error; err != nil {
+		return fmt.Errorf("failed to get assignments due soon: %w", err)
+	}
+
+	for i := range assignments {
+		if err := s.sendAssignmentReminders(&assignments[i], now); err != nil {
+			fmt.Printf("Warning: Failed to send due reminders for assignment %d: %v\n", assignments[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// sendAssignmentReminders 发送单个作业的截止提醒，有延期的学生按延期后的截止时间提醒
+func (s *NotificationService) sendAssignmentReminders(assignment *models.Assignment, now time.Time) error {
+	offsets := assignmentReminderOffsets(assignment)
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	var members []models.ProjectMember
+	if err := s.db.Preload("User").
+		Where("project_id = ? AND is_active = ? AND role <> ? AND user_id <> ?", assignment.ProjectID, true, "teacher", assignment.TeacherID).
+		Find(&members).Error; err != nil {
+		return fmt.Errorf("failed to get project members: %w", err)
+	}
+
+	var submittedIDs []uint
+	if err := s.db.Model(&models.AssignmentSubmission{}).
+		Where("assignment_id = ?", assignment.ID).
+		Distinct().Pluck("student_id", &submittedIDs).Error; err != nil {
+		return fmt.Errorf("failed to get submissions: %w", err)
+	}
+	submitted := make(map[uint]bool, len(submittedIDs))
+	for _, id := range submittedIDs {
+		submitted[id] = true
+	}
+
+	var extensions []models.AssignmentExtension
+	if err := s.db.Where("assignment_id = ?", assignment.ID).Find(&extensions).Error; err != nil {
+		return fmt.Errorf("failed to get extensions: %w", err)
+	}
+	extendedDue := make(map[uint]time.Time, len(extensions))
+	for _, extension := range extensions {
+		extendedDue[extension.StudentID] = extension.DueDate
+	}
+
+	var pending []string
+	for _, member := range members {
+		if submitted[member.UserID] {
+			continue
+		}
+
+		dueDate := assignment.DueDate
+		name := member.User.Name
+		if extension, ok := extendedDue[member.UserID]; ok {
+			dueDate = extension
+			name = fmt.Sprintf("%s（已延期至 %s）", name, extension.Format("01-02 15:04"))
+		}
+		if now.Before(dueDate) {
+			pending = append(pending, name)
+		}
+
+		offset, ok := currentReminderOffset(offsets, dueDate, now)
+		if !ok {
+			continue
+		}
+		notification := &models.Notification{
+			UserID:     member.UserID,
+			Title:      "作业即将到期",
+			Content:    fmt.Sprintf("作业「%s」将在 %d 小时后到期（%s），请及时提交", assignment.Title, hoursUntil(dueDate, now), dueDate.Format("2006-01-02 15:04")),
+			Type:       models.NotificationTypeAssignmentDue,
+			TargetType: "assignment",
+			TargetID:   assignment.ID,
+		}
+		if err := s.sendReminderOnce(assignment.ID, member.UserID, offset, models.ReminderRecipientStudent, dueDate, notification); err != nil {
+			fmt.Printf("Warning: Failed to remind user %d of assignment %d: %v\n", member.UserID, assignment.ID, err)
+		}
+	}
+
+	// 到达最后一个提醒时间点时向老师汇总
+	offset, ok := currentReminderOffset(offsets, assignment.DueDate, now)
+	if !ok || offset != offsets[len(offsets)-1] || len(pending) == 0 {
+		return nil
+	}
+	notification := &models.Notification{
+		UserID: assignment.TeacherID,
+		Title:  "作业未提交学生提醒",
+		Content: fmt.Sprintf("作业「%s」将在 %d 小时后到期，以下 %d 名学生尚未提交：%s",
+			assignment.Title, hoursUntil(assignment.DueDate, now), len(pending), strings.Join(pending, "、")),
+		Type:       models.NotificationTypeAssignmentUnsubmitted,
+		TargetType: "assignment",
+		TargetID:   assignment.ID,
+	}
+	return s.sendReminderOnce(assignment.ID, assignment.TeacherID, offset, models.ReminderRecipientTeacher, assignment.DueDate, notification)
+}
+
+// sendReminderOnce 先写入提醒记录再发送通知，记录已存在时不重复发送（多个实例并发运行时同样有效）
+func (s *NotificationService) sendReminderOnce(assignmentID, userID uint, offset int, recipient string, dueDate time.Time, notification *models.Notification) error {
+	reminder := &models.AssignmentReminder{
+		AssignmentID: assignmentID,
+		UserID:       userID,
+		OffsetHours:  offset,
+		Recipient:    recipient,
+		DueDate:      dueDate,
+		SentAt:       time.Now(),
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reminder)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record reminder: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	if err := s.CreateNotification(notification); err != nil {
+		// 通知发送失败时删除记录，下次调度重试
+		s.db.Delete(reminder)
+		return err
+	}
+	return nil
+}
+
+// hoursUntil 距截止的小时数，不足1小时按1小时计
+func hoursUntil(dueDate, now time.Time) int {
+	return int(math.Max(1, math.Ceil(dueDate.Sub(now).Hours())))
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/xanzy/go-gitlab"
+	"gorm.io/gorm"
+)
+
+// gitlabTokenRefreshMargin GitLab访问令牌在过期前多久刷新
+const gitlabTokenRefreshMargin = 5 * time.Minute
+
+// newTokenCipher 创建加密GitLab令牌的AES-256-GCM，密钥为32字节的base64或十六进制字符串，为空时由JWT密钥派生
+func newTokenCipher(encryptionKey, jwtSecret string) (cipher.AEAD, error) {
+	var key []byte
+	if encryptionKey == "" {
+		sum := sha256.Sum256([]byte("gitlabex-gitlab-token:" + jwtSecret))
+		key = sum[:]
+	} else if decoded, err := base64.StdEncoding.DecodeString(encryptionKey); err == nil && len(decoded) == 32 {
+		key = decoded
+	} else if decoded, err := hex.DecodeString(encryptionKey); err == nil && len(decoded) == 32 {
+		key = decoded
+	} else {
+		return nil, fmt.Errorf("GITLAB_TOKEN_ENCRYPTION_KEY must be 32 bytes encoded as base64 or hex")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptToken 加密令牌，结果为base64编码的 nonce+密文
+func (s *AuthService) encryptToken(plaintext string) (string, error) {
+	if s.tokenCipher == nil {
+		return "", fmt.Errorf("token encryption is not configured")
+	}
+	nonce := make([]byte, s.tokenCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.tokenCipher.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptToken 解密 encryptToken 的结果
+func (s *AuthService) decryptToken(encrypted string) (string, error) {
+	if s.tokenCipher == nil {
+		return "", fmt.Errorf("token encryption is not configured")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	nonceSize := s.tokenCipher.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("encrypted token is too short")
+	}
+	plaintext, err := s.tokenCipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// saveGitLabToken 加密保存用户的GitLab OAuth令牌，每个用户只保存最近一次获得的令牌
+func (s *AuthService) saveGitLabToken(userID uint, token *GitLabToken) error {
+	accessToken, err := s.encryptToken(token.AccessToken)
+	if err != nil {
+		return err
+	}
+	var refreshToken string
+	if token.RefreshToken != "" {
+		if refreshToken, err = s.encryptToken(token.RefreshToken); err != nil {
+			return err
+		}
+	}
+	var expiresAt *time.Time
+	if token.ExpiresIn > 0 {
+		issuedAt := time.Now()
+		if token.CreatedAt > 0 {
+			issuedAt = time.Unix(token.CreatedAt, 0)
+		}
+		t := issuedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+		expiresAt = &t
+	}
+
+	var credential models.GitLabCredential
+	err = s.db.Where("user_id = ?", userID).First(&credential).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to get gitlab credential: %w", err)
+	}
+	credential.UserID = userID
+	credential.AccessTokenEncrypted = accessToken
+	credential.RefreshTokenEncrypted = refreshToken
+	credential.Scope = token.Scope
+	credential.ExpiresAt = expiresAt
+	if err := s.db.Save(&credential).Error; err != nil {
+		return fmt.Errorf("failed to save gitlab credential: %w", err)
+	}
+	return nil
+}
+
+// GetGitLabAccessToken 获取用户的GitLab访问令牌，即将过期时使用刷新令牌换取新令牌
+func (s *AuthService) GetGitLabAccessToken(userID uint) (string, error) {
+	lock, _ := s.gitlabTokenLocks.LoadOrStore(userID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	var credential models.GitLabCredential
+	if err := s.db.Where("user_id = ?", userID).First(&credential).Error; err != nil {
+		return "", fmt.Errorf("gitlab token not found, please login again: %w", err)
+	}
+	if credential.ExpiresAt == nil || time.Until(*credential.ExpiresAt) > gitlabTokenRefreshMargin {
+		return s.decryptToken(credential.AccessTokenEncrypted)
+	}
+
+	if credential.RefreshTokenEncrypted == "" {
+		return "", fmt.Errorf("gitlab token has expired, please login again")
+	}
+	refreshToken, err := s.decryptToken(credential.RefreshTokenEncrypted)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := s.requestGitLabToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"redirect_uri":  {s.config.GitLab.RedirectURI},
+	})
+	if err != nil {
+		// 其他实例可能已经用同一个刷新令牌换取了新令牌
+		var latest models.GitLabCredential
+		if s.db.Where("user_id = ?", userID).First(&latest).Error == nil &&
+			latest.UpdatedAt.After(credential.UpdatedAt) && latest.ExpiresAt != nil && time.Now().Before(*latest.ExpiresAt) {
+			return s.decryptToken(latest.AccessTokenEncrypted)
+		}
+		return "", fmt.Errorf("failed to refresh gitlab token: %w", err)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if err := s.saveGitLabToken(userID, token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// GitLabClientForUser 使用用户自己的GitLab令牌创建客户端，以用户身份调用GitLab API
+func (s *AuthService) GitLabClientForUser(userID uint) (*gitlab.Client, error) {
+	accessToken, err := s.GetGitLabAccessToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := gitlab.NewOAuthClient(accessToken, gitlab.WithBaseURL(s.config.GitLab.GetBaseURL()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gitlab client: %w", err)
+	}
+	return client, nil
+}
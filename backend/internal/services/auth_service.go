@@ -1,18 +1,21 @@
 package services
 
 import (
+	"crypto/cipher"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"gitlabex/internal/config"
 	"gitlabex/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
@@ -20,6 +23,16 @@ import (
 type AuthService struct {
 	db     *gorm.DB
 	config *config.Config
+	redis  *redis.Client // 可为nil
+
+	tokenCipher      cipher.AEAD // 加密保存GitLab令牌，密钥无效时为nil
+	gitlabTokenLocks sync.Map    // 用户ID -> *sync.Mutex，避免并发刷新同一用户的GitLab令牌
+
+	revokedMu       sync.Mutex
+	revokedSessions map[string]time.Time // 本实例撤销的会话ID -> 访问令牌最晚过期时间
+
+	usedTicketsMu sync.Mutex
+	usedTickets   map[string]time.Time // 未配置Redis时本实例已使用的连接票据 -> 票据过期时间
 }
 
 type GitLabOAuthConfig struct {
@@ -49,19 +62,27 @@ type GitLabToken struct {
 }
 
 type JWTClaims struct {
-	UserID   uint   `json:"user_id"`
-	GitLabID int    `json:"gitlab_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Role     int    `json:"role"`
+	UserID    uint   `json:"user_id"`
+	GitLabID  int    `json:"gitlab_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      int    `json:"role"`
+	SessionID string `json:"sid"` // 登录会话ID，会话撤销后令牌失效
 	jwt.RegisteredClaims
 }
 
 func NewAuthService(db *gorm.DB, config *config.Config) *AuthService {
+	tokenCipher, err := newTokenCipher(config.GitLab.TokenEncryptionKey, config.JWT.Secret)
+	if err != nil {
+		fmt.Printf("Warning: GitLab tokens will not be stored: %v\n", err)
+	}
 	return &AuthService{
-		db:     db,
-		config: config,
+		db:              db,
+		config:          config,
+		tokenCipher:     tokenCipher,
+		revokedSessions: make(map[string]time.Time),
+		usedTickets:     make(map[string]time.Time),
 	}
 }
 
@@ -136,10 +157,15 @@ func (s *AuthService) HandleGitLabCallback(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT令牌
-	jwtToken, err := s.generateJWTToken(user)
+	// 加密保存GitLab令牌，用于之后以用户身份调用GitLab API
+	if err := s.saveGitLabToken(user.ID, token); err != nil {
+		fmt.Printf("Warning: Failed to save GitLab token for user %s: %v\n", user.Username, err)
+	}
+
+	// 签发一次性登录码，令牌不出现在重定向地址中（浏览器历史、访问日志、Referer）
+	loginCode, err := s.issueLoginCode(user.ID)
 	if err != nil {
-		fmt.Printf("ERROR: Failed to generate JWT: %v\n", err)
+		fmt.Printf("ERROR: Failed to issue login code: %v\n", err)
 		// 重定向到前端登录页面并显示错误
 		c.Redirect(http.StatusFound, fmt.Sprintf("%s/login?error=jwt_generation_failed", s.config.Frontend.URL))
 		return
@@ -147,79 +173,68 @@ func (s *AuthService) HandleGitLabCallback(c *gin.Context) {
 
 	fmt.Printf("SUCCESS: User %s logged in successfully\n", user.Username)
 
-	// 登录成功，重定向到前端登录成功页面并传递token
-	// 使用URL参数传递token（在生产环境中应该使用更安全的方式，如设置HttpOnly cookie）
-	redirectURL := fmt.Sprintf("%s/login/success?token=%s", s.config.Frontend.URL, jwtToken)
+	// 登录成功，重定向到前端登录成功页面，前端通过 POST /api/auth/login/exchange 用登录码换取令牌
+	redirectURL := fmt.Sprintf("%s/login/success?%s", s.config.Frontend.URL, url.Values{
+		"code": {loginCode},
+	}.Encode())
 	c.Redirect(http.StatusFound, redirectURL)
 }
 
 // exchangeCodeForToken 交换认证码为访问令牌
 func (s *AuthService) exchangeCodeForToken(code string) (*GitLabToken, error) {
-	// 使用内部URL进行token交换，确保容器内部网络访问正常
-	var tokenURL string
-	if s.config.GitLab.InternalURL != "" {
-		tokenURL = fmt.Sprintf("%s/oauth/token", s.config.GitLab.InternalURL)
-	} else {
-		tokenURL = fmt.Sprintf("%s/oauth/token", s.config.GitLab.URL)
-	}
-
 	fmt.Printf("DEBUG: GitLab InternalURL: %s\n", s.config.GitLab.InternalURL)
 	fmt.Printf("DEBUG: GitLab URL: %s\n", s.config.GitLab.URL)
-	fmt.Printf("DEBUG: Exchange token URL: %s\n", tokenURL)
 	fmt.Printf("DEBUG: Client ID: %s\n", s.config.GitLab.ClientID[:10]+"...")
 	fmt.Printf("DEBUG: Client Secret: %s\n", s.config.GitLab.ClientSecret[:10]+"...")
 	fmt.Printf("DEBUG: Redirect URI: %s\n", s.config.GitLab.RedirectURI)
 	fmt.Printf("DEBUG: Authorization Code: %s\n", code[:10]+"...")
 
-	// 使用HTTP Basic认证发送client credentials
-	formData := map[string][]string{
+	return s.requestGitLabToken(url.Values{
 		"code":         {code},
 		"grant_type":   {"authorization_code"},
 		"redirect_uri": {s.config.GitLab.RedirectURI},
+	})
+}
+
+// requestGitLabToken 请求GitLab令牌端点，用于授权码交换和刷新令牌
+func (s *AuthService) requestGitLabToken(formData url.Values) (*GitLabToken, error) {
+	tokenURL := fmt.Sprintf("%s/oauth/token", s.config.GitLab.URL)
+	if s.config.GitLab.InternalURL != "" {
+		tokenURL = fmt.Sprintf("%s/oauth/token", s.config.GitLab.InternalURL)
 	}
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(url.Values(formData).Encode()))
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(formData.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// 设置Basic认证头
+	// 使用HTTP Basic认证发送client credentials
 	req.SetBasicAuth(s.config.GitLab.ClientID, s.config.GitLab.ClientSecret)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	fmt.Printf("DEBUG: Request headers: %v\n", req.Header)
-
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Printf("DEBUG: HTTP request error: %v\n", err)
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("DEBUG: Response status: %s\n", resp.Status)
-	fmt.Printf("DEBUG: Response headers: %v\n", resp.Header)
-
-	// 读取响应体
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to read response body: %v\n", err)
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
-
-	fmt.Printf("DEBUG: Response body: %s\n", string(body))
-
+	// 响应体包含令牌，不输出到日志
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitLab OAuth error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("GitLab OAuth error (status %d)", resp.StatusCode)
 	}
 
 	var token GitLabToken
 	if err := json.Unmarshal(body, &token); err != nil {
-		fmt.Printf("DEBUG: JSON decode error: %v\n", err)
 		return nil, fmt.Errorf("failed to decode token response: %w", err)
 	}
-
-	fmt.Printf("DEBUG: Token exchange successful, access_token length: %d\n", len(token.AccessToken))
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("GitLab OAuth response has no access token")
+	}
 
 	return &token, nil
 }
@@ -292,17 +307,18 @@ func (s *AuthService) syncUserFromGitLab(gitlabUser *GitLabUser) (*models.User,
 	return &user, nil
 }
 
-// generateJWTToken 生成JWT令牌
-func (s *AuthService) generateJWTToken(user *models.User) (string, error) {
+// generateJWTToken 生成会话的访问令牌
+func (s *AuthService) generateJWTToken(user *models.User, sessionID string) (string, error) {
 	claims := JWTClaims{
-		UserID:   user.ID,
-		GitLabID: user.GitLabID,
-		Username: user.Username,
-		Email:    user.Email,
-		Name:     user.Name,
-		Role:     user.Role,
+		UserID:    user.ID,
+		GitLabID:  user.GitLabID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.JWT.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "gitlabex",
@@ -334,6 +350,25 @@ func (s *AuthService) ValidateJWTToken(tokenString string) (*JWTClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// ValidateAccessToken 验证访问令牌及其会话，返回令牌声明和数据库中的用户
+func (s *AuthService) ValidateAccessToken(tokenString string) (*JWTClaims, *models.User, error) {
+	claims, err := s.ValidateJWTToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 没有会话ID的令牌由旧版本签发，无法撤销，需要重新登录
+	if claims.SessionID == "" || s.isSessionRevoked(claims.SessionID) {
+		return nil, nil, fmt.Errorf("session has been revoked or expired")
+	}
+
+	// 从数据库获取完整的用户信息
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user not found")
+	}
+	return claims, user, nil
+}
+
 // GetUserByID 根据ID获取用户
 func (s *AuthService) GetUserByID(userID uint) (*models.User, error) {
 	var user models.User
@@ -343,20 +378,26 @@ func (s *AuthService) GetUserByID(userID uint) (*models.User, error) {
 	return &user, nil
 }
 
-// RefreshUserFromGitLab 从GitLab刷新用户信息
-func (s *AuthService) RefreshUserFromGitLab(userID uint, accessToken string) (*models.User, error) {
-	// 获取GitLab用户信息
-	gitlabUser, err := s.fetchGitLabUser(accessToken)
-	if err != nil {
-		return nil, err
+// Logout 用户登出，撤销访问令牌（可以已过期）或请求体中刷新令牌所属的会话
+func (s *AuthService) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
 	}
+	_ = c.ShouldBindJSON(&req)
 
-	// 同步到本地数据库
-	return s.syncUserFromGitLab(gitlabUser)
-}
+	if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); token != "" {
+		if sessionID := s.sessionIDFromToken(token); sessionID != "" {
+			if err := s.revokeSessions(s.db.Where("session_id = ?", sessionID)); err != nil {
+				fmt.Printf("Warning: Failed to revoke session on logout: %v\n", err)
+			}
+		}
+	}
+	if strings.HasPrefix(req.RefreshToken, sessionRefreshTokenPrefix) {
+		if err := s.revokeSessions(s.db.Where("refresh_token_hash = ?", hashSecretToken(req.RefreshToken))); err != nil {
+			fmt.Printf("Warning: Failed to revoke session on logout: %v\n", err)
+		}
+	}
 
-// Logout 用户登出
-func (s *AuthService) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
 
@@ -431,17 +472,9 @@ func (s *AuthService) AuthMiddleware() gin.HandlerFunc {
 			token = token[7:]
 		}
 
-		claims, err := s.ValidateJWTToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-
-		// 从数据库获取完整的用户信息
-		user, err := s.GetUserByID(claims.UserID)
+		claims, user, err := s.ValidateAccessToken(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
 			c.Abort()
 			return
 		}
@@ -454,6 +487,7 @@ func (s *AuthService) AuthMiddleware() gin.HandlerFunc {
 		c.Set("email", claims.Email)
 		c.Set("name", claims.Name)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
 
 		c.Next()
 	}
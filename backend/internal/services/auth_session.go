@@ -0,0 +1,444 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 登录会话参数
+const (
+	sessionRefreshTokenPrefix = "gls_"
+	sessionRevokedKeyPrefix   = "gitlabex:auth:revoked:"
+	sessionRedisTimeout       = 100 * time.Millisecond
+	sessionRetention          = 7 * 24 * time.Hour // 过期或撤销的会话保留时间
+)
+
+// AuthTokens 登录或刷新后返回的令牌
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // 访问令牌有效秒数
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionInfo 会话列表项，Current 表示当前请求使用的会话
+type SessionInfo struct {
+	models.UserSession
+	Current bool `json:"current"`
+}
+
+// SetRedisClient 设置Redis客户端，多个实例通过Redis共享已撤销的会话；未设置时查询数据库
+func (s *AuthService) SetRedisClient(client *redis.Client) {
+	s.redis = client
+}
+
+// createSession 创建登录会话，签发访问令牌和刷新令牌
+func (s *AuthService) createSession(user *models.User, ip, userAgent string) (*AuthTokens, error) {
+	sessionID, err := generateSessionID()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := generateSecretToken(sessionRefreshTokenPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	now := time.Now()
+	session := &models.UserSession{
+		SessionID:        sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: hashSecretToken(refreshToken),
+		Device:           describeDevice(userAgent),
+		UserAgent:        truncateString(userAgent, 512),
+		IP:               ip,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.config.JWT.RefreshTokenTTL),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueTokens(user, sessionID, refreshToken)
+}
+
+// RefreshSession 使用刷新令牌换取新的访问令牌，刷新令牌每次使用后轮换
+// 上一个刷新令牌再次使用视为泄露，撤销整个会话
+func (s *AuthService) RefreshSession(rawRefreshToken, ip, userAgent string) (*AuthTokens, error) {
+	if !strings.HasPrefix(rawRefreshToken, sessionRefreshTokenPrefix) {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	hash := hashSecretToken(rawRefreshToken)
+
+	var session models.UserSession
+	if err := s.db.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) &&
+			s.db.Where("previous_refresh_token_hash = ?", hash).First(&session).Error == nil {
+			if err := s.revokeSessions(s.db.Where("id = ?", session.ID)); err != nil {
+				fmt.Printf("Warning: Failed to revoke session %d after refresh token reuse: %v\n", session.ID, err)
+			}
+			return nil, fmt.Errorf("refresh token has already been used, session revoked")
+		}
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		return nil, fmt.Errorf("session has been revoked or expired")
+	}
+	user, err := s.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("session user not found: %w", err)
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("user is inactive")
+	}
+
+	refreshToken, err := generateSecretToken(sessionRefreshTokenPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	// 条件更新保证并发刷新时只有一个请求成功
+	result := s.db.Model(&session).Where("refresh_token_hash = ?", hash).Updates(map[string]interface{}{
+		"refresh_token_hash":          hashSecretToken(refreshToken),
+		"previous_refresh_token_hash": hash,
+		"ip":                          ip,
+		"last_used_at":                now,
+		"expires_at":                  now.Add(s.config.JWT.RefreshTokenTTL),
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("refresh token has already been used")
+	}
+	if userAgent != "" && session.UserAgent != truncateString(userAgent, 512) {
+		if err := s.db.Model(&session).UpdateColumns(map[string]interface{}{
+			"user_agent": truncateString(userAgent, 512),
+			"device":     describeDevice(userAgent),
+		}).Error; err != nil {
+			fmt.Printf("Warning: Failed to update device of session %d: %v\n", session.ID, err)
+		}
+	}
+
+	return s.issueTokens(user, session.SessionID, refreshToken)
+}
+
+// ListSessions 获取用户未过期且未撤销的会话
+func (s *AuthService) ListSessions(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession 撤销用户的一个会话
+func (s *AuthService) RevokeSession(userID, id uint) error {
+	var session models.UserSession
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		return fmt.Errorf("session not found: %w", err)
+	}
+	return s.revokeSessions(s.db.Where("id = ?", session.ID))
+}
+
+// RevokeAllSessions 撤销用户的所有会话，exceptSessionID 不为空时保留该会话（当前会话）
+func (s *AuthService) RevokeAllSessions(userID uint, exceptSessionID string) error {
+	query := s.db.Where("user_id = ?", userID)
+	if exceptSessionID != "" {
+		query = query.Where("session_id <> ?", exceptSessionID)
+	}
+	return s.revokeSessions(query)
+}
+
+// CleanupSessions 删除过期或撤销超过保留期的会话
+func (s *AuthService) CleanupSessions() error {
+	cutoff := time.Now().Add(-sessionRetention)
+	if err := s.db.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.UserSession{}).Error; err != nil {
+		return fmt.Errorf("failed to cleanup sessions: %w", err)
+	}
+	return nil
+}
+
+// revokeSessions 撤销查询到的未撤销会话，并将会话ID加入撤销列表，使已签发的访问令牌立即失效
+func (s *AuthService) revokeSessions(query *gorm.DB) error {
+	var sessionIDs []string
+	if err := query.Model(&models.UserSession{}).Where("revoked_at IS NULL").
+		Pluck("session_id", &sessionIDs).Error; err != nil {
+		return fmt.Errorf("failed to find sessions: %w", err)
+	}
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&models.UserSession{}).Where("session_id IN ?", sessionIDs).
+		Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	// 撤销列表只需要保留到访问令牌过期
+	ttl := s.config.JWT.AccessTokenTTL
+	s.revokedMu.Lock()
+	for sessionID, expiresAt := range s.revokedSessions {
+		if now.After(expiresAt) {
+			delete(s.revokedSessions, sessionID)
+		}
+	}
+	for _, sessionID := range sessionIDs {
+		s.revokedSessions[sessionID] = now.Add(ttl)
+	}
+	s.revokedMu.Unlock()
+
+	if s.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sessionRedisTimeout)
+		defer cancel()
+		pipe := s.redis.Pipeline()
+		for _, sessionID := range sessionIDs {
+			pipe.Set(ctx, sessionRevokedKeyPrefix+sessionID, 1, ttl)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			fmt.Printf("Warning: Failed to publish revoked sessions to Redis: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// isSessionRevoked 检查访问令牌所属会话是否已撤销
+// 依次检查本实例的撤销列表、Redis，未配置Redis或Redis出错时查询数据库
+func (s *AuthService) isSessionRevoked(sessionID string) bool {
+	now := time.Now()
+	s.revokedMu.Lock()
+	expiresAt, revoked := s.revokedSessions[sessionID]
+	s.revokedMu.Unlock()
+	if revoked && now.Before(expiresAt) {
+		return true
+	}
+
+	if s.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sessionRedisTimeout)
+		defer cancel()
+		count, err := s.redis.Exists(ctx, sessionRevokedKeyPrefix+sessionID).Result()
+		if err == nil {
+			return count > 0
+		}
+	}
+
+	var session models.UserSession
+	if err := s.db.Select("revoked_at", "expires_at").Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		return true
+	}
+	return !session.IsActive(now)
+}
+
+// issueTokens 签发访问令牌，并返回刷新令牌
+func (s *AuthService) issueTokens(user *models.User, sessionID, refreshToken string) (*AuthTokens, error) {
+	accessToken, err := s.generateJWTToken(user, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.JWT.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// sessionIDFromToken 获取访问令牌中的会话ID，令牌已过期时也返回，用于登出
+func (s *AuthService) sessionIDFromToken(tokenString string) string {
+	claims := &JWTClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.config.JWT.Secret), nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		return ""
+	}
+	return claims.SessionID
+}
+
+// ===== 会话接口 =====
+
+// HandleLoginExchange 使用登录回调中的一次性登录码换取访问令牌和刷新令牌
+func (s *AuthService) HandleLoginExchange(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	tokens, err := s.ExchangeLoginCode(req.Code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid login code",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login successful",
+		"data":    tokens,
+	})
+}
+
+// HandleRefresh 使用刷新令牌换取新的访问令牌和刷新令牌
+func (s *AuthService) HandleRefresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := s.RefreshSession(req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Invalid refresh token",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Token refreshed",
+		"data":    tokens,
+	})
+}
+
+// HandleListSessions 获取当前用户的登录会话
+func (s *AuthService) HandleListSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	sessions, err := s.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	currentSessionID := c.GetString("session_id")
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, SessionInfo{UserSession: session, Current: session.SessionID == currentSessionID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+// HandleRevokeSession 撤销当前用户的一个会话
+func (s *AuthService) HandleRevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := s.RevokeSession(c.GetUint("user_id"), uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Failed to revoke session",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// HandleRevokeAllSessions 撤销当前用户的所有其他会话，include_current=true 时同时撤销当前会话
+func (s *AuthService) HandleRevokeAllSessions(c *gin.Context) {
+	except := c.GetString("session_id")
+	if c.Query("include_current") == "true" {
+		except = ""
+	}
+
+	if err := s.RevokeAllSessions(c.GetUint("user_id"), except); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked"})
+}
+
+// generateSessionID 生成会话ID
+func generateSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// describeDevice 由User-Agent识别浏览器和系统，如 "Chrome / macOS"
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "未知设备"
+	}
+
+	browser := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+		{"Safari/", "Safari"}, {"curl/", "curl"}, {"PostmanRuntime/", "Postman"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+
+	system := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Windows", "Windows"},
+		{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " / " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return truncateString(userAgent, 64)
+}
+
+func truncateString(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	return value[:limit]
+}
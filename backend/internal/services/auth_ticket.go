@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 一次性票据：浏览器的 EventSource 无法设置请求头，用短期连接票据代替URL中的访问令牌；
+// 登录回调只在重定向地址中带登录码，前端再用登录码换取令牌
+const (
+	streamTicketTTL     = 30 * time.Second
+	loginCodeTTL        = time.Minute
+	usedTicketKeyPrefix = "gitlabex:auth:ticket:"
+)
+
+// StreamTicket 一次性连接票据
+type StreamTicket struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"` // 有效秒数
+}
+
+// loginCodeClaims 登录码内容，同样使用独立密钥签名
+type loginCodeClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// streamTicketClaims 票据内容，使用由JWT密钥派生的独立密钥签名，不能作为访问令牌使用
+type streamTicketClaims struct {
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// IssueStreamTicket 为当前会话签发一次性连接票据
+func (s *AuthService) IssueStreamTicket(userID uint, sessionID string) (*StreamTicket, error) {
+	jti, err := generateSessionID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims := streamTicketClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(streamTicketTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "gitlabex",
+		},
+	}
+	ticket, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.ticketKey("stream-ticket"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign ticket: %w", err)
+	}
+	return &StreamTicket{Ticket: ticket, ExpiresIn: int(streamTicketTTL.Seconds())}, nil
+}
+
+// RedeemStreamTicket 校验并使用票据，每个票据只能使用一次，返回票据所属的用户和会话
+func (s *AuthService) RedeemStreamTicket(ticket string) (*models.User, string, error) {
+	claims := &streamTicketClaims{}
+	_, err := jwt.ParseWithClaims(ticket, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.ticketKey("stream-ticket"), nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid ticket: %w", err)
+	}
+	if !s.markTicketUsed(claims.ID, streamTicketTTL) {
+		return nil, "", fmt.Errorf("ticket has already been used")
+	}
+	if claims.SessionID == "" || s.isSessionRevoked(claims.SessionID) {
+		return nil, "", fmt.Errorf("session has been revoked or expired")
+	}
+
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, "", fmt.Errorf("user not found")
+	}
+	return user, claims.SessionID, nil
+}
+
+// IsSessionRevoked 检查会话是否已撤销或过期，长连接定期调用以便登出后断开
+func (s *AuthService) IsSessionRevoked(sessionID string) bool {
+	return s.isSessionRevoked(sessionID)
+}
+
+// issueLoginCode 登录成功后签发一次性登录码，登录会话在换取令牌时才创建
+func (s *AuthService) issueLoginCode(userID uint) (string, error) {
+	jti, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := loginCodeClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(loginCodeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "gitlabex",
+		},
+	}
+	code, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.ticketKey("login-code"))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign login code: %w", err)
+	}
+	return code, nil
+}
+
+// ExchangeLoginCode 使用一次性登录码创建登录会话，返回访问令牌和刷新令牌
+func (s *AuthService) ExchangeLoginCode(code, ip, userAgent string) (*AuthTokens, error) {
+	claims := &loginCodeClaims{}
+	_, err := jwt.ParseWithClaims(code, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return s.ticketKey("login-code"), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid login code: %w", err)
+	}
+	if !s.markTicketUsed(claims.ID, loginCodeTTL) {
+		return nil, fmt.Errorf("login code has already been used")
+	}
+
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	// 登录回调之后被停用的用户不能再创建会话
+	if !user.Active {
+		return nil, fmt.Errorf("user is inactive")
+	}
+	return s.createSession(user, ip, userAgent)
+}
+
+// ticketKey 票据签名密钥，由JWT密钥按用途派生
+func (s *AuthService) ticketKey(purpose string) []byte {
+	sum := sha256.Sum256([]byte("gitlabex-" + purpose + ":" + s.config.JWT.Secret))
+	return sum[:]
+}
+
+// markTicketUsed 记录已使用的票据，票据已使用过时返回false；配置Redis时多个实例共享记录
+func (s *AuthService) markTicketUsed(jti string, ttl time.Duration) bool {
+	if s.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sessionRedisTimeout)
+		defer cancel()
+		ok, err := s.redis.SetNX(ctx, usedTicketKeyPrefix+jti, 1, ttl).Result()
+		if err == nil {
+			return ok
+		}
+		fmt.Printf("Warning: Failed to record used ticket in Redis: %v\n", err)
+	}
+
+	now := time.Now()
+	s.usedTicketsMu.Lock()
+	defer s.usedTicketsMu.Unlock()
+	for id, expiresAt := range s.usedTickets {
+		if now.After(expiresAt) {
+			delete(s.usedTickets, id)
+		}
+	}
+	if _, used := s.usedTickets[jti]; used {
+		return false
+	}
+	s.usedTickets[jti] = now.Add(ttl)
+	return true
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitlabex/internal/config"
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AutogradeService 自动评测服务：提交后检出学生代码，在沙箱中运行老师提供的测试
+type AutogradeService struct {
+	db                *gorm.DB
+	permissionService *PermissionService
+	gitlabService     *GitLabService
+	config            config.AutogradeConfig
+	queue             chan uint
+}
+
+// NewAutogradeService 创建自动评测服务
+func NewAutogradeService(db *gorm.DB, permissionService *PermissionService, gitlabService *GitLabService, cfg config.AutogradeConfig) *AutogradeService {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	return &AutogradeService{
+		db:                db,
+		permissionService: permissionService,
+		gitlabService:     gitlabService,
+		config:            cfg,
+		queue:             make(chan uint, 100),
+	}
+}
+
+// AutogradeSuiteRequest 保存自动评测配置请求
+type AutogradeSuiteRequest struct {
+	Runner         string                     `json:"runner" binding:"required,oneof=docker local"`
+	Image          string                     `json:"image"`
+	SetupCommand   string                     `json:"setup_command"`
+	Tests          []models.AutogradeTestCase `json:"tests" binding:"required,min=1"`
+	Files          map[string]string          `json:"files"`
+	TimeoutSeconds int                        `json:"timeout_seconds" binding:"min=0"`
+	MemoryMB       int                        `json:"memory_mb" binding:"min=0"`
+	CPUs           float64                    `json:"cpus" binding:"min=0"`
+	IsActive       *bool                      `json:"is_active"`
+}
+
+// AutogradeReport 写入提交自动检查报告的评测结果
+type AutogradeReport struct {
+	RunID          uint                         `json:"run_id"`
+	Status         string                       `json:"status"`
+	Passed         bool                         `json:"passed"`
+	Score          float64                      `json:"score"`
+	MaxScore       float64                      `json:"max_score"`
+	SuggestedScore float64                      `json:"suggested_score"`
+	Tests          []models.AutogradeTestResult `json:"tests"`
+	Error          string                       `json:"error,omitempty"`
+}
+
+// Start 启动评测worker，并重新排队服务重启前未完成的评测
+func (s *AutogradeService) Start() {
+	for i := 0; i < s.config.Workers; i++ {
+		go s.worker()
+	}
+
+	var runIDs []uint
+	if err := s.db.Model(&models.AutogradeRun{}).
+		Where("status IN ?", []string{models.AutogradeStatusPending, models.AutogradeStatusRunning}).
+		Order("id").Pluck("id", &runIDs).Error; err != nil {
+		fmt.Printf("Warning: Failed to load pending autograde runs: %v\n", err)
+		return
+	}
+	for _, runID := range runIDs {
+		s.dispatch(runID)
+	}
+}
+
+// GetSuite 获取作业的自动评测配置（老师权限，测试内容不对学生公开）
+func (s *AutogradeService) GetSuite(userID, assignmentID uint) (*models.AutogradeSuite, error) {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return nil, fmt.Errorf("permission denied to manage autograding of this assignment")
+	}
+
+	var suite models.AutogradeSuite
+	if err := s.db.Where("assignment_id = ?", assignmentID).First(&suite).Error; err != nil {
+		return nil, fmt.Errorf("autograde suite not found: %w", err)
+	}
+	return &suite, nil
+}
+
+// SaveSuite 创建或替换作业的自动评测配置（老师权限）
+func (s *AutogradeService) SaveSuite(userID, assignmentID uint, req *AutogradeSuiteRequest) (*models.AutogradeSuite, error) {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return nil, fmt.Errorf("permission denied to manage autograding of this assignment")
+	}
+	if err := s.validateSuite(req); err != nil {
+		return nil, err
+	}
+
+	var suite models.AutogradeSuite
+	err := s.db.Where("assignment_id = ?", assignmentID).First(&suite).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get autograde suite: %w", err)
+	}
+
+	suite.AssignmentID = assignmentID
+	suite.Runner = req.Runner
+	suite.Image = req.Image
+	suite.SetupCommand = req.SetupCommand
+	suite.Tests = req.Tests
+	suite.Files = req.Files
+	suite.TimeoutSeconds = req.TimeoutSeconds
+	suite.MemoryMB = req.MemoryMB
+	suite.CPUs = req.CPUs
+	suite.CreatedBy = userID
+	if suite.TimeoutSeconds == 0 {
+		suite.TimeoutSeconds = 60
+	}
+	if suite.MemoryMB == 0 {
+		suite.MemoryMB = 512
+	}
+	if suite.CPUs == 0 {
+		suite.CPUs = 1
+	}
+
+	isActive := req.IsActive == nil || *req.IsActive
+	suite.IsActive = isActive
+	if err := s.db.Save(&suite).Error; err != nil {
+		return nil, fmt.Errorf("failed to save autograde suite: %w", err)
+	}
+	// is_active 默认为 true，新建时 false 会被忽略，需单独更新
+	if !isActive {
+		if err := s.db.Model(&suite).Update("is_active", false).Error; err != nil {
+			return nil, fmt.Errorf("failed to save autograde suite: %w", err)
+		}
+	}
+
+	return &suite, nil
+}
+
+// DeleteSuite 删除作业的自动评测配置（老师权限）
+func (s *AutogradeService) DeleteSuite(userID, assignmentID uint) error {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return fmt.Errorf("permission denied to manage autograding of this assignment")
+	}
+
+	if err := s.db.Where("assignment_id = ?", assignmentID).Delete(&models.AutogradeSuite{}).Error; err != nil {
+		return fmt.Errorf("failed to delete autograde suite: %w", err)
+	}
+	return nil
+}
+
+// validateSuite 校验自动评测配置
+func (s *AutogradeService) validateSuite(req *AutogradeSuiteRequest) error {
+	switch req.Runner {
+	case models.AutogradeRunnerDocker:
+		if req.Image == "" {
+			return fmt.Errorf("image is required for docker runner")
+		}
+	case models.AutogradeRunnerLocal:
+		if !s.config.AllowLocalRunner {
+			return fmt.Errorf("local runner is disabled on this server")
+		}
+	default:
+		return fmt.Errorf("unsupported runner: %s", req.Runner)
+	}
+
+	if time.Duration(req.TimeoutSeconds)*time.Second > s.config.MaxTimeout {
+		return fmt.Errorf("timeout must not exceed %s", s.config.MaxTimeout)
+	}
+
+	for i, test := range req.Tests {
+		if strings.TrimSpace(test.Name) == "" || strings.TrimSpace(test.Command) == "" {
+			return fmt.Errorf("test %d must have a name and a command", i+1)
+		}
+		if test.Points < 0 {
+			return fmt.Errorf("test %s has negative points", test.Name)
+		}
+	}
+
+	for path := range req.Files {
+		if _, err := workspacePath(string(os.PathSeparator)+"workspace", path); err != nil {
+			return err
+		}
+		// 测试文件按路径挂载到容器中，路径不能包含挂载参数的分隔符
+		if strings.Contains(path, ":") {
+			return fmt.Errorf("invalid file path: %s", path)
+		}
+	}
+
+	return nil
+}
+
+// EnqueueSubmission 提交后自动评测，作业未配置或停用自动评测时忽略
+func (s *AutogradeService) EnqueueSubmission(submissionID uint) error {
+	var submission models.AssignmentSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return fmt.Errorf("submission not found: %w", err)
+	}
+
+	var suite models.AutogradeSuite
+	err := s.db.Where("assignment_id = ? AND is_active = true", submission.AssignmentID).First(&suite).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get autograde suite: %w", err)
+	}
+
+	_, err = s.createRun(&submission, &suite, 0)
+	return err
+}
+
+// EnqueueSubmissionQuietly 提交后自动评测，失败不影响提交
+func (s *AutogradeService) EnqueueSubmissionQuietly(submissionID uint) {
+	if err := s.EnqueueSubmission(submissionID); err != nil {
+		fmt.Printf("Warning: Failed to enqueue autograde for submission %d: %v\n", submissionID, err)
+	}
+}
+
+// RerunSubmission 重新评测提交（老师权限）
+func (s *AutogradeService) RerunSubmission(userID, submissionID uint) (*models.AutogradeRun, error) {
+	var submission models.AssignmentSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+	if !s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "review") {
+		return nil, fmt.Errorf("permission denied to review this submission")
+	}
+
+	var suite models.AutogradeSuite
+	if err := s.db.Where("assignment_id = ?", submission.AssignmentID).First(&suite).Error; err != nil {
+		return nil, fmt.Errorf("autograde suite not found: %w", err)
+	}
+
+	return s.createRun(&submission, &suite, userID)
+}
+
+// GetRuns 获取提交的评测记录（学生只能查看自己的提交）
+func (s *AutogradeService) GetRuns(userID, submissionID uint) ([]models.AutogradeRun, error) {
+	var submission models.AssignmentSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	canView := s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "review") ||
+		(submission.StudentID == userID && s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "read"))
+	if !canView {
+		return nil, fmt.Errorf("permission denied to view this submission")
+	}
+
+	var runs []models.AutogradeRun
+	if err := s.db.Where("submission_id = ?", submissionID).Order("created_at DESC").Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get autograde runs: %w", err)
+	}
+	return runs, nil
+}
+
+// createRun 创建评测任务并放入队列
+func (s *AutogradeService) createRun(submission *models.AssignmentSubmission, suite *models.AutogradeSuite, triggeredBy uint) (*models.AutogradeRun, error) {
+	run := &models.AutogradeRun{
+		SubmissionID: submission.ID,
+		SuiteID:      suite.ID,
+		Status:       models.AutogradeStatusPending,
+		CommitHash:   submission.CommitHash,
+		TriggeredBy:  triggeredBy,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to create autograde run: %w", err)
+	}
+
+	s.dispatch(run.ID)
+	return run, nil
+}
+
+// dispatch 将评测放入队列，队列已满时不阻塞调用方
+func (s *AutogradeService) dispatch(runID uint) {
+	select {
+	case s.queue <- runID:
+	default:
+		go func() { s.queue <- runID }()
+	}
+}
+
+// worker 依次执行队列中的评测
+func (s *AutogradeService) worker() {
+	for runID := range s.queue {
+		s.execute(runID)
+	}
+}
+
+// execute 执行一次评测并记录结果
+func (s *AutogradeService) execute(runID uint) {
+	var run models.AutogradeRun
+	if err := s.db.First(&run, runID).Error; err != nil {
+		fmt.Printf("Warning: Autograde run %d not found: %v\n", runID, err)
+		return
+	}
+	if run.Status == models.AutogradeStatusCompleted || run.Status == models.AutogradeStatusFailed {
+		return
+	}
+
+	now := time.Now()
+	run.Status = models.AutogradeStatusRunning
+	run.StartedAt = &now
+	s.db.Model(&run).Updates(map[string]interface{}{
+		"status":     run.Status,
+		"started_at": now,
+	})
+
+	if err := s.grade(&run); err != nil {
+		run.Status = models.AutogradeStatusFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = models.AutogradeStatusCompleted
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if err := s.db.Save(&run).Error; err != nil {
+		fmt.Printf("Warning: Failed to save autograde run %d: %v\n", run.ID, err)
+		return
+	}
+
+	if err := s.recordOnSubmission(&run); err != nil {
+		fmt.Printf("Warning: Failed to record autograde result on submission %d: %v\n", run.SubmissionID, err)
+	}
+}
+
+// grade 检出学生代码并依次运行测试
+func (s *AutogradeService) grade(run *models.AutogradeRun) error {
+	var suite models.AutogradeSuite
+	if err := s.db.First(&suite, run.SuiteID).Error; err != nil {
+		return fmt.Errorf("autograde suite not found: %w", err)
+	}
+
+	var submission models.AssignmentSubmission
+	if err := s.db.Preload("Assignment.Project").First(&submission, run.SubmissionID).Error; err != nil {
+		return fmt.Errorf("submission not found: %w", err)
+	}
+	if run.CommitHash == "" {
+		return fmt.Errorf("submission has no commit to grade")
+	}
+	gitlabProjectID := submission.Assignment.Project.GitLabProjectID
+	if gitlabProjectID == 0 {
+		return fmt.Errorf("project not linked to GitLab")
+	}
+
+	limits := sourceLimits{MaxFileSize: submission.Assignment.MaxFileSize, MaxTotalSize: s.config.MaxSourceSize}
+	ws, cleanup, err := s.prepareWorkspace(run, gitlabProjectID, suite.Files, limits)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if suite.SetupCommand != "" {
+		output, err := s.runSandboxed(&suite, ws, ws.Source, fmt.Sprintf("autograde-%d-setup", run.ID), suite.SetupCommand)
+		if err != nil {
+			return err
+		}
+		run.SetupOutput = output.Stdout + output.Stderr
+		if output.ExitCode != 0 {
+			return fmt.Errorf("setup command failed with exit code %d", output.ExitCode)
+		}
+	}
+
+	run.Results = make([]models.AutogradeTestResult, 0, len(suite.Tests))
+	run.Score, run.MaxScore = 0, 0
+	for i, test := range suite.Tests {
+		output, err := s.runTest(&suite, ws, fmt.Sprintf("autograde-%d-%d", run.ID, i+1), test.Command)
+		if err != nil {
+			return err
+		}
+
+		result := models.AutogradeTestResult{
+			Name:       test.Name,
+			Passed:     output.ExitCode == 0 && !output.TimedOut,
+			Points:     test.Points,
+			ExitCode:   output.ExitCode,
+			TimedOut:   output.TimedOut,
+			Stdout:     output.Stdout,
+			Stderr:     output.Stderr,
+			DurationMs: output.Duration.Milliseconds(),
+		}
+		if result.Passed {
+			result.EarnedPoints = test.Points
+		}
+		run.Results = append(run.Results, result)
+		run.Score += result.EarnedPoints
+		run.MaxScore += test.Points
+	}
+
+	// 按作业满分换算建议得分
+	if run.MaxScore > 0 {
+		run.SuggestedScore = math.Round(run.Score/run.MaxScore*submission.MaxScore*100) / 100
+	}
+
+	return nil
+}
+
+// runTest 在安装命令运行后代码的独立副本中运行一个测试，结束后删除副本
+func (s *AutogradeService) runTest(suite *models.AutogradeSuite, ws *autogradeWorkspace, name, command string) (*autogradeOutput, error) {
+	dir := filepath.Join(ws.Root, name)
+	defer func() {
+		if err := removeWorkspace(dir); err != nil {
+			fmt.Printf("Warning: Failed to remove autograde workspace %s: %v\n", dir, err)
+		}
+	}()
+	if err := copyWorkspace(ws.Source, dir, ws.Files); err != nil {
+		return nil, fmt.Errorf("failed to copy workspace: %w", err)
+	}
+	return s.runSandboxed(suite, ws, dir, name, command)
+}
+
+// prepareWorkspace 下载提交版本的代码并写入测试文件，测试文件另存一份用于只读挂载，返回评测目录和清理函数
+func (s *AutogradeService) prepareWorkspace(run *models.AutogradeRun, gitlabProjectID int, files map[string]string, limits sourceLimits) (*autogradeWorkspace, func(), error) {
+	root, err := filepath.Abs(s.config.WorkDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid work directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(root, fmt.Sprintf("run-%d-", run.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	cleanup := func() {
+		if err := removeWorkspace(dir); err != nil {
+			fmt.Printf("Warning: Failed to remove autograde workspace %s: %v\n", dir, err)
+		}
+	}
+
+	ws := &autogradeWorkspace{
+		Root:   dir,
+		Source: filepath.Join(dir, "workspace"),
+		Tests:  filepath.Join(dir, "tests"),
+		Files:  files,
+	}
+	archivePath := filepath.Join(dir, "source.tar.gz")
+	if err := s.downloadSource(gitlabProjectID, run.CommitHash, archivePath, ws.Source, limits); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := writeTestFiles(ws.Source, files); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(ws.Tests, 0755); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if err := writeTestFiles(ws.Tests, files); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return ws, cleanup, nil
+}
+
+// downloadSource 下载并解压指定版本的代码，归档和解压后的文件都不能超过大小限制
+func (s *AutogradeService) downloadSource(gitlabProjectID int, commit, archivePath, workspace string, limits sourceLimits) error {
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+	defer archive.Close()
+
+	if err := s.gitlabService.DownloadArchive(gitlabProjectID, commit, &limitedWriter{w: archive, limit: limits.MaxTotalSize}); err != nil {
+		if errors.Is(err, errSourceTooLarge) {
+			return fmt.Errorf("source archive exceeds the size limit of %d bytes", limits.MaxTotalSize)
+		}
+		return err
+	}
+	if _, err := archive.Seek(0, 0); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	return extractArchive(archive, workspace, limits)
+}
+
+// recordOnSubmission 将评测结果写入提交的自动检查报告和建议得分
+func (s *AutogradeService) recordOnSubmission(run *models.AutogradeRun) error {
+	autograde := &AutogradeReport{
+		RunID:          run.ID,
+		Status:         run.Status,
+		Passed:         run.Status == models.AutogradeStatusCompleted,
+		Score:          run.Score,
+		MaxScore:       run.MaxScore,
+		SuggestedScore: run.SuggestedScore,
+		Tests:          run.Results,
+		Error:          run.Error,
+	}
+	for _, result := range run.Results {
+		if !result.Passed {
+			autograde.Passed = false
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if run.Status == models.AutogradeStatusCompleted {
+		updates["autograde_score"] = run.SuggestedScore
+	}
+	return updateSubmissionCheckReport(s.db, run.SubmissionID, updates, func(report *SubmissionCheckReport) {
+		report.Autograde = autograde
+	})
+}
@@ -0,0 +1,403 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+)
+
+// 沙箱限制
+const (
+	autogradePidsLimit   = 256             // 容器内最大进程数
+	autogradeKillTimeout = 5 * time.Second // 超时后等待进程退出的时间
+	autogradeMaxEntries  = 100000          // 代码归档中最多解压的文件和目录数
+	autogradeNobodyID    = 65534           // 后端以root运行时容器使用的用户和组
+	autogradeTmpfsSize   = "256m"          // 容器内 /tmp 的大小
+)
+
+// autogradeWorkspace 评测目录：Source 为检出的代码，安装命令在其中运行；Tests 为老师的测试文件，只读挂载
+// 每个测试在 Source 的独立副本中运行，学生代码无法修改测试文件或影响之后的测试
+type autogradeWorkspace struct {
+	Root   string
+	Source string
+	Tests  string
+	Files  map[string]string
+}
+
+// sourceLimits 检出代码的大小限制，值为0时不限制
+type sourceLimits struct {
+	MaxFileSize  int64 // 单个文件的最大字节数
+	MaxTotalSize int64 // 所有文件的总字节数
+}
+
+// autogradeOutput 命令执行结果
+type autogradeOutput struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	TimedOut bool
+	Duration time.Duration
+}
+
+// runSandboxed 在沙箱中执行命令：docker 方式在断网的容器中运行，local 方式在受 ulimit 限制的独立进程组中运行
+// dir 为挂载到 /workspace 的代码目录
+func (s *AutogradeService) runSandboxed(suite *models.AutogradeSuite, ws *autogradeWorkspace, dir, name, command string) (*autogradeOutput, error) {
+	timeout := time.Duration(suite.TimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch suite.Runner {
+	case models.AutogradeRunnerDocker:
+		if err := prepareSandboxDir(dir); err != nil {
+			return nil, err
+		}
+		cmd = s.dockerCommand(ctx, suite, ws, dir, name, command)
+	case models.AutogradeRunnerLocal:
+		if !s.config.AllowLocalRunner {
+			return nil, fmt.Errorf("local runner is disabled")
+		}
+		cmd = localCommand(ctx, suite, dir, command)
+	default:
+		return nil, fmt.Errorf("unsupported runner: %s", suite.Runner)
+	}
+
+	stdout := &limitedBuffer{limit: s.config.OutputLimit}
+	stderr := &limitedBuffer{limit: s.config.OutputLimit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = autogradeKillTimeout
+
+	start := time.Now()
+	err := cmd.Run()
+	output := &autogradeOutput{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Duration: time.Since(start),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		output.ExitCode = 0
+	case errors.As(err, &exitErr):
+		output.ExitCode = exitErr.ExitCode()
+	case output.TimedOut:
+		output.ExitCode = -1
+	default:
+		return nil, fmt.Errorf("failed to run command: %w", err)
+	}
+
+	return output, nil
+}
+
+// dockerCommand 构建容器命令：断网、非root用户、只读根文件系统、只保留必要权限，并限制内存、CPU和进程数
+// 测试文件只读挂载到 /tests，并覆盖挂载到代码目录中的原路径
+func (s *AutogradeService) dockerCommand(ctx context.Context, suite *models.AutogradeSuite, ws *autogradeWorkspace, dir, name, command string) *exec.Cmd {
+	uid, gid := sandboxUser()
+	args := []string{
+		"run", "--rm",
+		"--name", name,
+		"--network", "none",
+		"--user", fmt.Sprintf("%d:%d", uid, gid),
+		"--read-only",
+		"--tmpfs", "/tmp:rw,size=" + autogradeTmpfsSize,
+		"-e", "HOME=/tmp",
+		"--memory", fmt.Sprintf("%dm", suite.MemoryMB),
+		"--memory-swap", fmt.Sprintf("%dm", suite.MemoryMB),
+		"--cpus", fmt.Sprintf("%g", suite.CPUs),
+		"--pids-limit", fmt.Sprintf("%d", autogradePidsLimit),
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"-v", dir + ":/workspace",
+		"-v", ws.Tests + ":/tests:ro",
+	}
+	paths := make([]string, 0, len(ws.Files))
+	for path := range ws.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		rel := filepath.Clean(filepath.FromSlash(path))
+		args = append(args, "-v", filepath.Join(ws.Tests, rel)+":/workspace/"+filepath.ToSlash(rel)+":ro")
+	}
+	args = append(args, "-w", "/workspace", suite.Image, "sh", "-c", command)
+
+	cmd := exec.CommandContext(ctx, s.config.DockerBinary, args...)
+	// 超时时docker客户端被结束后容器仍会运行，需要单独停止
+	cmd.Cancel = func() error {
+		kill := exec.Command(s.config.DockerBinary, "kill", name)
+		if err := kill.Run(); err != nil {
+			fmt.Printf("Warning: Failed to kill autograde container %s: %v\n", name, err)
+		}
+		return cmd.Process.Kill()
+	}
+	return cmd
+}
+
+// localCommand 构建本机命令：独立进程组，限制CPU时间、内存和进程数，仅保留最小环境变量
+func localCommand(ctx context.Context, suite *models.AutogradeSuite, workspace, command string) *exec.Cmd {
+	limits := fmt.Sprintf("ulimit -t %d; ulimit -v %d; ulimit -u %d 2>/dev/null;",
+		suite.TimeoutSeconds, suite.MemoryMB*1024, autogradePidsLimit)
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", limits+" "+command)
+	cmd.Dir = workspace
+	cmd.Env = []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME=" + workspace,
+		"LANG=C.UTF-8",
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	return cmd
+}
+
+// extractArchive 解压GitLab代码归档，去掉归档中的顶层目录，超过大小限制时返回错误
+func extractArchive(reader io.Reader, dest string, limits sourceLimits) error {
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var total int64
+	for entries := 0; ; entries++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if entries >= autogradeMaxEntries {
+			return fmt.Errorf("source archive contains more than %d entries", autogradeMaxEntries)
+		}
+
+		parts := strings.SplitN(header.Name, "/", 2)
+		if len(parts) < 2 || parts[1] == "" {
+			continue
+		}
+		target, err := workspacePath(dest, parts[1])
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+		case tar.TypeReg:
+			if limits.MaxFileSize > 0 && header.Size > limits.MaxFileSize {
+				return fmt.Errorf("file %s exceeds the size limit of %d bytes", parts[1], limits.MaxFileSize)
+			}
+			total += header.Size
+			if limits.MaxTotalSize > 0 && total > limits.MaxTotalSize {
+				return fmt.Errorf("source exceeds the size limit of %d bytes", limits.MaxTotalSize)
+			}
+			// 按归档头声明的大小读取，防止实际内容超出声明
+			if err := writeWorkspaceFile(target, io.LimitReader(tr, header.Size), os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		}
+		// 符号链接等其他类型不解压，避免指向工作目录之外
+	}
+}
+
+// copyWorkspace 将安装命令运行后的代码复制为一个测试的独立目录
+// 先写入测试文件，代码中与测试文件冲突的路径（包括安装命令替换成的符号链接）不复制；符号链接按原样复制，不跟随
+func copyWorkspace(src, dst string, files map[string]string) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	if err := writeTestFiles(dst, files); err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		existing, statErr := os.Lstat(target)
+		if statErr == nil {
+			if entry.IsDir() && existing.IsDir() {
+				return nil
+			}
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.Mkdir(target, 0755)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return writeWorkspaceFile(target, file, info.Mode().Perm())
+		}
+		// 管道、设备等其他类型不复制
+		return nil
+	})
+}
+
+// sandboxUser 容器内运行命令的用户，与后端进程相同，使产生的文件可以被清理；后端以root运行时使用nobody
+func sandboxUser() (int, int) {
+	if uid := os.Geteuid(); uid > 0 {
+		return uid, os.Getegid()
+	}
+	return autogradeNobodyID, autogradeNobodyID
+}
+
+// prepareSandboxDir 后端以root运行时将目录交给容器用户，否则容器无法写入
+func prepareSandboxDir(dir string) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	uid, gid := sandboxUser()
+	return filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return fmt.Errorf("failed to prepare workspace: %w", err)
+		}
+		return nil
+	})
+}
+
+// removeWorkspace 删除评测目录，先恢复学生代码可能去掉的目录写权限
+func removeWorkspace(dir string) error {
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			_ = os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	return os.RemoveAll(dir)
+}
+
+// writeTestFiles 将老师提供的测试文件写入代码目录，同名文件会被覆盖
+func writeTestFiles(dest string, files map[string]string) error {
+	for path, content := range files {
+		target, err := workspacePath(dest, path)
+		if err != nil {
+			return err
+		}
+		if err := writeWorkspaceFile(target, bytes.NewReader([]byte(content)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workspacePath 计算工作目录中的文件路径，拒绝越出工作目录的路径
+func workspacePath(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if target != dest && !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid file path: %s", name)
+	}
+	return target, nil
+}
+
+// writeWorkspaceFile 写入工作目录中的文件
+func writeWorkspaceFile(target string, reader io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// errSourceTooLarge 代码归档超过大小限制
+var errSourceTooLarge = errors.New("source archive exceeds the size limit")
+
+// limitedWriter 写入超过 limit 字节时返回 errSourceTooLarge，limit为0时不限制
+type limitedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.written+int64(len(p)) > l.limit {
+		return 0, errSourceTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+// limitedBuffer 只保留前 limit 字节的输出
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	// 始终返回完整长度，避免子进程因写入失败而退出
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n... (output truncated)"
+	}
+	return b.buf.String()
+}
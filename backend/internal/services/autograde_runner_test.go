@@ -0,0 +1,299 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry 测试归档中的一项，Body 为普通文件内容
+type tarEntry struct {
+	Name     string
+	Typeflag byte
+	Body     string
+	Linkname string
+}
+
+// buildArchive 生成 gzip 压缩的 tar 归档
+func buildArchive(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.Name,
+			Typeflag: entry.Typeflag,
+			Linkname: entry.Linkname,
+			Mode:     0644,
+		}
+		if entry.Typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.Body))
+		}
+		if entry.Typeflag == tar.TypeDir {
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if entry.Body != "" {
+			if _, err := tw.Write([]byte(entry.Body)); err != nil {
+				t.Fatalf("failed to write body: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip: %v", err)
+	}
+	return &buf
+}
+
+// listFiles 列出目录下的所有路径（相对路径）
+func listFiles(t *testing.T, root string) []string {
+	t.Helper()
+
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel, _ := filepath.Rel(root, path); rel != "." {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk %s: %v", root, err)
+	}
+	return paths
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		limits  sourceLimits
+		wantErr string
+		want    map[string]string // 解压后应存在的文件及内容
+		absent  []string          // 解压后不应存在的路径
+	}{
+		{
+			name: "strips top-level directory",
+			entries: []tarEntry{
+				{Name: "repo-abc/", Typeflag: tar.TypeDir},
+				{Name: "repo-abc/src/", Typeflag: tar.TypeDir},
+				{Name: "repo-abc/src/main.go", Typeflag: tar.TypeReg, Body: "package main"},
+				{Name: "repo-abc/README.md", Typeflag: tar.TypeReg, Body: "readme"},
+			},
+			want: map[string]string{"src/main.go": "package main", "README.md": "readme"},
+		},
+		{
+			name: "rejects parent directory traversal",
+			entries: []tarEntry{
+				{Name: "repo/../../escape.txt", Typeflag: tar.TypeReg, Body: "x"},
+			},
+			wantErr: "invalid file path",
+		},
+		{
+			name: "rejects traversal hidden in nested path",
+			entries: []tarEntry{
+				{Name: "repo/src/../../../escape.txt", Typeflag: tar.TypeReg, Body: "x"},
+			},
+			wantErr: "invalid file path",
+		},
+		{
+			name: "keeps absolute paths inside workspace",
+			entries: []tarEntry{
+				{Name: "repo//etc/passwd", Typeflag: tar.TypeReg, Body: "root"},
+				{Name: "/etc/hosts", Typeflag: tar.TypeReg, Body: "hosts"},
+			},
+			want: map[string]string{"etc/passwd": "root", "etc/hosts": "hosts"},
+		},
+		{
+			name: "skips symlinks",
+			entries: []tarEntry{
+				{Name: "repo/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+				{Name: "repo/dir", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+				{Name: "repo/dir/file.txt", Typeflag: tar.TypeReg, Body: "inside"},
+			},
+			want:   map[string]string{"dir/file.txt": "inside"},
+			absent: []string{"link"},
+		},
+		{
+			name: "skips hardlinks",
+			entries: []tarEntry{
+				{Name: "repo/hard", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"},
+				{Name: "repo/other", Typeflag: tar.TypeLink, Linkname: "repo/../../outside"},
+			},
+			absent: []string{"hard", "other"},
+		},
+		{
+			name: "rejects file over size limit",
+			entries: []tarEntry{
+				{Name: "repo/small.txt", Typeflag: tar.TypeReg, Body: "ok"},
+				{Name: "repo/big.bin", Typeflag: tar.TypeReg, Body: strings.Repeat("a", 11)},
+			},
+			limits:  sourceLimits{MaxFileSize: 10},
+			wantErr: "exceeds the size limit of 10 bytes",
+		},
+		{
+			name: "accepts file at size limit",
+			entries: []tarEntry{
+				{Name: "repo/exact.bin", Typeflag: tar.TypeReg, Body: strings.Repeat("a", 10)},
+			},
+			limits: sourceLimits{MaxFileSize: 10},
+			want:   map[string]string{"exact.bin": strings.Repeat("a", 10)},
+		},
+		{
+			name: "rejects total over size limit",
+			entries: []tarEntry{
+				{Name: "repo/a.txt", Typeflag: tar.TypeReg, Body: strings.Repeat("a", 6)},
+				{Name: "repo/b.txt", Typeflag: tar.TypeReg, Body: strings.Repeat("b", 6)},
+			},
+			limits:  sourceLimits{MaxFileSize: 10, MaxTotalSize: 10},
+			wantErr: "source exceeds the size limit of 10 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "workspace")
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				t.Fatalf("failed to create workspace: %v", err)
+			}
+
+			err := extractArchive(buildArchive(t, tt.entries), dest, tt.limits)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extractArchive() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+
+			// 任何情况下都不能在工作目录之外写入文件
+			for _, path := range listFiles(t, root) {
+				if path != "workspace" && !strings.HasPrefix(path, "workspace/") {
+					t.Errorf("file written outside workspace: %s", path)
+				}
+			}
+			for path, content := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(path)))
+				if err != nil {
+					t.Errorf("expected %s to be extracted: %v", path, err)
+					continue
+				}
+				if string(data) != content {
+					t.Errorf("%s = %q, want %q", path, data, content)
+				}
+			}
+			for _, path := range tt.absent {
+				if _, err := os.Lstat(filepath.Join(dest, path)); !os.IsNotExist(err) {
+					t.Errorf("expected %s not to be extracted, got err = %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveEntryLimit(t *testing.T) {
+	entries := make([]tarEntry, 0, autogradeMaxEntries+1)
+	for i := 0; i <= autogradeMaxEntries; i++ {
+		entries = append(entries, tarEntry{Name: "repo/", Typeflag: tar.TypeDir})
+	}
+
+	err := extractArchive(buildArchive(t, entries), t.TempDir(), sourceLimits{})
+	if err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Fatalf("extractArchive() error = %v, want entry limit error", err)
+	}
+}
+
+func TestWorkspacePath(t *testing.T) {
+	dest := filepath.Join(string(os.PathSeparator)+"tmp", "workspace")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "nested file", path: "src/main.go", want: filepath.Join(dest, "src", "main.go")},
+		{name: "cleaned inside", path: "src/../main.go", want: filepath.Join(dest, "main.go")},
+		{name: "absolute path stays inside", path: "/etc/passwd", want: filepath.Join(dest, "etc", "passwd")},
+		{name: "workspace root", path: "", want: dest},
+		{name: "parent directory", path: "../escape", wantErr: true},
+		{name: "nested traversal", path: "src/../../escape", wantErr: true},
+		{name: "sibling with common prefix", path: "../workspace-other/file", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := workspacePath(dest, tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("workspacePath(%q) = %q, want error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("workspacePath(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("workspacePath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyWorkspaceKeepsTestFiles(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "source")
+	dst := filepath.Join(root, "test-1")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{src, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	// 模拟安装命令篡改测试文件，并把测试目录替换为指向工作目录外的符号链接
+	if err := os.WriteFile(filepath.Join(src, "check.sh"), []byte("exit 0"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(src, "tests")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	files := map[string]string{
+		"check.sh":     "exit 1",
+		"tests/a.spec": "spec",
+	}
+	if err := copyWorkspace(src, dst, files); err != nil {
+		t.Fatalf("copyWorkspace() error = %v", err)
+	}
+
+	for path, want := range map[string]string{"check.sh": "exit 1", "tests/a.spec": "spec", "main.go": "package main"} {
+		data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(path)))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q (err %v), want %q", path, data, err, want)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "tests")); err != nil || !info.IsDir() {
+		t.Errorf("tests should be a real directory, got %v (err %v)", info, err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files written outside workspace: %v", entries)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package services
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 让命令在独立的进程组中运行，便于超时时结束全部子进程
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup 结束命令所在的进程组
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package services
+
+import "os/exec"
+
+// setProcessGroup Windows 下不支持进程组，保持默认行为
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup 结束命令进程
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
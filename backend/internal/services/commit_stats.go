@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// MemberCommitStats 成员个人分支的提交统计
+type MemberCommitStats struct {
+	MemberID      uint       `json:"member_id"`
+	UserID        uint       `json:"user_id"`
+	Branch        string     `json:"branch"`
+	CommitCount   int        `json:"commit_count"`
+	FilesModified int        `json:"files_modified"`
+	LinesAdded    int        `json:"lines_added"`
+	LinesDeleted  int        `json:"lines_deleted"`
+	LastCommit    string     `json:"last_commit_hash"`
+	LastCommitAt  *time.Time `json:"last_commit_time"`
+}
+
+// ReconcileCommitStats 重新统计所有关联GitLab的进行中课题的成员提交，补充推送事件可能遗漏的改动
+// 单个课题或成员失败不影响其他课题，所有失败汇总后返回
+func (s *ProjectService) ReconcileCommitStats() error {
+	var projects []models.Project
+	if err := s.db.Where("gitlab_project_id > 0 AND status = ?", "active").Find(&projects).Error; err != nil {
+		return fmt.Errorf("failed to load projects for commit stats sync: %w", err)
+	}
+
+	var errs []error
+	for i := range projects {
+		_, failed, err := s.syncProjectCommitStats(&projects[i])
+		if err == nil && failed > 0 {
+			err = fmt.Errorf("%d members failed", failed)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to sync commit stats of project %d: %v\n", projects[i].ID, err)
+			errs = append(errs, fmt.Errorf("project %d: %w", projects[i].ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sync commit stats of %d/%d projects: %w", len(errs), len(projects), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// SyncProjectCommitStats 立即重新统计课题所有成员的提交
+func (s *ProjectService) SyncProjectCommitStats(projectID uint) ([]MemberCommitStats, error) {
+	var project models.Project
+	if err := s.db.First(&project, projectID).Error; err != nil {
+		return nil, fmt.Errorf("project not found: %w", err)
+	}
+	if project.GitLabProjectID == 0 {
+		return nil, fmt.Errorf("project is not linked to GitLab")
+	}
+
+	results, _, err := s.syncProjectCommitStats(&project)
+	return results, err
+}
+
+// SyncBranchCommitStats 分支有新推送时重新统计该分支所属成员的提交，非个人分支忽略
+func (s *ProjectService) SyncBranchCommitStats(projectID uint, branch string) error {
+	var project models.Project
+	if err := s.db.First(&project, projectID).Error; err != nil {
+		return fmt.Errorf("project not found: %w", err)
+	}
+
+	var member models.ProjectMember
+	if err := s.db.Where("project_id = ? AND personal_branch = ? AND is_active = ?", projectID, branch, true).
+		First(&member).Error; err != nil {
+		return nil
+	}
+
+	_, err := s.syncMemberCommitStats(&project, &member)
+	return err
+}
+
+// syncProjectCommitStats 逐个统计课题成员的个人分支，单个成员失败不影响其他成员，返回失败的成员数
+func (s *ProjectService) syncProjectCommitStats(project *models.Project) ([]MemberCommitStats, int, error) {
+	var members []models.ProjectMember
+	if err := s.db.Where("project_id = ? AND is_active = ? AND personal_branch <> ''", project.ID, true).
+		Find(&members).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get project members: %w", err)
+	}
+
+	results := make([]MemberCommitStats, 0, len(members))
+	failed := 0
+	for i := range members {
+		stats, err := s.syncMemberCommitStats(project, &members[i])
+		if err != nil {
+			fmt.Printf("Warning: Failed to sync commit stats of member %d: %v\n", members[i].ID, err)
+			failed++
+			continue
+		}
+		results = append(results, *stats)
+	}
+
+	return results, failed, nil
+}
+
+// syncMemberCommitStats 重新计算成员个人分支的提交统计
+// 提交数和增删行数按个人分支创建后的提交累计，分支合并到默认分支后不会丢失；分支上的提交都归属于分支所属的成员，
+// 无论提交来自在线编辑、作业提交还是本地推送，但合并提交和其他课题成员的提交（如合并默认分支带入的提交）不计入。
+// 修改的文件数为个人分支相对默认分支的差异，差异为空（已全部合并）时保留原值
+func (s *ProjectService) syncMemberCommitStats(project *models.Project, member *models.ProjectMember) (*MemberCommitStats, error) {
+	since := member.BranchCreatedAt
+	if since == nil {
+		since = &member.JoinedAt
+	}
+	commits, err := s.gitlabService.ListBranchCommits(project.GitLabProjectID, member.PersonalBranch, since)
+	if err != nil {
+		return nil, err
+	}
+	otherAuthors, err := s.otherMemberEmails(project, member.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &MemberCommitStats{
+		MemberID:      member.ID,
+		UserID:        member.UserID,
+		Branch:        member.PersonalBranch,
+		FilesModified: member.FilesModified,
+	}
+	var latest *gitlab.Commit
+	for _, commit := range commits {
+		if len(commit.ParentIDs) > 1 || otherAuthors[strings.ToLower(commit.AuthorEmail)] {
+			continue
+		}
+		stats.CommitCount++
+		if commit.Stats != nil {
+			stats.LinesAdded += commit.Stats.Additions
+			stats.LinesDeleted += commit.Stats.Deletions
+		}
+		if latest == nil || (commit.CommittedDate != nil && latest.CommittedDate != nil && commit.CommittedDate.After(*latest.CommittedDate)) {
+			latest = commit
+		}
+	}
+
+	baseBranch := project.DefaultBranch
+	if baseBranch == "" {
+		baseBranch = "main"
+	}
+	compare, err := s.gitlabService.CompareBranch(project.GitLabProjectID, baseBranch, member.PersonalBranch)
+	if err != nil {
+		return nil, err
+	}
+	if len(compare.Diffs) > 0 {
+		stats.FilesModified = len(compare.Diffs)
+	}
+
+	updates := map[string]interface{}{
+		"commit_count":   stats.CommitCount,
+		"files_modified": stats.FilesModified,
+		"lines_added":    stats.LinesAdded,
+		"lines_deleted":  stats.LinesDeleted,
+	}
+
+	// 分支上没有自己的提交时保留原有的最后提交信息
+	if latest != nil {
+		stats.LastCommit = latest.ID
+		stats.LastCommitAt = latest.CommittedDate
+		updates["last_commit_hash"] = latest.ID
+		updates["last_commit_message"] = latest.Message
+		updates["last_commit_time"] = latest.CommittedDate
+		if latest.CommittedDate != nil && (member.LastActiveTime == nil || latest.CommittedDate.After(*member.LastActiveTime)) {
+			updates["last_active_time"] = latest.CommittedDate
+		}
+	}
+
+	if err := s.db.Model(member).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update member commit stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// otherMemberEmails 课题教师和其他成员的邮箱（小写），其提交不计入成员的个人分支统计
+func (s *ProjectService) otherMemberEmails(project *models.Project, userID uint) (map[string]bool, error) {
+	var emails []string
+	if err := s.db.Model(&models.User{}).
+		Where("(id IN (?) OR id = ?) AND id <> ?",
+			s.db.Model(&models.ProjectMember{}).Select("user_id").Where("project_id = ?", project.ID),
+			project.TeacherID, userID).
+		Pluck("LOWER(email)", &emails).Error; err != nil {
+		return nil, fmt.Errorf("failed to get project member emails: %w", err)
+	}
+
+	result := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		result[email] = true
+	}
+	return result, nil
+}
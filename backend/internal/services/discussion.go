@@ -15,6 +15,9 @@ type DiscussionService struct {
 	db                *gorm.DB
 	permissionService *PermissionService
 	gitlabService     *GitLabService
+
+	achievementService *AchievementService
+	webhookService     *WebhookService
 }
 
 // NewDiscussionService 创建话题讨论服务
@@ -26,6 +29,16 @@ func NewDiscussionService(db *gorm.DB, permissionService *PermissionService, git
 	}
 }
 
+// SetAchievementService 设置成就服务，用于在回复话题时评估成就
+func (s *DiscussionService) SetAchievementService(achievementService *AchievementService) {
+	s.achievementService = achievementService
+}
+
+// SetWebhookService 设置Webhook服务，用于向第三方推送话题创建事件
+func (s *DiscussionService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
 // CreateDiscussion 创建话题
 func (s *DiscussionService) CreateDiscussion(req *models.DiscussionCreateRequest, authorID uint) (*models.Discussion, error) {
 	// 验证项目权限
@@ -72,6 +85,10 @@ func (s *DiscussionService) CreateDiscussion(req *models.DiscussionCreateRequest
 		return nil, fmt.Errorf("加载讨论数据失败: %w", err)
 	}
 
+	if s.webhookService != nil {
+		s.webhookService.EmitDiscussionCreated(discussion)
+	}
+
 	return discussion, nil
 }
 
@@ -280,6 +297,11 @@ func (s *DiscussionService) CreateReply(discussionID uint, req *models.Discussio
 	// 更新话题回复数
 	s.db.Model(&discussion).UpdateColumn("reply_count", gorm.Expr("reply_count + ?", 1))
 
+	// 回答他人提问时评估成就
+	if s.achievementService != nil && discussion.Category == "question" && discussion.AuthorID != userID {
+		s.achievementService.EvaluateQuietly(userID, models.AchievementRuleDiscussionAnswers)
+	}
+
 	// 预加载关联数据
 	if err := s.db.Preload("Author").First(reply, reply.ID).Error; err != nil {
 		return nil, fmt.Errorf("加载回复数据失败: %w", err)
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// EducationReportService 教育报表服务
+// 班级管理已移除，报表中的"班级"即课题
+type EducationReportService struct {
+	db                *gorm.DB
+	permissionService *PermissionService
+	exportDir         string
+	exportTTL         time.Duration
+}
+
+// NewEducationReportService 创建教育报表服务
+func NewEducationReportService(db *gorm.DB, permissionService *PermissionService, exportDir string, exportTTL time.Duration) *EducationReportService {
+	return &EducationReportService{
+		db:                db,
+		permissionService: permissionService,
+		exportDir:         exportDir,
+		exportTTL:         exportTTL,
+	}
+}
+
+// 报表中优秀学生的数量
+const topPerformerLimit = 5
+
+// ReportFilter 报表筛选条件
+type ReportFilter struct {
+	TimeRange string // week, month, quarter, year, all
+	ClassID   uint   // 班级（课题）ID，0表示全部
+}
+
+// EducationReport 教育报表数据
+type EducationReport struct {
+	Overview        ReportOverview         `json:"overview"`
+	ClassActivity   []ClassActivity        `json:"class_activity"`
+	AssignmentStats []ReportAssignmentStat `json:"assignment_stats"`
+	ProgressTrends  []ProgressTrend        `json:"progress_trends"`
+	TopPerformers   []TopPerformer         `json:"top_performers"`
+
+	TimeRange   string    `json:"time_range"`
+	Since       time.Time `json:"since"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// ReportOverview 报表概览
+type ReportOverview struct {
+	TotalStudents     int     `json:"total_students"`
+	TotalAssignments  int     `json:"total_assignments"`
+	TotalProjects     int     `json:"total_projects"`
+	AverageCompletion float64 `json:"average_completion"`
+}
+
+// ClassActivity 班级活跃度
+type ClassActivity struct {
+	ClassID        uint    `json:"class_id"`
+	ClassName      string  `json:"class_name"`
+	ActiveStudents int     `json:"active_students"`
+	TotalStudents  int     `json:"total_students"`
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+// ReportAssignmentStat 作业提交统计
+type ReportAssignmentStat struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ClassName string    `json:"class_name"`
+	DueDate   time.Time `json:"due_date"`
+	Submitted int       `json:"submitted"`
+	Total     int       `json:"total"`
+	OnTime    int       `json:"on_time"`
+	Late      int       `json:"late"`
+}
+
+// ProgressTrend 完成率与参与率趋势
+type ProgressTrend struct {
+	Date          string `json:"date"`
+	Completion    int    `json:"completion"`    // 截至该日已到期作业的完成率（%）
+	Participation int    `json:"participation"` // 截至该日有提交记录的学生比例（%）
+}
+
+// TopPerformer 优秀学生
+type TopPerformer struct {
+	ID             uint    `json:"id"`
+	Name           string  `json:"name"`
+	CompletionRate float64 `json:"completion_rate"`
+	Points         float64 `json:"points"` // 已评分作业得分总和
+}
+
+// ReportClass 可选班级
+type ReportClass struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// reportMember 班级中的学生
+type reportMember struct {
+	ProjectID      uint
+	UserID         uint
+	Name           string
+	LastActiveTime *time.Time
+	LastCommitTime *time.Time
+}
+
+// reportSubmission 学生在作业上的提交情况（每个作业只取首次提交）
+type reportSubmission struct {
+	AssignmentID uint
+	StudentID    uint
+	SubmittedAt  time.Time
+	Score        float64
+	Graded       bool
+	IsLate       bool
+}
+
+// GetClassList 获取用户可查看报表的班级列表
+func (s *EducationReportService) GetClassList(userID uint) ([]ReportClass, error) {
+	query, err := s.projectScope(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []models.Project
+	if err := query.Select("id, name").Order("id").Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("failed to get classes: %w", err)
+	}
+
+	classes := []ReportClass{{Value: "all", Label: "所有班级"}}
+	for _, project := range projects {
+		classes = append(classes, ReportClass{Value: fmt.Sprintf("%d", project.ID), Label: project.Name})
+	}
+
+	return classes, nil
+}
+
+// GetReport 生成教育报表
+func (s *EducationReportService) GetReport(userID uint, filter ReportFilter) (*EducationReport, error) {
+	now := time.Now()
+	since, err := reportRangeStart(filter.TimeRange, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := s.projectScope(userID)
+	if err != nil {
+		return nil, err
+	}
+	if filter.ClassID != 0 {
+		query = query.Where("id = ?", filter.ClassID)
+	}
+
+	var projects []models.Project
+	if err := query.Order("id").Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("failed to get classes: %w", err)
+	}
+	if filter.ClassID != 0 && len(projects) == 0 {
+		return nil, fmt.Errorf("class not found or permission denied")
+	}
+
+	projectIDs := make([]uint, 0, len(projects))
+	for _, project := range projects {
+		projectIDs = append(projectIDs, project.ID)
+	}
+
+	var members []reportMember
+	if err := s.db.Table("project_members").
+		Select("project_members.project_id, project_members.user_id, users.name, project_members.last_active_time, project_members.last_commit_time").
+		Joins("JOIN users ON users.id = project_members.user_id").
+		Where("project_members.project_id IN ? AND project_members.role = 'student' AND project_members.is_active = true", projectIDs).
+		Scan(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to get class members: %w", err)
+	}
+
+	// 统计区间内处于开放状态的作业：区间开始后截止，且已经发布
+	var assignments []models.Assignment
+	if err := s.db.Where("project_id IN ? AND due_date >= ? AND created_at <= ?", projectIDs, since, now).
+		Order("due_date").Find(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get assignments: %w", err)
+	}
+
+	assignmentIDs := make([]uint, 0, len(assignments))
+	for _, assignment := range assignments {
+		assignmentIDs = append(assignmentIDs, assignment.ID)
+	}
+
+	var submissions []reportSubmission
+	if err := s.db.Table("assignment_submissions").
+		Select("assignment_id, student_id, MIN(submitted_at) AS submitted_at, MAX(CASE WHEN status = 'graded' AND is_selected THEN score ELSE 0 END) AS score, BOOL_OR(status = 'graded' AND is_selected) AS graded, BOOL_AND(is_late) AS is_late").
+		Where("assignment_id IN ?", assignmentIDs).
+		Group("assignment_id, student_id").
+		Scan(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get submissions: %w", err)
+	}
+
+	report := buildEducationReport(projects, members, assignments, submissions, since, now)
+	report.TimeRange = filter.TimeRange
+	return report, nil
+}
+
+// projectScope 用户可查看报表的课题范围：管理员全部，教师本人课题，学生无权查看
+func (s *EducationReportService) projectScope(userID uint) (*gorm.DB, error) {
+	query := s.db.Model(&models.Project{})
+	if s.permissionService.IsAdmin(userID) {
+		return query, nil
+	}
+	if s.permissionService.IsTeacher(userID) {
+		return query.Where("teacher_id = ?", userID), nil
+	}
+	return nil, fmt.Errorf("permission denied to view education reports")
+}
+
+// reportRangeStart 计算统计区间的开始时间（按自然周/月/季度/年）
+func reportRangeStart(timeRange string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch timeRange {
+	case "week":
+		// 以周一作为一周的开始
+		offset := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -offset), nil
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	case "quarter":
+		month := time.Month((int(now.Month())-1)/3*3 + 1)
+		return time.Date(now.Year(), month, 1, 0, 0, 0, 0, now.Location()), nil
+	case "year":
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location()), nil
+	case "", "all":
+		return time.Time{}, nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time range: %s", timeRange)
+	}
+}
+
+// buildEducationReport 根据班级、成员、作业和提交数据汇总报表
+func buildEducationReport(projects []models.Project, members []reportMember, assignments []models.Assignment,
+	submissions []reportSubmission, since, now time.Time) *EducationReport {
+	report := &EducationReport{
+		ClassActivity:   []ClassActivity{},
+		AssignmentStats: []ReportAssignmentStat{},
+		ProgressTrends:  []ProgressTrend{},
+		TopPerformers:   []TopPerformer{},
+		Since:           since,
+		GeneratedAt:     now,
+	}
+
+	projectNames := make(map[uint]string, len(projects))
+	for _, project := range projects {
+		projectNames[project.ID] = project.Name
+	}
+
+	// 班级成员
+	classStudents := make(map[uint][]reportMember)
+	studentNames := make(map[uint]string)
+	for _, member := range members {
+		classStudents[member.ProjectID] = append(classStudents[member.ProjectID], member)
+		studentNames[member.UserID] = member.Name
+	}
+
+	// 班级作业
+	classAssignments := make(map[uint][]models.Assignment)
+	assignmentByID := make(map[uint]models.Assignment, len(assignments))
+	for _, assignment := range assignments {
+		classAssignments[assignment.ProjectID] = append(classAssignments[assignment.ProjectID], assignment)
+		assignmentByID[assignment.ID] = assignment
+	}
+
+	// 提交记录按作业、学生索引
+	submitted := make(map[[2]uint]reportSubmission, len(submissions))
+	for _, submission := range submissions {
+		submitted[[2]uint{submission.AssignmentID, submission.StudentID}] = submission
+	}
+
+	// 作业统计
+	for _, assignment := range assignments {
+		stat := ReportAssignmentStat{
+			ID:        assignment.ID,
+			Name:      assignment.Title,
+			ClassName: projectNames[assignment.ProjectID],
+			DueDate:   assignment.DueDate,
+			Total:     len(classStudents[assignment.ProjectID]),
+		}
+		for _, member := range classStudents[assignment.ProjectID] {
+			submission, ok := submitted[[2]uint{assignment.ID, member.UserID}]
+			if !ok {
+				continue
+			}
+			stat.Submitted++
+			if submission.IsLate {
+				stat.Late++
+			} else {
+				stat.OnTime++
+			}
+		}
+		report.AssignmentStats = append(report.AssignmentStats, stat)
+	}
+
+	// 班级活跃度与学生完成情况
+	type studentTotal struct {
+		expected  int
+		completed int
+		points    float64
+	}
+	students := make(map[uint]*studentTotal)
+	totalExpected, totalCompleted := 0, 0
+
+	for _, project := range projects {
+		activity := ClassActivity{
+			ClassID:       project.ID,
+			ClassName:     project.Name,
+			TotalStudents: len(classStudents[project.ID]),
+		}
+		expected, completed := 0, 0
+
+		for _, member := range classStudents[project.ID] {
+			total, ok := students[member.UserID]
+			if !ok {
+				total = &studentTotal{}
+				students[member.UserID] = total
+			}
+
+			active := isActiveSince(member.LastActiveTime, since) || isActiveSince(member.LastCommitTime, since)
+			for _, assignment := range classAssignments[project.ID] {
+				expected++
+				total.expected++
+				submission, ok := submitted[[2]uint{assignment.ID, member.UserID}]
+				if !ok {
+					continue
+				}
+				completed++
+				total.completed++
+				if submission.Graded {
+					total.points += submission.Score
+				}
+				if !submission.SubmittedAt.Before(since) {
+					active = true
+				}
+			}
+			if active {
+				activity.ActiveStudents++
+			}
+		}
+
+		activity.CompletionRate = reportPercent(completed, expected)
+		totalExpected += expected
+		totalCompleted += completed
+		report.ClassActivity = append(report.ClassActivity, activity)
+	}
+
+	report.Overview = ReportOverview{
+		TotalStudents:     len(students),
+		TotalAssignments:  len(assignments),
+		TotalProjects:     len(projects),
+		AverageCompletion: reportPercent(totalCompleted, totalExpected),
+	}
+
+	// 优秀学生：按完成率、得分排序
+	for id, total := range students {
+		report.TopPerformers = append(report.TopPerformers, TopPerformer{
+			ID:             id,
+			Name:           studentNames[id],
+			CompletionRate: reportPercent(total.completed, total.expected),
+			Points:         math.Round(total.points*10) / 10,
+		})
+	}
+	sort.Slice(report.TopPerformers, func(i, j int) bool {
+		a, b := report.TopPerformers[i], report.TopPerformers[j]
+		if a.CompletionRate != b.CompletionRate {
+			return a.CompletionRate > b.CompletionRate
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.ID < b.ID
+	})
+	if len(report.TopPerformers) > topPerformerLimit {
+		report.TopPerformers = report.TopPerformers[:topPerformerLimit]
+	}
+
+	report.ProgressTrends = buildProgressTrends(assignments, classStudents, submitted, len(students), since, now)
+
+	return report
+}
+
+// buildProgressTrends 按区间长度选择按天或按周取样，计算截至每个时间点的完成率与参与率
+func buildProgressTrends(assignments []models.Assignment, classStudents map[uint][]reportMember,
+	submitted map[[2]uint]reportSubmission, totalStudents int, since, now time.Time) []ProgressTrend {
+	trends := []ProgressTrend{}
+
+	start := since
+	if start.IsZero() {
+		// 全部时间范围从最早的作业开始
+		if len(assignments) == 0 {
+			return trends
+		}
+		start = assignments[0].CreatedAt
+		for _, assignment := range assignments {
+			if assignment.CreatedAt.Before(start) {
+				start = assignment.CreatedAt
+			}
+		}
+	}
+
+	step := 7 * 24 * time.Hour
+	if now.Sub(start) <= 14*24*time.Hour {
+		step = 24 * time.Hour
+	}
+
+	for point := start.Add(step); ; point = point.Add(step) {
+		if point.After(now) {
+			point = now
+		}
+
+		expected, completed := 0, 0
+		participants := make(map[uint]bool)
+		for _, assignment := range assignments {
+			for _, member := range classStudents[assignment.ProjectID] {
+				submission, ok := submitted[[2]uint{assignment.ID, member.UserID}]
+				hasSubmitted := ok && !submission.SubmittedAt.After(point)
+				if hasSubmitted {
+					participants[member.UserID] = true
+				}
+				if assignment.DueDate.After(point) {
+					continue
+				}
+				expected++
+				if hasSubmitted {
+					completed++
+				}
+			}
+		}
+
+		trends = append(trends, ProgressTrend{
+			Date:          point.Format("2006-01-02"),
+			Completion:    int(math.Round(reportPercent(completed, expected))),
+			Participation: int(math.Round(reportPercent(len(participants), totalStudents))),
+		})
+
+		if !point.Before(now) {
+			break
+		}
+	}
+
+	return trends
+}
+
+// isActiveSince 判断时间是否在统计区间内
+func isActiveSince(t *time.Time, since time.Time) bool {
+	return t != nil && !t.Before(since)
+}
+
+// reportPercent 计算百分比，保留一位小数
+func reportPercent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*1000) / 10
+}
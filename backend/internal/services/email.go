@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlabex/internal/config"
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 邮件发送队列参数
+const (
+	emailOutboxBatch   = 50               // 每次处理的邮件数
+	emailSendLease     = 5 * time.Minute  // 发送中的邮件在租约到期前不会被其他实例重复发送
+	emailRetryBase     = time.Minute      // 第一次重试的等待时间，之后每次翻倍
+	emailRetryMaxDelay = 6 * time.Hour    // 重试等待时间上限
+	emailDialTimeout   = 30 * time.Second // 连接SMTP服务器的超时时间
+	emailSendTimeout   = 2 * time.Minute  // 单封邮件的SMTP会话超时时间
+)
+
+// EmailService 邮件发送服务，通知邮件先写入发送队列，由定时任务发送并在失败时重试
+type EmailService struct {
+	db          *gorm.DB
+	config      config.SMTPConfig
+	frontendURL string
+}
+
+// NewEmailService 创建邮件发送服务
+func NewEmailService(db *gorm.DB, smtpConfig config.SMTPConfig, frontendURL string) *EmailService {
+	if smtpConfig.MaxAttempts <= 0 {
+		smtpConfig.MaxAttempts = 1
+	}
+	return &EmailService{
+		db:          db,
+		config:      smtpConfig,
+		frontendURL: strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// Enabled 是否配置了SMTP服务器
+func (s *EmailService) Enabled() bool {
+	return s.config.Host != ""
+}
+
+// EnqueueNotification 渲染通知邮件并写入发送队列，tx 为创建通知的事务；用户没有有效邮箱时跳过
+func (s *EmailService) EnqueueNotification(tx *gorm.DB, notification *models.Notification) error {
+	var user models.User
+	if err := tx.First(&user, notification.UserID).Error; err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if _, err := mail.ParseAddress(user.Email); err != nil {
+		fmt.Printf("Warning: User %d has no valid email address, skip email notification\n", user.ID)
+		return nil
+	}
+
+	email, err := renderNotificationEmail(notification.Type, emailTemplateData{
+		AppName:  emailAppName,
+		UserName: user.Name,
+		Title:    notification.Title,
+		Content:  notification.Content,
+		Link:     s.notificationLink(notification),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(tx, &models.EmailOutbox{
+		NotificationID: notification.ID,
+		UserID:         user.ID,
+		To:             user.Email,
+		Subject:        email.Subject,
+		TextBody:       email.Text,
+		HTMLBody:       email.HTML,
+	})
+}
+
+// enqueue 写入发送队列
+func (s *EmailService) enqueue(tx *gorm.DB, email *models.EmailOutbox) error {
+	email.Status = models.EmailStatusPending
+	email.NextAttemptAt = time.Now()
+	if err := tx.Create(email).Error; err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return nil
+}
+
+// notificationLink 通知对象在前端中的地址
+func (s *EmailService) notificationLink(notification *models.Notification) string {
+	if s.frontendURL == "" {
+		return ""
+	}
+	switch notification.TargetType {
+	case "assignment":
+		return fmt.Sprintf("%s/assignments/%d", s.frontendURL, notification.TargetID)
+	case "project":
+		return fmt.Sprintf("%s/projects/%d", s.frontendURL, notification.TargetID)
+	case "discussion":
+		return fmt.Sprintf("%s/discussions/%d", s.frontendURL, notification.TargetID)
+	default:
+		return s.frontendURL + "/"
+	}
+}
+
+// ProcessOutbox 发送队列中到期的邮件，由定时任务调用
+func (s *EmailService) ProcessOutbox() error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	var emails []models.EmailOutbox
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", models.EmailStatusPending, time.Now()).
+		Order("id").Limit(emailOutboxBatch).Find(&emails).Error; err != nil {
+		return fmt.Errorf("failed to get pending emails: %w", err)
+	}
+
+	failed := 0
+	for i := range emails {
+		if !s.claim(&emails[i]) {
+			continue
+		}
+		if !s.deliver(&emails[i]) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to send %d of %d emails", failed, len(emails))
+	}
+	return nil
+}
+
+// claim 以租约方式锁定邮件，多个实例同时处理队列时只有一个实例能发送
+func (s *EmailService) claim(email *models.EmailOutbox) bool {
+	now := time.Now()
+	result := s.db.Model(&models.EmailOutbox{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", email.ID, models.EmailStatusPending, now).
+		Updates(map[string]interface{}{
+			"next_attempt_at": now.Add(emailSendLease),
+			"attempts":        gorm.Expr("attempts + 1"),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	email.Attempts++
+	return true
+}
+
+// deliver 发送邮件并保存结果，失败时按指数退避安排重试
+func (s *EmailService) deliver(email *models.EmailOutbox) bool {
+	err := s.Send(email.To, email.Subject, email.TextBody, email.HTMLBody)
+	now := time.Now()
+
+	updates := map[string]interface{}{}
+	switch {
+	case err == nil:
+		updates["status"] = models.EmailStatusSent
+		updates["sent_at"] = now
+		updates["last_error"] = ""
+	case email.Attempts >= s.config.MaxAttempts:
+		updates["status"] = models.EmailStatusFailed
+		updates["last_error"] = err.Error()
+	default:
+		updates["next_attempt_at"] = now.Add(emailRetryDelay(email.Attempts))
+		updates["last_error"] = err.Error()
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to send email %d (attempt %d): %v\n", email.ID, email.Attempts, err)
+	}
+
+	if dbErr := s.db.Model(email).Updates(updates).Error; dbErr != nil {
+		fmt.Printf("Warning: Failed to update email %d: %v\n", email.ID, dbErr)
+	}
+	return err == nil
+}
+
+// emailRetryDelay 第 attempts 次发送失败后的重试等待时间
+func emailRetryDelay(attempts int) time.Duration {
+	delay := emailRetryBase
+	for i := 1; i < attempts && delay < emailRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > emailRetryMaxDelay {
+		delay = emailRetryMaxDelay
+	}
+	return delay
+}
+
+// Send 通过SMTP发送一封包含纯文本和HTML正文的邮件
+func (s *EmailService) Send(to, subject, textBody, htmlBody string) error {
+	if !s.Enabled() {
+		return fmt.Errorf("SMTP is not configured")
+	}
+
+	message, err := buildEmailMessage(s.config.From, to, subject, textBody, htmlBody)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	var conn net.Conn
+	if s.config.ImplicitTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: emailDialTimeout}, "tcp", addr, &tls.Config{ServerName: s.config.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, emailDialTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(emailSendTimeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+	defer client.Close()
+
+	if !s.config.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+				return fmt.Errorf("failed to start TLS: %w", err)
+			}
+		}
+	}
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(s.config.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("failed to set recipient: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildEmailMessage 构造 multipart/alternative 格式的邮件
+func buildEmailMessage(from, to, subject, textBody, htmlBody string) ([]byte, error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	toAddr, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	// 防止主题中的换行注入邮件头
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", textBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(writer)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	headers := [][2]string{
+		{"From", fromAddr.String()},
+		{"To", toAddr.String()},
+		{"Subject", mime.QEncoding.Encode("UTF-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", randomMessageID(), emailDomain(fromAddr.Address))},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", parts.Boundary())},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header[0], header[1])
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// randomMessageID 生成邮件的 Message-ID
+func randomMessageID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// emailDomain 邮箱地址的域名部分
+func emailDomain(address string) string {
+	if idx := strings.LastIndex(address, "@"); idx >= 0 {
+		return address[idx+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"gitlabex/internal/models"
+)
+
+// emailAppName 邮件中显示的系统名称
+const emailAppName = "GitLabEx"
+
+// emailTemplateData 渲染邮件模板的数据
+type emailTemplateData struct {
+	AppName  string
+	UserName string
+	Title    string
+	Content  string
+	Link     string // 前端中查看通知对象的地址
+}
+
+// emailBodyTemplate 某类通知的邮件模板，主题和正文可使用 emailTemplateData 中的字段
+type emailBodyTemplate struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// emailTextLayout 纯文本邮件布局，正文由各类型模板的 body 定义
+const emailTextLayout = `{{.UserName}}，您好：
+
+{{template "body" .}}
+{{if .Link}}
+查看详情：{{.Link}}
+{{end}}
+--
+此邮件由 {{.AppName}} 自动发送，可在个人设置中修改通知方式。
+`
+
+// emailHTMLLayout HTML邮件布局
+const emailHTMLLayout = `<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, 'PingFang SC', 'Microsoft YaHei', sans-serif; color: #303133; line-height: 1.6;">
+  <div style="max-width: 600px; margin: 0 auto; padding: 24px;">
+    <h2 style="margin-top: 0;">{{.Title}}</h2>
+    <p>{{.UserName}}，您好：</p>
+    {{template "body" .}}
+    {{if .Link}}<p><a href="{{.Link}}" style="display: inline-block; padding: 8px 16px; background: #409eff; color: #fff; text-decoration: none; border-radius: 4px;">查看详情</a></p>{{end}}
+    <hr style="border: none; border-top: 1px solid #ebeef5; margin-top: 32px;">
+    <p style="font-size: 12px; color: #909399;">此邮件由 {{.AppName}} 自动发送，可在个人设置中修改通知方式。</p>
+  </div>
+</body>
+</html>
+`
+
+// defaultEmailTemplate 未单独定义模板的通知类型使用的模板
+var defaultEmailTemplate = emailBodyTemplate{
+	Subject: "[{{.AppName}}] {{.Title}}",
+	Text:    "{{.Content}}\n",
+	HTML:    "<p>{{.Content}}</p>",
+}
+
+// emailTemplates 各通知类型的邮件模板
+var emailTemplates = map[string]emailBodyTemplate{
+	models.NotificationTypeAssignmentDue: {
+		Subject: "[{{.AppName}}] 作业即将到期提醒",
+		Text:    "{{.Content}}\n\n如已完成，请尽快在系统中提交；已提交的同学不会再收到此提醒。\n",
+		HTML:    "<p><strong>{{.Content}}</strong></p><p>如已完成，请尽快在系统中提交；已提交的同学不会再收到此提醒。</p>",
+	},
+	models.NotificationTypeAssignmentUnsubmitted: {
+		Subject: "[{{.AppName}}] 作业未提交学生汇总",
+		Text:    "{{.Content}}\n\n可在作业详情中为有需要的学生批准延期。\n",
+		HTML:    "<p>{{.Content}}</p><p>可在作业详情中为有需要的学生批准延期。</p>",
+	},
+	models.NotificationTypeAssignmentCreated: {
+		Subject: "[{{.AppName}}] 新作业：{{.Title}}",
+		Text:    "老师发布了新作业。\n\n{{.Content}}\n",
+		HTML:    "<p>老师发布了新作业。</p><p>{{.Content}}</p>",
+	},
+	models.NotificationTypeAssignmentReviewed: {
+		Subject: "[{{.AppName}}] 作业评审结果",
+		Text:    "{{.Content}}\n",
+		HTML:    "<p>{{.Content}}</p><p>评审意见和得分可在作业详情中查看。</p>",
+	},
+	models.NotificationTypeDigest: {
+		Subject: "[{{.AppName}}] {{.Title}}",
+		Text:    "{{.Content}}\n",
+		HTML:    "<pre style=\"white-space: pre-wrap; font-family: inherit;\">{{.Content}}</pre>",
+	},
+	models.NotificationTypeAchievementEarned: {
+		Subject: "[{{.AppName}}] 恭喜获得新成就",
+		Text:    "{{.Content}}\n",
+		HTML:    "<p>🎉 {{.Content}}</p>",
+	},
+}
+
+// renderedEmail 渲染后的邮件
+type renderedEmail struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// renderNotificationEmail 按通知类型渲染邮件主题和正文
+func renderNotificationEmail(notificationType string, data emailTemplateData) (*renderedEmail, error) {
+	tmpl, ok := emailTemplates[notificationType]
+	if !ok {
+		tmpl = defaultEmailTemplate
+	}
+
+	subject, err := renderTextTemplate("subject", tmpl.Subject, "", data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := renderTextTemplate("text", emailTextLayout, tmpl.Text, data)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := htmltemplate.New("html").Parse(emailHTMLLayout)
+	if err == nil {
+		_, err = htmlTmpl.New("body").Parse(tmpl.HTML)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email template: %w", err)
+	}
+	var html bytes.Buffer
+	if err := htmlTmpl.ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return &renderedEmail{
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+		HTML:    html.String(),
+	}, nil
+}
+
+// renderTextTemplate 渲染纯文本模板，body 不为空时作为布局中的 body 模板
+func renderTextTemplate(name, layout, body string, data emailTemplateData) (string, error) {
+	tmpl, err := texttemplate.New(name).Parse(layout)
+	if err == nil && body != "" {
+		_, err = tmpl.New("body").Parse(body)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to parse email template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render email template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,10 +19,11 @@ import (
 
 // GitLabService GitLab服务 - 所有GitLab API的统一封装
 type GitLabService struct {
-	client *gitlab.Client
-	cache  *redis.Client
-	db     *gorm.DB
-	config *config.Config
+	client     *gitlab.Client
+	cache      *redis.Client
+	db         *gorm.DB
+	config     *config.Config
+	userClient func(userID uint) (*gitlab.Client, error) // 获取用户自己的GitLab客户端，未设置时只使用系统令牌
 }
 
 // NewGitLabService 创建GitLab服务实例
@@ -58,6 +61,27 @@ func (s *GitLabService) SetAccessToken(token string) error {
 	return nil
 }
 
+// SetUserClientProvider 设置获取用户GitLab客户端的方法，用于以用户身份调用GitLab API
+func (s *GitLabService) SetUserClientProvider(provider func(userID uint) (*gitlab.Client, error)) {
+	s.userClient = provider
+}
+
+// ForUser 返回以用户身份调用GitLab API的服务，GitLab按用户自己的权限处理请求并记录操作者
+// 用户没有可用的GitLab令牌（未重新登录或令牌已失效）时使用系统令牌
+func (s *GitLabService) ForUser(userID uint) *GitLabService {
+	if s.userClient == nil {
+		return s
+	}
+	client, err := s.userClient(userID)
+	if err != nil {
+		fmt.Printf("Warning: Failed to use GitLab token of user %d, falling back to system token: %v\n", userID, err)
+		return s
+	}
+	scoped := *s
+	scoped.client = client
+	return &scoped
+}
+
 // GetCurrentUser 获取当前用户信息
 func (s *GitLabService) GetCurrentUser() (*gitlab.User, error) {
 	user, _, err := s.client.Users.CurrentUser()
@@ -455,9 +479,38 @@ func (s *GitLabService) CreateProjectRepository(name, description, readmeContent
 		}
 	}
 
+	// 注册Webhook，接收推送、合并请求、Issue、Wiki和流水线事件
+	if err := s.AddProjectWebhook(project.ID); err != nil {
+		fmt.Printf("Warning: Failed to register project webhook: %v\n", err)
+	}
+
 	return project, nil
 }
 
+// AddProjectWebhook 为项目注册本系统的Webhook，未配置Webhook地址或密钥时跳过
+func (s *GitLabService) AddProjectWebhook(projectID int) error {
+	if s.config.GitLab.WebhookURL == "" || s.config.GitLab.WebhookSecret == "" {
+		return nil
+	}
+
+	opts := &gitlab.AddProjectHookOptions{
+		URL:                   gitlab.String(s.config.GitLab.WebhookURL),
+		Token:                 gitlab.String(s.config.GitLab.WebhookSecret),
+		PushEvents:            gitlab.Bool(true),
+		MergeRequestsEvents:   gitlab.Bool(true),
+		IssuesEvents:          gitlab.Bool(true),
+		WikiPageEvents:        gitlab.Bool(true),
+		PipelineEvents:        gitlab.Bool(true),
+		EnableSSLVerification: gitlab.Bool(strings.HasPrefix(s.config.GitLab.WebhookURL, "https://")),
+	}
+
+	if _, _, err := s.client.Projects.AddProjectHook(projectID, opts); err != nil {
+		return fmt.Errorf("failed to add project hook: %w", err)
+	}
+
+	return nil
+}
+
 // CreateStudentBranch 为学生创建个人分支
 func (s *GitLabService) CreateStudentBranch(projectID int, studentID int, branchName string) (*gitlab.Branch, error) {
 	// 创建分支
@@ -499,14 +552,56 @@ func (s *GitLabService) AddStudentToProject(projectID int, studentID int, access
 	return nil
 }
 
+// ListProjectMembers 获取项目的直接成员（不含从群组继承的成员）
+func (s *GitLabService) ListProjectMembers(projectID int) ([]*gitlab.ProjectMember, error) {
+	opts := &gitlab.ListProjectMembersOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+			Page:    1,
+		},
+	}
+
+	var members []*gitlab.ProjectMember
+	for {
+		page, resp, err := s.client.ProjectMembers.ListProjectMembers(projectID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list project members: %w", err)
+		}
+		members = append(members, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return members, nil
+}
+
+// GetUser 根据GitLab用户ID获取用户信息
+func (s *GitLabService) GetUser(gitlabID int) (*gitlab.User, error) {
+	user, _, err := s.client.Users.GetUser(gitlabID, gitlab.GetUsersOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitLab user: %w", err)
+	}
+	return user, nil
+}
+
 // SubmitAssignment 学生提交作业
 func (s *GitLabService) SubmitAssignment(projectID int, branchName string, files map[string]string, commitMessage string) (string, error) {
 	var actions []*gitlab.CommitActionOptions
 
-	// 为每个文件创建commit action
+	// 为每个文件创建commit action，分支中已存在的文件（重复提交）改为更新
 	for filePath, content := range files {
+		action := gitlab.FileCreate
+		if _, _, err := s.client.RepositoryFiles.GetFileMetaData(projectID, filePath, &gitlab.GetFileMetaDataOptions{
+			Ref: gitlab.String(branchName),
+		}); err == nil {
+			action = gitlab.FileUpdate
+		}
+
 		actions = append(actions, &gitlab.CommitActionOptions{
-			Action:   gitlab.FileAction(gitlab.FileCreate), // 或者 gitlab.FileUpdate
+			Action:   gitlab.FileAction(action),
 			FilePath: gitlab.String(filePath),
 			Content:  gitlab.String(content),
 		})
@@ -644,6 +739,205 @@ func (s *GitLabService) GetBranchCommits(projectID int, branchName string, limit
 	return commits, nil
 }
 
+// GetBranchHead 获取分支最新提交的哈希
+func (s *GitLabService) GetBranchHead(projectID int, branchName string) (string, error) {
+	branch, _, err := s.client.Branches.GetBranch(projectID, branchName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch: %w", err)
+	}
+	if branch.Commit == nil {
+		return "", fmt.Errorf("branch %s has no commits", branchName)
+	}
+
+	return branch.Commit.ID, nil
+}
+
+// ListRepositoryTree 递归获取指定版本的全部文件
+func (s *GitLabService) ListRepositoryTree(projectID int, ref string) ([]*gitlab.TreeNode, error) {
+	opts := &gitlab.ListTreeOptions{
+		Ref:       gitlab.String(ref),
+		Recursive: gitlab.Bool(true),
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+			Page:    1,
+		},
+	}
+
+	var files []*gitlab.TreeNode
+	for {
+		nodes, resp, err := s.client.Repositories.ListTree(projectID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repository tree: %w", err)
+		}
+		for _, node := range nodes {
+			if node.Type == "blob" {
+				files = append(files, node)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return files, nil
+}
+
+// GetFileSize 获取指定版本中文件的大小（字节）
+func (s *GitLabService) GetFileSize(projectID int, filePath, ref string) (int64, error) {
+	file, _, err := s.client.RepositoryFiles.GetFileMetaData(projectID, filePath, &gitlab.GetFileMetaDataOptions{
+		Ref: gitlab.String(ref),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	return int64(file.Size), nil
+}
+
+// DownloadArchive 下载指定版本的代码归档（tar.gz）
+func (s *GitLabService) DownloadArchive(projectID int, sha string, w io.Writer) error {
+	opts := &gitlab.ArchiveOptions{
+		Format: gitlab.String("tar.gz"),
+		SHA:    gitlab.String(sha),
+	}
+
+	if _, err := s.client.Repositories.StreamArchive(projectID, w, opts); err != nil {
+		return fmt.Errorf("failed to download archive: %w", err)
+	}
+
+	return nil
+}
+
+// CompareCommits 比较两个提交之间的差异
+func (s *GitLabService) CompareCommits(projectID int, from, to string) (*gitlab.Compare, error) {
+	opts := &gitlab.CompareOptions{
+		From:     gitlab.String(from),
+		To:       gitlab.String(to),
+		Straight: gitlab.Bool(true),
+	}
+
+	compare, _, err := s.client.Repositories.Compare(projectID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare commits: %w", err)
+	}
+
+	return compare, nil
+}
+
+// CompareBranch 比较分支相对于基准分支的改动（从两者的合并基点开始计算）
+func (s *GitLabService) CompareBranch(projectID int, baseBranch, branch string) (*gitlab.Compare, error) {
+	opts := &gitlab.CompareOptions{
+		From:     gitlab.String(baseBranch),
+		To:       gitlab.String(branch),
+		Straight: gitlab.Bool(false),
+	}
+
+	compare, _, err := s.client.Repositories.Compare(projectID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare branch: %w", err)
+	}
+
+	return compare, nil
+}
+
+// GetCommitPipeline 获取指定提交最新的CI流水线，没有流水线时返回nil
+func (s *GitLabService) GetCommitPipeline(projectID int, sha string) (*gitlab.PipelineInfo, error) {
+	opts := &gitlab.ListProjectPipelinesOptions{
+		SHA:     gitlab.String(sha),
+		OrderBy: gitlab.String("id"),
+		Sort:    gitlab.String("desc"),
+		ListOptions: gitlab.ListOptions{
+			PerPage: 1,
+		},
+	}
+
+	pipelines, _, err := s.client.Pipelines.ListProjectPipelines(projectID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pipelines: %w", err)
+	}
+	if len(pipelines) == 0 {
+		return nil, nil
+	}
+
+	return pipelines[0], nil
+}
+
+// GetPipeline 获取CI流水线
+func (s *GitLabService) GetPipeline(projectID, pipelineID int) (*gitlab.Pipeline, error) {
+	pipeline, _, err := s.client.Pipelines.GetPipeline(projectID, pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipeline: %w", err)
+	}
+
+	return pipeline, nil
+}
+
+// ListPipelineJobs 获取CI流水线的全部作业（不含被重试的旧作业）
+func (s *GitLabService) ListPipelineJobs(projectID, pipelineID int) ([]*gitlab.Job, error) {
+	opts := &gitlab.ListJobsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+			Page:    1,
+		},
+	}
+
+	var jobs []*gitlab.Job
+	for {
+		page, resp, err := s.client.Jobs.ListPipelineJobs(projectID, pipelineID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pipeline jobs: %w", err)
+		}
+		jobs = append(jobs, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return jobs, nil
+}
+
+// GetPipelineTestReport 获取CI流水线的JUnit测试报告
+func (s *GitLabService) GetPipelineTestReport(projectID, pipelineID int) (*gitlab.PipelineTestReport, error) {
+	report, _, err := s.client.Pipelines.GetPipelineTestReport(projectID, pipelineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipeline test report: %w", err)
+	}
+
+	return report, nil
+}
+
+// ListUserMergeRequests 获取用户在项目中创建的全部合并请求
+func (s *GitLabService) ListUserMergeRequests(projectID int, authorID int) ([]*gitlab.MergeRequest, error) {
+	opts := &gitlab.ListProjectMergeRequestsOptions{
+		AuthorID: gitlab.Int(authorID),
+		OrderBy:  gitlab.String("updated_at"),
+		ListOptions: gitlab.ListOptions{
+			PerPage: 100,
+			Page:    1,
+		},
+	}
+
+	var mergeRequests []*gitlab.MergeRequest
+	for {
+		mrs, resp, err := s.client.MergeRequests.ListProjectMergeRequests(projectID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list merge requests: %w", err)
+		}
+		mergeRequests = append(mergeRequests, mrs...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return mergeRequests, nil
+}
+
 // GetDiscussions 获取项目讨论（通过Issues实现）
 func (s *GitLabService) GetDiscussions(projectID int) ([]*gitlab.Issue, error) {
 	// 使用Issues来实现讨论功能
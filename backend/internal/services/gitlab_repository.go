@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// RepositoryPage GitLab分页结果
+type RepositoryPage struct {
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	Total      int `json:"total"`       // GitLab对数量过多的结果不返回总数，此时为0
+	TotalPages int `json:"total_pages"` // 同上
+	NextPage   int `json:"next_page"`   // 没有下一页时为0
+}
+
+// ListCommitsRequest 提交记录查询条件
+type ListCommitsRequest struct {
+	Ref      string // 分支、标签或提交，为空时为默认分支
+	Path     string // 只返回修改了该路径的提交
+	Since    *time.Time
+	Until    *time.Time
+	Page     int
+	PageSize int
+}
+
+// SaveFileRequest 创建或更新仓库文件请求
+type SaveFileRequest struct {
+	Branch        string
+	Content       string
+	Encoding      string // text 或 base64
+	CommitMessage string
+	LastCommitID  string // 不为空时，文件在该提交之后被修改过则更新失败
+	AuthorName    string
+	AuthorEmail   string
+}
+
+// ListCommits 分页获取提交记录
+func (s *GitLabService) ListCommits(projectID int, req *ListCommitsRequest) ([]*gitlab.Commit, *RepositoryPage, error) {
+	opts := &gitlab.ListCommitsOptions{
+		Since:       req.Since,
+		Until:       req.Until,
+		ListOptions: gitlab.ListOptions{Page: req.Page, PerPage: req.PageSize},
+	}
+	if req.Ref != "" {
+		opts.RefName = gitlab.String(req.Ref)
+	}
+	if req.Path != "" {
+		opts.Path = gitlab.String(req.Path)
+	}
+
+	commits, resp, err := s.client.Commits.ListCommits(projectID, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+
+	return commits, newRepositoryPage(resp, req.Page, req.PageSize), nil
+}
+
+// maxBranchCommitPages ListBranchCommits 最多读取的页数（每页100条）
+const maxBranchCommitPages = 50
+
+// ListBranchCommits 获取分支上指定时间之后的全部提交（含增删行数），按时间倒序
+func (s *GitLabService) ListBranchCommits(projectID int, branch string, since *time.Time) ([]*gitlab.Commit, error) {
+	opts := &gitlab.ListCommitsOptions{
+		RefName:     gitlab.String(branch),
+		Since:       since,
+		WithStats:   gitlab.Bool(true),
+		ListOptions: gitlab.ListOptions{Page: 1, PerPage: 100},
+	}
+
+	var commits []*gitlab.Commit
+	for page := 0; page < maxBranchCommitPages; page++ {
+		batch, resp, err := s.client.Commits.ListCommits(projectID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits: %w", err)
+		}
+		commits = append(commits, batch...)
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return commits, nil
+}
+
+// ListBranches 分页获取分支，search 不为空时只返回名称包含该字符串的分支
+func (s *GitLabService) ListBranches(projectID int, search string, page, pageSize int) ([]*gitlab.Branch, *RepositoryPage, error) {
+	opts := &gitlab.ListBranchesOptions{
+		ListOptions: gitlab.ListOptions{Page: page, PerPage: pageSize},
+	}
+	if search != "" {
+		opts.Search = gitlab.String(search)
+	}
+
+	branches, resp, err := s.client.Branches.ListBranches(projectID, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	return branches, newRepositoryPage(resp, page, pageSize), nil
+}
+
+// CreateBranch 从指定分支、标签或提交创建分支
+func (s *GitLabService) CreateBranch(projectID int, branch, ref string) (*gitlab.Branch, error) {
+	created, _, err := s.client.Branches.CreateBranch(projectID, &gitlab.CreateBranchOptions{
+		Branch: gitlab.String(branch),
+		Ref:    gitlab.String(ref),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create branch: %w", err)
+	}
+
+	return created, nil
+}
+
+// ListTree 分页获取目录下的文件和子目录，recursive 为true时包含所有下级
+func (s *GitLabService) ListTree(projectID int, ref, path string, recursive bool, page, pageSize int) ([]*gitlab.TreeNode, *RepositoryPage, error) {
+	opts := &gitlab.ListTreeOptions{
+		Recursive:   gitlab.Bool(recursive),
+		ListOptions: gitlab.ListOptions{Page: page, PerPage: pageSize},
+	}
+	if ref != "" {
+		opts.Ref = gitlab.String(ref)
+	}
+	if path != "" {
+		opts.Path = gitlab.String(path)
+	}
+
+	nodes, resp, err := s.client.Repositories.ListTree(projectID, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list repository tree: %w", err)
+	}
+
+	return nodes, newRepositoryPage(resp, page, pageSize), nil
+}
+
+// GetFile 获取文件及其元数据，内容为base64编码
+func (s *GitLabService) GetFile(projectID int, filePath, ref string) (*gitlab.File, error) {
+	file, _, err := s.client.RepositoryFiles.GetFile(projectID, filePath, &gitlab.GetFileOptions{
+		Ref: gitlab.String(ref),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	return file, nil
+}
+
+// GetRawFile 获取文件的原始内容
+func (s *GitLabService) GetRawFile(projectID int, filePath, ref string) ([]byte, error) {
+	content, _, err := s.client.RepositoryFiles.GetRawFile(projectID, filePath, &gitlab.GetRawFileOptions{
+		Ref: gitlab.String(ref),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw file: %w", err)
+	}
+
+	return content, nil
+}
+
+// SaveFile 在分支上创建或更新文件，返回文件信息以及是否为新建的文件
+func (s *GitLabService) SaveFile(projectID int, filePath string, req *SaveFileRequest) (*gitlab.FileInfo, bool, error) {
+	_, resp, err := s.client.RepositoryFiles.GetFileMetaData(projectID, filePath, &gitlab.GetFileMetaDataOptions{
+		Ref: gitlab.String(req.Branch),
+	})
+	exists := err == nil
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return nil, false, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	var author, email *string
+	if req.AuthorName != "" {
+		author = gitlab.String(req.AuthorName)
+	}
+	if req.AuthorEmail != "" {
+		email = gitlab.String(req.AuthorEmail)
+	}
+
+	if !exists {
+		info, _, err := s.client.RepositoryFiles.CreateFile(projectID, filePath, &gitlab.CreateFileOptions{
+			Branch:        gitlab.String(req.Branch),
+			Content:       gitlab.String(req.Content),
+			Encoding:      gitlab.String(req.Encoding),
+			CommitMessage: gitlab.String(req.CommitMessage),
+			AuthorName:    author,
+			AuthorEmail:   email,
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create file: %w", err)
+		}
+		return info, true, nil
+	}
+
+	opts := &gitlab.UpdateFileOptions{
+		Branch:        gitlab.String(req.Branch),
+		Content:       gitlab.String(req.Content),
+		Encoding:      gitlab.String(req.Encoding),
+		CommitMessage: gitlab.String(req.CommitMessage),
+		AuthorName:    author,
+		AuthorEmail:   email,
+	}
+	if req.LastCommitID != "" {
+		opts.LastCommitID = gitlab.String(req.LastCommitID)
+	}
+	info, _, err := s.client.RepositoryFiles.UpdateFile(projectID, filePath, opts)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update file: %w", err)
+	}
+	return info, false, nil
+}
+
+// newRepositoryPage 从GitLab响应头获取分页信息
+func newRepositoryPage(resp *gitlab.Response, page, pageSize int) *RepositoryPage {
+	result := &RepositoryPage{Page: page, PageSize: pageSize}
+	if resp == nil {
+		return result
+	}
+	if resp.CurrentPage > 0 {
+		result.Page = resp.CurrentPage
+	}
+	if resp.ItemsPerPage > 0 {
+		result.PageSize = resp.ItemsPerPage
+	}
+	result.Total = resp.TotalItems
+	result.TotalPages = resp.TotalPages
+	result.NextPage = resp.NextPage
+	return result
+}
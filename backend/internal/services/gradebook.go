@@ -0,0 +1,484 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// GradebookService 成绩册服务
+type GradebookService struct {
+	db                *gorm.DB
+	permissionService *PermissionService
+}
+
+// NewGradebookService 创建成绩册服务
+func NewGradebookService(db *gorm.DB, permissionService *PermissionService) *GradebookService {
+	return &GradebookService{
+		db:                db,
+		permissionService: permissionService,
+	}
+}
+
+// 成绩册中的学生标识类型
+const (
+	GradebookIdentifierUsername = "username"
+	GradebookIdentifierEmail    = "email"
+	GradebookIdentifierGitLabID = "gitlab_id"
+)
+
+// gradebookIdentifierHeaders 学生标识列的表头，与常见LMS导入模板一致
+var gradebookIdentifierHeaders = map[string]string{
+	GradebookIdentifierUsername: "Username",
+	GradebookIdentifierEmail:    "Email",
+	GradebookIdentifierGitLabID: "GitLab ID",
+}
+
+// gradebookColumnPattern 作业列表头格式："作业标题 (作业ID)"，反馈列追加 " Feedback"
+var gradebookColumnPattern = regexp.MustCompile(`\((\d+)\)( Feedback)?$`)
+
+// Gradebook 课题成绩册
+type Gradebook struct {
+	ProjectID   uint                  `json:"project_id"`
+	ProjectName string                `json:"project_name"`
+	Assignments []GradebookAssignment `json:"assignments"`
+	Rows        []GradebookRow        `json:"rows"`
+}
+
+// GradebookAssignment 成绩册中的作业列
+type GradebookAssignment struct {
+	ID      uint      `json:"id"`
+	Title   string    `json:"title"`
+	DueDate time.Time `json:"due_date"`
+}
+
+// GradebookRow 成绩册中的学生行
+type GradebookRow struct {
+	StudentID uint            `json:"student_id"`
+	Name      string          `json:"name"`
+	Username  string          `json:"username"`
+	Email     string          `json:"email"`
+	GitLabID  int             `json:"gitlab_id"`
+	Grades    []GradebookCell `json:"grades"` // 与 Assignments 顺序一致
+	Total     float64         `json:"total"`
+	MaxTotal  float64         `json:"max_total"`
+}
+
+// GradebookCell 学生某个作业的成绩
+type GradebookCell struct {
+	AssignmentID uint     `json:"assignment_id"`
+	SubmissionID uint     `json:"submission_id,omitempty"` // 0表示未提交
+	Status       string   `json:"status,omitempty"`
+	Score        *float64 `json:"score"` // 未评分时为空
+	MaxScore     float64  `json:"max_score"`
+	Feedback     string   `json:"feedback,omitempty"`
+}
+
+// GradebookImportResult 成绩导入结果
+type GradebookImportResult struct {
+	DryRun  bool                   `json:"dry_run"`
+	Applied bool                   `json:"applied"`
+	Changes []GradebookChange      `json:"changes"`
+	Errors  []GradebookImportError `json:"errors"`
+}
+
+// GradebookChange 成绩导入的差异
+type GradebookChange struct {
+	Row             int      `json:"row"`
+	StudentID       uint     `json:"student_id"`
+	StudentName     string   `json:"student_name"`
+	AssignmentID    uint     `json:"assignment_id"`
+	AssignmentTitle string   `json:"assignment_title"`
+	SubmissionID    uint     `json:"submission_id"`
+	OldScore        *float64 `json:"old_score"`
+	NewScore        *float64 `json:"new_score"`
+	OldFeedback     string   `json:"old_feedback"`
+	NewFeedback     string   `json:"new_feedback"`
+}
+
+// GradebookImportError 成绩导入中的错误
+type GradebookImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// gradebookColumn CSV中的作业列
+type gradebookColumn struct {
+	index        int
+	header       string
+	assignmentID uint
+	feedback     bool
+}
+
+// GetGradebook 获取课题成绩册（需要课题管理权限）
+func (s *GradebookService) GetGradebook(userID, projectID uint) (*Gradebook, error) {
+	if !s.permissionService.CanAccessProject(userID, projectID, "manage") {
+		return nil, fmt.Errorf("permission denied to access gradebook")
+	}
+
+	var project models.Project
+	if err := s.db.First(&project, projectID).Error; err != nil {
+		return nil, fmt.Errorf("project not found: %w", err)
+	}
+
+	var assignments []models.Assignment
+	if err := s.db.Where("project_id = ?", projectID).Order("due_date, id").Find(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get assignments: %w", err)
+	}
+
+	var students []models.User
+	if err := s.db.Joins("JOIN project_members ON project_members.user_id = users.id").
+		Where("project_members.project_id = ? AND project_members.role = 'student' AND project_members.is_active = true", projectID).
+		Order("users.username").Find(&students).Error; err != nil {
+		return nil, fmt.Errorf("failed to get students: %w", err)
+	}
+
+	var submissions []models.AssignmentSubmission
+	if err := s.db.Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignments.project_id = ? AND assignment_submissions.is_selected = true", projectID).
+		Order("assignment_submissions.submitted_at").
+		Find(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get submissions: %w", err)
+	}
+
+	// 同一作业有多次提交时以老师选中的提交为准
+	selected := make(map[[2]uint]models.AssignmentSubmission, len(submissions))
+	for _, submission := range submissions {
+		selected[[2]uint{submission.AssignmentID, submission.StudentID}] = submission
+	}
+
+	gradebook := &Gradebook{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		Assignments: make([]GradebookAssignment, 0, len(assignments)),
+		Rows:        make([]GradebookRow, 0, len(students)),
+	}
+	for _, assignment := range assignments {
+		gradebook.Assignments = append(gradebook.Assignments, GradebookAssignment{
+			ID:      assignment.ID,
+			Title:   assignment.Title,
+			DueDate: assignment.DueDate,
+		})
+	}
+
+	for _, student := range students {
+		row := GradebookRow{
+			StudentID: student.ID,
+			Name:      student.Name,
+			Username:  student.Username,
+			Email:     student.Email,
+			GitLabID:  student.GitLabID,
+			Grades:    make([]GradebookCell, 0, len(assignments)),
+		}
+
+		for _, assignment := range assignments {
+			cell := GradebookCell{AssignmentID: assignment.ID, MaxScore: 100}
+			if submission, ok := selected[[2]uint{assignment.ID, student.ID}]; ok {
+				cell.SubmissionID = submission.ID
+				cell.Status = submission.Status
+				cell.MaxScore = submission.MaxScore
+				cell.Feedback = submission.Feedback
+				if submission.GradedAt != nil {
+					score := submission.Score
+					cell.Score = &score
+					row.Total += score
+				}
+			}
+			row.MaxTotal += cell.MaxScore
+			row.Grades = append(row.Grades, cell)
+		}
+
+		gradebook.Rows = append(gradebook.Rows, row)
+	}
+
+	return gradebook, nil
+}
+
+// ExportGradebookCSV 导出成绩册CSV
+// 表头为：学生标识、姓名、各作业 "标题 (ID)"，可选附带 "标题 (ID) Feedback" 反馈列，最后为总分
+func (s *GradebookService) ExportGradebookCSV(userID, projectID uint, identifier string, includeFeedback bool) ([]byte, string, error) {
+	identifierHeader, ok := gradebookIdentifierHeaders[identifier]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported student identifier: %s", identifier)
+	}
+
+	gradebook, err := s.GetGradebook(userID, projectID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	header := []string{identifierHeader, "Name"}
+	for _, assignment := range gradebook.Assignments {
+		column := fmt.Sprintf("%s (%d)", assignment.Title, assignment.ID)
+		header = append(header, column)
+		if includeFeedback {
+			header = append(header, column+" Feedback")
+		}
+	}
+	header = append(header, "Total")
+
+	var buf bytes.Buffer
+	// 写入UTF-8 BOM，避免Excel打开中文乱码
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(&buf)
+	writer.Write(header)
+
+	for _, row := range gradebook.Rows {
+		record := []string{gradebookIdentifier(row, identifier), row.Name}
+		for _, cell := range row.Grades {
+			score := ""
+			if cell.Score != nil {
+				score = formatReportFloat(*cell.Score)
+			}
+			record = append(record, score)
+			if includeFeedback {
+				record = append(record, cell.Feedback)
+			}
+		}
+		record = append(record, formatReportFloat(row.Total))
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, "", fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	fileName := fmt.Sprintf("gradebook_project_%d_%s.csv", projectID, time.Now().Format("20060102"))
+	return buf.Bytes(), fileName, nil
+}
+
+// ImportGradebookCSV 从CSV批量导入成绩和反馈
+// 只会更新已有的作业提交；dryRun 为 true 时只返回差异预览，存在错误时不会写入任何数据
+func (s *GradebookService) ImportGradebookCSV(userID, projectID uint, identifier string, reader io.Reader, dryRun bool) (*GradebookImportResult, error) {
+	identifierHeader, ok := gradebookIdentifierHeaders[identifier]
+	if !ok {
+		return nil, fmt.Errorf("unsupported student identifier: %s", identifier)
+	}
+
+	gradebook, err := s.GetGradebook(userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse csv: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("csv file is empty")
+	}
+
+	result := &GradebookImportResult{
+		DryRun:  dryRun,
+		Changes: []GradebookChange{},
+		Errors:  []GradebookImportError{},
+	}
+
+	// 解析表头
+	header := records[0]
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\xEF\xBB\xBF")
+	}
+	identifierIndex := -1
+	assignmentTitles := make(map[uint]string, len(gradebook.Assignments))
+	for _, assignment := range gradebook.Assignments {
+		assignmentTitles[assignment.ID] = assignment.Title
+	}
+
+	var columns []gradebookColumn
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, identifierHeader) {
+			identifierIndex = i
+			continue
+		}
+		match := gradebookColumnPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		id, _ := strconv.ParseUint(match[1], 10, 32)
+		if _, ok := assignmentTitles[uint(id)]; !ok {
+			result.Errors = append(result.Errors, GradebookImportError{Row: 1, Column: name, Message: "作业不属于该课题"})
+			continue
+		}
+		columns = append(columns, gradebookColumn{index: i, header: name, assignmentID: uint(id), feedback: match[2] != ""})
+	}
+	if identifierIndex < 0 {
+		return nil, fmt.Errorf("missing student identifier column: %s", identifierHeader)
+	}
+
+	// 学生及其成绩
+	rowsByIdentifier := make(map[string]*GradebookRow, len(gradebook.Rows))
+	for i := range gradebook.Rows {
+		row := &gradebook.Rows[i]
+		rowsByIdentifier[strings.ToLower(gradebookIdentifier(*row, identifier))] = row
+	}
+	assignmentIndex := make(map[uint]int, len(gradebook.Assignments))
+	for i, assignment := range gradebook.Assignments {
+		assignmentIndex[assignment.ID] = i
+	}
+
+	// 逐行比较差异，同一提交的分数和反馈合并为一条变更
+	for r, record := range records[1:] {
+		rowNumber := r + 2
+		if identifierIndex >= len(record) || strings.TrimSpace(record[identifierIndex]) == "" {
+			continue
+		}
+
+		studentKey := strings.TrimSpace(record[identifierIndex])
+		row, ok := rowsByIdentifier[strings.ToLower(studentKey)]
+		if !ok {
+			result.Errors = append(result.Errors, GradebookImportError{Row: rowNumber, Column: identifierHeader, Message: fmt.Sprintf("学生 %s 不在课题中", studentKey)})
+			continue
+		}
+
+		changes := make(map[uint]*GradebookChange)
+		var order []uint
+		for _, column := range columns {
+			if column.index >= len(record) {
+				continue
+			}
+			value := strings.TrimSpace(record[column.index])
+			cell := row.Grades[assignmentIndex[column.assignmentID]]
+
+			if !column.feedback && value == "" {
+				continue // 空分数不修改
+			}
+			if column.feedback && value == cell.Feedback {
+				continue
+			}
+
+			var newScore *float64
+			if !column.feedback {
+				score, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					result.Errors = append(result.Errors, GradebookImportError{Row: rowNumber, Column: column.header, Message: fmt.Sprintf("无效的分数：%s", value)})
+					continue
+				}
+				if score < 0 || score > cell.MaxScore {
+					result.Errors = append(result.Errors, GradebookImportError{Row: rowNumber, Column: column.header, Message: fmt.Sprintf("分数超出范围 0-%s", formatReportFloat(cell.MaxScore))})
+					continue
+				}
+				if cell.Score != nil && *cell.Score == score {
+					continue
+				}
+				newScore = &score
+			}
+
+			if cell.SubmissionID == 0 {
+				result.Errors = append(result.Errors, GradebookImportError{Row: rowNumber, Column: column.header, Message: "学生未提交该作业"})
+				continue
+			}
+
+			change, ok := changes[column.assignmentID]
+			if !ok {
+				change = &GradebookChange{
+					Row:             rowNumber,
+					StudentID:       row.StudentID,
+					StudentName:     row.Name,
+					AssignmentID:    column.assignmentID,
+					AssignmentTitle: assignmentTitles[column.assignmentID],
+					SubmissionID:    cell.SubmissionID,
+					OldScore:        cell.Score,
+					NewScore:        cell.Score,
+					OldFeedback:     cell.Feedback,
+					NewFeedback:     cell.Feedback,
+				}
+				changes[column.assignmentID] = change
+				order = append(order, column.assignmentID)
+			}
+			if column.feedback {
+				change.NewFeedback = value
+			} else {
+				change.NewScore = newScore
+			}
+		}
+
+		for _, assignmentID := range order {
+			result.Changes = append(result.Changes, *changes[assignmentID])
+		}
+	}
+
+	if dryRun || len(result.Errors) > 0 || len(result.Changes) == 0 {
+		return result, nil
+	}
+
+	if err := s.applyGradebookChanges(result.Changes); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+
+	return result, nil
+}
+
+// applyGradebookChanges 在事务中写入成绩变更，导入的分数作为原始得分，迟交的提交按作业迟交策略扣分
+func (s *GradebookService) applyGradebookChanges(changes []GradebookChange) error {
+	now := time.Now()
+	assignments := make(map[uint]*models.Assignment)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			updates := map[string]interface{}{
+				"feedback": change.NewFeedback,
+			}
+			if change.NewScore != nil && (change.OldScore == nil || *change.NewScore != *change.OldScore) {
+				var submission models.AssignmentSubmission
+				if err := tx.Select("id", "assignment_id", "is_late", "late_minutes").
+					First(&submission, change.SubmissionID).Error; err != nil {
+					return fmt.Errorf("failed to get submission %d: %w", change.SubmissionID, err)
+				}
+
+				penalty := 0.0
+				if submission.IsLate {
+					assignment, ok := assignments[submission.AssignmentID]
+					if !ok {
+						assignment = &models.Assignment{}
+						if err := tx.First(assignment, submission.AssignmentID).Error; err != nil {
+							return fmt.Errorf("failed to get assignment %d: %w", submission.AssignmentID, err)
+						}
+						assignments[submission.AssignmentID] = assignment
+					}
+					penalty = calculateLatePenalty(assignment, submission.LateMinutes)
+				}
+
+				updates["score"] = applyLatePenalty(*change.NewScore, penalty)
+				updates["raw_score"] = *change.NewScore
+				updates["late_penalty_percent"] = penalty
+				updates["graded_at"] = now
+				// 导入分数视为完成评分，已退回的提交保持原状态
+				updates["status"] = gorm.Expr("CASE WHEN status = 'submitted' THEN 'graded' ELSE status END")
+			}
+
+			if err := tx.Model(&models.AssignmentSubmission{}).Where("id = ?", change.SubmissionID).
+				Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update submission %d: %w", change.SubmissionID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// gradebookIdentifier 获取学生在成绩册中的标识
+func gradebookIdentifier(row GradebookRow, identifier string) string {
+	switch identifier {
+	case GradebookIdentifierEmail:
+		return row.Email
+	case GradebookIdentifierGitLabID:
+		return strconv.Itoa(row.GitLabID)
+	default:
+		return row.Username
+	}
+}
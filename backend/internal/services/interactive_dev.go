@@ -542,7 +542,7 @@ func (s *InteractiveDevService) releaseEditLock(projectID uint, filePath, branch
 // updateMemberStats 更新成员统计
 func (s *InteractiveDevService) updateMemberStats(projectID, userID uint) {
 	var member models.ProjectMember
-	err := s.db.Where("project_id = ? AND student_id = ?", projectID, userID).First(&member).Error
+	err := s.db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error
 
 	if err == nil {
 		now := time.Now()
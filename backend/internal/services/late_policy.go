@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 迟交扣分周期
+const (
+	LatePenaltyIntervalHour = "hour"
+	LatePenaltyIntervalDay  = "day"
+)
+
+// SubmissionDeadline 学生的有效截止时间
+type SubmissionDeadline struct {
+	DueDate    time.Time `json:"due_date"`    // 截止时间（有延期时为延期后的时间）
+	GraceUntil time.Time `json:"grace_until"` // 宽限期结束时间，此前提交不算迟交
+	Cutoff     time.Time `json:"cutoff"`      // 最晚可提交时间
+	Extended   bool      `json:"extended"`    // 是否有老师批准的延期
+}
+
+// GrantExtensionRequest 批准延期请求
+type GrantExtensionRequest struct {
+	StudentID uint      `json:"student_id" binding:"required"`
+	DueDate   time.Time `json:"due_date" binding:"required"`
+	Reason    string    `json:"reason"`
+}
+
+// validateLatePolicy 校验迟交策略配置
+func validateLatePolicy(dueDate time.Time, lateUntil *time.Time, penaltyPercent float64, interval string) error {
+	if interval != "" && interval != LatePenaltyIntervalHour && interval != LatePenaltyIntervalDay {
+		return fmt.Errorf("invalid late penalty interval: %s", interval)
+	}
+	if penaltyPercent < 0 || penaltyPercent > 100 {
+		return fmt.Errorf("late penalty percent must be between 0 and 100")
+	}
+	if lateUntil != nil && lateUntil.Before(dueDate) {
+		return fmt.Errorf("late_until must not be earlier than due date")
+	}
+	return nil
+}
+
+// buildSubmissionDeadline 根据作业策略和延期计算有效截止时间
+func buildSubmissionDeadline(assignment *models.Assignment, extension *models.AssignmentExtension) SubmissionDeadline {
+	grace := time.Duration(assignment.GracePeriodMinutes) * time.Minute
+
+	deadline := SubmissionDeadline{DueDate: assignment.DueDate}
+	if extension != nil {
+		deadline.DueDate = extension.DueDate
+		deadline.Extended = true
+	}
+	deadline.GraceUntil = deadline.DueDate.Add(grace)
+
+	// 未设置最晚提交时间时，宽限期结束即停止接收提交
+	deadline.Cutoff = deadline.GraceUntil
+	if assignment.LateUntil != nil && assignment.LateUntil.After(deadline.Cutoff) {
+		deadline.Cutoff = *assignment.LateUntil
+	}
+
+	return deadline
+}
+
+// lateness 计算提交是否迟交及迟交分钟数（从截止时间起算）
+func (d SubmissionDeadline) lateness(submittedAt time.Time) (bool, int) {
+	if !submittedAt.After(d.GraceUntil) {
+		return false, 0
+	}
+	return true, int(math.Ceil(submittedAt.Sub(d.DueDate).Minutes()))
+}
+
+// calculateLatePenalty 按扣分周期计算迟交扣分百分比，不足一个周期按一个周期计算
+func calculateLatePenalty(assignment *models.Assignment, lateMinutes int) float64 {
+	if lateMinutes <= 0 || assignment.LatePenaltyPercent <= 0 {
+		return 0
+	}
+
+	intervalMinutes := 24 * 60
+	if assignment.LatePenaltyInterval == LatePenaltyIntervalHour {
+		intervalMinutes = 60
+	}
+
+	periods := (lateMinutes + intervalMinutes - 1) / intervalMinutes
+	return math.Min(100, float64(periods)*assignment.LatePenaltyPercent)
+}
+
+// applyLatePenalty 计算扣分后的得分，保留两位小数
+func applyLatePenalty(score, penaltyPercent float64) float64 {
+	return math.Round(score*(100-penaltyPercent)) / 100
+}
+
+// GetSubmissionDeadline 获取学生在作业上的有效截止时间
+func (s *AssignmentService) GetSubmissionDeadline(assignment *models.Assignment, studentID uint) (SubmissionDeadline, error) {
+	var extension models.AssignmentExtension
+	err := s.db.Where("assignment_id = ? AND student_id = ?", assignment.ID, studentID).First(&extension).Error
+	if err == gorm.ErrRecordNotFound {
+		return buildSubmissionDeadline(assignment, nil), nil
+	}
+	if err != nil {
+		return SubmissionDeadline{}, fmt.Errorf("failed to get extension: %w", err)
+	}
+	return buildSubmissionDeadline(assignment, &extension), nil
+}
+
+// GetExtensions 获取作业的延期列表（老师权限）
+func (s *AssignmentService) GetExtensions(userID, assignmentID uint) ([]models.AssignmentExtension, error) {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return nil, fmt.Errorf("permission denied to manage extensions of this assignment")
+	}
+
+	var extensions []models.AssignmentExtension
+	if err := s.db.Preload("Student").Where("assignment_id = ?", assignmentID).
+		Order("due_date ASC").Find(&extensions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get extensions: %w", err)
+	}
+
+	return extensions, nil
+}
+
+// GrantExtension 为学生批准或更新延期（老师权限）
+func (s *AssignmentService) GrantExtension(userID, assignmentID uint, req *GrantExtensionRequest) (*models.AssignmentExtension, error) {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return nil, fmt.Errorf("permission denied to manage extensions of this assignment")
+	}
+
+	var assignment models.Assignment
+	if err := s.db.First(&assignment, assignmentID).Error; err != nil {
+		return nil, fmt.Errorf("assignment not found: %w", err)
+	}
+
+	if !req.DueDate.After(assignment.DueDate) {
+		return nil, fmt.Errorf("extension due date must be later than assignment due date")
+	}
+
+	var count int64
+	if err := s.db.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND user_id = ? AND is_active = true", assignment.ProjectID, req.StudentID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check project member: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("student not in project or inactive")
+	}
+
+	extension := &models.AssignmentExtension{
+		AssignmentID: assignmentID,
+		StudentID:    req.StudentID,
+		DueDate:      req.DueDate,
+		Reason:       req.Reason,
+		GrantedBy:    userID,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "assignment_id"}, {Name: "student_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"due_date", "reason", "granted_by", "updated_at"}),
+	}).Create(extension).Error; err != nil {
+		return nil, fmt.Errorf("failed to grant extension: %w", err)
+	}
+
+	if err := s.db.Preload("Student").
+		Where("assignment_id = ? AND student_id = ?", assignmentID, req.StudentID).
+		First(extension).Error; err != nil {
+		return nil, fmt.Errorf("failed to load extension: %w", err)
+	}
+
+	return extension, nil
+}
+
+// RevokeExtension 撤销学生的延期（老师权限）
+func (s *AssignmentService) RevokeExtension(userID, assignmentID, studentID uint) error {
+	if !s.permissionService.CanAccessAssignment(userID, assignmentID, "manage") {
+		return fmt.Errorf("permission denied to manage extensions of this assignment")
+	}
+
+	result := s.db.Where("assignment_id = ? AND student_id = ?", assignmentID, studentID).
+		Delete(&models.AssignmentExtension{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke extension: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("extension not found")
+	}
+
+	return nil
+}
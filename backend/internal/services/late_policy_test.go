@@ -0,0 +1,193 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gitlabex/internal/models"
+)
+
+func TestCalculateLatePenalty(t *testing.T) {
+	tests := []struct {
+		name        string
+		percent     float64
+		interval    string
+		lateMinutes int
+		want        float64
+	}{
+		{name: "not late", percent: 10, interval: LatePenaltyIntervalDay, lateMinutes: 0, want: 0},
+		{name: "no penalty configured", percent: 0, interval: LatePenaltyIntervalDay, lateMinutes: 600, want: 0},
+		{name: "one minute late counts as a full day", percent: 10, interval: LatePenaltyIntervalDay, lateMinutes: 1, want: 10},
+		{name: "exactly one day", percent: 10, interval: LatePenaltyIntervalDay, lateMinutes: 24 * 60, want: 10},
+		{name: "just over one day", percent: 10, interval: LatePenaltyIntervalDay, lateMinutes: 24*60 + 1, want: 20},
+		{name: "default interval is day", percent: 10, interval: "", lateMinutes: 25 * 60, want: 20},
+		{name: "one minute late counts as a full hour", percent: 5, interval: LatePenaltyIntervalHour, lateMinutes: 1, want: 5},
+		{name: "per hour", percent: 5, interval: LatePenaltyIntervalHour, lateMinutes: 3*60 + 30, want: 20},
+		{name: "capped at 100", percent: 30, interval: LatePenaltyIntervalDay, lateMinutes: 4 * 24 * 60, want: 100},
+		{name: "fractional percent", percent: 2.5, interval: LatePenaltyIntervalHour, lateMinutes: 90, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment := &models.Assignment{LatePenaltyPercent: tt.percent, LatePenaltyInterval: tt.interval}
+			if got := calculateLatePenalty(assignment, tt.lateMinutes); got != tt.want {
+				t.Errorf("calculateLatePenalty(%v%%/%q, %d) = %v, want %v", tt.percent, tt.interval, tt.lateMinutes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyLatePenalty(t *testing.T) {
+	tests := []struct {
+		score   float64
+		penalty float64
+		want    float64
+	}{
+		{score: 90, penalty: 0, want: 90},
+		{score: 90, penalty: 10, want: 81},
+		{score: 87.5, penalty: 15, want: 74.38},
+		{score: 100, penalty: 100, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := applyLatePenalty(tt.score, tt.penalty); got != tt.want {
+			t.Errorf("applyLatePenalty(%v, %v) = %v, want %v", tt.score, tt.penalty, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSubmissionDeadline(t *testing.T) {
+	due := time.Date(2025, 10, 1, 23, 59, 0, 0, time.UTC)
+	lateUntil := due.Add(72 * time.Hour)
+	extended := due.Add(48 * time.Hour)
+	shortLateUntil := due.Add(10 * time.Minute)
+
+	tests := []struct {
+		name       string
+		assignment models.Assignment
+		extension  *models.AssignmentExtension
+		want       SubmissionDeadline
+	}{
+		{
+			name:       "no late policy",
+			assignment: models.Assignment{DueDate: due},
+			want:       SubmissionDeadline{DueDate: due, GraceUntil: due, Cutoff: due},
+		},
+		{
+			name:       "grace period without late submissions",
+			assignment: models.Assignment{DueDate: due, GracePeriodMinutes: 15},
+			want:       SubmissionDeadline{DueDate: due, GraceUntil: due.Add(15 * time.Minute), Cutoff: due.Add(15 * time.Minute)},
+		},
+		{
+			name:       "late until after grace period",
+			assignment: models.Assignment{DueDate: due, GracePeriodMinutes: 15, LateUntil: &lateUntil},
+			want:       SubmissionDeadline{DueDate: due, GraceUntil: due.Add(15 * time.Minute), Cutoff: lateUntil},
+		},
+		{
+			name:       "late until within grace period",
+			assignment: models.Assignment{DueDate: due, GracePeriodMinutes: 15, LateUntil: &shortLateUntil},
+			want:       SubmissionDeadline{DueDate: due, GraceUntil: due.Add(15 * time.Minute), Cutoff: due.Add(15 * time.Minute)},
+		},
+		{
+			name:       "extension moves due date and grace period",
+			assignment: models.Assignment{DueDate: due, GracePeriodMinutes: 15, LateUntil: &lateUntil},
+			extension:  &models.AssignmentExtension{DueDate: extended},
+			want:       SubmissionDeadline{DueDate: extended, GraceUntil: extended.Add(15 * time.Minute), Cutoff: lateUntil, Extended: true},
+		},
+		{
+			name:       "extension past late until",
+			assignment: models.Assignment{DueDate: due, LateUntil: &lateUntil},
+			extension:  &models.AssignmentExtension{DueDate: lateUntil.Add(time.Hour)},
+			want:       SubmissionDeadline{DueDate: lateUntil.Add(time.Hour), GraceUntil: lateUntil.Add(time.Hour), Cutoff: lateUntil.Add(time.Hour), Extended: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSubmissionDeadline(&tt.assignment, tt.extension); got != tt.want {
+				t.Errorf("buildSubmissionDeadline() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmissionLatePenalty(t *testing.T) {
+	due := time.Date(2025, 10, 1, 23, 59, 0, 0, time.UTC)
+	lateUntil := due.Add(7 * 24 * time.Hour)
+	assignment := &models.Assignment{
+		DueDate:             due,
+		GracePeriodMinutes:  10,
+		LateUntil:           &lateUntil,
+		LatePenaltyPercent:  10,
+		LatePenaltyInterval: LatePenaltyIntervalDay,
+	}
+	extension := &models.AssignmentExtension{DueDate: due.Add(24 * time.Hour)}
+
+	tests := []struct {
+		name        string
+		extension   *models.AssignmentExtension
+		submittedAt time.Time
+		wantLate    bool
+		wantMinutes int
+		wantPenalty float64
+	}{
+		{name: "before due date", submittedAt: due.Add(-time.Hour)},
+		{name: "at due date", submittedAt: due},
+		{name: "within grace period", submittedAt: due.Add(10 * time.Minute)},
+		// 超过宽限期后从截止时间起算
+		{name: "just after grace period", submittedAt: due.Add(10*time.Minute + time.Second), wantLate: true, wantMinutes: 11, wantPenalty: 10},
+		{name: "second day", submittedAt: due.Add(25 * time.Hour), wantLate: true, wantMinutes: 25 * 60, wantPenalty: 20},
+		{name: "extension covers first day", extension: extension, submittedAt: due.Add(23 * time.Hour)},
+		{name: "late after extension", extension: extension, submittedAt: due.Add(26 * time.Hour), wantLate: true, wantMinutes: 120, wantPenalty: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			late, minutes := buildSubmissionDeadline(assignment, tt.extension).lateness(tt.submittedAt)
+			if late != tt.wantLate || minutes != tt.wantMinutes {
+				t.Fatalf("lateness() = %v, %d, want %v, %d", late, minutes, tt.wantLate, tt.wantMinutes)
+			}
+			if got := calculateLatePenalty(assignment, minutes); got != tt.wantPenalty {
+				t.Errorf("calculateLatePenalty() = %v, want %v", got, tt.wantPenalty)
+			}
+		})
+	}
+}
+
+func TestValidateLatePolicy(t *testing.T) {
+	due := time.Date(2025, 10, 1, 23, 59, 0, 0, time.UTC)
+	before := due.Add(-time.Minute)
+	after := due.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		lateUntil *time.Time
+		percent   float64
+		interval  string
+		wantErr   string
+	}{
+		{name: "defaults"},
+		{name: "hourly penalty", lateUntil: &after, percent: 5, interval: LatePenaltyIntervalHour},
+		{name: "late until equals due date", lateUntil: &due, percent: 100, interval: LatePenaltyIntervalDay},
+		{name: "invalid interval", interval: "week", wantErr: "invalid late penalty interval"},
+		{name: "negative percent", percent: -1, wantErr: "between 0 and 100"},
+		{name: "percent over 100", percent: 100.5, wantErr: "between 0 and 100"},
+		{name: "late until before due date", lateUntil: &before, wantErr: "late_until"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLatePolicy(due, tt.lateUntil, tt.percent, tt.interval)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateLatePolicy() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateLatePolicy() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// LearningProgressService 学习进度服务
+type LearningProgressService struct {
+	db                 *gorm.DB
+	permissionService  *PermissionService
+	gitlabService      *GitLabService
+	achievementService *AchievementService
+}
+
+// NewLearningProgressService 创建学习进度服务
+func NewLearningProgressService(db *gorm.DB, permissionService *PermissionService, gitlabService *GitLabService, achievementService *AchievementService) *LearningProgressService {
+	return &LearningProgressService{
+		db:                 db,
+		permissionService:  permissionService,
+		gitlabService:      gitlabService,
+		achievementService: achievementService,
+	}
+}
+
+// 学习进度图表的统计周数
+const progressChartWeeks = 12
+
+// LearningProgressData 学习进度数据
+type LearningProgressData struct {
+	Overview       LearningOverview    `json:"overview"`
+	RecentActivity []LearningActivity  `json:"recent_activity"`
+	ProgressChart  []ProgressPoint     `json:"progress_chart"`
+	Achievements   []AchievementStatus `json:"achievements"`
+}
+
+// LearningOverview 学习进度概览
+type LearningOverview struct {
+	TotalAssignments     int `json:"total_assignments"`
+	CompletedAssignments int `json:"completed_assignments"`
+	TotalProjects        int `json:"total_projects"`
+	ActiveProjects       int `json:"active_projects"`
+	TotalCommits         int `json:"total_commits"`
+	TotalMergeRequests   int `json:"total_merge_requests"`
+	MergedMergeRequests  int `json:"merged_merge_requests"`
+	FilesModified        int `json:"files_modified"`
+	LinesAdded           int `json:"lines_added"`
+	LinesDeleted         int `json:"lines_deleted"`
+}
+
+// LearningActivity 学习活动记录
+type LearningActivity struct {
+	ID     int    `json:"id"`
+	Type   string `json:"type"` // assignment, review, commit, merge_request
+	Title  string `json:"title"`
+	Time   string `json:"time"`
+	Status string `json:"status"`
+
+	timestamp time.Time
+}
+
+// ProgressPoint 作业完成进度（截至Date的累计值）
+type ProgressPoint struct {
+	Date      string `json:"date"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+}
+
+// ProgressStudent 可查看学习进度的学生
+type ProgressStudent struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+// CanViewProgress 检查用户是否可以查看学生的学习进度
+// 学生只能查看自己，教师只能查看自己课题中的学生，管理员可以查看所有学生
+func (s *LearningProgressService) CanViewProgress(viewerID, studentID uint) bool {
+	if viewerID == studentID || s.permissionService.IsAdmin(viewerID) {
+		return true
+	}
+
+	var count int64
+	s.db.Model(&models.ProjectMember{}).
+		Joins("JOIN projects ON projects.id = project_members.project_id").
+		Where("project_members.user_id = ? AND project_members.is_active = true AND projects.teacher_id = ?", studentID, viewerID).
+		Count(&count)
+
+	return count > 0
+}
+
+// GetVisibleStudents 获取用户可查看学习进度的学生列表
+func (s *LearningProgressService) GetVisibleStudents(viewerID uint) ([]ProgressStudent, error) {
+	query := s.db.Model(&models.User{}).
+		Select("DISTINCT users.id, users.name, users.username, users.avatar").
+		Joins("JOIN project_members ON project_members.user_id = users.id AND project_members.role = 'student' AND project_members.is_active = true").
+		Order("users.id")
+
+	if !s.permissionService.IsAdmin(viewerID) {
+		query = query.Where("users.id = ? OR project_members.project_id IN (?)", viewerID,
+			s.db.Model(&models.Project{}).Select("id").Where("teacher_id = ?", viewerID))
+	}
+
+	var students []ProgressStudent
+	if err := query.Scan(&students).Error; err != nil {
+		return nil, fmt.Errorf("failed to get students: %w", err)
+	}
+
+	return students, nil
+}
+
+// GetLearningProgress 获取学生的学习进度
+func (s *LearningProgressService) GetLearningProgress(viewerID, studentID uint) (*LearningProgressData, error) {
+	if !s.CanViewProgress(viewerID, studentID) {
+		return nil, fmt.Errorf("permission denied to view learning progress of this student")
+	}
+
+	var student models.User
+	if err := s.db.First(&student, studentID).Error; err != nil {
+		return nil, fmt.Errorf("student not found: %w", err)
+	}
+
+	var members []models.ProjectMember
+	if err := s.db.Preload("Project").Where("user_id = ?", studentID).Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to get project members: %w", err)
+	}
+
+	var submissions []models.AssignmentSubmission
+	if err := s.db.Preload("Assignment").Where("student_id = ?", studentID).Order("submitted_at DESC").Find(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get submissions: %w", err)
+	}
+
+	var assignments []models.Assignment
+	if err := s.db.Joins("JOIN project_members ON project_members.project_id = assignments.project_id").
+		Where("project_members.user_id = ? AND project_members.is_active = true", studentID).
+		Find(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get assignments: %w", err)
+	}
+
+	progress := &LearningProgressData{
+		RecentActivity: []LearningActivity{},
+		Achievements:   []AchievementStatus{},
+	}
+
+	// 课题与代码统计
+	for _, member := range members {
+		progress.Overview.TotalProjects++
+		if member.IsActive && member.Project.Status == "active" {
+			progress.Overview.ActiveProjects++
+		}
+		progress.Overview.TotalCommits += member.CommitCount
+		progress.Overview.FilesModified += member.FilesModified
+		progress.Overview.LinesAdded += member.LinesAdded
+		progress.Overview.LinesDeleted += member.LinesDeleted
+
+		if member.LastCommitTime != nil && member.LastCommitHash != "" {
+			progress.RecentActivity = append(progress.RecentActivity, LearningActivity{
+				Type:      "commit",
+				Title:     fmt.Sprintf("提交了代码：%s", member.LastCommitMessage),
+				Status:    "success",
+				timestamp: *member.LastCommitTime,
+			})
+		}
+	}
+
+	// 作业统计
+	firstSubmitted := make(map[uint]time.Time)
+	for _, submission := range submissions {
+		if first, ok := firstSubmitted[submission.AssignmentID]; !ok || submission.SubmittedAt.Before(first) {
+			firstSubmitted[submission.AssignmentID] = submission.SubmittedAt
+		}
+
+		progress.RecentActivity = append(progress.RecentActivity, LearningActivity{
+			Type:      "assignment",
+			Title:     fmt.Sprintf("提交了作业：%s", submission.Assignment.Title),
+			Status:    submission.Status,
+			timestamp: submission.SubmittedAt,
+		})
+		if submission.GradedAt != nil {
+			progress.RecentActivity = append(progress.RecentActivity, LearningActivity{
+				Type:      "review",
+				Title:     fmt.Sprintf("作业「%s」已评审，得分：%.1f", submission.Assignment.Title, submission.Score),
+				Status:    submission.Status,
+				timestamp: *submission.GradedAt,
+			})
+		}
+	}
+	progress.Overview.TotalAssignments = len(assignments)
+	for _, assignment := range assignments {
+		if _, ok := firstSubmitted[assignment.ID]; ok {
+			progress.Overview.CompletedAssignments++
+		}
+	}
+
+	// 合并请求统计
+	s.collectMergeRequests(progress, &student, members)
+
+	progress.ProgressChart = buildProgressChart(assignments, firstSubmitted, time.Now())
+
+	if s.achievementService != nil {
+		achievements, err := s.achievementService.GetUserAchievements(studentID)
+		if err != nil {
+			return nil, err
+		}
+		progress.Achievements = achievements
+	}
+
+	// 最近活动按时间倒序，保留最近10条
+	sort.Slice(progress.RecentActivity, func(i, j int) bool {
+		return progress.RecentActivity[i].timestamp.After(progress.RecentActivity[j].timestamp)
+	})
+	if len(progress.RecentActivity) > 10 {
+		progress.RecentActivity = progress.RecentActivity[:10]
+	}
+	for i := range progress.RecentActivity {
+		progress.RecentActivity[i].ID = i + 1
+		progress.RecentActivity[i].Time = progress.RecentActivity[i].timestamp.Format("2006-01-02 15:04:05")
+	}
+
+	return progress, nil
+}
+
+// collectMergeRequests 从GitLab获取学生在各课题中的合并请求
+func (s *LearningProgressService) collectMergeRequests(progress *LearningProgressData, student *models.User, members []models.ProjectMember) {
+	if s.gitlabService == nil || student.GitLabID == 0 {
+		return
+	}
+
+	for _, member := range members {
+		if member.Project.GitLabProjectID == 0 {
+			continue
+		}
+
+		mrs, err := s.gitlabService.ListUserMergeRequests(member.Project.GitLabProjectID, student.GitLabID)
+		if err != nil {
+			// GitLab不可用时不影响其他进度数据
+			fmt.Printf("Warning: Failed to get merge requests of project %d: %v\n", member.ProjectID, err)
+			continue
+		}
+
+		for _, mr := range mrs {
+			progress.Overview.TotalMergeRequests++
+			if mr.State == "merged" {
+				progress.Overview.MergedMergeRequests++
+			}
+			if mr.CreatedAt != nil {
+				progress.RecentActivity = append(progress.RecentActivity, LearningActivity{
+					Type:      "merge_request",
+					Title:     fmt.Sprintf("提交了合并请求：%s", mr.Title),
+					Status:    mr.State,
+					timestamp: *mr.CreatedAt,
+				})
+			}
+		}
+	}
+}
+
+// buildProgressChart 按周统计截至每个时间点的作业累计发布数与完成数
+func buildProgressChart(assignments []models.Assignment, firstSubmitted map[uint]time.Time, now time.Time) []ProgressPoint {
+	points := make([]ProgressPoint, 0, progressChartWeeks)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
+
+	for week := progressChartWeeks - 1; week >= 0; week-- {
+		date := end.AddDate(0, 0, -7*week)
+		point := ProgressPoint{Date: date.Format("2006-01-02")}
+
+		for _, assignment := range assignments {
+			if assignment.CreatedAt.After(date) {
+				continue
+			}
+			point.Total++
+			if submittedAt, ok := firstSubmitted[assignment.ID]; ok && !submittedAt.After(date) {
+				point.Completed++
+			}
+		}
+
+		points = append(points, point)
+	}
+
+	return points
+}
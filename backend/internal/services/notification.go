@@ -2,10 +2,12 @@ package services
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gitlabex/internal/models"
 
+	"github.com/xanzy/go-gitlab"
 	"gorm.io/gorm"
 )
 
@@ -14,6 +16,12 @@ type NotificationService struct {
 	db                *gorm.DB
 	permissionService *PermissionService
 	gitlabService     *GitLabService
+
+	achievementService *AchievementService
+	assignmentService  *AssignmentService
+	projectService     *ProjectService
+	emailService       *EmailService
+	notificationHub    *NotificationHub
 }
 
 // NewNotificationService 创建通知管理服务
@@ -25,6 +33,33 @@ func NewNotificationService(db *gorm.DB, permissionService *PermissionService, g
 	}
 }
 
+// SetAchievementService 设置成就服务，用于在GitLab事件到达时评估成就
+// 成就服务依赖通知服务发送获得通知，因此通过setter注入以避免循环依赖
+func (s *NotificationService) SetAchievementService(achievementService *AchievementService) {
+	s.achievementService = achievementService
+}
+
+// SetAssignmentService 设置作业服务，用于处理流水线事件
+// 作业服务依赖通知服务发送提醒，因此通过setter注入以避免循环依赖
+func (s *NotificationService) SetAssignmentService(assignmentService *AssignmentService) {
+	s.assignmentService = assignmentService
+}
+
+// SetProjectService 设置课题服务，用于在推送事件到达时重新统计成员提交
+func (s *NotificationService) SetProjectService(projectService *ProjectService) {
+	s.projectService = projectService
+}
+
+// SetEmailService 设置邮件服务，用于按用户设置发送邮件通知
+func (s *NotificationService) SetEmailService(emailService *EmailService) {
+	s.emailService = emailService
+}
+
+// SetNotificationHub 设置通知推送中心，用于向在线用户实时推送通知变化
+func (s *NotificationService) SetNotificationHub(notificationHub *NotificationHub) {
+	s.notificationHub = notificationHub
+}
+
 // CreateNotificationRequest 创建通知请求
 type CreateNotificationRequest struct {
 	UserID     uint   `json:"user_id" binding:"required"`
@@ -36,13 +71,66 @@ type CreateNotificationRequest struct {
 }
 
 // CreateNotification 创建通知
+// 按接收人的通知设置投递：off 不创建通知，email 同时写入邮件发送队列，digest 等待汇总到摘要
 func (s *NotificationService) CreateNotification(notification *models.Notification) error {
-	if err := s.db.Create(notification).Error; err != nil {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.createNotification(tx, notification)
+	}); err != nil {
+		return err
+	}
+
+	s.publishCreated(notification)
+	return nil
+}
+
+// createNotification 在事务中按投递渠道创建通知
+func (s *NotificationService) createNotification(tx *gorm.DB, notification *models.Notification) error {
+	channel := s.NotificationChannel(notification.UserID, notification.Type)
+	if channel == models.NotificationChannelOff {
+		return nil
+	}
+	notification.Digest = channel == models.NotificationChannelDigest
+
+	if err := tx.Create(notification).Error; err != nil {
 		return fmt.Errorf("failed to create notification: %w", err)
 	}
+	if channel == models.NotificationChannelEmail && s.emailService != nil && s.emailService.Enabled() {
+		return s.emailService.EnqueueNotification(tx, notification)
+	}
 	return nil
 }
 
+// publishCreated 事务提交后推送新通知，未创建或摘要模式的通知不推送
+func (s *NotificationService) publishCreated(notification *models.Notification) {
+	if notification.ID == 0 || notification.Digest {
+		return
+	}
+	s.publishEvent(NotificationEvent{
+		Type:         NotificationEventCreated,
+		UserID:       notification.UserID,
+		Notification: notification,
+	})
+}
+
+// publishEvent 填入用户当前的未读数量后推送事件
+func (s *NotificationService) publishEvent(event NotificationEvent) {
+	if s.notificationHub == nil {
+		return
+	}
+	count, err := s.GetUnreadCount(event.UserID)
+	if err != nil {
+		fmt.Printf("Warning: Failed to get unread count for user %d: %v\n", event.UserID, err)
+		return
+	}
+	event.UnreadCount = count
+	s.notificationHub.Publish(event)
+}
+
+// visibleNotifications 通知列表只显示非摘要模式的通知，摘要模式的通知通过摘要查看
+func visibleNotifications(db *gorm.DB) *gorm.DB {
+	return db.Where("digest = ?", false)
+}
+
 // CreateNotificationFromRequest 从请求创建通知
 func (s *NotificationService) CreateNotificationFromRequest(req *CreateNotificationRequest) (*models.Notification, error) {
 	notification := &models.Notification{
@@ -58,6 +146,9 @@ func (s *NotificationService) CreateNotificationFromRequest(req *CreateNotificat
 	if err := s.CreateNotification(notification); err != nil {
 		return nil, err
 	}
+	if notification.ID == 0 {
+		return notification, nil // 接收人关闭了该类通知
+	}
 
 	// 预加载关联数据
 	if err := s.db.Preload("User").First(notification, notification.ID).Error; err != nil {
@@ -73,13 +164,13 @@ func (s *NotificationService) GetNotificationsByUser(userID uint, page, pageSize
 	var total int64
 
 	// 计算总数
-	if err := s.db.Model(&models.Notification{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := s.db.Model(&models.Notification{}).Scopes(visibleNotifications).Where("user_id = ?", userID).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count notifications: %w", err)
 	}
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	err := s.db.Where("user_id = ?", userID).
+	err := s.db.Scopes(visibleNotifications).Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Limit(pageSize).
 		Offset(offset).
@@ -95,7 +186,7 @@ func (s *NotificationService) GetNotificationsByUser(userID uint, page, pageSize
 // GetUnreadNotifications 获取未读通知
 func (s *NotificationService) GetUnreadNotifications(userID uint) ([]models.Notification, error) {
 	var notifications []models.Notification
-	err := s.db.Where("user_id = ? AND read = false", userID).
+	err := s.db.Scopes(visibleNotifications).Where("user_id = ? AND read = false", userID).
 		Order("created_at DESC").
 		Find(&notifications).Error
 
@@ -109,7 +200,7 @@ func (s *NotificationService) GetUnreadNotifications(userID uint) ([]models.Noti
 // GetUnreadCount 获取未读通知数量
 func (s *NotificationService) GetUnreadCount(userID uint) (int, error) {
 	var count int64
-	err := s.db.Model(&models.Notification{}).
+	err := s.db.Model(&models.Notification{}).Scopes(visibleNotifications).
 		Where("user_id = ? AND read = false", userID).
 		Count(&count).Error
 
@@ -132,6 +223,11 @@ func (s *NotificationService) MarkAsRead(notificationID uint, userID uint) error
 		return fmt.Errorf("failed to mark notification as read: %w", err)
 	}
 
+	s.publishEvent(NotificationEvent{
+		Type:            NotificationEventRead,
+		UserID:          userID,
+		NotificationIDs: []uint{notification.ID},
+	})
 	return nil
 }
 
@@ -149,6 +245,13 @@ func (s *NotificationService) MarkAllAsRead(userID uint) error {
 		return fmt.Errorf("failed to mark all notifications as read: %w", result.Error)
 	}
 
+	if result.RowsAffected > 0 {
+		s.publishEvent(NotificationEvent{
+			Type:   NotificationEventRead,
+			UserID: userID,
+			All:    true,
+		})
+	}
 	return nil
 }
 
@@ -163,6 +266,11 @@ func (s *NotificationService) DeleteNotification(notificationID uint, userID uin
 		return fmt.Errorf("notification not found")
 	}
 
+	s.publishEvent(NotificationEvent{
+		Type:            NotificationEventDeleted,
+		UserID:          userID,
+		NotificationIDs: []uint{notificationID},
+	})
 	return nil
 }
 
@@ -172,6 +280,11 @@ func (s *NotificationService) DeleteAllNotifications(userID uint) error {
 		return fmt.Errorf("failed to delete all notifications: %w", err)
 	}
 
+	s.publishEvent(NotificationEvent{
+		Type:   NotificationEventDeleted,
+		UserID: userID,
+		All:    true,
+	})
 	return nil
 }
 
@@ -214,7 +327,7 @@ func (s *NotificationService) NotifyAssignmentReviewed(review *models.Review) er
 	notification := &models.Notification{
 		UserID:     submission.StudentID,
 		Title:      "作业评审完成",
-		Content:    fmt.Sprintf("您的作业「%s」已完成评审，得分：%d", submission.Assignment.Title, review.Score),
+		Content:    fmt.Sprintf("您的作业「%s」已完成评审，得分：%.1f", submission.Assignment.Title, review.Score),
 		Type:       models.NotificationTypeAssignmentReviewed,
 		TargetType: "assignment",
 		TargetID:   submission.AssignmentID,
@@ -251,6 +364,20 @@ func (s *NotificationService) NotifyAssignmentCreated(assignment *models.Assignm
 	return nil
 }
 
+// NotifyAchievementEarned 通知获得成就
+func (s *NotificationService) NotifyAchievementEarned(userID uint, achievement *models.Achievement) error {
+	notification := &models.Notification{
+		UserID:     userID,
+		Title:      "获得新成就",
+		Content:    fmt.Sprintf("恭喜您获得成就「%s」：%s", achievement.Title, achievement.Description),
+		Type:       models.NotificationTypeAchievementEarned,
+		TargetType: "achievement",
+		TargetID:   achievement.ID,
+	}
+
+	return s.CreateNotification(notification)
+}
+
 // NotifyProjectJoined 通知课题加入
 func (s *NotificationService) NotifyProjectJoined(projectID uint, studentID uint) error {
 	// 获取课题信息
@@ -289,7 +416,7 @@ func (s *NotificationService) NotifyProjectCreated(project *models.Project) erro
 // GetNotificationsByType 根据类型获取通知
 func (s *NotificationService) GetNotificationsByType(userID uint, notificationType string) ([]models.Notification, error) {
 	var notifications []models.Notification
-	err := s.db.Where("user_id = ? AND type = ?", userID, notificationType).
+	err := s.db.Scopes(visibleNotifications).Where("user_id = ? AND type = ?", userID, notificationType).
 		Order("created_at DESC").
 		Find(&notifications).Error
 
@@ -306,7 +433,7 @@ func (s *NotificationService) GetNotificationStats(userID uint) (*NotificationSt
 
 	// 总通知数
 	var totalCount int64
-	if err := s.db.Model(&models.Notification{}).Where("user_id = ?", userID).Count(&totalCount).Error; err != nil {
+	if err := s.db.Model(&models.Notification{}).Scopes(visibleNotifications).Where("user_id = ?", userID).Count(&totalCount).Error; err != nil {
 		return nil, fmt.Errorf("failed to count total notifications: %w", err)
 	}
 	stats.TotalCount = int(totalCount)
@@ -321,7 +448,7 @@ func (s *NotificationService) GetNotificationStats(userID uint) (*NotificationSt
 	// 今日通知数
 	today := time.Now().Format("2006-01-02")
 	var todayCount int64
-	if err := s.db.Model(&models.Notification{}).
+	if err := s.db.Model(&models.Notification{}).Scopes(visibleNotifications).
 		Where("user_id = ? AND DATE(created_at) = ?", userID, today).
 		Count(&todayCount).Error; err != nil {
 		return nil, fmt.Errorf("failed to count today's notifications: %w", err)
@@ -330,7 +457,7 @@ func (s *NotificationService) GetNotificationStats(userID uint) (*NotificationSt
 
 	// 按类型统计
 	var typeStats []NotificationTypeStat
-	if err := s.db.Model(&models.Notification{}).
+	if err := s.db.Model(&models.Notification{}).Scopes(visibleNotifications).
 		Select("type, COUNT(*) as count").
 		Where("user_id = ?", userID).
 		Group("type").
@@ -550,43 +677,6 @@ func (s *NotificationService) NotifyWikiPageCreated(projectID uint, userID uint,
 	return nil
 }
 
-// NotifyAssignmentDue 通知作业即将到期
-func (s *NotificationService) NotifyAssignmentDue(assignmentID uint, hours int) error {
-	// 获取作业信息
-	var assignment models.Assignment
-	if err := s.db.Preload("Project").First(&assignment, assignmentID).Error; err != nil {
-		return fmt.Errorf("failed to get assignment: %w", err)
-	}
-
-	// 获取项目成员
-	var members []models.ProjectMember
-	if err := s.db.Where("project_id = ? AND status = 'active'", assignment.ProjectID).Find(&members).Error; err != nil {
-		return fmt.Errorf("failed to get project members: %w", err)
-	}
-
-	// 检查哪些学生还没有提交作业
-	for _, member := range members {
-		var submission models.AssignmentSubmission
-		if err := s.db.Where("assignment_id = ? AND student_id = ?", assignmentID, member.UserID).First(&submission).Error; err != nil {
-			// 学生还没有提交作业，发送通知
-			notification := &models.Notification{
-				UserID:     member.UserID,
-				Title:      "作业即将到期",
-				Content:    fmt.Sprintf("作业「%s」将在 %d 小时后到期，请及时提交", assignment.Title, hours),
-				Type:       models.NotificationTypeAssignmentDue,
-				TargetType: "assignment",
-				TargetID:   assignment.ID,
-			}
-
-			if err := s.CreateNotification(notification); err != nil {
-				fmt.Printf("Warning: Failed to create notification for user %d: %v\n", member.UserID, err)
-			}
-		}
-	}
-
-	return nil
-}
-
 // NotifyCodeReviewRequest 通知代码审查请求
 func (s *NotificationService) NotifyCodeReviewRequest(projectID uint, studentID uint, reviewerID uint, mrTitle string) error {
 	// 获取项目信息
@@ -659,160 +749,190 @@ func (s *NotificationService) SyncNotificationsFromGitLab(projectID uint) error
 	return nil
 }
 
-// ProcessGitLabWebhook 处理GitLab Webhook
-func (s *NotificationService) ProcessGitLabWebhook(projectID uint, eventType string, eventData map[string]interface{}) error {
-	// 获取项目信息
-	var project models.Project
-	if err := s.db.First(&project, projectID).Error; err != nil {
-		return fmt.Errorf("failed to get project: %w", err)
-	}
-
-	switch eventType {
-	case "push":
+// ProcessGitLabWebhook 处理GitLab Webhook，event 为 gitlab.ParseWebhook 解析出的事件
+func (s *NotificationService) ProcessGitLabWebhook(event interface{}) error {
+	switch e := event.(type) {
+	case *gitlab.PushEvent:
 		// 处理推送事件
-		return s.handlePushEvent(project, eventData)
-	case "merge_request":
+		project, err := s.findWebhookProject(e.ProjectID, e.Project.WebURL)
+		if err != nil {
+			return err
+		}
+		return s.handlePushEvent(project, e)
+	case *gitlab.MergeEvent:
 		// 处理合并请求事件
-		return s.handleMergeRequestEvent(project, eventData)
-	case "issue":
+		project, err := s.findWebhookProject(e.Project.ID, e.Project.WebURL)
+		if err != nil {
+			return err
+		}
+		return s.handleMergeRequestEvent(project, e)
+	case *gitlab.IssueEvent:
 		// 处理Issue事件
-		return s.handleIssueEvent(project, eventData)
-	case "wiki":
-		// 处理Wiki事件
-		return s.handleWikiEvent(project, eventData)
+		project, err := s.findWebhookProject(e.Project.ID, e.Project.WebURL)
+		if err != nil {
+			return err
+		}
+		return s.handleIssueEvent(project, e)
+	case *gitlab.WikiPageEvent:
+		// 处理Wiki事件（Wiki事件不含项目ID，按项目URL匹配）
+		project, err := s.findWebhookProject(0, e.Project.WebURL)
+		if err != nil {
+			return err
+		}
+		return s.handleWikiEvent(project, e)
+	case *gitlab.PipelineEvent:
+		// 处理流水线事件，更新提交的自动检查结果
+		project, err := s.findWebhookProject(e.Project.ID, e.Project.WebURL)
+		if err != nil {
+			return err
+		}
+		if s.assignmentService == nil {
+			return nil
+		}
+		return s.assignmentService.HandlePipelineEvent(project.ID, e)
 	default:
-		fmt.Printf("Unknown GitLab event type: %s\n", eventType)
+		fmt.Printf("Unknown GitLab event type: %T\n", event)
 		return nil
 	}
 }
 
-// handlePushEvent 处理推送事件
-func (s *NotificationService) handlePushEvent(project models.Project, eventData map[string]interface{}) error {
-	// 解析事件数据
-	commits, ok := eventData["commits"].([]interface{})
-	if !ok || len(commits) == 0 {
-		return nil
+// findWebhookProject 根据GitLab项目ID（或项目URL）查找课题
+func (s *NotificationService) findWebhookProject(gitlabProjectID int, webURL string) (models.Project, error) {
+	var project models.Project
+	query := s.db.Model(&models.Project{})
+	switch {
+	case gitlabProjectID > 0:
+		query = query.Where("gitlab_project_id = ?", gitlabProjectID)
+	case webURL != "":
+		query = query.Where("gitlab_url = ?", webURL)
+	default:
+		return project, fmt.Errorf("webhook event has no project")
 	}
 
-	// 获取用户信息
-	userEmail, ok := eventData["user_email"].(string)
-	if !ok {
-		return nil
+	if err := query.First(&project).Error; err != nil {
+		return project, fmt.Errorf("failed to get project: %w", err)
 	}
+	return project, nil
+}
 
+// findWebhookUser 根据GitLab用户ID（或邮箱）查找用户
+func (s *NotificationService) findWebhookUser(gitlabUserID int, email string) (*models.User, bool) {
 	var user models.User
-	if err := s.db.Where("email = ?", userEmail).First(&user).Error; err != nil {
-		return nil // 用户不存在，忽略
+	if gitlabUserID > 0 {
+		if err := s.db.Where("gitlab_id = ?", gitlabUserID).First(&user).Error; err == nil {
+			return &user, true
+		}
 	}
-
-	// 创建通知
-	commitMessage := fmt.Sprintf("推送了 %d 个提交", len(commits))
-	if len(commits) == 1 {
-		if commit, ok := commits[0].(map[string]interface{}); ok {
-			if message, ok := commit["message"].(string); ok {
-				commitMessage = message
-			}
+	// GitLab可能隐藏邮箱，此时邮箱为空或为 [REDACTED]
+	if email != "" && strings.Contains(email, "@") {
+		if err := s.db.Where("email = ?", email).First(&user).Error; err == nil {
+			return &user, true
 		}
 	}
-
-	return s.NotifyGitLabCommitPushed(project.ID, user.ID, "", commitMessage)
+	return nil, false
 }
 
-// handleMergeRequestEvent 处理合并请求事件
-func (s *NotificationService) handleMergeRequestEvent(project models.Project, eventData map[string]interface{}) error {
-	// 解析事件数据
-	mrTitle, ok := eventData["title"].(string)
-	if !ok {
+// handlePushEvent 处理推送事件
+func (s *NotificationService) handlePushEvent(project models.Project, event *gitlab.PushEvent) error {
+	// 删除分支时没有提交
+	commitCount := event.TotalCommitsCount
+	if commitCount == 0 {
+		commitCount = len(event.Commits)
+	}
+	if commitCount == 0 {
 		return nil
 	}
 
-	mrURL, ok := eventData["url"].(string)
-	if !ok {
-		return nil
+	// 推送到个人分支时重新统计分支所属成员的提交
+	if s.projectService != nil && strings.HasPrefix(event.Ref, "refs/heads/") {
+		branch := strings.TrimPrefix(event.Ref, "refs/heads/")
+		if err := s.projectService.SyncBranchCommitStats(project.ID, branch); err != nil {
+			fmt.Printf("Warning: Failed to sync commit stats of branch %s: %v\n", branch, err)
+		}
 	}
 
-	userEmail, ok := eventData["user_email"].(string)
+	// 获取用户信息
+	user, ok := s.findWebhookUser(event.UserID, event.UserEmail)
 	if !ok {
-		return nil
+		return nil // 用户不存在，忽略
 	}
 
-	var user models.User
-	if err := s.db.Where("email = ?", userEmail).First(&user).Error; err != nil {
-		return nil // 用户不存在，忽略
+	// 更新成员活跃时间并评估成就
+	if err := s.db.Model(&models.ProjectMember{}).
+		Where("project_id = ? AND user_id = ?", project.ID, user.ID).
+		Update("last_active_time", time.Now()).Error; err != nil {
+		fmt.Printf("Warning: Failed to update member active time: %v\n", err)
+	}
+	if s.achievementService != nil {
+		s.achievementService.EvaluateQuietly(user.ID, models.AchievementRuleCommits)
+	}
+
+	// 创建通知
+	commitMessage := fmt.Sprintf("推送了 %d 个提交", commitCount)
+	if commitCount == 1 && len(event.Commits) == 1 {
+		commitMessage = event.Commits[0].Message
 	}
 
-	return s.NotifyMergeRequestCreated(project.ID, user.ID, mrTitle, mrURL)
+	return s.NotifyGitLabCommitPushed(project.ID, user.ID, event.CheckoutSHA, commitMessage)
 }
 
-// handleIssueEvent 处理Issue事件
-func (s *NotificationService) handleIssueEvent(project models.Project, eventData map[string]interface{}) error {
-	// 解析事件数据
-	issueTitle, ok := eventData["title"].(string)
-	if !ok {
-		return nil
-	}
+// handleMergeRequestEvent 处理合并请求事件
+func (s *NotificationService) handleMergeRequestEvent(project models.Project, event *gitlab.MergeEvent) error {
+	attributes := event.ObjectAttributes
 
-	issueURL, ok := eventData["url"].(string)
-	if !ok {
-		return nil
+	var userID int
+	var email string
+	if event.User != nil {
+		userID, email = event.User.ID, event.User.Email
 	}
-
-	userEmail, ok := eventData["user_email"].(string)
-	if !ok {
-		return nil
+	// 合并操作的触发者是合并人，成就属于合并请求的作者
+	if attributes.Action == "merge" {
+		userID, email = attributes.AuthorID, ""
 	}
 
-	var user models.User
-	if err := s.db.Where("email = ?", userEmail).First(&user).Error; err != nil {
+	user, ok := s.findWebhookUser(userID, email)
+	if !ok {
 		return nil // 用户不存在，忽略
 	}
 
-	return s.NotifyIssueCreated(project.ID, user.ID, issueTitle, issueURL)
-}
-
-// handleWikiEvent 处理Wiki事件
-func (s *NotificationService) handleWikiEvent(project models.Project, eventData map[string]interface{}) error {
-	// 解析事件数据
-	wikiTitle, ok := eventData["title"].(string)
-	if !ok {
+	switch attributes.Action {
+	case "merge":
+		// 合并请求被合并时评估成就
+		if s.achievementService != nil {
+			s.achievementService.EvaluateQuietly(user.ID, models.AchievementRuleMergedMergeRequests)
+		}
 		return nil
-	}
-
-	wikiURL, ok := eventData["url"].(string)
-	if !ok {
+	case "open":
+		return s.NotifyMergeRequestCreated(project.ID, user.ID, attributes.Title, attributes.URL)
+	default:
 		return nil
 	}
+}
 
-	userEmail, ok := eventData["user_email"].(string)
-	if !ok {
+// handleIssueEvent 处理Issue事件
+func (s *NotificationService) handleIssueEvent(project models.Project, event *gitlab.IssueEvent) error {
+	if event.ObjectAttributes.Action != "open" || event.User == nil {
 		return nil
 	}
 
-	var user models.User
-	if err := s.db.Where("email = ?", userEmail).First(&user).Error; err != nil {
+	user, ok := s.findWebhookUser(event.User.ID, event.User.Email)
+	if !ok {
 		return nil // 用户不存在，忽略
 	}
 
-	return s.NotifyWikiPageCreated(project.ID, user.ID, wikiTitle, wikiURL)
+	return s.NotifyIssueCreated(project.ID, user.ID, event.ObjectAttributes.Title, event.ObjectAttributes.URL)
 }
 
-// ScheduleAssignmentDueNotifications 计划作业到期通知
-func (s *NotificationService) ScheduleAssignmentDueNotifications() error {
-	// 获取即将到期的作业（24小时内）
-	tomorrow := time.Now().Add(24 * time.Hour)
-	var assignments []models.Assignment
-
-	if err := s.db.Where("due_date > ? AND due_date < ? AND status = 'active'", time.Now(), tomorrow).Find(&assignments).Error; err != nil {
-		return fmt.Errorf("failed to get assignments due soon: %w", err)
+// handleWikiEvent 处理Wiki事件
+func (s *NotificationService) handleWikiEvent(project models.Project, event *gitlab.WikiPageEvent) error {
+	if event.ObjectAttributes.Action != "create" || event.User == nil {
+		return nil
 	}
 
-	// 为每个作业发送通知
-	for _, assignment := range assignments {
-		hours := int(assignment.DueDate.Sub(time.Now()).Hours())
-		if err := s.NotifyAssignmentDue(assignment.ID, hours); err != nil {
-			fmt.Printf("Warning: Failed to notify assignment due for assignment %d: %v\n", assignment.ID, err)
-		}
+	user, ok := s.findWebhookUser(event.User.ID, event.User.Email)
+	if !ok {
+		return nil // 用户不存在，忽略
 	}
 
-	return nil
+	return s.NotifyWikiPageCreated(project.ID, user.ID, event.ObjectAttributes.Title, event.ObjectAttributes.URL)
 }
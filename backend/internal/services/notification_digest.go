@@ -0,0 +1,357 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// 摘要内容中每个分组列出的通知数，超出部分只显示数量
+const digestItemsPerGroup = 10
+
+// 摘要的默认发送时间
+const (
+	defaultDigestHour    = 8
+	defaultDigestWeekday = 1
+)
+
+// UpdateDigestSettingRequest 更新摘要设置请求
+type UpdateDigestSettingRequest struct {
+	Frequency string `json:"frequency" binding:"required"` // daily, weekly
+	Hour      *int   `json:"hour"`                         // 发送时间（0-23时），默认8时
+	Weekday   *int   `json:"weekday"`                      // 每周发送时的星期（0为周日），默认周一
+}
+
+// DigestGroup 摘要中按课题和作业分组的通知
+type DigestGroup struct {
+	ProjectID       uint                  `json:"project_id"`
+	ProjectName     string                `json:"project_name"`
+	AssignmentID    uint                  `json:"assignment_id"`
+	AssignmentTitle string                `json:"assignment_title"`
+	Count           int                   `json:"count"`
+	Notifications   []models.Notification `json:"notifications"`
+}
+
+// DigestDetail 摘要及其汇总的通知
+type DigestDetail struct {
+	models.NotificationDigest
+	Groups []DigestGroup `json:"groups"`
+}
+
+// GetDigestSetting 获取用户的摘要设置，未设置时返回默认值
+func (s *NotificationService) GetDigestSetting(userID uint) (*models.NotificationDigestSetting, error) {
+	var setting models.NotificationDigestSetting
+	err := s.db.Where("user_id = ?", userID).First(&setting).Error
+	if err == gorm.ErrRecordNotFound {
+		return &models.NotificationDigestSetting{
+			UserID:    userID,
+			Frequency: models.DigestFrequencyDaily,
+			Hour:      defaultDigestHour,
+			Weekday:   defaultDigestWeekday,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest setting: %w", err)
+	}
+	return &setting, nil
+}
+
+// UpdateDigestSetting 修改用户的摘要频率和发送时间
+func (s *NotificationService) UpdateDigestSetting(userID uint, req *UpdateDigestSettingRequest) (*models.NotificationDigestSetting, error) {
+	if req.Frequency != models.DigestFrequencyDaily && req.Frequency != models.DigestFrequencyWeekly {
+		return nil, fmt.Errorf("invalid digest frequency: %s", req.Frequency)
+	}
+
+	setting, err := s.GetDigestSetting(userID)
+	if err != nil {
+		return nil, err
+	}
+	setting.Frequency = req.Frequency
+	if req.Hour != nil {
+		if *req.Hour < 0 || *req.Hour > 23 {
+			return nil, fmt.Errorf("hour must be between 0 and 23")
+		}
+		setting.Hour = *req.Hour
+	}
+	if req.Weekday != nil {
+		if *req.Weekday < 0 || *req.Weekday > 6 {
+			return nil, fmt.Errorf("weekday must be between 0 and 6")
+		}
+		setting.Weekday = *req.Weekday
+	}
+
+	if err := s.db.Save(setting).Error; err != nil {
+		return nil, fmt.Errorf("failed to save digest setting: %w", err)
+	}
+	return setting, nil
+}
+
+// digestSchedule 摘要设置对应的执行计划
+func digestSchedule(setting *models.NotificationDigestSetting) (*utils.CronSchedule, error) {
+	if setting.Frequency == models.DigestFrequencyWeekly {
+		return utils.ParseCronSchedule(fmt.Sprintf("0 %d * * %d", setting.Hour, setting.Weekday))
+	}
+	return utils.ParseCronSchedule(fmt.Sprintf("0 %d * * *", setting.Hour))
+}
+
+// SendNotificationDigests 为到达发送时间的用户生成摘要，由定时任务周期调用
+func (s *NotificationService) SendNotificationDigests() error {
+	var userIDs []uint
+	if err := s.db.Model(&models.Notification{}).
+		Where("digest = ? AND digest_id IS NULL", true).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return fmt.Errorf("failed to get users with pending digest notifications: %w", err)
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, userID := range userIDs {
+		if err := s.sendUserDigest(userID, now); err != nil {
+			fmt.Printf("Warning: Failed to send notification digest to user %d: %v\n", userID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to send digests to %d of %d users", failed, len(userIDs))
+	}
+	return nil
+}
+
+// sendUserDigest 到达发送时间时汇总用户待摘要的通知
+func (s *NotificationService) sendUserDigest(userID uint, now time.Time) error {
+	setting, err := s.GetDigestSetting(userID)
+	if err != nil {
+		return err
+	}
+	schedule, err := digestSchedule(setting)
+	if err != nil {
+		return err
+	}
+
+	var pending []models.Notification
+	if err := s.db.Where("user_id = ? AND digest = ? AND digest_id IS NULL", userID, true).
+		Order("created_at").Find(&pending).Error; err != nil {
+		return fmt.Errorf("failed to get pending notifications: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// 从上次发送（从未发送时为最早的待汇总通知）之后的第一个发送时间点起即可发送
+	since := pending[0].CreatedAt
+	if setting.LastDigestAt != nil {
+		since = *setting.LastDigestAt
+	}
+	if next := schedule.Next(since); next.IsZero() || next.After(now) {
+		return nil
+	}
+
+	groups, err := s.groupDigestNotifications(pending)
+	if err != nil {
+		return err
+	}
+
+	var notification *models.Notification
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		digest := &models.NotificationDigest{
+			UserID:      userID,
+			Frequency:   setting.Frequency,
+			PeriodStart: pending[0].CreatedAt,
+			PeriodEnd:   now,
+			ItemCount:   len(pending),
+		}
+		if err := tx.Create(digest).Error; err != nil {
+			return fmt.Errorf("failed to create digest: %w", err)
+		}
+
+		ids := make([]uint, len(pending))
+		for i, notification := range pending {
+			ids[i] = notification.ID
+		}
+		// 只标记仍未汇总的通知，避免多个实例重复汇总
+		result := tx.Model(&models.Notification{}).
+			Where("id IN ? AND digest_id IS NULL", ids).
+			Updates(map[string]interface{}{
+				"digest_id":   digest.ID,
+				"digested_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to mark notifications as digested: %w", result.Error)
+		}
+		if result.RowsAffected != int64(len(ids)) {
+			return fmt.Errorf("notifications were digested concurrently")
+		}
+
+		period := "今日"
+		if setting.Frequency == models.DigestFrequencyWeekly {
+			period = "本周"
+		}
+		notification = &models.Notification{
+			UserID:     userID,
+			Title:      fmt.Sprintf("%s通知摘要（%d 条）", period, len(pending)),
+			Content:    formatDigestContent(groups),
+			Type:       models.NotificationTypeDigest,
+			TargetType: "digest",
+			TargetID:   digest.ID,
+		}
+		if err := s.createNotification(tx, notification); err != nil {
+			return err
+		}
+
+		if err := tx.Model(digest).Update("notification_id", notification.ID).Error; err != nil {
+			return fmt.Errorf("failed to update digest: %w", err)
+		}
+		setting.LastDigestAt = &now
+		if err := tx.Save(setting).Error; err != nil {
+			return fmt.Errorf("failed to save digest setting: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publishCreated(notification)
+	return nil
+}
+
+// groupDigestNotifications 按课题和作业对通知分组，与作业无关的通知归入课题或“其他”分组
+func (s *NotificationService) groupDigestNotifications(notifications []models.Notification) ([]DigestGroup, error) {
+	var assignmentIDs, projectIDs []uint
+	for _, notification := range notifications {
+		switch notification.TargetType {
+		case "assignment":
+			assignmentIDs = append(assignmentIDs, notification.TargetID)
+		case "project":
+			projectIDs = append(projectIDs, notification.TargetID)
+		}
+	}
+
+	assignments := make(map[uint]models.Assignment)
+	if len(assignmentIDs) > 0 {
+		var list []models.Assignment
+		if err := s.db.Preload("Project").Where("id IN ?", assignmentIDs).Find(&list).Error; err != nil {
+			return nil, fmt.Errorf("failed to get assignments: %w", err)
+		}
+		for _, assignment := range list {
+			assignments[assignment.ID] = assignment
+		}
+	}
+	projects := make(map[uint]models.Project)
+	if len(projectIDs) > 0 {
+		var list []models.Project
+		if err := s.db.Where("id IN ?", projectIDs).Find(&list).Error; err != nil {
+			return nil, fmt.Errorf("failed to get projects: %w", err)
+		}
+		for _, project := range list {
+			projects[project.ID] = project
+		}
+	}
+
+	type groupKey struct{ projectID, assignmentID uint }
+	groupIndex := make(map[groupKey]int)
+	var groups []DigestGroup
+	for _, notification := range notifications {
+		group := DigestGroup{}
+		switch notification.TargetType {
+		case "assignment":
+			if assignment, ok := assignments[notification.TargetID]; ok {
+				group.ProjectID = assignment.ProjectID
+				group.ProjectName = assignment.Project.Name
+				group.AssignmentID = assignment.ID
+				group.AssignmentTitle = assignment.Title
+			}
+		case "project":
+			if project, ok := projects[notification.TargetID]; ok {
+				group.ProjectID = project.ID
+				group.ProjectName = project.Name
+			}
+		}
+
+		key := groupKey{group.ProjectID, group.AssignmentID}
+		idx, ok := groupIndex[key]
+		if !ok {
+			idx = len(groups)
+			groupIndex[key] = idx
+			groups = append(groups, group)
+		}
+		groups[idx].Count++
+		groups[idx].Notifications = append(groups[idx].Notifications, notification)
+	}
+
+	// 按课题、作业排序，“其他”分组放在最后
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].ProjectID == 0) != (groups[j].ProjectID == 0) {
+			return groups[j].ProjectID == 0
+		}
+		if groups[i].ProjectName != groups[j].ProjectName {
+			return groups[i].ProjectName < groups[j].ProjectName
+		}
+		return groups[i].AssignmentID < groups[j].AssignmentID
+	})
+
+	return groups, nil
+}
+
+// formatDigestContent 生成摘要通知的正文
+func formatDigestContent(groups []DigestGroup) string {
+	var b strings.Builder
+	lastProject := ^uint(0)
+	for _, group := range groups {
+		if group.ProjectID != lastProject {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			if group.ProjectID == 0 {
+				b.WriteString("【其他】\n")
+			} else {
+				fmt.Fprintf(&b, "【%s】\n", group.ProjectName)
+			}
+			lastProject = group.ProjectID
+		}
+
+		indent := ""
+		if group.AssignmentID != 0 {
+			fmt.Fprintf(&b, "作业「%s」（%d 条）\n", group.AssignmentTitle, group.Count)
+			indent = "  "
+		}
+		for i, notification := range group.Notifications {
+			if i == digestItemsPerGroup {
+				fmt.Fprintf(&b, "%s…另有 %d 条\n", indent, group.Count-digestItemsPerGroup)
+				break
+			}
+			fmt.Fprintf(&b, "%s- %s %s\n", indent, notification.CreatedAt.Format("01-02 15:04"), notification.Content)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// GetDigest 获取摘要及其汇总的通知
+func (s *NotificationService) GetDigest(userID, digestID uint) (*DigestDetail, error) {
+	var digest models.NotificationDigest
+	if err := s.db.Where("id = ? AND user_id = ?", digestID, userID).First(&digest).Error; err != nil {
+		return nil, fmt.Errorf("digest not found: %w", err)
+	}
+
+	var notifications []models.Notification
+	if err := s.db.Where("digest_id = ?", digest.ID).Order("created_at").Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to get digest notifications: %w", err)
+	}
+
+	groups, err := s.groupDigestNotifications(notifications)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DigestDetail{
+		NotificationDigest: digest,
+		Groups:             groups,
+	}, nil
+}
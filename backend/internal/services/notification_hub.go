@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 实时推送的事件类型
+const (
+	NotificationEventCreated     = "notification" // 新通知
+	NotificationEventUnreadCount = "unread_count" // 未读数量（建立连接时发送）
+	NotificationEventRead        = "read"         // 通知被标记为已读
+	NotificationEventDeleted     = "deleted"      // 通知被删除
+)
+
+// 实时推送参数
+const (
+	notificationHubChannel       = "gitlabex:notifications" // Redis 发布订阅频道
+	notificationSubscriberBuffer = 32                       // 每个连接缓存的事件数，连接处理不及时时丢弃新事件
+	notificationPublishTimeout   = 5 * time.Second
+)
+
+// NotificationEvent 推送给用户的通知事件，每个事件都带有变化后的未读数量
+type NotificationEvent struct {
+	Type            string               `json:"type"`
+	UserID          uint                 `json:"user_id"`
+	Notification    *models.Notification `json:"notification,omitempty"`
+	NotificationIDs []uint               `json:"notification_ids,omitempty"` // 已读或删除的通知
+	All             bool                 `json:"all,omitempty"`              // 是否为全部通知
+	UnreadCount     int                  `json:"unread_count"`
+}
+
+// NotificationHub 通知实时推送中心
+// 配置了Redis时事件经Redis发布订阅转发给所有实例，每个实例再推送给本实例上的连接；否则只推送给本实例的连接
+type NotificationHub struct {
+	redis *redis.Client
+
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan NotificationEvent]struct{}
+}
+
+// NewNotificationHub 创建通知推送中心，redisClient 为nil时只在本实例内推送
+func NewNotificationHub(redisClient *redis.Client) *NotificationHub {
+	return &NotificationHub{
+		redis:       redisClient,
+		subscribers: make(map[uint]map[chan NotificationEvent]struct{}),
+	}
+}
+
+// Start 订阅Redis频道，将其他实例发布的事件推送给本实例的连接
+func (h *NotificationHub) Start() {
+	if h.redis == nil {
+		return
+	}
+
+	pubsub := h.redis.Subscribe(context.Background(), notificationHubChannel)
+	go func() {
+		// Channel 在Redis连接断开后会自动重连
+		for message := range pubsub.Channel() {
+			var event NotificationEvent
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				fmt.Printf("Warning: Failed to decode notification event: %v\n", err)
+				continue
+			}
+			h.dispatch(event)
+		}
+	}()
+}
+
+// Subscribe 订阅用户的通知事件，连接关闭时需调用 Unsubscribe
+func (h *NotificationHub) Subscribe(userID uint) chan NotificationEvent {
+	ch := make(chan NotificationEvent, notificationSubscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan NotificationEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe 取消订阅
+func (h *NotificationHub) Unsubscribe(userID uint, ch chan NotificationEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers[userID], ch)
+	if len(h.subscribers[userID]) == 0 {
+		delete(h.subscribers, userID)
+	}
+}
+
+// Publish 发布事件，Redis发布失败时退回为只推送给本实例的连接
+func (h *NotificationHub) Publish(event NotificationEvent) {
+	if h.redis == nil {
+		h.dispatch(event)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("Warning: Failed to encode notification event: %v\n", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notificationPublishTimeout)
+	defer cancel()
+	if err := h.redis.Publish(ctx, notificationHubChannel, payload).Err(); err != nil {
+		fmt.Printf("Warning: Failed to publish notification event: %v\n", err)
+		h.dispatch(event)
+	}
+}
+
+// dispatch 推送给本实例上该用户的所有连接
+func (h *NotificationHub) dispatch(event NotificationEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			// 连接处理不及时，丢弃事件；之后的事件仍带有最新的未读数量
+		}
+	}
+}
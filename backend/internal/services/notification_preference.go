@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationTypes 可设置投递渠道的通知类型
+var NotificationTypes = []string{
+	models.NotificationTypeAssignmentCreated,
+	models.NotificationTypeAssignmentDue,
+	models.NotificationTypeAssignmentUnsubmitted,
+	models.NotificationTypeAssignmentSubmitted,
+	models.NotificationTypeAssignmentReviewed,
+	models.NotificationTypeProjectCreated,
+	models.NotificationTypeProjectJoined,
+	models.NotificationTypeClassJoined,
+	models.NotificationTypeAchievementEarned,
+	models.NotificationTypeGitLabCommit,
+	models.NotificationTypeMergeRequest,
+	models.NotificationTypeIssueCreated,
+	models.NotificationTypeWikiCreated,
+	models.NotificationTypeCodeReview,
+	models.NotificationTypeGitLabActivity,
+	models.NotificationTypeDigest,
+}
+
+// defaultNotificationChannels 用户未设置时的投递渠道，未列出的类型仅站内通知
+var defaultNotificationChannels = map[string]string{
+	models.NotificationTypeAssignmentCreated:     models.NotificationChannelEmail,
+	models.NotificationTypeAssignmentDue:         models.NotificationChannelEmail,
+	models.NotificationTypeAssignmentUnsubmitted: models.NotificationChannelEmail,
+	models.NotificationTypeAssignmentReviewed:    models.NotificationChannelEmail,
+	models.NotificationTypeDigest:                models.NotificationChannelEmail,
+}
+
+// NotificationPreferenceItem 某类通知的投递渠道
+type NotificationPreferenceItem struct {
+	Type      string `json:"type"`
+	Channel   string `json:"channel"`
+	IsDefault bool   `json:"is_default"` // 是否为默认设置
+}
+
+// validNotificationChannel 检查投递渠道是否有效
+func validNotificationChannel(channel string) bool {
+	switch channel {
+	case models.NotificationChannelInApp, models.NotificationChannelEmail,
+		models.NotificationChannelDigest, models.NotificationChannelOff:
+		return true
+	}
+	return false
+}
+
+// defaultNotificationChannel 通知类型的默认投递渠道
+func defaultNotificationChannel(notificationType string) string {
+	if channel, ok := defaultNotificationChannels[notificationType]; ok {
+		return channel
+	}
+	return models.NotificationChannelInApp
+}
+
+// NotificationChannel 获取用户接收某类通知的投递渠道
+func (s *NotificationService) NotificationChannel(userID uint, notificationType string) string {
+	var preference models.NotificationPreference
+	if err := s.db.Where("user_id = ? AND type = ?", userID, notificationType).First(&preference).Error; err == nil &&
+		validNotificationChannel(preference.Channel) &&
+		!(notificationType == models.NotificationTypeDigest && preference.Channel == models.NotificationChannelDigest) {
+		return preference.Channel
+	}
+	return defaultNotificationChannel(notificationType)
+}
+
+// GetNotificationPreferences 获取用户所有通知类型的投递渠道
+func (s *NotificationService) GetNotificationPreferences(userID uint) ([]NotificationPreferenceItem, error) {
+	var preferences []models.NotificationPreference
+	if err := s.db.Where("user_id = ?", userID).Find(&preferences).Error; err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	channels := make(map[string]string, len(preferences))
+	for _, preference := range preferences {
+		channels[preference.Type] = preference.Channel
+	}
+
+	items := make([]NotificationPreferenceItem, 0, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		item := NotificationPreferenceItem{Type: notificationType}
+		if channel, ok := channels[notificationType]; ok && validNotificationChannel(channel) {
+			item.Channel = channel
+		} else {
+			item.Channel = defaultNotificationChannel(notificationType)
+			item.IsDefault = true
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// UpdateNotificationPreferences 设置通知类型的投递渠道，渠道为 default 时恢复默认设置
+func (s *NotificationService) UpdateNotificationPreferences(userID uint, channels map[string]string) ([]NotificationPreferenceItem, error) {
+	known := make(map[string]bool, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		known[notificationType] = true
+	}
+	for notificationType, channel := range channels {
+		if !known[notificationType] {
+			return nil, fmt.Errorf("unknown notification type: %s", notificationType)
+		}
+		if channel != "default" && !validNotificationChannel(channel) {
+			return nil, fmt.Errorf("invalid notification channel: %s", channel)
+		}
+		if notificationType == models.NotificationTypeDigest && channel == models.NotificationChannelDigest {
+			return nil, fmt.Errorf("digest notifications cannot be delivered in digest mode")
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for notificationType, channel := range channels {
+			if channel == "default" {
+				if err := tx.Where("user_id = ? AND type = ?", userID, notificationType).
+					Delete(&models.NotificationPreference{}).Error; err != nil {
+					return fmt.Errorf("failed to reset notification preference: %w", err)
+				}
+				continue
+			}
+
+			preference := &models.NotificationPreference{
+				UserID:  userID,
+				Type:    notificationType,
+				Channel: channel,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+				DoUpdates: clause.AssignmentColumns([]string{"channel", "updated_at"}),
+			}).Create(preference).Error; err != nil {
+				return fmt.Errorf("failed to save notification preference: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNotificationPreferences(userID)
+}
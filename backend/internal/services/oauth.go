@@ -0,0 +1,996 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlabex/internal/config"
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// OAuth2授权服务参数
+const (
+	oauthClientIDPrefix     = "glc_"
+	oauthClientSecretPrefix = "glcs_"
+	oauthCodePrefix         = "gla_"
+	oauthAccessTokenPrefix  = "glo_"
+	oauthRefreshTokenPrefix = "glr_"
+	oauthCodeTTL            = 10 * time.Minute
+	oauthAccessTokenTTL     = time.Hour
+	oauthRefreshTokenTTL    = 30 * 24 * time.Hour
+	oauthUsageWriteInterval = time.Minute
+	oauthRetention          = 7 * 24 * time.Hour // 过期或撤销的令牌、授权码保留时间
+)
+
+// OAuth2错误码（RFC 6749）
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrServerError             = "server_error"
+)
+
+// OAuthError OAuth2协议错误
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthScope OAuth2权限范围，Resource 和 Permission 对应 PermissionService 的资源类型和 config.Permission* 权限
+type OAuthScope struct {
+	Name        string `json:"name"`
+	Resource    string `json:"resource"`
+	Permission  string `json:"permission"`
+	Description string `json:"description"`
+}
+
+// OAuthScopes 第三方应用可以申请的权限范围，实际操作仍受用户自身权限限制
+var OAuthScopes = []OAuthScope{
+	{Name: "profile", Resource: "user", Permission: config.PermissionRead, Description: "读取用户基本信息（用户名、姓名、邮箱和角色）"},
+	{Name: "projects:read", Resource: "project", Permission: config.PermissionRead, Description: "查看你参与的课题及其代码仓库"},
+	{Name: "projects:write", Resource: "project", Permission: config.PermissionWrite, Description: "修改你有写权限的课题及其代码仓库"},
+	{Name: "assignments:read", Resource: "assignment", Permission: config.PermissionRead, Description: "查看你的作业"},
+	{Name: "assignments:manage", Resource: "assignment", Permission: config.PermissionManage, Description: "创建、修改和删除你负责的作业"},
+}
+
+// oauthPermissionImplies 同一资源上权限的包含关系
+var oauthPermissionImplies = map[string][]string{
+	config.PermissionWrite:  {config.PermissionRead},
+	config.PermissionSubmit: {config.PermissionRead},
+	config.PermissionReview: {config.PermissionRead},
+	config.PermissionManage: {config.PermissionRead, config.PermissionWrite, config.PermissionSubmit, config.PermissionReview},
+	config.PermissionDelete: {config.PermissionRead, config.PermissionWrite, config.PermissionManage},
+}
+
+// oauthScopeForAPIScope 第三方API路由权限范围所需的OAuth2权限范围，未列出的路由不允许OAuth2令牌访问
+var oauthScopeForAPIScope = map[string]string{
+	models.APIScopeReposRead:        "projects:read",
+	models.APIScopeReposWrite:       "projects:write",
+	models.APIScopeUsersRead:        "profile",
+	models.APIScopePermissionsRead:  "profile",
+	models.APIScopeAssignmentsRead:  "assignments:read",
+	models.APIScopeAssignmentsWrite: "assignments:manage",
+}
+
+var pkceVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// OAuthService OAuth2授权服务，第三方应用经用户同意后代表用户调用第三方API
+type OAuthService struct {
+	db *gorm.DB
+}
+
+// NewOAuthService 创建OAuth2授权服务
+func NewOAuthService(db *gorm.DB) *OAuthService {
+	return &OAuthService{db: db}
+}
+
+// CreateOAuthClientRequest 注册客户端请求
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	Description  string   `json:"description"`
+	HomepageURL  string   `json:"homepage_url"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required"`
+	Scopes       []string `json:"scopes" binding:"required"`
+	Confidential bool     `json:"confidential"` // 有服务端的应用，使用密钥认证；单页和移动应用为公开客户端
+}
+
+// UpdateOAuthClientRequest 更新客户端请求，未提供的字段保持不变
+type UpdateOAuthClientRequest struct {
+	Name         *string   `json:"name"`
+	Description  *string   `json:"description"`
+	HomepageURL  *string   `json:"homepage_url"`
+	RedirectURIs *[]string `json:"redirect_uris"`
+	Scopes       *[]string `json:"scopes"`
+	Active       *bool     `json:"active"`
+}
+
+// AuthorizeRequest 授权请求参数
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// OAuthConsentInfo 授权确认页面需要的信息
+type OAuthConsentInfo struct {
+	Client      *models.OAuthClient `json:"client"`
+	Scopes      []OAuthScope        `json:"scopes"`
+	RedirectURI string              `json:"redirect_uri"`
+	Consented   bool                `json:"consented"` // 用户已同意过这些权限范围
+}
+
+// TokenRequest 令牌请求参数
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+// TokenResponse 令牌响应
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// ===== 客户端管理 =====
+
+// CreateClient 注册客户端，机密客户端的明文密钥只在创建时返回一次
+func (s *OAuthService) CreateClient(ownerID uint, req *CreateOAuthClientRequest) (*models.OAuthClient, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("client name is required")
+	}
+	redirectURIs, err := normalizeRedirectURIs(req.RedirectURIs)
+	if err != nil {
+		return nil, "", err
+	}
+	scopes, err := normalizeOAuthScopes(req.Scopes, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateHomepageURL(req.HomepageURL); err != nil {
+		return nil, "", err
+	}
+
+	clientID, err := generateSecretToken(oauthClientIDPrefix)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate client id: %w", err)
+	}
+	client := &models.OAuthClient{
+		ClientID:     clientID[:len(oauthClientIDPrefix)+24],
+		Name:         name,
+		Description:  strings.TrimSpace(req.Description),
+		HomepageURL:  strings.TrimSpace(req.HomepageURL),
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		Confidential: req.Confidential,
+		OwnerID:      ownerID,
+		Active:       true,
+	}
+
+	var secret string
+	if req.Confidential {
+		if secret, err = generateSecretToken(oauthClientSecretPrefix); err != nil {
+			return nil, "", fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		client.SecretHash = hashSecretToken(secret)
+	}
+
+	if err := s.db.Create(client).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create oauth client: %w", err)
+	}
+	return client, secret, nil
+}
+
+// ListClients 获取用户注册的客户端，all 为true时返回所有客户端（管理员）
+func (s *OAuthService) ListClients(ownerID uint, all bool) ([]models.OAuthClient, error) {
+	query := s.db.Order("created_at DESC")
+	if !all {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+
+	var clients []models.OAuthClient
+	if err := query.Find(&clients).Error; err != nil {
+		return nil, fmt.Errorf("failed to list oauth clients: %w", err)
+	}
+	return clients, nil
+}
+
+// GetClient 获取客户端，all 为false时只能获取自己注册的客户端
+func (s *OAuthService) GetClient(ownerID, id uint, all bool) (*models.OAuthClient, error) {
+	query := s.db.Where("id = ?", id)
+	if !all {
+		query = query.Where("owner_id = ?", ownerID)
+	}
+
+	var client models.OAuthClient
+	if err := query.First(&client).Error; err != nil {
+		return nil, fmt.Errorf("oauth client not found: %w", err)
+	}
+	return &client, nil
+}
+
+// UpdateClient 更新客户端，停用后其令牌立即失效
+func (s *OAuthService) UpdateClient(ownerID, id uint, all bool, req *UpdateOAuthClientRequest) (*models.OAuthClient, error) {
+	client, err := s.GetClient(ownerID, id, all)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, fmt.Errorf("client name is required")
+		}
+		updates["name"] = name
+		client.Name = name
+	}
+	if req.Description != nil {
+		client.Description = strings.TrimSpace(*req.Description)
+		updates["description"] = client.Description
+	}
+	if req.HomepageURL != nil {
+		if err := validateHomepageURL(*req.HomepageURL); err != nil {
+			return nil, err
+		}
+		client.HomepageURL = strings.TrimSpace(*req.HomepageURL)
+		updates["homepage_url"] = client.HomepageURL
+	}
+	if req.RedirectURIs != nil {
+		redirectURIs, err := normalizeRedirectURIs(*req.RedirectURIs)
+		if err != nil {
+			return nil, err
+		}
+		client.RedirectURIs = redirectURIs
+		updates["redirect_uris"] = redirectURIs
+	}
+	if req.Scopes != nil {
+		scopes, err := normalizeOAuthScopes(*req.Scopes, nil)
+		if err != nil {
+			return nil, err
+		}
+		client.Scopes = scopes
+		updates["scopes"] = scopes
+	}
+	if req.Active != nil {
+		client.Active = *req.Active
+		updates["active"] = client.Active
+	}
+	if len(updates) == 0 {
+		return client, nil
+	}
+
+	if err := s.db.Model(client).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update oauth client: %w", err)
+	}
+	return client, nil
+}
+
+// RotateClientSecret 重新生成机密客户端的密钥，旧密钥立即失效
+func (s *OAuthService) RotateClientSecret(ownerID, id uint, all bool) (*models.OAuthClient, string, error) {
+	client, err := s.GetClient(ownerID, id, all)
+	if err != nil {
+		return nil, "", err
+	}
+	if !client.Confidential {
+		return nil, "", fmt.Errorf("public clients have no secret")
+	}
+
+	secret, err := generateSecretToken(oauthClientSecretPrefix)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate client secret: %w", err)
+	}
+	if err := s.db.Model(client).Update("secret_hash", hashSecretToken(secret)).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to rotate client secret: %w", err)
+	}
+	return client, secret, nil
+}
+
+// DeleteClient 删除客户端及其授权码、令牌和用户授权记录
+func (s *OAuthService) DeleteClient(ownerID, id uint, all bool) error {
+	client, err := s.GetClient(ownerID, id, all)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.OAuthAuthorizationCode{}, &models.OAuthToken{}, &models.OAuthConsent{}} {
+			if err := tx.Where("client_id = ?", client.ID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to delete oauth client data: %w", err)
+			}
+		}
+		if err := tx.Delete(client).Error; err != nil {
+			return fmt.Errorf("failed to delete oauth client: %w", err)
+		}
+		return nil
+	})
+}
+
+// ===== 授权 =====
+
+// ValidateAuthorization 校验授权请求，返回客户端、回调地址和申请的权限范围
+// 返回错误时回调地址为空表示客户端或回调地址无效，不能重定向回客户端
+func (s *OAuthService) ValidateAuthorization(req *AuthorizeRequest) (*models.OAuthClient, string, []string, error) {
+	var client models.OAuthClient
+	if req.ClientID == "" || s.db.Where("client_id = ? AND active = ?", req.ClientID, true).First(&client).Error != nil {
+		return nil, "", nil, newOAuthError(OAuthErrInvalidClient, "unknown or inactive client")
+	}
+
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !containsString(client.RedirectURIs, redirectURI) {
+		return nil, "", nil, newOAuthError(OAuthErrInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return &client, redirectURI, nil, newOAuthError(OAuthErrUnsupportedResponseType, "response_type must be code")
+	}
+	if req.CodeChallenge == "" {
+		return &client, redirectURI, nil, newOAuthError(OAuthErrInvalidRequest, "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return &client, redirectURI, nil, newOAuthError(OAuthErrInvalidRequest, "code_challenge_method must be S256")
+	}
+	if !pkceVerifierPattern.MatchString(req.CodeChallenge) {
+		return &client, redirectURI, nil, newOAuthError(OAuthErrInvalidRequest, "invalid code_challenge")
+	}
+
+	requested := strings.Fields(req.Scope)
+	if len(requested) == 0 {
+		requested = client.Scopes
+	}
+	scopes, err := normalizeOAuthScopes(requested, client.Scopes)
+	if err != nil {
+		return &client, redirectURI, nil, newOAuthError(OAuthErrInvalidScope, err.Error())
+	}
+
+	return &client, redirectURI, scopes, nil
+}
+
+// GetConsentInfo 授权确认页面信息，用户已同意过申请的权限范围时 Consented 为true
+func (s *OAuthService) GetConsentInfo(userID uint, req *AuthorizeRequest) (*OAuthConsentInfo, string, error) {
+	client, redirectURI, scopes, err := s.ValidateAuthorization(req)
+	if err != nil {
+		return nil, redirectURI, err
+	}
+
+	info := &OAuthConsentInfo{
+		Client:      client,
+		Scopes:      lookupOAuthScopes(scopes),
+		RedirectURI: redirectURI,
+	}
+
+	var consent models.OAuthConsent
+	if err := s.db.Where("user_id = ? AND client_id = ?", userID, client.ID).First(&consent).Error; err == nil {
+		info.Consented = true
+		for _, scope := range scopes {
+			if !containsString(consent.Scopes, scope) {
+				info.Consented = false
+				break
+			}
+		}
+	}
+	return info, redirectURI, nil
+}
+
+// Authorize 处理用户的授权决定，返回重定向回客户端的地址
+// 同意时保存授权记录并签发授权码，拒绝时返回 access_denied
+func (s *OAuthService) Authorize(userID uint, req *AuthorizeRequest, approved bool) (string, error) {
+	client, redirectURI, scopes, err := s.ValidateAuthorization(req)
+	if err != nil {
+		if redirectURI == "" {
+			return "", err
+		}
+		return OAuthErrorRedirect(redirectURI, req.State, err), nil
+	}
+	if !approved {
+		return OAuthErrorRedirect(redirectURI, req.State, newOAuthError(OAuthErrAccessDenied, "the user denied the request")), nil
+	}
+
+	code, err := generateSecretToken(oauthCodePrefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var consent models.OAuthConsent
+		err := tx.Where("user_id = ? AND client_id = ?", userID, client.ID).First(&consent).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			consent = models.OAuthConsent{UserID: userID, ClientID: client.ID, Scopes: scopes}
+			if err := tx.Create(&consent).Error; err != nil {
+				return fmt.Errorf("failed to save oauth consent: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to get oauth consent: %w", err)
+		default:
+			merged := mergeScopes(consent.Scopes, scopes)
+			if err := tx.Model(&consent).Update("scopes", merged).Error; err != nil {
+				return fmt.Errorf("failed to update oauth consent: %w", err)
+			}
+		}
+
+		return tx.Create(&models.OAuthAuthorizationCode{
+			CodeHash:            hashSecretToken(code),
+			ClientID:            client.ID,
+			UserID:              userID,
+			RedirectURI:         req.RedirectURI, // 授权请求未提供时为空，换取令牌时也不需要提供
+			Scopes:              scopes,
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+			ExpiresAt:           time.Now().Add(oauthCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create authorization code: %w", err)
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return appendQuery(redirectURI, params), nil
+}
+
+// ===== 令牌 =====
+
+// AuthenticateClient 校验令牌端点的客户端，机密客户端必须提供正确的密钥
+func (s *OAuthService) AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if clientID == "" || s.db.Where("client_id = ? AND active = ?", clientID, true).First(&client).Error != nil {
+		return nil, newOAuthError(OAuthErrInvalidClient, "unknown or inactive client")
+	}
+
+	if client.Confidential {
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(hashSecretToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+			return nil, newOAuthError(OAuthErrInvalidClient, "invalid client credentials")
+		}
+	} else if clientSecret != "" {
+		return nil, newOAuthError(OAuthErrInvalidClient, "public clients must not send a secret")
+	}
+	return &client, nil
+}
+
+// ExchangeCode 使用授权码和PKCE校验码换取令牌，授权码被重复使用时撤销已签发的令牌
+func (s *OAuthService) ExchangeCode(client *models.OAuthClient, req *TokenRequest) (*TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "code and code_verifier are required")
+	}
+
+	var code models.OAuthAuthorizationCode
+	if err := s.db.Where("code_hash = ? AND client_id = ?", hashSecretToken(req.Code), client.ID).First(&code).Error; err != nil {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "invalid authorization code")
+	}
+
+	now := time.Now()
+	if code.UsedAt != nil {
+		if err := s.db.Model(&models.OAuthToken{}).
+			Where("authorization_id = ? AND revoked_at IS NULL", code.ID).
+			Update("revoked_at", now).Error; err != nil {
+			fmt.Printf("Warning: Failed to revoke tokens of reused authorization code %d: %v\n", code.ID, err)
+		}
+		return nil, newOAuthError(OAuthErrInvalidGrant, "authorization code has already been used")
+	}
+	if now.After(code.ExpiresAt) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "authorization code has expired")
+	}
+	// 授权请求中提供了 redirect_uri 时换取令牌必须使用相同的值（RFC 6749 4.1.3）
+	if code.RedirectURI != "" && req.RedirectURI != code.RedirectURI {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "code_verifier does not match code_challenge")
+	}
+	if err := s.checkUserActive(code.UserID); err != nil {
+		return nil, err
+	}
+
+	var response *TokenResponse
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发请求中只有一个能使用授权码
+		result := tx.Model(&code).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return newOAuthError(OAuthErrInvalidGrant, "authorization code has already been used")
+		}
+
+		var err error
+		response, err = issueOAuthToken(tx, client.ID, code.UserID, code.Scopes, &code.ID, now)
+		return err
+	})
+	if err != nil {
+		return nil, oauthServerError(err)
+	}
+	return response, nil
+}
+
+// Refresh 使用刷新令牌换取新令牌，刷新令牌每次使用后轮换
+// 已轮换的刷新令牌再次使用视为泄露，撤销该用户对此客户端的所有令牌
+func (s *OAuthService) Refresh(client *models.OAuthClient, req *TokenRequest) (*TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "refresh_token is required")
+	}
+
+	var token models.OAuthToken
+	if !strings.HasPrefix(req.RefreshToken, oauthRefreshTokenPrefix) ||
+		s.db.Where("refresh_token_hash = ? AND client_id = ?", hashSecretToken(req.RefreshToken), client.ID).First(&token).Error != nil {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "invalid refresh token")
+	}
+
+	now := time.Now()
+	if token.RefreshedAt != nil {
+		if err := s.revokeUserClientTokens(s.db, token.UserID, client.ID, now); err != nil {
+			fmt.Printf("Warning: Failed to revoke tokens after refresh token reuse: %v\n", err)
+		}
+		return nil, newOAuthError(OAuthErrInvalidGrant, "refresh token has already been used")
+	}
+	if token.RevokedAt != nil || now.After(token.RefreshExpiresAt) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "refresh token has been revoked or expired")
+	}
+
+	scopes := token.Scopes
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		var err error
+		if scopes, err = normalizeOAuthScopes(requested, token.Scopes); err != nil {
+			return nil, newOAuthError(OAuthErrInvalidScope, err.Error())
+		}
+	}
+	// 客户端允许的范围缩小后，刷新得到的令牌也随之缩小
+	scopes = intersectScopes(scopes, client.Scopes)
+	if len(scopes) == 0 {
+		return nil, newOAuthError(OAuthErrInvalidScope, "none of the granted scopes are allowed for this client")
+	}
+	if err := s.checkUserActive(token.UserID); err != nil {
+		return nil, err
+	}
+
+	var response *TokenResponse
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&token).Where("refreshed_at IS NULL AND revoked_at IS NULL").
+			Updates(map[string]interface{}{"refreshed_at": now, "revoked_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return newOAuthError(OAuthErrInvalidGrant, "refresh token has already been used")
+		}
+
+		var err error
+		response, err = issueOAuthToken(tx, client.ID, token.UserID, scopes, token.AuthorizationID, now)
+		return err
+	})
+	if err != nil {
+		return nil, oauthServerError(err)
+	}
+	return response, nil
+}
+
+// AuthenticateAccessToken 校验访问令牌，返回令牌及其用户，并记录最近使用时间
+func (s *OAuthService) AuthenticateAccessToken(rawToken string) (*models.OAuthToken, *models.User, error) {
+	if !IsOAuthAccessToken(rawToken) {
+		return nil, nil, fmt.Errorf("invalid access token")
+	}
+
+	var token models.OAuthToken
+	if err := s.db.Where("access_token_hash = ?", hashSecretToken(rawToken)).First(&token).Error; err != nil {
+		return nil, nil, fmt.Errorf("invalid access token")
+	}
+	now := time.Now()
+	if !token.IsAccessActive(now) {
+		return nil, nil, fmt.Errorf("access token has been revoked or expired")
+	}
+
+	var client models.OAuthClient
+	if err := s.db.First(&client, token.ClientID).Error; err != nil || !client.Active {
+		return nil, nil, fmt.Errorf("oauth client is inactive")
+	}
+	var user models.User
+	if err := s.db.First(&user, token.UserID).Error; err != nil {
+		return nil, nil, fmt.Errorf("access token owner not found: %w", err)
+	}
+	if !user.Active {
+		return nil, nil, fmt.Errorf("access token owner is inactive")
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= oauthUsageWriteInterval {
+		if err := s.db.Model(&token).UpdateColumn("last_used_at", now).Error; err != nil {
+			fmt.Printf("Warning: Failed to record oauth token %d usage: %v\n", token.ID, err)
+		}
+		token.LastUsedAt = &now
+	}
+
+	return &token, &user, nil
+}
+
+// Introspect 令牌内省（RFC 7662），只能查询签发给该客户端的令牌，无效令牌返回 active=false
+func (s *OAuthService) Introspect(client *models.OAuthClient, rawToken string) map[string]interface{} {
+	inactive := map[string]interface{}{"active": false}
+
+	token, tokenType := s.findClientToken(client.ID, rawToken)
+	if token == nil {
+		return inactive
+	}
+
+	now := time.Now()
+	expiresAt := token.AccessExpiresAt
+	if tokenType == "refresh_token" {
+		if token.RevokedAt != nil || token.RefreshedAt != nil || now.After(token.RefreshExpiresAt) {
+			return inactive
+		}
+		expiresAt = token.RefreshExpiresAt
+	} else if !token.IsAccessActive(now) {
+		return inactive
+	}
+
+	var user models.User
+	if err := s.db.First(&user, token.UserID).Error; err != nil || !user.Active {
+		return inactive
+	}
+
+	return map[string]interface{}{
+		"active":          true,
+		"scope":           strings.Join(token.Scopes, " "),
+		"client_id":       client.ClientID,
+		"username":        user.Username,
+		"sub":             strconv.FormatUint(uint64(user.ID), 10),
+		"token_type":      "Bearer",
+		"token_type_hint": tokenType,
+		"exp":             expiresAt.Unix(),
+		"iat":             token.CreatedAt.Unix(),
+	}
+}
+
+// Revoke 撤销令牌（RFC 7009），访问令牌和刷新令牌都会撤销同一条记录，未知令牌直接忽略
+func (s *OAuthService) Revoke(client *models.OAuthClient, rawToken string) error {
+	token, _ := s.findClientToken(client.ID, rawToken)
+	if token == nil || token.RevokedAt != nil {
+		return nil
+	}
+	if err := s.db.Model(token).Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke oauth token: %w", err)
+	}
+	return nil
+}
+
+// ===== 用户授权 =====
+
+// ListAuthorizations 用户已授权的应用
+func (s *OAuthService) ListAuthorizations(userID uint) ([]models.OAuthConsent, error) {
+	var consents []models.OAuthConsent
+	if err := s.db.Preload("Client").Where("user_id = ?", userID).
+		Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return nil, fmt.Errorf("failed to list oauth authorizations: %w", err)
+	}
+	return consents, nil
+}
+
+// RevokeAuthorization 取消对应用的授权，撤销该应用代表用户持有的所有令牌
+func (s *OAuthService) RevokeAuthorization(userID uint, clientID string) error {
+	var client models.OAuthClient
+	if err := s.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return fmt.Errorf("oauth client not found: %w", err)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND client_id = ?", userID, client.ID).Delete(&models.OAuthConsent{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete oauth consent: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("oauth authorization not found")
+		}
+		return s.revokeUserClientTokens(tx, userID, client.ID, time.Now())
+	})
+}
+
+// CleanupExpired 删除过期或撤销超过保留期的授权码和令牌
+func (s *OAuthService) CleanupExpired() error {
+	cutoff := time.Now().Add(-oauthRetention)
+	if err := s.db.Where("expires_at < ?", cutoff).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+		return fmt.Errorf("failed to cleanup authorization codes: %w", err)
+	}
+	if err := s.db.Where("refresh_expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.OAuthToken{}).Error; err != nil {
+		return fmt.Errorf("failed to cleanup oauth tokens: %w", err)
+	}
+	return nil
+}
+
+// IsOAuthAccessToken 是否为OAuth2访问令牌格式
+func IsOAuthAccessToken(rawToken string) bool {
+	return strings.HasPrefix(rawToken, oauthAccessTokenPrefix)
+}
+
+// OAuthAllowsAPIScope 令牌的OAuth2权限范围是否允许访问需要 apiScope 的第三方API
+func OAuthAllowsAPIScope(granted []string, apiScope string) bool {
+	required, ok := oauthScopeForAPIScope[apiScope]
+	if !ok {
+		return false
+	}
+	requiredScope := findOAuthScope(required)
+	for _, name := range granted {
+		if name == required {
+			return true
+		}
+		scope := findOAuthScope(name)
+		if scope == nil || requiredScope == nil || scope.Resource != requiredScope.Resource {
+			continue
+		}
+		if containsString(oauthPermissionImplies[scope.Permission], requiredScope.Permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// findClientToken 按访问令牌或刷新令牌查找签发给客户端的令牌
+func (s *OAuthService) findClientToken(clientID uint, rawToken string) (*models.OAuthToken, string) {
+	var column, tokenType string
+	switch {
+	case strings.HasPrefix(rawToken, oauthAccessTokenPrefix):
+		column, tokenType = "access_token_hash", "access_token"
+	case strings.HasPrefix(rawToken, oauthRefreshTokenPrefix):
+		column, tokenType = "refresh_token_hash", "refresh_token"
+	default:
+		return nil, ""
+	}
+
+	var token models.OAuthToken
+	if err := s.db.Where(column+" = ? AND client_id = ?", hashSecretToken(rawToken), clientID).First(&token).Error; err != nil {
+		return nil, ""
+	}
+	return &token, tokenType
+}
+
+// checkUserActive 停用的用户不能获取新令牌
+func (s *OAuthService) checkUserActive(userID uint) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil || !user.Active {
+		return newOAuthError(OAuthErrInvalidGrant, "the user is inactive")
+	}
+	return nil
+}
+
+// revokeUserClientTokens 撤销用户对客户端的所有令牌
+func (s *OAuthService) revokeUserClientTokens(db *gorm.DB, userID, clientID uint, now time.Time) error {
+	if err := db.Model(&models.OAuthToken{}).
+		Where("user_id = ? AND client_id = ? AND revoked_at IS NULL", userID, clientID).
+		Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("failed to revoke oauth tokens: %w", err)
+	}
+	return nil
+}
+
+// issueOAuthToken 签发访问令牌和刷新令牌
+func issueOAuthToken(tx *gorm.DB, clientID, userID uint, scopes []string, authorizationID *uint, now time.Time) (*TokenResponse, error) {
+	accessToken, err := generateSecretToken(oauthAccessTokenPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	refreshToken, err := generateSecretToken(oauthRefreshTokenPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if err := tx.Create(&models.OAuthToken{
+		ClientID:         clientID,
+		UserID:           userID,
+		AuthorizationID:  authorizationID,
+		AccessTokenHash:  hashSecretToken(accessToken),
+		RefreshTokenHash: hashSecretToken(refreshToken),
+		Scopes:           scopes,
+		AccessExpiresAt:  now.Add(oauthAccessTokenTTL),
+		RefreshExpiresAt: now.Add(oauthRefreshTokenTTL),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save oauth token: %w", err)
+	}
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// verifyPKCE 校验 S256 方式的PKCE：BASE64URL(SHA256(code_verifier)) == code_challenge
+func verifyPKCE(verifier, challenge string) bool {
+	if !pkceVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// normalizeOAuthScopes 检查权限范围并去重，allowed 不为nil时不能超出它的范围
+func normalizeOAuthScopes(scopes []string, allowed []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if findOAuthScope(scope) == nil {
+			return nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+		if allowed != nil && !containsString(allowed, scope) {
+			return nil, fmt.Errorf("scope %s is not allowed", scope)
+		}
+		if !containsString(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// normalizeRedirectURIs 回调地址必须是不含片段的绝对地址，http只允许本机地址
+func normalizeRedirectURIs(uris []string) ([]string, error) {
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("at least one redirect_uri is required")
+	}
+
+	result := make([]string, 0, len(uris))
+	for _, raw := range uris {
+		raw = strings.TrimSpace(raw)
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Fragment != "" || strings.Contains(raw, "#") {
+			return nil, fmt.Errorf("invalid redirect_uri: %s", raw)
+		}
+		switch u.Scheme {
+		case "https":
+			if u.Host == "" {
+				return nil, fmt.Errorf("invalid redirect_uri: %s", raw)
+			}
+		case "http":
+			host := u.Hostname()
+			if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+				return nil, fmt.Errorf("redirect_uri must use https unless it points to localhost: %s", raw)
+			}
+		case "javascript", "data", "file":
+			return nil, fmt.Errorf("invalid redirect_uri scheme: %s", raw)
+		}
+		if !containsString(result, raw) {
+			result = append(result, raw)
+		}
+	}
+	return result, nil
+}
+
+// validateHomepageURL 主页地址为空或http(s)地址
+func validateHomepageURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid homepage_url: %s", raw)
+	}
+	return nil
+}
+
+// OAuthErrorRedirect 带错误信息重定向回客户端的地址
+func OAuthErrorRedirect(redirectURI, state string, err error) string {
+	oauthErr, ok := err.(*OAuthError)
+	if !ok {
+		oauthErr = newOAuthError(OAuthErrServerError, err.Error())
+	}
+	params := url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return appendQuery(redirectURI, params)
+}
+
+// oauthServerError 非协议错误转换为 server_error
+func oauthServerError(err error) error {
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr
+	}
+	fmt.Printf("Warning: OAuth token request failed: %v\n", err)
+	return newOAuthError(OAuthErrServerError, "failed to issue token")
+}
+
+// appendQuery 在地址原有查询参数后追加参数
+func appendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func findOAuthScope(name string) *OAuthScope {
+	for i := range OAuthScopes {
+		if OAuthScopes[i].Name == name {
+			return &OAuthScopes[i]
+		}
+	}
+	return nil
+}
+
+func lookupOAuthScopes(names []string) []OAuthScope {
+	result := make([]OAuthScope, 0, len(names))
+	for _, name := range names {
+		if scope := findOAuthScope(name); scope != nil {
+			result = append(result, *scope)
+		}
+	}
+	return result
+}
+
+func mergeScopes(a, b []string) []string {
+	result := append([]string{}, a...)
+	for _, scope := range b {
+		if !containsString(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+func intersectScopes(scopes, allowed []string) []string {
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if containsString(allowed, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
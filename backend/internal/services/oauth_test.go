@@ -0,0 +1,240 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlabex/internal/models"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	rfcVerifier, rfcChallenge := testCodeVerifier, testChallenge
+	longVerifier := strings.Repeat("a", 128)
+	longSum := sha256.Sum256([]byte(longVerifier))
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "RFC 7636 appendix B", verifier: rfcVerifier, challenge: rfcChallenge, want: true},
+		{name: "wrong verifier", verifier: strings.Repeat("b", 43), challenge: rfcChallenge, want: false},
+		{name: "challenge with padding", verifier: rfcVerifier, challenge: rfcChallenge + "=", want: false},
+		{name: "plain method is not accepted", verifier: rfcVerifier, challenge: rfcVerifier, want: false},
+		{name: "verifier too short", verifier: rfcVerifier[:42], challenge: rfcChallenge, want: false},
+		{name: "verifier with invalid characters", verifier: rfcVerifier[:42] + "+", challenge: rfcChallenge, want: false},
+		{name: "maximum length verifier", verifier: longVerifier, challenge: base64.RawURLEncoding.EncodeToString(longSum[:]), want: true},
+		{name: "verifier too long", verifier: longVerifier + "a", challenge: base64.RawURLEncoding.EncodeToString(longSum[:]), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("verifyPKCE(%q, %q) = %v, want %v", tt.verifier, tt.challenge, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRedirectURIs(t *testing.T) {
+	tests := []struct {
+		name    string
+		uris    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "https", uris: []string{"https://grader.example.com/callback"}, want: []string{"https://grader.example.com/callback"}},
+		{name: "trims and deduplicates", uris: []string{" https://a.example.com/cb ", "https://a.example.com/cb"}, want: []string{"https://a.example.com/cb"}},
+		{name: "http localhost", uris: []string{"http://localhost:8000/cb"}, want: []string{"http://localhost:8000/cb"}},
+		{name: "http loopback ipv4", uris: []string{"http://127.0.0.1:8000/cb"}, want: []string{"http://127.0.0.1:8000/cb"}},
+		{name: "http loopback ipv6", uris: []string{"http://[::1]:8000/cb"}, want: []string{"http://[::1]:8000/cb"}},
+		{name: "native app scheme", uris: []string{"com.example.app:/oauth"}, want: []string{"com.example.app:/oauth"}},
+		{name: "http non-localhost", uris: []string{"http://grader.example.com/callback"}, wantErr: "must use https"},
+		{name: "http localhost lookalike", uris: []string{"http://localhost.example.com/cb"}, wantErr: "must use https"},
+		{name: "fragment", uris: []string{"https://a.example.com/cb#token"}, wantErr: "invalid redirect_uri"},
+		{name: "relative", uris: []string{"/callback"}, wantErr: "invalid redirect_uri"},
+		{name: "https without host", uris: []string{"https:///cb"}, wantErr: "invalid redirect_uri"},
+		{name: "javascript scheme", uris: []string{"javascript:alert(1)"}, wantErr: "invalid redirect_uri scheme"},
+		{name: "empty", uris: nil, wantErr: "at least one redirect_uri"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeRedirectURIs(tt.uris)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("normalizeRedirectURIs(%v) error = %v, want %q", tt.uris, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeRedirectURIs(%v) error = %v", tt.uris, err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("normalizeRedirectURIs(%v) = %v, want %v", tt.uris, got, tt.want)
+			}
+		})
+	}
+}
+
+// oauthFixture 授权码测试数据：一个只注册了一个回调地址的公开客户端
+type oauthFixture struct {
+	service *OAuthService
+	user    models.User
+	client  models.OAuthClient
+}
+
+// PKCE 使用 RFC 7636 附录B的示例
+const (
+	testRedirectURI  = "https://grader.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge    = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func seedOAuthFixture(t *testing.T) *oauthFixture {
+	t.Helper()
+
+	db := openTestDB(t)
+	suffix := time.Now().UnixNano()
+	user := models.User{
+		GitLabID: -int(suffix % 1000000000),
+		Username: fmt.Sprintf("oauth_%d", suffix),
+		Email:    fmt.Sprintf("oauth_%d@example.com", suffix),
+		Name:     "oauth",
+		Role:     3,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	client := models.OAuthClient{
+		ClientID:     fmt.Sprintf("glc_test_%d", suffix),
+		Name:         "grader",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{"profile"},
+		OwnerID:      user.ID,
+		Active:       true,
+	}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return &oauthFixture{service: NewOAuthService(db), user: user, client: client}
+}
+
+// authorize 用户同意授权并返回授权码
+func (f *oauthFixture) authorize(t *testing.T, redirectURI string) string {
+	t.Helper()
+
+	redirectTo, err := f.service.Authorize(f.user.ID, &AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            f.client.ClientID,
+		RedirectURI:         redirectURI,
+		Scope:               "profile",
+		CodeChallenge:       testChallenge,
+		CodeChallengeMethod: "S256",
+	}, true)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	u, err := url.Parse(redirectTo)
+	if err != nil || u.Query().Get("code") == "" {
+		t.Fatalf("Authorize() redirect = %q, want a code", redirectTo)
+	}
+	return u.Query().Get("code")
+}
+
+// wantOAuthError 检查返回的OAuth错误码
+func wantOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Fatalf("error = %v, want %s", err, code)
+	}
+}
+
+func TestExchangeCodeRedirectURI(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorizeURI  string // 授权请求中的 redirect_uri
+		exchangeURI   string // 换取令牌时的 redirect_uri
+		wantErrorCode string
+	}{
+		{name: "explicit and matching", authorizeURI: testRedirectURI, exchangeURI: testRedirectURI},
+		{name: "explicit but omitted on exchange", authorizeURI: testRedirectURI, exchangeURI: "", wantErrorCode: OAuthErrInvalidGrant},
+		{name: "explicit but different on exchange", authorizeURI: testRedirectURI, exchangeURI: testRedirectURI + "/other", wantErrorCode: OAuthErrInvalidGrant},
+		{name: "omitted on both", authorizeURI: "", exchangeURI: ""},
+		{name: "omitted on authorize, registered uri on exchange", authorizeURI: "", exchangeURI: testRedirectURI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := seedOAuthFixture(t)
+			code := f.authorize(t, tt.authorizeURI)
+
+			response, err := f.service.ExchangeCode(&f.client, &TokenRequest{
+				GrantType:    "authorization_code",
+				Code:         code,
+				RedirectURI:  tt.exchangeURI,
+				CodeVerifier: testCodeVerifier,
+			})
+			if tt.wantErrorCode != "" {
+				wantOAuthError(t, err, tt.wantErrorCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExchangeCode() error = %v", err)
+			}
+			if response.AccessToken == "" || response.RefreshToken == "" {
+				t.Errorf("ExchangeCode() = %+v, want tokens", response)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeRejectsWrongVerifier(t *testing.T) {
+	f := seedOAuthFixture(t)
+	code := f.authorize(t, testRedirectURI)
+
+	_, err := f.service.ExchangeCode(&f.client, &TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: strings.Repeat("x", 43),
+	})
+	wantOAuthError(t, err, OAuthErrInvalidGrant)
+}
+
+func TestExchangeCodeReuseRevokesTokens(t *testing.T) {
+	f := seedOAuthFixture(t)
+	code := f.authorize(t, testRedirectURI)
+	req := &TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	}
+
+	first, err := f.service.ExchangeCode(&f.client, req)
+	if err != nil {
+		t.Fatalf("first ExchangeCode() error = %v", err)
+	}
+
+	_, err = f.service.ExchangeCode(&f.client, req)
+	wantOAuthError(t, err, OAuthErrInvalidGrant)
+
+	// 授权码被重复使用时，第一次换取的令牌也被撤销
+	var token models.OAuthToken
+	if err := f.service.db.Where("access_token_hash = ?", hashSecretToken(first.AccessToken)).First(&token).Error; err != nil {
+		t.Fatalf("failed to load token: %v", err)
+	}
+	if token.RevokedAt == nil {
+		t.Errorf("token issued from a reused code should be revoked")
+	}
+}
@@ -18,6 +18,8 @@ type ProjectService struct {
 	db                *gorm.DB
 	permissionService *PermissionService
 	gitlabService     *GitLabService
+	autogradeService  *AutogradeService
+	webhookService    *WebhookService
 }
 
 // NewProjectService 创建课题管理服务
@@ -29,6 +31,16 @@ func NewProjectService(db *gorm.DB, permissionService *PermissionService, gitlab
 	}
 }
 
+// SetAutogradeService 设置自动评测服务，用于在GitLab提交后运行测试
+func (s *ProjectService) SetAutogradeService(autogradeService *AutogradeService) {
+	s.autogradeService = autogradeService
+}
+
+// SetWebhookService 设置Webhook服务，用于向第三方推送课题成员加入事件
+func (s *ProjectService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
 // CreateProjectRequest 创建课题请求
 type CreateProjectRequest struct {
 	Name        string         `json:"name" binding:"required"`
@@ -480,6 +492,10 @@ func (s *ProjectService) AddStudentToProject(projectID uint, studentID uint, rol
 		return fmt.Errorf("failed to create project member: %w", err)
 	}
 
+	if s.webhookService != nil {
+		s.webhookService.EmitProjectMemberAdded(&project, member, &student)
+	}
+
 	return nil
 }
 
@@ -554,7 +570,7 @@ func (s *ProjectService) GetProjectStats(projectID uint) (*models.ProjectStats,
 	var completedCount int64
 	if err := s.db.Table("assignment_submissions").
 		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
-		Where("assignments.project_id = ? AND assignment_submissions.status = ?", projectID, "graded").
+		Where("assignments.project_id = ? AND assignment_submissions.status = ? AND assignment_submissions.is_selected = true", projectID, "graded").
 		Count(&completedCount).Error; err != nil {
 		return nil, fmt.Errorf("failed to count completed assignments: %w", err)
 	}
@@ -638,10 +654,26 @@ func (s *ProjectService) SubmitAssignmentToGitLab(projectID uint, studentID uint
 
 	// 获取学生的项目成员信息
 	var member models.ProjectMember
-	if err := s.db.Where("project_id = ? AND student_id = ?", projectID, studentID).First(&member).Error; err != nil {
+	if err := s.db.Where("project_id = ? AND user_id = ? AND is_active = true", projectID, studentID).First(&member).Error; err != nil {
 		return nil, fmt.Errorf("student not in project: %w", err)
 	}
 
+	// 检查提交次数
+	var assignment models.Assignment
+	if err := s.db.Where("id = ? AND project_id = ?", assignmentID, projectID).First(&assignment).Error; err != nil {
+		return nil, fmt.Errorf("assignment not found: %w", err)
+	}
+	attemptNumber, selectAttempt, err := nextSubmissionAttempt(s.db, &assignment, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查必交文件、文件类型和大小
+	report, err := checkSubmission(s.gitlabService, &assignment, project.GitLabProjectID, member.PersonalBranch, files)
+	if err != nil {
+		return nil, err
+	}
+
 	// 构建提交消息
 	commitMessage := fmt.Sprintf("Submit assignment %d by %s", assignmentID, student.Username)
 
@@ -663,6 +695,9 @@ func (s *ProjectService) SubmitAssignmentToGitLab(projectID uint, studentID uint
 		CommitURL:     fmt.Sprintf("%s/-/commit/%s", project.GitLabURL, commitHash),
 		BranchName:    member.PersonalBranch,
 		BranchURL:     member.PersonalBranchURL,
+		AttemptNumber: attemptNumber,
+		// 跟踪该版本的CI流水线
+		PipelineStatus: models.PipelineStatusWaiting,
 	}
 
 	// 构建文件列表
@@ -671,8 +706,9 @@ func (s *ProjectService) SubmitAssignmentToGitLab(projectID uint, studentID uint
 		fileList = append(fileList, filePath)
 	}
 	submission.FilesSubmitted = fileList
+	applySubmissionCheck(submission, report)
 
-	if err := s.db.Create(submission).Error; err != nil {
+	if err := createSubmissionAttempt(s.db, submission, selectAttempt); err != nil {
 		return nil, fmt.Errorf("failed to create submission record: %w", err)
 	}
 
@@ -681,15 +717,21 @@ func (s *ProjectService) SubmitAssignmentToGitLab(projectID uint, studentID uint
 	member.LastCommitMessage = commitMessage
 	now := time.Now()
 	member.LastCommitTime = &now
+	member.LastActiveTime = &now
+	member.CommitCount++
 	if err := s.db.Save(&member).Error; err != nil {
 		return nil, fmt.Errorf("failed to update member commit info: %w", err)
 	}
 
+	if s.autogradeService != nil {
+		s.autogradeService.EnqueueSubmissionQuietly(submission.ID)
+	}
+
 	return submission, nil
 }
 
-// GetProjectGitLabInfo 获取课题GitLab信息
-func (s *ProjectService) GetProjectGitLabInfo(projectID uint) (*GitLabProjectInfo, error) {
+// GetLinkedProject 获取已关联GitLab仓库的课题
+func (s *ProjectService) GetLinkedProject(projectID uint) (*models.Project, error) {
 	var project models.Project
 	if err := s.db.First(&project, projectID).Error; err != nil {
 		return nil, fmt.Errorf("project not found: %w", err)
@@ -699,6 +741,16 @@ func (s *ProjectService) GetProjectGitLabInfo(projectID uint) (*GitLabProjectInf
 		return nil, fmt.Errorf("project not linked to GitLab")
 	}
 
+	return &project, nil
+}
+
+// GetProjectGitLabInfo 获取课题GitLab信息
+func (s *ProjectService) GetProjectGitLabInfo(projectID uint) (*GitLabProjectInfo, error) {
+	project, err := s.GetLinkedProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取GitLab统计信息
 	stats, err := s.gitlabService.GetProjectStatistics(project.GitLabProjectID)
 	if err != nil {
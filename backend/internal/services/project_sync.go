@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// SyncProjectMembersFromGitLab 核对进行中课题的GitLab成员：更新成员的访问级别，
+// 重新添加在GitLab中被移除的学生，单个课题失败不影响其他课题
+func (s *ProjectService) SyncProjectMembersFromGitLab() error {
+	var projects []models.Project
+	if err := s.db.Where("gitlab_project_id > 0 AND status = ?", "active").Find(&projects).Error; err != nil {
+		return fmt.Errorf("failed to get projects: %w", err)
+	}
+
+	failed := 0
+	for i := range projects {
+		if err := s.syncProjectMembers(&projects[i]); err != nil {
+			fmt.Printf("Warning: Failed to sync GitLab members of project %d: %v\n", projects[i].ID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to sync members of %d of %d projects", failed, len(projects))
+	}
+	return nil
+}
+
+// syncProjectMembers 核对单个课题的GitLab成员
+func (s *ProjectService) syncProjectMembers(project *models.Project) error {
+	gitlabMembers, err := s.gitlabService.ListProjectMembers(project.GitLabProjectID)
+	if err != nil {
+		return err
+	}
+	accessLevels := make(map[int]gitlab.AccessLevelValue, len(gitlabMembers))
+	for _, member := range gitlabMembers {
+		accessLevels[member.ID] = member.AccessLevel
+	}
+
+	var members []models.ProjectMember
+	if err := s.db.Preload("User").
+		Where("project_id = ? AND is_active = ? AND role <> ?", project.ID, true, "teacher").
+		Find(&members).Error; err != nil {
+		return fmt.Errorf("failed to get project members: %w", err)
+	}
+
+	for i := range members {
+		member := &members[i]
+		if member.User.GitLabID == 0 {
+			continue
+		}
+
+		accessLevel, ok := accessLevels[member.User.GitLabID]
+		if !ok {
+			// 与 AddStudentToProject 保持一致：组长为Maintainer，其他学生为Developer
+			accessLevel = gitlab.DeveloperPermissions
+			if member.Role == "leader" {
+				accessLevel = gitlab.MaintainerPermissions
+			}
+			if err := s.gitlabService.AddStudentToProject(project.GitLabProjectID, member.User.GitLabID, accessLevel); err != nil {
+				fmt.Printf("Warning: Failed to re-add member %d to GitLab project %d: %v\n", member.ID, project.GitLabProjectID, err)
+				continue
+			}
+		}
+
+		if int(accessLevel) != member.GitLabAccessLevel {
+			if err := s.db.Model(member).Update("gitlab_access_level", int(accessLevel)).Error; err != nil {
+				return fmt.Errorf("failed to update member access level: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RefreshProjectStats 重新计算所有课题的作业总数和已截止的作业数
+func (s *ProjectService) RefreshProjectStats() error {
+	now := time.Now()
+	result := s.db.Exec(`
+		UPDATE projects SET
+			total_assignments = (
+				SELECT COUNT(*) FROM assignments
+				WHERE assignments.project_id = projects.id
+			),
+			completed_assignments = (
+				SELECT COUNT(*) FROM assignments
+				WHERE assignments.project_id = projects.id
+					AND (assignments.status = 'completed' OR assignments.due_date < ?)
+			)`, now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to refresh project stats: %w", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 支持的报表导出格式
+const (
+	ReportFormatCSV  = "csv"
+	ReportFormatXLSX = "xlsx"
+	ReportFormatPDF  = "pdf"
+)
+
+// 导出任务超时：创建后长时间未开始的任务重新执行，生成中长时间未完成的任务视为中断
+const (
+	exportPendingTimeout    = 2 * time.Minute
+	exportProcessingTimeout = 30 * time.Minute
+)
+
+// reportTable 报表中的一个表格，供各导出格式统一渲染
+type reportTable struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+}
+
+// NormalizeReportFormat 规范化导出格式，excel 视为 xlsx
+func NormalizeReportFormat(format string) (string, error) {
+	switch format {
+	case ReportFormatCSV:
+		return ReportFormatCSV, nil
+	case ReportFormatXLSX, "excel", "xls":
+		return ReportFormatXLSX, nil
+	case ReportFormatPDF:
+		return ReportFormatPDF, nil
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// CreateExport 创建报表导出任务，报表在后台生成
+func (s *EducationReportService) CreateExport(userID uint, format string, filter ReportFilter) (*models.ReportExport, error) {
+	format, err := NormalizeReportFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reportRangeStart(filter.TimeRange, time.Now()); err != nil {
+		return nil, err
+	}
+	if _, err := s.projectScope(userID); err != nil {
+		return nil, err
+	}
+
+	export := &models.ReportExport{
+		UserID:    userID,
+		Format:    format,
+		TimeRange: filter.TimeRange,
+		ClassID:   filter.ClassID,
+		Status:    models.ReportExportStatusPending,
+	}
+	if err := s.db.Create(export).Error; err != nil {
+		return nil, fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	go s.runExport(export.ID)
+
+	return export, nil
+}
+
+// GetExport 获取导出任务（只能查看自己的导出任务）
+func (s *EducationReportService) GetExport(userID, exportID uint) (*models.ReportExport, error) {
+	var export models.ReportExport
+	if err := s.db.Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error; err != nil {
+		return nil, fmt.Errorf("export not found: %w", err)
+	}
+
+	// 过期的文件不再提供下载
+	if export.Status == models.ReportExportStatusCompleted && export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt) {
+		s.expireExport(&export)
+	}
+
+	return &export, nil
+}
+
+// GetExportFile 获取可下载的导出文件路径
+func (s *EducationReportService) GetExportFile(userID, exportID uint) (*models.ReportExport, error) {
+	export, err := s.GetExport(userID, exportID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch export.Status {
+	case models.ReportExportStatusCompleted:
+		return export, nil
+	case models.ReportExportStatusExpired:
+		return nil, fmt.Errorf("export has expired")
+	case models.ReportExportStatusFailed:
+		return nil, fmt.Errorf("export failed: %s", export.Error)
+	default:
+		return nil, fmt.Errorf("export is not ready yet")
+	}
+}
+
+// CleanupExpiredExports 清理过期的导出文件
+func (s *EducationReportService) CleanupExpiredExports() error {
+	var exports []models.ReportExport
+	if err := s.db.Where("status = ? AND expires_at < ?", models.ReportExportStatusCompleted, time.Now()).
+		Find(&exports).Error; err != nil {
+		return fmt.Errorf("failed to get expired exports: %w", err)
+	}
+
+	for i := range exports {
+		s.expireExport(&exports[i])
+	}
+
+	return nil
+}
+
+// ProcessStaleExports 处理服务重启等原因遗留的导出任务：重新执行未开始的任务，标记中断的任务为失败，并清理过期文件
+func (s *EducationReportService) ProcessStaleExports() error {
+	now := time.Now()
+
+	var pendingIDs []uint
+	if err := s.db.Model(&models.ReportExport{}).
+		Where("status = ? AND updated_at < ?", models.ReportExportStatusPending, now.Add(-exportPendingTimeout)).
+		Order("id").Pluck("id", &pendingIDs).Error; err != nil {
+		return fmt.Errorf("failed to get pending exports: %w", err)
+	}
+	for _, id := range pendingIDs {
+		s.runExport(id)
+	}
+
+	if err := s.db.Model(&models.ReportExport{}).
+		Where("status = ? AND updated_at < ?", models.ReportExportStatusProcessing, now.Add(-exportProcessingTimeout)).
+		Updates(map[string]interface{}{
+			"status": models.ReportExportStatusFailed,
+			"error":  "export was interrupted, please export again",
+		}).Error; err != nil {
+		return fmt.Errorf("failed to fail interrupted exports: %w", err)
+	}
+
+	return s.CleanupExpiredExports()
+}
+
+// expireExport 删除导出文件并标记为过期
+func (s *EducationReportService) expireExport(export *models.ReportExport) {
+	if export.FilePath != "" {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Failed to remove expired export file %s: %v\n", export.FilePath, err)
+		}
+	}
+
+	export.Status = models.ReportExportStatusExpired
+	if err := s.db.Model(export).Update("status", models.ReportExportStatusExpired).Error; err != nil {
+		fmt.Printf("Warning: Failed to mark export %d as expired: %v\n", export.ID, err)
+	}
+}
+
+// runExport 生成导出文件并更新任务状态
+func (s *EducationReportService) runExport(exportID uint) {
+	var export models.ReportExport
+	if err := s.db.First(&export, exportID).Error; err != nil {
+		fmt.Printf("Warning: Export job %d not found: %v\n", exportID, err)
+		return
+	}
+
+	// 条件更新保证同一任务只被执行一次（创建后的后台任务和定时任务可能同时执行）
+	result := s.db.Model(&export).Where("status = ?", models.ReportExportStatusPending).
+		Update("status", models.ReportExportStatusProcessing)
+	if result.Error != nil {
+		fmt.Printf("Warning: Failed to start export %d: %v\n", export.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	fileName, filePath, size, err := s.generateExportFile(&export)
+	if err != nil {
+		fmt.Printf("Warning: Failed to generate export %d: %v\n", export.ID, err)
+		s.db.Model(&export).Updates(map[string]interface{}{
+			"status": models.ReportExportStatusFailed,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.exportTTL)
+	if err := s.db.Model(&export).Updates(map[string]interface{}{
+		"status":       models.ReportExportStatusCompleted,
+		"file_name":    fileName,
+		"file_path":    filePath,
+		"file_size":    size,
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		fmt.Printf("Warning: Failed to update export %d: %v\n", export.ID, err)
+	}
+}
+
+// generateExportFile 生成报表并写入导出目录
+func (s *EducationReportService) generateExportFile(export *models.ReportExport) (string, string, int64, error) {
+	report, err := s.GetReport(export.UserID, ReportFilter{TimeRange: export.TimeRange, ClassID: export.ClassID})
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	tables := reportTables(report)
+
+	var content []byte
+	switch export.Format {
+	case ReportFormatCSV:
+		content, err = renderReportCSV(tables)
+	case ReportFormatXLSX:
+		content, err = renderReportXLSX(tables)
+	case ReportFormatPDF:
+		content, err = renderReportPDF("教育报表", tables)
+	default:
+		err = fmt.Errorf("unsupported export format: %s", export.Format)
+	}
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if err := os.MkdirAll(s.exportDir, 0755); err != nil {
+		return "", "", 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	fileName := fmt.Sprintf("education_report_%s.%s", report.GeneratedAt.Format("20060102_150405"), export.Format)
+	filePath := filepath.Join(s.exportDir, fmt.Sprintf("%d_%s", export.ID, fileName))
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		return "", "", 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	return fileName, filePath, int64(len(content)), nil
+}
+
+// reportTables 将报表转换为表格
+func reportTables(report *EducationReport) []reportTable {
+	rangeLabel := report.TimeRange
+	if rangeLabel == "" {
+		rangeLabel = "all"
+	}
+
+	tables := []reportTable{
+		{
+			Title:  "概览",
+			Header: []string{"指标", "数值"},
+			Rows: [][]string{
+				{"统计区间", rangeLabel},
+				{"生成时间", report.GeneratedAt.Format("2006-01-02 15:04:05")},
+				{"学生总数", strconv.Itoa(report.Overview.TotalStudents)},
+				{"作业总数", strconv.Itoa(report.Overview.TotalAssignments)},
+				{"班级数", strconv.Itoa(report.Overview.TotalProjects)},
+				{"平均完成率(%)", formatReportFloat(report.Overview.AverageCompletion)},
+			},
+		},
+	}
+
+	classTable := reportTable{Title: "班级活跃度", Header: []string{"班级", "活跃学生", "学生总数", "完成率(%)"}}
+	for _, class := range report.ClassActivity {
+		classTable.Rows = append(classTable.Rows, []string{
+			class.ClassName,
+			strconv.Itoa(class.ActiveStudents),
+			strconv.Itoa(class.TotalStudents),
+			formatReportFloat(class.CompletionRate),
+		})
+	}
+
+	assignmentTable := reportTable{Title: "作业统计", Header: []string{"作业", "班级", "截止时间", "已提交", "应提交", "按时", "逾期"}}
+	for _, stat := range report.AssignmentStats {
+		assignmentTable.Rows = append(assignmentTable.Rows, []string{
+			stat.Name,
+			stat.ClassName,
+			stat.DueDate.Format("2006-01-02 15:04"),
+			strconv.Itoa(stat.Submitted),
+			strconv.Itoa(stat.Total),
+			strconv.Itoa(stat.OnTime),
+			strconv.Itoa(stat.Late),
+		})
+	}
+
+	trendTable := reportTable{Title: "进度趋势", Header: []string{"日期", "完成率(%)", "参与率(%)"}}
+	for _, trend := range report.ProgressTrends {
+		trendTable.Rows = append(trendTable.Rows, []string{
+			trend.Date,
+			strconv.Itoa(trend.Completion),
+			strconv.Itoa(trend.Participation),
+		})
+	}
+
+	performerTable := reportTable{Title: "优秀学生", Header: []string{"排名", "姓名", "完成率(%)", "总得分"}}
+	for i, performer := range report.TopPerformers {
+		performerTable.Rows = append(performerTable.Rows, []string{
+			strconv.Itoa(i + 1),
+			performer.Name,
+			formatReportFloat(performer.CompletionRate),
+			formatReportFloat(performer.Points),
+		})
+	}
+
+	return append(tables, classTable, assignmentTable, trendTable, performerTable)
+}
+
+// renderReportCSV 导出为CSV，各表格之间以空行分隔
+func renderReportCSV(tables []reportTable) ([]byte, error) {
+	var buf bytes.Buffer
+	// 写入UTF-8 BOM，避免Excel打开中文乱码
+	buf.WriteString("\xEF\xBB\xBF")
+
+	writer := csv.NewWriter(&buf)
+	for i, table := range tables {
+		if i > 0 {
+			writer.Write([]string{})
+		}
+		writer.Write([]string{table.Title})
+		writer.Write(table.Header)
+		writer.WriteAll(table.Rows)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderReportXLSX 导出为Excel，每个表格一个工作表
+func renderReportXLSX(tables []reportTable) ([]byte, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	for i, table := range tables {
+		sheet := table.Title
+		if i == 0 {
+			if err := file.SetSheetName(file.GetSheetName(0), sheet); err != nil {
+				return nil, fmt.Errorf("failed to rename sheet: %w", err)
+			}
+		} else if _, err := file.NewSheet(sheet); err != nil {
+			return nil, fmt.Errorf("failed to create sheet: %w", err)
+		}
+
+		if err := file.SetSheetRow(sheet, "A1", &table.Header); err != nil {
+			return nil, fmt.Errorf("failed to write sheet header: %w", err)
+		}
+		for r, row := range table.Rows {
+			cell, _ := excelize.CoordinatesToCellName(1, r+2)
+			values := make([]interface{}, len(row))
+			for c, value := range row {
+				// 数值列按数字写入，便于在Excel中计算
+				if number, err := strconv.ParseFloat(value, 64); err == nil {
+					values[c] = number
+				} else {
+					values[c] = value
+				}
+			}
+			if err := file.SetSheetRow(sheet, cell, &values); err != nil {
+				return nil, fmt.Errorf("failed to write sheet row: %w", err)
+			}
+		}
+	}
+
+	buf, err := file.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write xlsx: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// formatReportFloat 格式化报表中的小数
+func formatReportFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PDF页面布局（A4，单位pt）
+const (
+	pdfPageWidth    = 595.0
+	pdfPageHeight   = 842.0
+	pdfMargin       = 40.0
+	pdfTitleSize    = 16.0
+	pdfHeadingSize  = 12.0
+	pdfTextSize     = 9.0
+	pdfLineSpacing  = 1.6
+	pdfSectionSpace = 12.0
+)
+
+// pdfText 页面上的一段文本
+type pdfText struct {
+	X, Y float64
+	Size float64
+	Text string
+}
+
+// pdfWriter 简单的PDF文本排版
+// 使用PDF阅读器内置的 STSong-Light 中文字体，无需嵌入字体文件
+type pdfWriter struct {
+	pages [][]pdfText
+	y     float64
+}
+
+// renderReportPDF 导出为PDF，各表格依次排版
+func renderReportPDF(title string, tables []reportTable) ([]byte, error) {
+	w := &pdfWriter{}
+	w.newPage()
+
+	w.writeLine([]string{title}, []float64{pdfMargin}, pdfTitleSize)
+	for _, table := range tables {
+		w.y -= pdfSectionSpace
+		w.writeLine([]string{table.Title}, []float64{pdfMargin}, pdfHeadingSize)
+
+		columns := pdfColumns(len(table.Header))
+		w.writeLine(table.Header, columns, pdfTextSize)
+		for _, row := range table.Rows {
+			w.writeLine(row, columns, pdfTextSize)
+		}
+	}
+
+	return w.bytes(), nil
+}
+
+// pdfColumns 将页面宽度平均分配给各列，返回每列的起始横坐标
+func pdfColumns(count int) []float64 {
+	if count == 0 {
+		return nil
+	}
+	width := (pdfPageWidth - 2*pdfMargin) / float64(count)
+	columns := make([]float64, count)
+	for i := range columns {
+		columns[i] = pdfMargin + float64(i)*width
+	}
+	return columns
+}
+
+// newPage 开始新的一页
+func (w *pdfWriter) newPage() {
+	w.pages = append(w.pages, nil)
+	w.y = pdfPageHeight - pdfMargin
+}
+
+// writeLine 写入一行文本，超出页面底部时自动换页，超出列宽的文本被截断
+func (w *pdfWriter) writeLine(cells []string, columns []float64, size float64) {
+	lineHeight := size * pdfLineSpacing
+	if w.y-lineHeight < pdfMargin {
+		w.newPage()
+	}
+	w.y -= lineHeight
+
+	page := len(w.pages) - 1
+	for i, cell := range cells {
+		if i >= len(columns) {
+			break
+		}
+		maxWidth := pdfPageWidth - pdfMargin - columns[i]
+		if i+1 < len(columns) {
+			maxWidth = columns[i+1] - columns[i] - size/2
+		}
+		w.pages[page] = append(w.pages[page], pdfText{
+			X:    columns[i],
+			Y:    w.y,
+			Size: size,
+			Text: truncatePDFText(cell, maxWidth, size),
+		})
+	}
+}
+
+// bytes 生成PDF文件内容
+func (w *pdfWriter) bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// 对象编号：1 目录，2 页面树，3-5 字体，之后每页依次为页面对象和内容流
+	const firstPageObject = 6
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+i*2)
+	}
+
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	writeObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>")
+	writeObject("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> " +
+		"/FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	writeObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	for i, texts := range w.pages {
+		var content bytes.Buffer
+		for _, text := range texts {
+			fmt.Fprintf(&content, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", text.Size, text.X, text.Y, encodePDFText(text.Text))
+		}
+
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, firstPageObject+i*2+1))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// encodePDFText 按 UCS-2 编码为十六进制字符串，超出基本平面的字符替换为问号
+func encodePDFText(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	return sb.String()
+}
+
+// pdfTextWidth 估算文本宽度：ASCII字符半角，其余字符全角
+func pdfTextWidth(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			width += size / 2
+		} else {
+			width += size
+		}
+	}
+	return width
+}
+
+// truncatePDFText 截断超出宽度的文本
+func truncatePDFText(text string, maxWidth, size float64) string {
+	if pdfTextWidth(text, size) <= maxWidth {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"…", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
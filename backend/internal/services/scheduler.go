@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"gitlabex/internal/models"
+	"gitlabex/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// schedulerLockKey 调度器选主使用的Postgres advisory lock键，多个副本中只有持有锁的实例按计划运行任务
+const schedulerLockKey int64 = 0x6769746c6162 // "gitlab"
+
+// staleJobRunTimeout 超过该时长仍处于运行中的记录视为实例退出时中断的运行
+const staleJobRunTimeout = 6 * time.Hour
+
+// JobFunc 定时任务逻辑
+type JobFunc func() error
+
+// registeredJob 代码中注册的定时任务
+type registeredJob struct {
+	name        string
+	description string
+	schedule    string // 默认执行计划，首次启动时写入数据库
+	run         JobFunc
+}
+
+// SchedulerService 定时任务调度服务
+type SchedulerService struct {
+	db                *gorm.DB
+	permissionService *PermissionService
+	pollInterval      time.Duration
+
+	mu         sync.Mutex
+	jobs       map[string]*registeredJob
+	running    map[string]bool
+	leaderConn *sql.Conn
+}
+
+// NewSchedulerService 创建定时任务调度服务
+func NewSchedulerService(db *gorm.DB, permissionService *PermissionService, pollInterval time.Duration) *SchedulerService {
+	if pollInterval <= 0 {
+		pollInterval = 30 * time.Second
+	}
+	return &SchedulerService{
+		db:                db,
+		permissionService: permissionService,
+		pollInterval:      pollInterval,
+		jobs:              make(map[string]*registeredJob),
+		running:           make(map[string]bool),
+	}
+}
+
+// ScheduledJobInfo 定时任务及最近的运行记录
+type ScheduledJobInfo struct {
+	models.ScheduledJob
+	Registered bool           `json:"registered"` // 当前版本的代码是否包含该任务
+	Running    bool           `json:"running"`    // 当前实例是否正在运行
+	LastRun    *models.JobRun `json:"last_run,omitempty"`
+}
+
+// UpdateScheduledJobRequest 更新定时任务请求
+type UpdateScheduledJobRequest struct {
+	Schedule *string `json:"schedule"`
+	Enabled  *bool   `json:"enabled"`
+}
+
+// RegisterJob 注册定时任务，需在 Start 之前调用
+func (s *SchedulerService) RegisterJob(name, description, schedule string, run JobFunc) {
+	if _, err := utils.ParseCronSchedule(schedule); err != nil {
+		panic(fmt.Sprintf("invalid schedule of job %s: %v", name, err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = &registeredJob{
+		name:        name,
+		description: description,
+		schedule:    schedule,
+		run:         run,
+	}
+}
+
+// Start 保存任务定义并启动调度循环
+func (s *SchedulerService) Start() {
+	for _, job := range s.jobs {
+		if err := s.ensureJobDefinition(job); err != nil {
+			fmt.Printf("Warning: Failed to save scheduled job %s: %v\n", job.name, err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if s.ensureLeader() {
+				s.runDueJobs()
+			}
+		}
+	}()
+}
+
+// ensureJobDefinition 首次注册时创建任务定义，已存在时保留管理员修改过的执行计划和启用状态
+func (s *SchedulerService) ensureJobDefinition(job *registeredJob) error {
+	var existing models.ScheduledJob
+	err := s.db.Where("name = ?", job.name).First(&existing).Error
+	if err == nil {
+		if existing.Description != job.description {
+			return s.db.Model(&existing).Update("description", job.description).Error
+		}
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	schedule, _ := utils.ParseCronSchedule(job.schedule)
+	next := schedule.Next(time.Now())
+	return s.db.Create(&models.ScheduledJob{
+		Name:        job.name,
+		Description: job.description,
+		Schedule:    job.schedule,
+		Enabled:     true,
+		NextRunAt:   &next,
+	}).Error
+}
+
+// ensureLeader 尝试成为调度主实例；advisory lock 绑定在数据库连接上，连接断开时锁自动释放
+func (s *SchedulerService) ensureLeader() bool {
+	ctx := context.Background()
+	if s.leaderConn != nil {
+		if err := s.leaderConn.PingContext(ctx); err == nil {
+			return true
+		}
+		fmt.Println("Warning: Scheduler lost leadership, database connection closed")
+		s.leaderConn.Close()
+		s.leaderConn = nil
+	}
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return false
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		fmt.Printf("Warning: Scheduler failed to get database connection: %v\n", err)
+		return false
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", schedulerLockKey).Scan(&locked); err != nil || !locked {
+		if err != nil {
+			fmt.Printf("Warning: Scheduler failed to acquire lock: %v\n", err)
+		}
+		conn.Close()
+		return false
+	}
+
+	s.leaderConn = conn
+	fmt.Println("Scheduler acquired leadership")
+	s.failStaleRuns()
+	return true
+}
+
+// failStaleRuns 将长时间未结束的运行记录标记为失败
+func (s *SchedulerService) failStaleRuns() {
+	now := time.Now()
+	if err := s.db.Model(&models.JobRun{}).
+		Where("status = ? AND started_at < ?", models.JobRunStatusRunning, now.Add(-staleJobRunTimeout)).
+		Updates(map[string]interface{}{
+			"status":      models.JobRunStatusFailed,
+			"error":       "interrupted",
+			"finished_at": now,
+		}).Error; err != nil {
+		fmt.Printf("Warning: Failed to mark stale job runs: %v\n", err)
+	}
+}
+
+// runDueJobs 运行所有到期的任务
+func (s *SchedulerService) runDueJobs() {
+	now := time.Now()
+	var due []models.ScheduledJob
+	if err := s.db.Where("enabled = ? AND next_run_at <= ?", true, now).Find(&due).Error; err != nil {
+		fmt.Printf("Warning: Failed to load due jobs: %v\n", err)
+		return
+	}
+
+	for i := range due {
+		job := &due[i]
+		schedule, err := utils.ParseCronSchedule(job.Schedule)
+		if err != nil {
+			fmt.Printf("Warning: Invalid schedule of job %s: %v\n", job.Name, err)
+			continue
+		}
+
+		// 先推进下次运行时间，错过的多次运行只补一次
+		next := schedule.Next(now)
+		if err := s.db.Model(job).Update("next_run_at", next).Error; err != nil {
+			fmt.Printf("Warning: Failed to update next run of job %s: %v\n", job.Name, err)
+			continue
+		}
+
+		run, err := s.startRun(job.Name, 0)
+		if err != nil {
+			fmt.Printf("Warning: Failed to start job %s: %v\n", job.Name, err)
+			continue
+		}
+		go s.finishRun(job.Name, run)
+	}
+}
+
+// startRun 创建运行记录，同一任务在本实例上不会同时运行
+func (s *SchedulerService) startRun(name string, triggeredBy uint) (*models.JobRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[name]; !ok {
+		return nil, fmt.Errorf("job %s is not registered", name)
+	}
+	if s.running[name] {
+		return nil, fmt.Errorf("job %s is already running", name)
+	}
+
+	now := time.Now()
+	run := &models.JobRun{
+		JobName:     name,
+		Status:      models.JobRunStatusRunning,
+		TriggeredBy: triggeredBy,
+		StartedAt:   now,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to create job run: %w", err)
+	}
+	if err := s.db.Model(&models.ScheduledJob{}).Where("name = ?", name).Updates(map[string]interface{}{
+		"last_run_at": now,
+		"last_status": models.JobRunStatusRunning,
+	}).Error; err != nil {
+		fmt.Printf("Warning: Failed to update job %s: %v\n", name, err)
+	}
+
+	s.running[name] = true
+	return run, nil
+}
+
+// finishRun 执行任务并保存结果
+func (s *SchedulerService) finishRun(name string, run *models.JobRun) {
+	s.mu.Lock()
+	job := s.jobs[name]
+	s.mu.Unlock()
+
+	err := runJobSafely(job.run)
+
+	s.mu.Lock()
+	delete(s.running, name)
+	s.mu.Unlock()
+
+	finishedAt := time.Now()
+	status := models.JobRunStatusSucceeded
+	errMessage := ""
+	if err != nil {
+		status = models.JobRunStatusFailed
+		errMessage = err.Error()
+		fmt.Printf("Warning: Scheduled job %s failed: %v\n", name, err)
+	}
+
+	if err := s.db.Model(run).Updates(map[string]interface{}{
+		"status":      status,
+		"error":       errMessage,
+		"finished_at": finishedAt,
+		"duration_ms": finishedAt.Sub(run.StartedAt).Milliseconds(),
+	}).Error; err != nil {
+		fmt.Printf("Warning: Failed to save job run %d: %v\n", run.ID, err)
+	}
+	if err := s.db.Model(&models.ScheduledJob{}).Where("name = ?", name).Updates(map[string]interface{}{
+		"last_status": status,
+		"last_error":  errMessage,
+	}).Error; err != nil {
+		fmt.Printf("Warning: Failed to update job %s: %v\n", name, err)
+	}
+}
+
+// runJobSafely 运行任务，任务panic时转换为错误，避免影响调度器
+func runJobSafely(run JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return run()
+}
+
+// ListJobs 获取所有定时任务（管理员）
+func (s *SchedulerService) ListJobs(userID uint) ([]ScheduledJobInfo, error) {
+	if !s.permissionService.IsAdmin(userID) {
+		return nil, fmt.Errorf("only admin can manage scheduled jobs")
+	}
+
+	var jobs []models.ScheduledJob
+	if err := s.db.Order("name").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get scheduled jobs: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]ScheduledJobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		info := ScheduledJobInfo{
+			ScheduledJob: job,
+			Registered:   s.jobs[job.Name] != nil,
+			Running:      s.running[job.Name],
+		}
+		var lastRun models.JobRun
+		if err := s.db.Where("job_name = ?", job.Name).Order("id DESC").First(&lastRun).Error; err == nil {
+			info.LastRun = &lastRun
+		}
+		result = append(result, info)
+	}
+
+	return result, nil
+}
+
+// GetJobRuns 获取定时任务的运行记录（管理员）
+func (s *SchedulerService) GetJobRuns(userID uint, name string, limit int) ([]models.JobRun, error) {
+	if !s.permissionService.IsAdmin(userID) {
+		return nil, fmt.Errorf("only admin can manage scheduled jobs")
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var runs []models.JobRun
+	if err := s.db.Where("job_name = ?", name).Order("id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get job runs: %w", err)
+	}
+	return runs, nil
+}
+
+// TriggerJob 立即在当前实例运行定时任务（管理员）
+func (s *SchedulerService) TriggerJob(userID uint, name string) (*models.JobRun, error) {
+	if !s.permissionService.IsAdmin(userID) {
+		return nil, fmt.Errorf("only admin can manage scheduled jobs")
+	}
+
+	run, err := s.startRun(name, userID)
+	if err != nil {
+		return nil, err
+	}
+	go s.finishRun(name, run)
+
+	return run, nil
+}
+
+// UpdateJob 修改定时任务的执行计划或启用状态（管理员）
+func (s *SchedulerService) UpdateJob(userID uint, name string, req *UpdateScheduledJobRequest) (*models.ScheduledJob, error) {
+	if !s.permissionService.IsAdmin(userID) {
+		return nil, fmt.Errorf("only admin can manage scheduled jobs")
+	}
+
+	var job models.ScheduledJob
+	if err := s.db.Where("name = ?", name).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("scheduled job not found: %w", err)
+	}
+
+	updates := map[string]interface{}{}
+	if req.Schedule != nil {
+		schedule, err := utils.ParseCronSchedule(*req.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		updates["schedule"] = *req.Schedule
+		updates["next_run_at"] = schedule.Next(time.Now())
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if len(updates) == 0 {
+		return &job, nil
+	}
+
+	if err := s.db.Model(&job).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update scheduled job: %w", err)
+	}
+	if err := s.db.First(&job, job.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload scheduled job: %w", err)
+	}
+	return &job, nil
+}
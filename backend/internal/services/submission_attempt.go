@@ -0,0 +1,163 @@
+package services
+
+import (
+	"fmt"
+
+	"gitlabex/internal/models"
+
+	"github.com/xanzy/go-gitlab"
+	"gorm.io/gorm"
+)
+
+// AttemptComparison 两次提交之间的代码差异
+type AttemptComparison struct {
+	From    *models.AssignmentSubmission `json:"from"`
+	To      *models.AssignmentSubmission `json:"to"`
+	Commits []*gitlab.Commit             `json:"commits"`
+	Diffs   []*gitlab.Diff               `json:"diffs"`
+}
+
+// nextSubmissionAttempt 计算学生下一次提交的序号，并判断新提交是否应作为评分提交
+// 已评分的提交保持选中，新提交需由老师手动选择
+func nextSubmissionAttempt(db *gorm.DB, assignment *models.Assignment, studentID uint) (int, bool, error) {
+	var attempts []models.AssignmentSubmission
+	if err := db.Select("id, attempt_number, status, is_selected").
+		Where("assignment_id = ? AND student_id = ?", assignment.ID, studentID).
+		Find(&attempts).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to get submission attempts: %w", err)
+	}
+
+	if assignment.MaxAttempts > 0 && len(attempts) >= assignment.MaxAttempts {
+		return 0, false, fmt.Errorf("maximum number of attempts (%d) reached", assignment.MaxAttempts)
+	}
+
+	next, selectNew := 1, true
+	for _, attempt := range attempts {
+		if attempt.AttemptNumber >= next {
+			next = attempt.AttemptNumber + 1
+		}
+		if attempt.IsSelected && attempt.Status == "graded" {
+			selectNew = false
+		}
+	}
+
+	return next, selectNew, nil
+}
+
+// createSubmissionAttempt 保存一次提交，并更新学生在该作业上的评分提交
+func createSubmissionAttempt(db *gorm.DB, submission *models.AssignmentSubmission, selectAttempt bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(submission).Error; err != nil {
+			return fmt.Errorf("failed to create submission: %w", err)
+		}
+
+		// is_selected 默认为 true，不选中时需单独更新
+		query := tx.Model(&models.AssignmentSubmission{}).
+			Where("assignment_id = ? AND student_id = ?", submission.AssignmentID, submission.StudentID)
+		if selectAttempt {
+			query = query.Where("id <> ?", submission.ID)
+		} else {
+			query = query.Where("id = ?", submission.ID)
+		}
+		if err := query.Update("is_selected", false).Error; err != nil {
+			return fmt.Errorf("failed to update selected attempt: %w", err)
+		}
+
+		submission.IsSelected = selectAttempt
+		return nil
+	})
+}
+
+// GetSubmissionAttempts 获取学生在作业上的全部提交（学生只能查看自己的提交）
+func (s *AssignmentService) GetSubmissionAttempts(userID, assignmentID, studentID uint) ([]models.AssignmentSubmission, error) {
+	if !s.canViewAttempts(userID, assignmentID, studentID) {
+		return nil, fmt.Errorf("permission denied to view submissions of this student")
+	}
+
+	var attempts []models.AssignmentSubmission
+	if err := s.db.Where("assignment_id = ? AND student_id = ?", assignmentID, studentID).
+		Order("attempt_number ASC").Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get submission attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// SelectSubmissionAttempt 选择用于评分的提交（老师权限）
+func (s *AssignmentService) SelectSubmissionAttempt(userID, submissionID uint) (*models.AssignmentSubmission, error) {
+	var submission models.AssignmentSubmission
+	if err := s.db.First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	if !s.permissionService.CanAccessAssignment(userID, submission.AssignmentID, "review") {
+		return nil, fmt.Errorf("permission denied to review this submission")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AssignmentSubmission{}).
+			Where("assignment_id = ? AND student_id = ? AND id <> ?", submission.AssignmentID, submission.StudentID, submission.ID).
+			Update("is_selected", false).Error; err != nil {
+			return fmt.Errorf("failed to unselect attempts: %w", err)
+		}
+		if err := tx.Model(&submission).Update("is_selected", true).Error; err != nil {
+			return fmt.Errorf("failed to select attempt: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	submission.IsSelected = true
+	return &submission, nil
+}
+
+// CompareSubmissionAttempts 通过GitLab比较同一学生的两次提交
+func (s *AssignmentService) CompareSubmissionAttempts(userID, fromID, toID uint) (*AttemptComparison, error) {
+	var from, to models.AssignmentSubmission
+	if err := s.db.First(&from, fromID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+	if err := s.db.First(&to, toID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+
+	if from.AssignmentID != to.AssignmentID || from.StudentID != to.StudentID {
+		return nil, fmt.Errorf("submissions must belong to the same student and assignment")
+	}
+	if !s.canViewAttempts(userID, from.AssignmentID, from.StudentID) {
+		return nil, fmt.Errorf("permission denied to view submissions of this student")
+	}
+	if from.CommitHash == "" || to.CommitHash == "" {
+		return nil, fmt.Errorf("submission has no GitLab commit to compare")
+	}
+
+	var assignment models.Assignment
+	if err := s.db.Preload("Project").First(&assignment, from.AssignmentID).Error; err != nil {
+		return nil, fmt.Errorf("assignment not found: %w", err)
+	}
+	if assignment.Project.GitLabProjectID == 0 {
+		return nil, fmt.Errorf("project not linked to GitLab")
+	}
+
+	compare, err := s.gitlabService.CompareCommits(assignment.Project.GitLabProjectID, from.CommitHash, to.CommitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttemptComparison{
+		From:    &from,
+		To:      &to,
+		Commits: compare.Commits,
+		Diffs:   compare.Diffs,
+	}, nil
+}
+
+// canViewAttempts 学生可查看自己的提交，老师可查看所负责作业的全部提交
+func (s *AssignmentService) canViewAttempts(userID, assignmentID, studentID uint) bool {
+	if userID == studentID && s.permissionService.CanAccessAssignment(userID, assignmentID, "read") {
+		return true
+	}
+	return s.permissionService.CanAccessAssignment(userID, assignmentID, "review")
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 提交文件检查规则
+const (
+	SubmissionCheckRequiredFile = "required_file"
+	SubmissionCheckFileType     = "file_type"
+	SubmissionCheckFileSize     = "file_size"
+)
+
+// 文件检查的来源
+const (
+	FileCheckSourceFiles      = "files"      // 本次提交的文件
+	FileCheckSourceRepository = "repository" // 学生分支在提交时的文件树
+	FileCheckSourceNone       = "none"       // 没有可检查的文件
+)
+
+// maxTreeSizeChecks 检查分支文件树时最多查询大小的文件数
+const maxTreeSizeChecks = 200
+
+// SubmissionCheckIssue 检查发现的问题
+type SubmissionCheckIssue struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// FileCheckResult 文件检查结果
+type FileCheckResult struct {
+	Passed   bool                   `json:"passed"`
+	Source   string                 `json:"source"`
+	Commit   string                 `json:"commit,omitempty"` // 检查的分支版本
+	Files    []string               `json:"files"`
+	Issues   []SubmissionCheckIssue `json:"issues"`
+	Warnings []string               `json:"warnings,omitempty"`
+}
+
+// SubmissionCheckReport 写入 AutoCheckResults 的自动检查报告
+type SubmissionCheckReport struct {
+	Passed    bool                 `json:"passed"`
+	CheckedAt time.Time            `json:"checked_at"`
+	FileCheck *FileCheckResult     `json:"file_check,omitempty"`
+	Autograde *AutogradeReport     `json:"autograde,omitempty"`
+	Pipeline  *PipelineCheckResult `json:"pipeline,omitempty"`
+}
+
+// updatePassed 所有检查项都通过时报告才算通过
+func (r *SubmissionCheckReport) updatePassed() {
+	r.Passed = (r.FileCheck == nil || r.FileCheck.Passed) &&
+		(r.Autograde == nil || r.Autograde.Passed) &&
+		(r.Pipeline == nil || r.Pipeline.Passed)
+}
+
+// SubmissionCheckError 作业要求严格检查且检查未通过时拒绝提交
+type SubmissionCheckError struct {
+	Report *SubmissionCheckReport
+}
+
+func (e *SubmissionCheckError) Error() string {
+	var messages []string
+	if e.Report.FileCheck != nil {
+		for _, issue := range e.Report.FileCheck.Issues {
+			messages = append(messages, issue.Message)
+		}
+	}
+	return fmt.Sprintf("submission failed file checks: %s", strings.Join(messages, "; "))
+}
+
+// runFileCheck 检查提交是否满足作业的文件要求
+// files 不为空时检查本次提交的文件，必交文件也可以已存在于分支中；否则检查学生分支的文件树
+func runFileCheck(gitlabService *GitLabService, assignment *models.Assignment, gitlabProjectID int, branch string, files map[string]string) *FileCheckResult {
+	result := &FileCheckResult{Files: []string{}, Issues: []SubmissionCheckIssue{}}
+
+	sizes := make(map[string]int64)
+	present := make(map[string]bool)
+	if len(files) > 0 {
+		result.Source = FileCheckSourceFiles
+		for path, content := range files {
+			path = normalizeCheckPath(path)
+			sizes[path] = int64(len(content))
+			present[path] = true
+		}
+	}
+
+	// 只有需要查看分支时才访问GitLab：检查分支文件树，或本次提交中缺少必交文件
+	needTree := len(checkRequiredFiles(assignment.RequiredFiles, present)) > 0
+	if len(files) == 0 {
+		needTree = needTree || len(assignment.AllowedFileTypes) > 0 || assignment.MaxFileSize > 0
+	}
+	if needTree && gitlabService != nil && gitlabProjectID > 0 && branch != "" {
+		if err := loadBranchFiles(gitlabService, assignment, gitlabProjectID, branch, len(files) == 0, result, sizes, present); err != nil {
+			result.Warnings = append(result.Warnings, err.Error())
+		}
+	}
+	if result.Source == "" {
+		result.Source = FileCheckSourceNone
+	}
+
+	for path := range sizes {
+		result.Files = append(result.Files, path)
+	}
+	sort.Strings(result.Files)
+
+	result.Issues = append(result.Issues, checkRequiredFiles(assignment.RequiredFiles, present)...)
+	result.Issues = append(result.Issues, checkFileRules(assignment, result.Files, sizes)...)
+	result.Passed = len(result.Issues) == 0
+
+	return result
+}
+
+// loadBranchFiles 读取学生分支最新版本的文件列表，检查分支时同时查询文件大小
+func loadBranchFiles(gitlabService *GitLabService, assignment *models.Assignment, gitlabProjectID int, branch string,
+	checkTree bool, result *FileCheckResult, sizes map[string]int64, present map[string]bool) error {
+	commit, err := gitlabService.GetBranchHead(gitlabProjectID, branch)
+	if err != nil {
+		return fmt.Errorf("无法读取分支 %s: %v", branch, err)
+	}
+	tree, err := gitlabService.ListRepositoryTree(gitlabProjectID, commit)
+	if err != nil {
+		return fmt.Errorf("无法读取分支 %s 的文件树: %v", branch, err)
+	}
+
+	for _, node := range tree {
+		present[normalizeCheckPath(node.Path)] = true
+	}
+	if !checkTree {
+		return nil
+	}
+
+	result.Source = FileCheckSourceRepository
+	result.Commit = commit
+	checked := 0
+	for _, node := range tree {
+		path := normalizeCheckPath(node.Path)
+		sizes[path] = 0
+		if assignment.MaxFileSize <= 0 {
+			continue
+		}
+		if checked >= maxTreeSizeChecks {
+			continue
+		}
+		checked++
+		size, err := gitlabService.GetFileSize(gitlabProjectID, node.Path, commit)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("无法获取文件 %s 的大小", path))
+			continue
+		}
+		sizes[path] = size
+	}
+	if assignment.MaxFileSize > 0 && len(tree) > maxTreeSizeChecks {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("分支文件超过 %d 个，其余文件未检查大小", maxTreeSizeChecks))
+	}
+
+	return nil
+}
+
+// checkRequiredFiles 检查必交文件是否存在
+func checkRequiredFiles(required []string, present map[string]bool) []SubmissionCheckIssue {
+	var issues []SubmissionCheckIssue
+	for _, path := range required {
+		path = normalizeCheckPath(path)
+		if path == "" || present[path] {
+			continue
+		}
+		issues = append(issues, SubmissionCheckIssue{
+			Rule:    SubmissionCheckRequiredFile,
+			Path:    path,
+			Message: fmt.Sprintf("缺少必须提交的文件：%s", path),
+		})
+	}
+	return issues
+}
+
+// checkFileRules 检查文件类型和大小
+func checkFileRules(assignment *models.Assignment, paths []string, sizes map[string]int64) []SubmissionCheckIssue {
+	var issues []SubmissionCheckIssue
+	for _, path := range paths {
+		if !isAllowedFileType(path, assignment.AllowedFileTypes) {
+			issues = append(issues, SubmissionCheckIssue{
+				Rule:    SubmissionCheckFileType,
+				Path:    path,
+				Message: fmt.Sprintf("不允许提交的文件类型：%s", path),
+			})
+		}
+		if assignment.MaxFileSize > 0 && sizes[path] > assignment.MaxFileSize {
+			issues = append(issues, SubmissionCheckIssue{
+				Rule:    SubmissionCheckFileSize,
+				Path:    path,
+				Message: fmt.Sprintf("文件 %s 大小 %d 字节，超过限制 %d 字节", path, sizes[path], assignment.MaxFileSize),
+			})
+		}
+	}
+	return issues
+}
+
+// isAllowedFileType 检查文件扩展名，无扩展名的文件（如README、Makefile）总是允许
+func isAllowedFileType(path string, allowedTypes []string) bool {
+	if len(allowedTypes) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return true
+	}
+
+	for _, allowedType := range allowedTypes {
+		allowedType = strings.ToLower(strings.TrimSpace(allowedType))
+		if ext == allowedType || ext == "."+allowedType {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCheckPath 统一文件路径格式
+func normalizeCheckPath(path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(path), "./"), "/")
+}
+
+// newSubmissionCheckReport 汇总检查结果
+func newSubmissionCheckReport(fileCheck *FileCheckResult) *SubmissionCheckReport {
+	return &SubmissionCheckReport{
+		Passed:    fileCheck.Passed,
+		CheckedAt: time.Now(),
+		FileCheck: fileCheck,
+	}
+}
+
+// applySubmissionCheck 将检查报告写入提交记录
+func applySubmissionCheck(submission *models.AssignmentSubmission, report *SubmissionCheckReport) {
+	submission.AutoCheckPassed = report.Passed
+	if data, err := json.Marshal(report); err == nil {
+		submission.AutoCheckResults = string(data)
+	} else {
+		fmt.Printf("Warning: Failed to encode submission check report: %v\n", err)
+	}
+}
+
+// checkSubmission 执行提交检查，作业要求严格检查时检查未通过返回 SubmissionCheckError
+func checkSubmission(gitlabService *GitLabService, assignment *models.Assignment, gitlabProjectID int, branch string, files map[string]string) (*SubmissionCheckReport, error) {
+	report := newSubmissionCheckReport(runFileCheck(gitlabService, assignment, gitlabProjectID, branch, files))
+	if !report.Passed && assignment.StrictFileCheck {
+		return report, &SubmissionCheckError{Report: report}
+	}
+	return report, nil
+}
+
+// updateSubmissionCheckReport 更新提交的自动检查报告中的某一项，并一同写入 updates 中的其他字段
+// 评测和流水线结果可能同时到达，读取报告时锁定提交记录，避免相互覆盖
+func updateSubmissionCheckReport(db *gorm.DB, submissionID uint, updates map[string]interface{}, update func(report *SubmissionCheckReport)) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var submission models.AssignmentSubmission
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&submission, submissionID).Error; err != nil {
+			return fmt.Errorf("submission not found: %w", err)
+		}
+
+		report := parseSubmissionCheckReport(&submission)
+		update(report)
+		report.CheckedAt = time.Now()
+		report.updatePassed()
+
+		data, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("failed to encode check report: %w", err)
+		}
+
+		if updates == nil {
+			updates = make(map[string]interface{})
+		}
+		updates["auto_check_passed"] = report.Passed
+		updates["auto_check_results"] = string(data)
+		return tx.Model(&submission).Updates(updates).Error
+	})
+}
+
+// loadSubmissionCheckReport 读取提交的自动检查报告
+func loadSubmissionCheckReport(db *gorm.DB, submissionID uint) (*SubmissionCheckReport, error) {
+	var submission models.AssignmentSubmission
+	if err := db.Select("id", "auto_check_results").First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+	return parseSubmissionCheckReport(&submission), nil
+}
+
+// parseSubmissionCheckReport 解析提交的自动检查报告，格式错误时返回空报告
+func parseSubmissionCheckReport(submission *models.AssignmentSubmission) *SubmissionCheckReport {
+	report := &SubmissionCheckReport{}
+	if submission.AutoCheckResults == "" {
+		return report
+	}
+	if err := json.Unmarshal([]byte(submission.AutoCheckResults), report); err != nil {
+		fmt.Printf("Warning: Failed to parse check report of submission %d: %v\n", submission.ID, err)
+		return &SubmissionCheckReport{}
+	}
+	return report
+}
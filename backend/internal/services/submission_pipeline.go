@@ -0,0 +1,287 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// 流水线结果保留的失败用例数和堆栈长度
+const (
+	maxPipelineFailedCases = 50
+	maxPipelineStackTrace  = 2000
+)
+
+// pipelineTrackingBatch 每次轮询处理的提交数
+const pipelineTrackingBatch = 100
+
+// pendingPipelineStatuses 尚未结束、需要继续跟踪的流水线状态
+var pendingPipelineStatuses = []string{
+	models.PipelineStatusWaiting,
+	"created",
+	"waiting_for_resource",
+	"preparing",
+	"pending",
+	"running",
+	"scheduled",
+}
+
+// PipelineJobResult CI作业结果
+type PipelineJobResult struct {
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Stage         string  `json:"stage"`
+	Status        string  `json:"status"`
+	AllowFailure  bool    `json:"allow_failure"`
+	Duration      float64 `json:"duration"`
+	FailureReason string  `json:"failure_reason,omitempty"`
+	WebURL        string  `json:"web_url"`
+}
+
+// PipelineTestCaseResult JUnit测试报告中未通过的用例
+type PipelineTestCaseResult struct {
+	Suite      string `json:"suite"`
+	Name       string `json:"name"`
+	Classname  string `json:"classname"`
+	File       string `json:"file,omitempty"`
+	Status     string `json:"status"`
+	StackTrace string `json:"stack_trace,omitempty"`
+}
+
+// PipelineTestSummary JUnit测试报告汇总
+type PipelineTestSummary struct {
+	TotalCount   int                      `json:"total_count"`
+	SuccessCount int                      `json:"success_count"`
+	FailedCount  int                      `json:"failed_count"`
+	SkippedCount int                      `json:"skipped_count"`
+	ErrorCount   int                      `json:"error_count"`
+	FailedCases  []PipelineTestCaseResult `json:"failed_cases"`
+}
+
+// PipelineCheckResult 写入自动检查报告的CI流水线结果
+type PipelineCheckResult struct {
+	Passed     bool                 `json:"passed"`
+	Finished   bool                 `json:"finished"`
+	PipelineID int                  `json:"pipeline_id"`
+	Status     string               `json:"status"`
+	Ref        string               `json:"ref"`
+	SHA        string               `json:"sha"`
+	WebURL     string               `json:"web_url"`
+	Jobs       []PipelineJobResult  `json:"jobs"`
+	Tests      *PipelineTestSummary `json:"tests,omitempty"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// SetPipelineLookupTimeout 设置提交后等待GitLab创建流水线的最长时间
+func (s *AssignmentService) SetPipelineLookupTimeout(timeout time.Duration) {
+	s.pipelineLookupTimeout = timeout
+}
+
+// SyncPendingPipelines 同步所有尚未结束的提交流水线，由定时任务调用，作为流水线Webhook的补充
+func (s *AssignmentService) SyncPendingPipelines() {
+	var submissions []models.AssignmentSubmission
+	if err := s.db.Preload("Assignment.Project").
+		Where("commit_hash <> '' AND pipeline_status IN ?", pendingPipelineStatuses).
+		Order("id").Limit(pipelineTrackingBatch).
+		Find(&submissions).Error; err != nil {
+		fmt.Printf("Warning: Failed to load pending pipelines: %v\n", err)
+		return
+	}
+
+	for i := range submissions {
+		if err := s.syncSubmissionPipeline(&submissions[i]); err != nil {
+			fmt.Printf("Warning: Failed to sync pipeline of submission %d: %v\n", submissions[i].ID, err)
+		}
+	}
+}
+
+// SyncSubmissionPipeline 立即同步提交的CI流水线（提交者或有评审权限的用户）
+func (s *AssignmentService) SyncSubmissionPipeline(userID, submissionID uint) (*PipelineCheckResult, error) {
+	var submission models.AssignmentSubmission
+	if err := s.db.Preload("Assignment.Project").First(&submission, submissionID).Error; err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+	if !s.canViewAttempts(userID, submission.AssignmentID, submission.StudentID) {
+		return nil, fmt.Errorf("permission denied to view this submission")
+	}
+	if submission.CommitHash == "" || submission.Assignment.Project.GitLabProjectID == 0 {
+		return nil, fmt.Errorf("submission has no GitLab commit")
+	}
+
+	if err := s.syncSubmissionPipeline(&submission); err != nil {
+		return nil, err
+	}
+
+	report, err := loadSubmissionCheckReport(s.db, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	return report.Pipeline, nil
+}
+
+// HandlePipelineEvent 处理GitLab流水线Webhook，更新对应提交版本的流水线结果
+func (s *AssignmentService) HandlePipelineEvent(projectID uint, event *gitlab.PipelineEvent) error {
+	sha := event.ObjectAttributes.SHA
+	pipelineID := event.ObjectAttributes.ID
+	if sha == "" || pipelineID == 0 {
+		return nil
+	}
+
+	var submissions []models.AssignmentSubmission
+	if err := s.db.Preload("Assignment.Project").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignments.project_id = ? AND assignment_submissions.commit_hash = ?", projectID, sha).
+		Find(&submissions).Error; err != nil {
+		return fmt.Errorf("failed to find submissions of commit: %w", err)
+	}
+
+	for i := range submissions {
+		submission := &submissions[i]
+		// 同一版本重新运行流水线时以最新的流水线为准
+		if pipelineID < submission.PipelineID {
+			continue
+		}
+		submission.PipelineID = pipelineID
+		if err := s.syncSubmissionPipeline(submission); err != nil {
+			fmt.Printf("Warning: Failed to sync pipeline of submission %d: %v\n", submission.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// syncSubmissionPipeline 从GitLab读取流水线、作业和测试报告，写入提交的自动检查报告
+func (s *AssignmentService) syncSubmissionPipeline(submission *models.AssignmentSubmission) error {
+	gitlabProjectID := submission.Assignment.Project.GitLabProjectID
+	if gitlabProjectID == 0 || submission.CommitHash == "" {
+		return nil
+	}
+
+	pipelineID := submission.PipelineID
+	if pipelineID == 0 {
+		info, err := s.gitlabService.GetCommitPipeline(gitlabProjectID, submission.CommitHash)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			// 超过等待时间仍没有流水线，视为该版本未配置CI
+			if time.Since(submission.SubmittedAt) > s.pipelineLookupTimeout {
+				return s.db.Model(submission).Update("pipeline_status", models.PipelineStatusNone).Error
+			}
+			return nil
+		}
+		pipelineID = info.ID
+	}
+
+	pipeline, err := s.gitlabService.GetPipeline(gitlabProjectID, pipelineID)
+	if err != nil {
+		return err
+	}
+	jobs, err := s.gitlabService.ListPipelineJobs(gitlabProjectID, pipelineID)
+	if err != nil {
+		return err
+	}
+
+	result := buildPipelineCheckResult(pipeline, jobs)
+	if result.Finished {
+		testReport, err := s.gitlabService.GetPipelineTestReport(gitlabProjectID, pipelineID)
+		if err != nil {
+			fmt.Printf("Warning: Failed to get test report of pipeline %d: %v\n", pipelineID, err)
+		} else {
+			result.Tests = summarizePipelineTests(testReport)
+		}
+	}
+
+	updates := map[string]interface{}{
+		"pipeline_id":     pipeline.ID,
+		"pipeline_status": pipeline.Status,
+		"pipeline_url":    pipeline.WebURL,
+	}
+	return updateSubmissionCheckReport(s.db, submission.ID, updates, func(report *SubmissionCheckReport) {
+		report.Pipeline = result
+	})
+}
+
+// buildPipelineCheckResult 汇总流水线和作业状态，流水线成功才算通过（允许失败的作业不影响结果）
+func buildPipelineCheckResult(pipeline *gitlab.Pipeline, jobs []*gitlab.Job) *PipelineCheckResult {
+	result := &PipelineCheckResult{
+		PipelineID: pipeline.ID,
+		Status:     pipeline.Status,
+		Ref:        pipeline.Ref,
+		SHA:        pipeline.SHA,
+		WebURL:     pipeline.WebURL,
+		Finished:   isPipelineFinished(pipeline.Status),
+		Passed:     pipeline.Status == "success",
+		Jobs:       []PipelineJobResult{},
+		UpdatedAt:  time.Now(),
+	}
+
+	for _, job := range jobs {
+		result.Jobs = append(result.Jobs, PipelineJobResult{
+			ID:            job.ID,
+			Name:          job.Name,
+			Stage:         job.Stage,
+			Status:        job.Status,
+			AllowFailure:  job.AllowFailure,
+			Duration:      job.Duration,
+			FailureReason: job.FailureReason,
+			WebURL:        job.WebURL,
+		})
+	}
+
+	return result
+}
+
+// summarizePipelineTests 汇总JUnit测试报告，只保留未通过的用例
+func summarizePipelineTests(report *gitlab.PipelineTestReport) *PipelineTestSummary {
+	if report == nil || report.TotalCount == 0 {
+		return nil
+	}
+
+	summary := &PipelineTestSummary{
+		TotalCount:   report.TotalCount,
+		SuccessCount: report.SuccessCount,
+		FailedCount:  report.FailedCount,
+		SkippedCount: report.SkippedCount,
+		ErrorCount:   report.ErrorCount,
+		FailedCases:  []PipelineTestCaseResult{},
+	}
+
+	for _, suite := range report.TestSuites {
+		for _, testCase := range suite.TestCases {
+			if testCase.Status != "failed" && testCase.Status != "error" {
+				continue
+			}
+			if len(summary.FailedCases) >= maxPipelineFailedCases {
+				return summary
+			}
+
+			stackTrace := testCase.StackTrace
+			if len(stackTrace) > maxPipelineStackTrace {
+				stackTrace = stackTrace[:maxPipelineStackTrace] + "\n... (truncated)"
+			}
+			summary.FailedCases = append(summary.FailedCases, PipelineTestCaseResult{
+				Suite:      suite.Name,
+				Name:       testCase.Name,
+				Classname:  testCase.Classname,
+				File:       testCase.File,
+				Status:     testCase.Status,
+				StackTrace: stackTrace,
+			})
+		}
+	}
+
+	return summary
+}
+
+// isPipelineFinished 流水线是否已结束（需要手动触发的流水线也不再自动跟踪）
+func isPipelineFinished(status string) bool {
+	switch status {
+	case "success", "failed", "canceled", "skipped", "manual":
+		return true
+	}
+	return false
+}
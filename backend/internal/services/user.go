@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"gitlabex/internal/config"
 	"gitlabex/internal/models"
@@ -253,16 +254,15 @@ func (s *UserService) GetUserStats() (map[string]int, error) {
 	}
 	stats["active"] = int(activeUsers)
 
-	// 各角色统计
+	// 各角色统计（User.Role 存储值 1:管理员, 2:老师, 3:学生, 4:访客）
 	roleStats := []struct {
 		role int
 		key  string
 	}{
-		{int(models.EduRoleAdmin), "admin"},
-		{int(models.EduRoleTeacher), "teacher"},
-		{int(models.EduRoleAssistant), "assistant"},
-		{int(models.EduRoleStudent), "student"},
-		{int(models.EduRoleGuest), "guest"},
+		{1, "admin"},
+		{2, "teacher"},
+		{3, "student"},
+		{4, "guest"},
 	}
 
 	for _, rs := range roleStats {
@@ -322,12 +322,66 @@ func (s *UserService) CheckUserPermission(userID uint, resourceType string, reso
 	return false, nil
 }
 
-// SyncUserFromGitLab 从GitLab同步用户信息
+// SyncUserFromGitLab 从GitLab同步用户信息，GitLab中被封禁的用户会被停用
 func (s *UserService) SyncUserFromGitLab(gitlabID int) (*models.User, error) {
-	// TODO: 实现从GitLab同步用户信息的逻辑
+	user, err := s.GetUserByGitLabID(gitlabID)
+	if err != nil {
+		return nil, err
+	}
+
+	gitlabUser, err := s.gitlabService.GetUser(gitlabID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"last_sync_at": time.Now(),
+	}
+	// 非管理员令牌读取不到的字段保持原值
+	if gitlabUser.Username != "" {
+		updates["username"] = gitlabUser.Username
+	}
+	if gitlabUser.Name != "" {
+		updates["name"] = gitlabUser.Name
+	}
+	if gitlabUser.Email != "" {
+		updates["email"] = gitlabUser.Email
+	}
+	if gitlabUser.AvatarURL != "" {
+		updates["avatar"] = gitlabUser.AvatarURL
+	}
+	if gitlabUser.State == "blocked" {
+		updates["active"] = false
+	}
+
+	if err := s.db.Model(user).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
 	return s.GetUserByGitLabID(gitlabID)
 }
 
+// SyncUsersFromGitLab 同步所有启用用户的GitLab信息，单个用户失败不影响其他用户
+func (s *UserService) SyncUsersFromGitLab() error {
+	users, err := s.ListActiveUsers()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, user := range users {
+		if _, err := s.SyncUserFromGitLab(user.GitLabID); err != nil {
+			fmt.Printf("Warning: Failed to sync user %d from GitLab: %v\n", user.ID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %d of %d users", failed, len(users))
+	}
+	return nil
+}
+
 // GetCurrentUser 获取当前用户（用于测试环境）
 func (s *UserService) GetCurrentUser() (*models.User, error) {
 	// 在生产环境中，这个方法应该从上下文中获取当前用户
@@ -352,7 +406,7 @@ func (s *UserService) GetUserProfile(userID uint) (*UserProfile, error) {
 
 	// 统计用户相关的作业数量
 	var assignmentCount int64
-	s.db.Table("assignment_submissions").Where("student_id = ?", userID).Count(&assignmentCount)
+	s.db.Table("assignment_submissions").Where("student_id = ? AND is_selected = true", userID).Count(&assignmentCount)
 
 	profile := &UserProfile{
 		User:            user,
@@ -398,7 +452,7 @@ func (s *UserService) GetUserDashboard(userID uint) (map[string]interface{}, err
 		// 学生看到的统计信息
 		var projectCount, assignmentCount int64
 		s.db.Table("project_members").Where("user_id = ? AND is_active = true", userID).Count(&projectCount)
-		s.db.Model(&models.AssignmentSubmission{}).Where("student_id = ?", userID).Count(&assignmentCount)
+		s.db.Model(&models.AssignmentSubmission{}).Where("student_id = ? AND is_selected = true", userID).Count(&assignmentCount)
 
 		dashboard["project_count"] = projectCount
 		dashboard["assignment_count"] = assignmentCount
@@ -416,7 +470,7 @@ func (s *UserService) ToProfile(user *models.User) *UserProfile {
 	// 统计用户相关信息
 	var projectCount, assignmentCount int64
 	s.db.Table("project_members").Where("user_id = ? AND is_active = true", user.ID).Count(&projectCount)
-	s.db.Table("assignment_submissions").Where("student_id = ?", user.ID).Count(&assignmentCount)
+	s.db.Table("assignment_submissions").Where("student_id = ? AND is_selected = true", user.ID).Count(&assignmentCount)
 
 	profile := &UserProfile{
 		User:            user,
@@ -0,0 +1,840 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gitlabex/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Webhook投递参数
+const (
+	webhookDeliveryBatch   = 50               // 每次处理的投递数
+	webhookMaxAttempts     = 8                // 最大尝试次数
+	webhookSendLease       = 2 * time.Minute  // 投递中的记录在租约到期前不会被其他实例重复投递
+	webhookRetryBase       = time.Minute      // 第一次重试的等待时间，之后每次翻倍
+	webhookRetryMaxDelay   = 12 * time.Hour   // 重试等待时间上限
+	webhookRequestTimeout  = 10 * time.Second // 单次请求超时时间
+	webhookResolveTimeout  = 5 * time.Second  // 校验接收地址时的域名解析超时时间
+	webhookResponseLimit   = 2048             // 保存的响应正文字节数
+	webhookUserAgent       = "GitLabEx-Webhook/1.0"
+	webhookSignatureHeader = "X-GitLabEx-Signature"
+)
+
+// WebhookEvents 可订阅的事件
+var WebhookEvents = []string{
+	models.WebhookEventAssignmentCreated,
+	models.WebhookEventSubmissionCreated,
+	models.WebhookEventReviewCompleted,
+	models.WebhookEventProjectMemberAdded,
+	models.WebhookEventDiscussionCreated,
+}
+
+// WebhookService 第三方Webhook订阅和投递服务
+// 事件发生时为每个匹配的订阅写入投递记录并立即尝试投递，失败的投递由定时任务按指数退避重试
+type WebhookService struct {
+	db                *gorm.DB
+	permissionService *PermissionService
+	client            *http.Client
+}
+
+// NewWebhookService 创建Webhook服务
+func NewWebhookService(db *gorm.DB, permissionService *PermissionService) *WebhookService {
+	return &WebhookService{
+		db:                db,
+		permissionService: permissionService,
+		client: &http.Client{
+			Timeout:   webhookRequestTimeout,
+			Transport: newWebhookTransport(),
+			// 不跟随重定向，避免投递被转发到订阅以外的地址
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// CreateWebhookRequest 创建Webhook订阅请求
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	ProjectID   *uint    `json:"project_id"`
+	Description string   `json:"description"`
+}
+
+// UpdateWebhookRequest 更新Webhook订阅请求，未提供的字段保持不变
+type UpdateWebhookRequest struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	ProjectID   *uint     `json:"project_id"`
+	Description *string   `json:"description"`
+	Active      *bool     `json:"active"`
+}
+
+// WebhookPayload 推送的JSON正文
+type WebhookPayload struct {
+	ID        string      `json:"id"` // 事件ID，接收方可用于去重
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// CreateSubscription 创建Webhook订阅，返回的订阅中包含签名密钥，之后不再返回
+func (s *WebhookService) CreateSubscription(userID uint, req *CreateWebhookRequest) (*models.WebhookSubscription, string, error) {
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, "", err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.checkProjectFilter(userID, req.ProjectID); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	subscription := &models.WebhookSubscription{
+		UserID:      userID,
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      events,
+		ProjectID:   req.ProjectID,
+		Description: req.Description,
+		Active:      true,
+	}
+	if err := s.db.Create(subscription).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return subscription, secret, nil
+}
+
+// ListSubscriptions 获取用户的Webhook订阅
+func (s *WebhookService) ListSubscriptions(userID uint) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// GetSubscription 获取用户的Webhook订阅
+func (s *WebhookService) GetSubscription(userID, subscriptionID uint) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := s.db.Where("id = ? AND user_id = ?", subscriptionID, userID).First(&subscription).Error; err != nil {
+		return nil, fmt.Errorf("webhook subscription not found: %w", err)
+	}
+	return &subscription, nil
+}
+
+// UpdateSubscription 修改Webhook订阅
+func (s *WebhookService) UpdateSubscription(userID, subscriptionID uint, req *UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	subscription, err := s.GetSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			return nil, err
+		}
+		subscription.URL = *req.URL
+	}
+	if req.Events != nil {
+		events, err := normalizeWebhookEvents(*req.Events)
+		if err != nil {
+			return nil, err
+		}
+		subscription.Events = events
+	}
+	if req.ProjectID != nil {
+		// project_id 为0时取消课题限制
+		if *req.ProjectID == 0 {
+			subscription.ProjectID = nil
+		} else {
+			if err := s.checkProjectFilter(userID, req.ProjectID); err != nil {
+				return nil, err
+			}
+			subscription.ProjectID = req.ProjectID
+		}
+	}
+	if req.Description != nil {
+		subscription.Description = *req.Description
+	}
+	if req.Active != nil {
+		subscription.Active = *req.Active
+	}
+
+	if err := s.db.Save(subscription).Error; err != nil {
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// RotateSecret 重新生成签名密钥
+func (s *WebhookService) RotateSecret(userID, subscriptionID uint) (*models.WebhookSubscription, string, error) {
+	subscription, err := s.GetSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.db.Model(subscription).Update("secret", secret).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+	return subscription, secret, nil
+}
+
+// DeleteSubscription 删除Webhook订阅及其投递记录
+func (s *WebhookService) DeleteSubscription(userID, subscriptionID uint) error {
+	subscription, err := s.GetSubscription(userID, subscriptionID)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", subscription.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+		}
+		if err := tx.Delete(subscription).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook subscription: %w", err)
+		}
+		return nil
+	})
+}
+
+// Ping 向订阅推送 ping 事件，用于验证接收地址和签名
+func (s *WebhookService) Ping(userID, subscriptionID uint) (*models.WebhookDelivery, error) {
+	subscription, err := s.GetSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := newWebhookPayload(models.WebhookEventPing, map[string]interface{}{
+		"subscription_id": subscription.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: subscription.ID,
+		EventID:        payload.ID,
+		Event:          payload.Event,
+	}
+	if err := s.enqueue(s.db, delivery, payload); err != nil {
+		return nil, err
+	}
+	return s.deliverNowFor(userID, delivery.ID)
+}
+
+// GetDeliveries 分页获取订阅的投递记录，不含推送正文
+func (s *WebhookService) GetDeliveries(userID, subscriptionID uint, page, pageSize int) ([]models.WebhookDelivery, int64, error) {
+	subscription, err := s.GetSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	query := s.db.Model(&models.WebhookDelivery{}).Where("subscription_id = ?", subscription.ID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Omit("payload", "response_body").Order("id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// GetDelivery 获取投递记录详情，响应正文仅管理员可见
+func (s *WebhookService) GetDelivery(userID, subscriptionID, deliveryID uint) (*models.WebhookDelivery, error) {
+	delivery, err := s.getDelivery(userID, subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	s.redactDelivery(userID, delivery)
+	return delivery, nil
+}
+
+// getDelivery 获取投递记录
+func (s *WebhookService) getDelivery(userID, subscriptionID, deliveryID uint) (*models.WebhookDelivery, error) {
+	subscription, err := s.GetSubscription(userID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	var delivery models.WebhookDelivery
+	if err := s.db.Where("id = ? AND subscription_id = ?", deliveryID, subscription.ID).First(&delivery).Error; err != nil {
+		return nil, fmt.Errorf("webhook delivery not found: %w", err)
+	}
+	return &delivery, nil
+}
+
+// Redeliver 以原推送正文和事件ID重新投递，生成新的投递记录
+func (s *WebhookService) Redeliver(userID, subscriptionID, deliveryID uint) (*models.WebhookDelivery, error) {
+	original, err := s.getDelivery(userID, subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		RedeliveryOf:   &original.ID,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  time.Now(),
+	}
+	if err := s.db.Create(delivery).Error; err != nil {
+		return nil, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return s.deliverNowFor(userID, delivery.ID)
+}
+
+// deliverNowFor 立即投递并按查看者隐藏响应正文
+func (s *WebhookService) deliverNowFor(userID, deliveryID uint) (*models.WebhookDelivery, error) {
+	delivery, err := s.deliverNow(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	s.redactDelivery(userID, delivery)
+	return delivery, nil
+}
+
+// redactDelivery 非管理员只能看到响应状态码，不返回接收方的响应正文
+func (s *WebhookService) redactDelivery(userID uint, delivery *models.WebhookDelivery) {
+	if !s.permissionService.IsAdmin(userID) {
+		delivery.ResponseBody = ""
+	}
+}
+
+// ===== 事件 =====
+
+// webhookRef 推送正文中引用的对象
+type webhookRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// webhookUser 推送正文中的用户
+type webhookUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// EmitAssignmentCreated 推送作业创建事件，课题成员的订阅都会收到
+func (s *WebhookService) EmitAssignmentCreated(assignment *models.Assignment) {
+	s.emit(models.WebhookEventAssignmentCreated, assignment.ProjectID, "read", 0, map[string]interface{}{
+		"assignment": map[string]interface{}{
+			"id":          assignment.ID,
+			"title":       assignment.Title,
+			"description": assignment.Description,
+			"type":        assignment.Type,
+			"status":      assignment.Status,
+			"due_date":    assignment.DueDate,
+			"created_at":  assignment.CreatedAt,
+		},
+		"project": webhookRef{ID: assignment.ProjectID, Name: assignment.Project.Name},
+		"teacher": webhookUser{ID: assignment.TeacherID, Username: assignment.Teacher.Username, Name: assignment.Teacher.Name},
+	})
+}
+
+// EmitSubmissionCreated 推送作业提交事件，只有课题老师和提交的学生本人的订阅会收到
+func (s *WebhookService) EmitSubmissionCreated(submission *models.AssignmentSubmission, assignment *models.Assignment) {
+	var student models.User
+	s.db.First(&student, submission.StudentID)
+
+	s.emit(models.WebhookEventSubmissionCreated, assignment.ProjectID, "manage", submission.StudentID, map[string]interface{}{
+		"submission": map[string]interface{}{
+			"id":             submission.ID,
+			"status":         submission.Status,
+			"attempt_number": submission.AttemptNumber,
+			"is_late":        submission.IsLate,
+			"late_minutes":   submission.LateMinutes,
+			"commit_hash":    submission.CommitHash,
+			"submitted_at":   submission.SubmittedAt,
+		},
+		"assignment": webhookRef{ID: assignment.ID, Name: assignment.Title},
+		"project":    webhookRef{ID: assignment.ProjectID, Name: assignment.Project.Name},
+		"student":    webhookUser{ID: student.ID, Username: student.Username, Name: student.Name},
+	})
+}
+
+// EmitReviewCompleted 评审完成后推送事件，携带最终成绩和提交状态，只有课题老师和被评审的学生本人的订阅会收到
+func (s *WebhookService) EmitReviewCompleted(review *models.Review, submission *models.AssignmentSubmission) {
+	var assignment models.Assignment
+	if err := s.db.Preload("Project").First(&assignment, submission.AssignmentID).Error; err != nil {
+		fmt.Printf("Warning: Failed to load assignment %d for webhook: %v\n", submission.AssignmentID, err)
+		return
+	}
+
+	var latest models.AssignmentSubmission
+	if err := s.db.First(&latest, submission.ID).Error; err != nil {
+		latest = *submission
+	}
+
+	s.emit(models.WebhookEventReviewCompleted, assignment.ProjectID, "manage", submission.StudentID, map[string]interface{}{
+		"review": map[string]interface{}{
+			"id":           review.ID,
+			"score":        review.Score,
+			"reviewer_id":  review.ReviewerID,
+			"completed_at": review.UpdatedAt,
+		},
+		"submission": map[string]interface{}{
+			"id":                   latest.ID,
+			"student_id":           latest.StudentID,
+			"status":               latest.Status,
+			"score":                latest.Score, // 扣除迟交罚分后的最终成绩，退回时不更新
+			"raw_score":            latest.RawScore,
+			"late_penalty_percent": latest.LatePenaltyPercent,
+		},
+		"assignment": webhookRef{ID: assignment.ID, Name: assignment.Title},
+		"project":    webhookRef{ID: assignment.ProjectID, Name: assignment.Project.Name},
+	})
+}
+
+// EmitProjectMemberAdded 推送课题成员加入事件
+func (s *WebhookService) EmitProjectMemberAdded(project *models.Project, member *models.ProjectMember, user *models.User) {
+	s.emit(models.WebhookEventProjectMemberAdded, project.ID, "read", 0, map[string]interface{}{
+		"project": webhookRef{ID: project.ID, Name: project.Name},
+		"member": map[string]interface{}{
+			"user":            webhookUser{ID: user.ID, Username: user.Username, Name: user.Name},
+			"role":            member.Role,
+			"personal_branch": member.PersonalBranch,
+			"joined_at":       member.JoinedAt,
+		},
+	})
+}
+
+// EmitDiscussionCreated 推送话题创建事件
+func (s *WebhookService) EmitDiscussionCreated(discussion *models.Discussion) {
+	s.emit(models.WebhookEventDiscussionCreated, discussion.ProjectID, "read", 0, map[string]interface{}{
+		"discussion": map[string]interface{}{
+			"id":         discussion.ID,
+			"title":      discussion.Title,
+			"content":    discussion.Content,
+			"category":   discussion.Category,
+			"tags":       discussion.Tags,
+			"url":        discussion.GitLabIssueURL,
+			"created_at": discussion.CreatedAt,
+		},
+		"project": webhookRef{ID: discussion.ProjectID, Name: discussion.Project.Name},
+		"author":  webhookUser{ID: discussion.AuthorID, Username: discussion.Author.Username, Name: discussion.Author.Name},
+	})
+}
+
+// emit 为订阅了事件且有权查看的订阅写入投递记录并在后台投递
+// permission 为订阅创建者需要的课题权限，subjectUserID 为事件涉及的学生，学生本人不受该限制
+func (s *WebhookService) emit(event string, projectID uint, permission string, subjectUserID uint, data interface{}) {
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Where("active = ? AND (project_id IS NULL OR project_id = ?)", true, projectID).
+		Find(&subscriptions).Error; err != nil {
+		fmt.Printf("Warning: Failed to get webhook subscriptions for %s: %v\n", event, err)
+		return
+	}
+
+	// 停用用户的订阅不再推送
+	var ownerIDs, activeIDs []uint
+	for _, subscription := range subscriptions {
+		ownerIDs = append(ownerIDs, subscription.UserID)
+	}
+	if len(ownerIDs) > 0 {
+		if err := s.db.Model(&models.User{}).Where("id IN ? AND active = ?", ownerIDs, true).
+			Pluck("id", &activeIDs).Error; err != nil {
+			fmt.Printf("Warning: Failed to get webhook owners for %s: %v\n", event, err)
+			return
+		}
+	}
+	activeOwners := make(map[uint]bool, len(activeIDs))
+	for _, id := range activeIDs {
+		activeOwners[id] = true
+	}
+
+	var recipients []models.WebhookSubscription
+	for _, subscription := range subscriptions {
+		if !activeOwners[subscription.UserID] || !webhookSubscribed(subscription.Events, event) {
+			continue
+		}
+		if subscription.UserID != subjectUserID && !s.permissionService.IsAdmin(subscription.UserID) &&
+			!s.permissionService.CanAccessProject(subscription.UserID, projectID, permission) {
+			continue
+		}
+		recipients = append(recipients, subscription)
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	payload, err := newWebhookPayload(event, data)
+	if err != nil {
+		fmt.Printf("Warning: Failed to build webhook payload for %s: %v\n", event, err)
+		return
+	}
+
+	var ids []uint
+	for _, subscription := range recipients {
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        payload.ID,
+			Event:          event,
+		}
+		if err := s.enqueue(s.db, delivery, payload); err != nil {
+			fmt.Printf("Warning: Failed to enqueue webhook %s for subscription %d: %v\n", event, subscription.ID, err)
+			continue
+		}
+		ids = append(ids, delivery.ID)
+	}
+
+	go func() {
+		for _, id := range ids {
+			s.deliverNow(id)
+		}
+	}()
+}
+
+// enqueue 写入待投递记录
+func (s *WebhookService) enqueue(tx *gorm.DB, delivery *models.WebhookDelivery, payload *WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	delivery.Payload = string(body)
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = time.Now()
+	if err := tx.Create(delivery).Error; err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ===== 投递 =====
+
+// ProcessDeliveries 投递到期的待投递记录，由定时任务调用
+func (s *WebhookService) ProcessDeliveries() error {
+	var deliveries []models.WebhookDelivery
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+		Order("id").Limit(webhookDeliveryBatch).Find(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to get pending webhook deliveries: %w", err)
+	}
+
+	failed := 0
+	for i := range deliveries {
+		if !s.claim(&deliveries[i]) {
+			continue
+		}
+		if !s.deliver(&deliveries[i]) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to deliver %d of %d webhooks", failed, len(deliveries))
+	}
+	return nil
+}
+
+// deliverNow 立即投递一条记录并返回投递结果
+func (s *WebhookService) deliverNow(deliveryID uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := s.db.First(&delivery, deliveryID).Error; err != nil {
+		return nil, fmt.Errorf("webhook delivery not found: %w", err)
+	}
+	if s.claim(&delivery) {
+		s.deliver(&delivery)
+	}
+	return &delivery, nil
+}
+
+// claim 以租约方式锁定投递记录，多个实例同时处理时只有一个实例能投递
+func (s *WebhookService) claim(delivery *models.WebhookDelivery) bool {
+	now := time.Now()
+	result := s.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, models.WebhookDeliveryPending, now).
+		Updates(map[string]interface{}{
+			"next_attempt_at": now.Add(webhookSendLease),
+			"attempts":        gorm.Expr("attempts + 1"),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	delivery.Attempts++
+	return true
+}
+
+// deliver 发送请求并保存结果，失败时按指数退避安排重试
+func (s *WebhookService) deliver(delivery *models.WebhookDelivery) bool {
+	var subscription models.WebhookSubscription
+	err := s.db.First(&subscription, delivery.SubscriptionID).Error
+	if err == nil && !subscription.Active {
+		err = fmt.Errorf("webhook subscription is disabled")
+	}
+
+	start := time.Now()
+	status, body := 0, ""
+	if err == nil {
+		status, body, err = s.send(&subscription, delivery)
+	}
+	now := time.Now()
+
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	delivery.DurationMs = now.Sub(start).Milliseconds()
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= webhookMaxAttempts || !subscription.Active:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to deliver webhook %d (attempt %d): %v\n", delivery.ID, delivery.Attempts, err)
+	}
+
+	if dbErr := s.db.Model(delivery).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"response_status": delivery.ResponseStatus,
+		"response_body":   delivery.ResponseBody,
+		"duration_ms":     delivery.DurationMs,
+		"last_error":      delivery.LastError,
+		"delivered_at":    delivery.DeliveredAt,
+	}).Error; dbErr != nil {
+		fmt.Printf("Warning: Failed to update webhook delivery %d: %v\n", delivery.ID, dbErr)
+	}
+	if err == nil {
+		s.db.Model(&subscription).UpdateColumn("last_sent_at", now)
+	}
+	return err == nil
+}
+
+// send 发送签名的推送请求，非2xx响应视为失败
+func (s *WebhookService) send(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set("X-GitLabEx-Event", delivery.Event)
+	req.Header.Set("X-GitLabEx-Event-ID", delivery.EventID)
+	req.Header.Set("X-GitLabEx-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-GitLabEx-Timestamp", timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+SignWebhookPayload(subscription.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// SignWebhookPayload 计算推送签名：HMAC-SHA256(secret, timestamp + "." + body) 的十六进制
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay 第 attempts 次投递失败后的重试等待时间
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		delay = webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// ===== 辅助函数 =====
+
+// newWebhookPayload 生成带新事件ID的推送正文
+func newWebhookPayload(event string, data interface{}) (*WebhookPayload, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate event id: %w", err)
+	}
+	return &WebhookPayload{
+		ID:        "evt_" + hex.EncodeToString(buf),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}, nil
+}
+
+// generateWebhookSecret 生成签名密钥
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// validateWebhookURL 检查接收地址为 http(s) 绝对地址，且域名解析到的地址均为公网地址
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid webhook url: %s", rawURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("failed to resolve webhook host: %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicWebhookIP(addr.IP) {
+			return fmt.Errorf("webhook url must not point to a private or local address: %s", rawURL)
+		}
+	}
+	return nil
+}
+
+// newWebhookTransport 创建投递使用的连接，建立连接时再次检查实际连接的地址，防止域名在校验后被重新解析到内网（DNS重绑定）
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookRequestTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicWebhookIP(ip) {
+				return fmt.Errorf("webhook delivery to private or local address is not allowed: %s", host)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		Proxy:                 nil, // 不使用环境代理，否则检查的是代理地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   webhookRequestTimeout,
+		ResponseHeaderTimeout: webhookRequestTimeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
+
+// webhookBlockedNets 不允许投递的保留地址段（回环、内网、链路本地等由 isPublicWebhookIP 单独判断）
+var webhookBlockedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级NAT
+	"192.0.0.0/24",  // IETF协议分配
+	"198.18.0.0/15", // 基准测试
+	"240.0.0.0/4",   // 保留
+	"64:ff9b::/96",  // NAT64，可映射到内网IPv4地址
+)
+
+// isPublicWebhookIP 判断地址是否为可投递的公网地址
+func isPublicWebhookIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, block := range webhookBlockedNets {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDRs 解析固定的地址段列表
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, block)
+	}
+	return nets
+}
+
+// normalizeWebhookEvents 检查订阅的事件并去重
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("at least one event is required")
+	}
+	known := map[string]bool{models.WebhookEventAll: true}
+	for _, event := range WebhookEvents {
+		known[event] = true
+	}
+
+	seen := make(map[string]bool, len(events))
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		if !known[event] {
+			return nil, fmt.Errorf("unknown webhook event: %s", event)
+		}
+		if seen[event] {
+			continue
+		}
+		seen[event] = true
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+// webhookSubscribed 订阅的事件中是否包含 event
+func webhookSubscribed(events []string, event string) bool {
+	for _, e := range events {
+		if e == event || e == models.WebhookEventAll {
+			return true
+		}
+	}
+	return false
+}
+
+// checkProjectFilter 限定课题时创建者需要能访问该课题
+func (s *WebhookService) checkProjectFilter(userID uint, projectID *uint) error {
+	if projectID == nil || s.permissionService.IsAdmin(userID) {
+		return nil
+	}
+	if !s.permissionService.CanAccessProject(userID, *projectID, "read") {
+		return fmt.Errorf("permission denied to access project %d", *projectID)
+	}
+	return nil
+}
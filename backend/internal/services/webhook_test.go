@@ -0,0 +1,116 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsPublicWebhookIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "8.8.8.8", want: true},
+		{ip: "2606:4700:4700::1111", want: true},
+		{ip: "127.0.0.1", want: false},           // 回环
+		{ip: "127.255.255.254", want: false},     // 回环
+		{ip: "::1", want: false},                 // IPv6回环
+		{ip: "169.254.169.254", want: false},     // 链路本地（云服务元数据）
+		{ip: "fe80::1", want: false},             // IPv6链路本地
+		{ip: "10.0.0.1", want: false},            // RFC 1918
+		{ip: "172.16.0.1", want: false},          // RFC 1918
+		{ip: "172.31.255.255", want: false},      // RFC 1918
+		{ip: "192.168.1.1", want: false},         // RFC 1918
+		{ip: "fd00::1", want: false},             // IPv6唯一本地地址
+		{ip: "0.0.0.0", want: false},             // 未指定
+		{ip: "::", want: false},                  // IPv6未指定
+		{ip: "100.64.0.1", want: false},          // 运营商级NAT
+		{ip: "198.18.0.1", want: false},          // 基准测试
+		{ip: "224.0.0.1", want: false},           // 组播
+		{ip: "255.255.255.255", want: false},     // 广播
+		{ip: "::ffff:127.0.0.1", want: false},    // IPv4映射的回环地址
+		{ip: "::ffff:10.0.0.1", want: false},     // IPv4映射的内网地址
+		{ip: "64:ff9b::a00:1", want: false},      // NAT64映射的内网地址
+		{ip: "172.32.0.1", want: true},           // 172.16.0.0/12 之外
+		{ip: "::ffff:93.184.216.34", want: true}, // IPv4映射的公网地址
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := net.ParseIP(tt.ip)
+			if ip == nil {
+				t.Fatalf("invalid test ip %q", tt.ip)
+			}
+			if got := isPublicWebhookIP(ip); got != tt.want {
+				t.Errorf("isPublicWebhookIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{name: "public ipv4", url: "https://93.184.216.34/hook"},
+		{name: "public ipv6", url: "https://[2606:4700:4700::1111]:8443/hook"},
+		{name: "loopback", url: "http://127.0.0.1/hook", wantErr: "private or local address"},
+		{name: "loopback with port", url: "http://127.0.0.1:8080/hook", wantErr: "private or local address"},
+		{name: "ipv6 loopback", url: "http://[::1]/hook", wantErr: "private or local address"},
+		{name: "metadata service", url: "http://169.254.169.254/latest/meta-data", wantErr: "private or local address"},
+		{name: "rfc 1918 class a", url: "http://10.0.0.1/hook", wantErr: "private or local address"},
+		{name: "rfc 1918 class b", url: "https://172.16.5.4/hook", wantErr: "private or local address"},
+		{name: "rfc 1918 class c", url: "http://192.168.1.1/hook", wantErr: "private or local address"},
+		{name: "unspecified", url: "http://0.0.0.0/hook", wantErr: "private or local address"},
+		{name: "unsupported scheme", url: "ftp://93.184.216.34/hook", wantErr: "invalid webhook url"},
+		{name: "file scheme", url: "file:///etc/passwd", wantErr: "invalid webhook url"},
+		{name: "relative", url: "/hook", wantErr: "invalid webhook url"},
+		{name: "missing host", url: "http:///hook", wantErr: "invalid webhook url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWebhookURL(tt.url)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateWebhookURL(%q) error = %v", tt.url, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateWebhookURL(%q) error = %v, want %q", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookTransportRejectsLocalAddress(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+
+	// 模拟校验通过后域名被重新解析到本机：连接时仍然拒绝
+	client := &http.Client{Transport: newWebhookTransport()}
+	for _, target := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		resp, err := client.Post(target, "application/json", strings.NewReader("{}"))
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("POST %s succeeded, want dial error", target)
+		}
+		if !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("POST %s error = %v, want private address error", target, err)
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 0 {
+		t.Errorf("server received %d requests, want 0", n)
+	}
+}
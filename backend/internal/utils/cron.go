@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 解析后的cron表达式
+// 支持标准5段格式（分 时 日 月 周），以及 @hourly、@daily、@weekly、@monthly 和 @every <时长>
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	every                         time.Duration
+}
+
+// cronMaxSearch 查找下次运行时间时最多向后搜索的时长
+const cronMaxSearch = 5 * 366 * 24 * time.Hour
+
+// cronDescriptors 预定义的cron表达式
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseCronSchedule 解析cron表达式
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration in %q: %w", spec, err)
+		}
+		if every < time.Minute {
+			return nil, fmt.Errorf("interval must be at least 1m: %q", spec)
+		}
+		return &CronSchedule{every: every}, nil
+	}
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields: %q", spec)
+	}
+
+	schedule := &CronSchedule{
+		domAny: fields[2] == "*" || fields[2] == "?",
+		dowAny: fields[4] == "*" || fields[4] == "?",
+	}
+	bounds := []struct {
+		target   *uint64
+		min, max int
+	}{
+		{&schedule.minute, 0, 59},
+		{&schedule.hour, 0, 23},
+		{&schedule.dom, 1, 31},
+		{&schedule.month, 1, 12},
+		{&schedule.dow, 0, 7},
+	}
+	for i, field := range fields {
+		bits, err := parseCronField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %w", field, err)
+		}
+		*bounds[i].target = bits
+	}
+	// 周日可以写作0或7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseCronField 解析单个字段，支持 *、?、列表（1,2）、范围（1-5）和步长（*/15、1-30/5）
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[idx+1:])
+			}
+			part = part[:idx]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[1])
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			start, end = value, value
+			if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value out of range [%d-%d]", min, max)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Next 返回晚于 t 的下次运行时间（按 t 所在时区计算），找不到时返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	limit := t.Add(cronMaxSearch)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日和周都有限制时满足其一即可（与标准cron一致）
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
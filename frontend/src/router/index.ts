@@ -17,6 +17,12 @@ const router = createRouter({
       component: () => import('../views/LoginSuccessView.vue'),
       meta: { title: '登录成功 - GitLabEx', requiresAuth: false }
     },
+    {
+      path: '/oauth/authorize',
+      name: 'oauth-authorize',
+      component: () => import('../views/OAuthAuthorizeView.vue'),
+      meta: { title: '应用授权 - GitLabEx', requiresAuth: true }
+    },
     {
       path: '/',
       name: 'home',
@@ -23,19 +23,56 @@ api.interceptors.request.use(
   }
 )
 
+// 使用刷新令牌换取新的访问令牌，并发请求共用同一次刷新
+let refreshing: Promise<string | null> | null = null
+
+const refreshAccessToken = (): Promise<string | null> => {
+  const refreshToken = localStorage.getItem('refreshToken')
+  if (!refreshToken) {
+    return Promise.resolve(null)
+  }
+  if (!refreshing) {
+    refreshing = axios
+      .post(`${api.defaults.baseURL}/api/auth/refresh`, { refresh_token: refreshToken })
+      .then((response) => {
+        const { access_token, refresh_token } = response.data.data
+        localStorage.setItem('authToken', access_token)
+        localStorage.setItem('refreshToken', refresh_token)
+        return access_token as string
+      })
+      .catch(() => null)
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
 // 响应拦截器
 api.interceptors.response.use(
   (response) => {
     return response.data
   },
-  (error) => {
+  async (error) => {
     if (error.response) {
       switch (error.response.status) {
-        case 401:
+        case 401: {
+          // 访问令牌过期时先尝试刷新，刷新成功后重试原请求
+          const original = error.config
+          if (original && !original._retried) {
+            original._retried = true
+            const accessToken = await refreshAccessToken()
+            if (accessToken) {
+              original.headers.Authorization = `Bearer ${accessToken}`
+              return api(original)
+            }
+          }
           // 未授权，清除token并跳转到登录页
           localStorage.removeItem('authToken')
+          localStorage.removeItem('refreshToken')
           window.location.href = '/login'
           break
+        }
         case 403:
           // 权限不足
           console.error('权限不足')
@@ -131,6 +168,37 @@ export interface DocumentConfig {
   }
 }
 
+// OAuth2授权请求参数，原样来自 /api/oauth/authorize 的查询参数
+export interface OAuthAuthorizeParams {
+  response_type?: string
+  client_id?: string
+  redirect_uri?: string
+  scope?: string
+  state?: string
+  code_challenge?: string
+  code_challenge_method?: string
+}
+
+export interface OAuthScope {
+  name: string
+  resource: string
+  permission: string
+  description: string
+}
+
+// OAuth2授权确认页面信息
+export interface OAuthConsentInfo {
+  client: {
+    client_id: string
+    name: string
+    description: string
+    homepage_url: string
+  }
+  scopes: OAuthScope[]
+  redirect_uri: string
+  consented: boolean
+}
+
 // API服务类
 export class ApiService {
   
@@ -180,8 +248,25 @@ export class ApiService {
     return response.data
   }
 
+  // 使用登录回调中的一次性登录码换取访问令牌和刷新令牌
+  static async exchangeLoginCode(code: string): Promise<{ access_token: string, refresh_token: string, expires_in: number }> {
+    const response = await api.post('/api/auth/login/exchange', { code })
+    return response.data
+  }
+
+  // OAuth2授权确认
+  static async getOAuthConsent(params: OAuthAuthorizeParams): Promise<OAuthConsentInfo> {
+    const response = await api.get('/api/oauth/consent', { params })
+    return response.data
+  }
+
+  static async submitOAuthConsent(params: OAuthAuthorizeParams, approve: boolean): Promise<{ redirect_to: string }> {
+    const response = await api.post('/api/oauth/consent', { ...params, approve })
+    return response.data
+  }
+
   static async logout(): Promise<void> {
-    await api.post('/api/auth/logout')
+    await api.post('/api/auth/logout', { refresh_token: localStorage.getItem('refreshToken') })
   }
 
   // 文档相关API
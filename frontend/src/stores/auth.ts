@@ -42,6 +42,7 @@ export const useAuthStore = defineStore('auth', () => {
       isAuthenticated.value = true
     } else {
       localStorage.removeItem('authToken')
+      localStorage.removeItem('refreshToken')
       isAuthenticated.value = false
     }
   }